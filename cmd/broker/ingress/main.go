@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	// Uncomment the following line to load the gcp plugin (only required to authenticate against GKE clusters).
 	// _ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -63,6 +64,7 @@ const (
 	defaultMaxIdleConnectionsPerHost = 1000
 	defaultMetricsPort               = 9092
 	component                        = "mt_broker_ingress"
+	retryQueueFlushTimeout           = 30 * time.Second
 )
 
 type envConfig struct {
@@ -73,6 +75,12 @@ type envConfig struct {
 	MaxTTL        int    `envconfig:"MAX_TTL" default:"255"`
 	HTTPPort      int    `envconfig:"INGRESS_PORT" default:"8080"`
 	HTTPSPort     int    `envconfig:"INGRESS_PORT_HTTPS" default:"8443"`
+
+	// MetricsEventTypeTag is the granularity of the eventType metrics tag, one of "full",
+	// "none", "hashed" or "allowlist".
+	MetricsEventTypeTag     string   `envconfig:"METRICS_EVENT_TYPE_TAG" default:"full"`
+	MetricsEventTypeBuckets int      `envconfig:"METRICS_EVENT_TYPE_BUCKETS" default:"64"`
+	MetricsEventTypes       []string `envconfig:"METRICS_EVENT_TYPES"`
 }
 
 func main() {
@@ -148,7 +156,19 @@ func main() {
 	})
 	featureStore.WatchConfigs(configMapWatcher)
 
-	reporter := ingress.NewStatsReporter(env.ContainerName, kmeta.ChildName(env.PodName, uuid.New().String()))
+	var reporterOpts []ingress.StatsReporterOption
+	switch env.MetricsEventTypeTag {
+	case "full":
+	case "none":
+		reporterOpts = append(reporterOpts, ingress.WithoutEventTypeTag())
+	case "hashed":
+		reporterOpts = append(reporterOpts, ingress.WithHashedEventTypeTag(env.MetricsEventTypeBuckets))
+	case "allowlist":
+		reporterOpts = append(reporterOpts, ingress.WithEventTypeTagAllowList(env.MetricsEventTypes...))
+	default:
+		logger.Fatal("Invalid eventType metrics tag granularity", zap.String("granularity", env.MetricsEventTypeTag))
+	}
+	reporter := ingress.NewStatsReporter(env.ContainerName, kmeta.ChildName(env.PodName, uuid.New().String()), reporterOpts...)
 
 	handler, err = ingress.NewHandler(logger, reporter, broker.TTLDefaulter(logger, int32(env.MaxTTL)), brokerInformer)
 	if err != nil {
@@ -188,6 +208,10 @@ func main() {
 	if err != nil {
 		logger.Fatal("serverManager.StartServers() returned an error", zap.Error(err))
 	}
+	// The servers stopped receiving events, retry the events still queued a last time.
+	flushCtx, cancel := context.WithTimeout(context.Background(), retryQueueFlushTimeout)
+	handler.FlushRetryQueue(flushCtx)
+	cancel()
 	tracer.Shutdown(context.Background())
 	logger.Info("Exiting...")
 }
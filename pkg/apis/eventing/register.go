@@ -80,6 +80,33 @@ const (
 	// annotation key used to specify the name of the channel for
 	// the triggers to subscribe to.
 	BrokerChannelNameStatusAnnotationKey = "knative.dev/channelName"
+
+	// BrokerRoutingOverrideTargetsAnnotationKey is the broker annotation key
+	// used to specify the comma separated list of URLs events are allowed to
+	// be routed to instead of the broker channel, when the ingress allows
+	// routing overrides.
+	BrokerRoutingOverrideTargetsAnnotationKey = GroupName + "/routingOverrideTargets"
+
+	// BrokerDispatchTimeoutAnnotationKey is the broker annotation key used
+	// to specify the timeout of each request dispatching an event to the
+	// broker channel, as a duration (e.g. 5s).
+	BrokerDispatchTimeoutAnnotationKey = GroupName + "/dispatchTimeout"
+
+	// BrokerDispatchRetryAnnotationKey is the broker annotation key used to
+	// specify the number of retries of the at-least-once deliveries of the
+	// events to the broker channel.
+	BrokerDispatchRetryAnnotationKey = GroupName + "/dispatchRetry"
+
+	// BrokerDispatchContentModeAnnotationKey is the broker annotation key
+	// used to specify the content mode, binary or structured, the events are
+	// dispatched to the broker channel with.
+	BrokerDispatchContentModeAnnotationKey = GroupName + "/dispatchContentMode"
+
+	// BrokerChannelCACertsSecretAnnotationKey is the broker annotation key
+	// used to specify the name of a secret, in the broker namespace, holding
+	// the channel Certification Authority (CA) certificates in its ca.crt
+	// key, instead of the broker status.
+	BrokerChannelCACertsSecretAnnotationKey = GroupName + "/channelCACertsSecret"
 )
 
 var (
@@ -0,0 +1,93 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"knative.dev/eventing/pkg/kncloudevents"
+)
+
+const (
+	// Access log entries are sampled per second: the first accessLogSampleFirst entries with
+	// the same message are logged, then every accessLogSampleThereafter-th entry.
+	accessLogSampleTick       = time.Second
+	accessLogSampleFirst      = 100
+	accessLogSampleThereafter = 100
+)
+
+// accessLogEntry holds the information logged for a single request when access logging is enabled.
+type accessLogEntry struct {
+	method          string
+	uri             string
+	sourceIP        string
+	brokerNamespace string
+	brokerName      string
+	eventType       string
+	eventID         string
+	statusCode      int
+	dispatchTime    time.Duration
+}
+
+func newAccessLogEntry(request *http.Request) *accessLogEntry {
+	sourceIP, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		sourceIP = request.RemoteAddr
+	}
+	return &accessLogEntry{
+		method:       request.Method,
+		uri:          request.RequestURI,
+		sourceIP:     sourceIP,
+		dispatchTime: kncloudevents.NoDuration,
+	}
+}
+
+// statusRecorder records the status code written to the wrapped http.ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// logAccess emits the access log entry as a single sampled structured entry.
+func (h *Handler) logAccess(entry *accessLogEntry) {
+	h.accessLoggerOnce.Do(func() {
+		h.accessLogger = h.Logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(core, accessLogSampleTick, accessLogSampleFirst, accessLogSampleThereafter)
+		}))
+	})
+	h.accessLogger.Info("access",
+		zap.String("method", entry.method),
+		zap.String("uri", entry.uri),
+		zap.String("sourceIP", entry.sourceIP),
+		zap.String("namespace", entry.brokerNamespace),
+		zap.String("broker", entry.brokerName),
+		zap.String("event.type", entry.eventType),
+		zap.String("event.id", entry.eventID),
+		zap.Int("statusCode", entry.statusCode),
+		zap.Duration("dispatchTime", entry.dispatchTime),
+	)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// parseBrokerRef extracts the broker namespace and name from a request URI of the form
+// <pathPrefix>/<namespace>/<name>, optionally followed by a trailing slash and a query string.
+// The path prefix is optional and must match whole path segments.
+func parseBrokerRef(requestURI, pathPrefix string) (namespace, name string, err error) {
+	u, err := url.ParseRequestURI(requestURI)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid request URI: %w", err)
+	}
+
+	// Split the escaped path so that encoded slashes are not taken as separators.
+	path := u.EscapedPath()
+	if !strings.HasPrefix(path, "/") {
+		return "", "", fmt.Errorf("request path %q is not absolute", path)
+	}
+	if prefix := normalizePathPrefix(pathPrefix); prefix != "" {
+		if !strings.HasPrefix(path, prefix+"/") {
+			return "", "", fmt.Errorf("request path %q doesn't start with the path prefix %q", path, prefix)
+		}
+		path = strings.TrimPrefix(path, prefix)
+	}
+	segments := strings.Split(strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/"), "/")
+	if len(segments) != 2 {
+		return "", "", fmt.Errorf("request path %q doesn't match /<namespace>/<name>", path)
+	}
+
+	if namespace, err = url.PathUnescape(segments[0]); err != nil {
+		return "", "", fmt.Errorf("invalid namespace: %w", err)
+	}
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return "", "", fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, ", "))
+	}
+	if name, err = url.PathUnescape(segments[1]); err != nil {
+		return "", "", fmt.Errorf("invalid broker name: %w", err)
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", "", fmt.Errorf("invalid broker name %q: %s", name, strings.Join(errs, ", "))
+	}
+	return namespace, name, nil
+}
+
+// normalizePathPrefix returns the path prefix with a leading slash and without trailing
+// slashes, or an empty string for the root path.
+func normalizePathPrefix(prefix string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestParseBrokerRef(t *testing.T) {
+	tt := []struct {
+		requestURI    string
+		pathPrefix    string
+		wantNamespace string
+		wantName      string
+		wantErr       bool
+	}{
+		{requestURI: "/ns/name", wantNamespace: "ns", wantName: "name"},
+		{requestURI: "/ns/name/", wantNamespace: "ns", wantName: "name"},
+		{requestURI: "/ns/name?foo=bar", wantNamespace: "ns", wantName: "name"},
+		{requestURI: "/ns/name/?foo=/a/b", wantNamespace: "ns", wantName: "name"},
+		{requestURI: "/ns/my.broker", wantNamespace: "ns", wantName: "my.broker"},
+		{requestURI: "/n%73/name", wantNamespace: "ns", wantName: "name"},
+		{requestURI: "http://broker-ingress.knative-eventing.svc/ns/name", wantNamespace: "ns", wantName: "name"},
+		{requestURI: "", wantErr: true},
+		{requestURI: "/", wantErr: true},
+		{requestURI: "ns/name", wantErr: true},
+		{requestURI: "/ns", wantErr: true},
+		{requestURI: "/ns/", wantErr: true},
+		{requestURI: "//ns/name", wantErr: true},
+		{requestURI: "/ns//name", wantErr: true},
+		{requestURI: "/ns/name//", wantErr: true},
+		{requestURI: "/knative/ns/name", wantErr: true},
+		{requestURI: "/ns%2Fname/broker", wantErr: true},
+		{requestURI: "/ns/na%2Fme", wantErr: true},
+		{requestURI: "/ns/na%zzme", wantErr: true},
+		{requestURI: "/NS/name", wantErr: true},
+		{requestURI: "/ns/Name", wantErr: true},
+		{requestURI: "/my.ns/name", wantErr: true},
+		{requestURI: "/ns/name%20", wantErr: true},
+		{requestURI: "/../name", wantErr: true},
+		{requestURI: "/ns/name#fragment", wantErr: true},
+		{requestURI: "/eventing/ns/name", pathPrefix: "/eventing", wantNamespace: "ns", wantName: "name"},
+		{requestURI: "/eventing/ns/name/", pathPrefix: "/eventing/", wantNamespace: "ns", wantName: "name"},
+		{requestURI: "/eventing/ns/name", pathPrefix: "eventing", wantNamespace: "ns", wantName: "name"},
+		{requestURI: "/a/b/ns/name?foo=bar", pathPrefix: "/a/b", wantNamespace: "ns", wantName: "name"},
+		{requestURI: "/ns/name", pathPrefix: "/", wantNamespace: "ns", wantName: "name"},
+		{requestURI: "/ns/name", pathPrefix: "/eventing", wantErr: true},
+		{requestURI: "/eventingx/ns/name", pathPrefix: "/eventing", wantErr: true},
+		{requestURI: "/eventing", pathPrefix: "/eventing", wantErr: true},
+		{requestURI: "/eventing/ns", pathPrefix: "/eventing", wantErr: true},
+		{requestURI: "/eventing/x/ns/name", pathPrefix: "/eventing", wantErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.pathPrefix+" "+tc.requestURI, func(t *testing.T) {
+			namespace, name, err := parseBrokerRef(tc.requestURI, tc.pathPrefix)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("want error %v, got %v", tc.wantErr, err)
+			}
+			if namespace != tc.wantNamespace || name != tc.wantName {
+				t.Errorf("want %s/%s, got %s/%s", tc.wantNamespace, tc.wantName, namespace, name)
+			}
+		})
+	}
+}
+
+func FuzzParseBrokerRef(f *testing.F) {
+	for _, seed := range []string{"/ns/name", "/ns/name/", "/ns/name?a=b", "//ns/name", "/ns%2Fname", "/a/b/c", ""} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, requestURI string) {
+		namespace, name, err := parseBrokerRef(requestURI, "")
+		if err != nil {
+			if namespace != "" || name != "" {
+				t.Errorf("expected no broker ref on error, got %q/%q", namespace, name)
+			}
+			return
+		}
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			t.Errorf("parsed invalid namespace %q from %q", namespace, requestURI)
+		}
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			t.Errorf("parsed invalid name %q from %q", name, requestURI)
+		}
+		gotNamespace, gotName, err := parseBrokerRef("/"+namespace+"/"+name, "")
+		if err != nil || gotNamespace != namespace || gotName != name {
+			t.Errorf("parsing %q is not stable: got %q/%q, %v", requestURI, gotNamespace, gotName, err)
+		}
+	})
+}
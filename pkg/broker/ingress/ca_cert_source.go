@@ -0,0 +1,153 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/pointer"
+
+	"knative.dev/eventing/pkg/apis/eventing"
+	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
+	"knative.dev/eventing/pkg/eventingtls"
+)
+
+// CACertSource resolves the Certification Authority (CA) certificates trusted to dispatch
+// the events to the channel of a broker.
+type CACertSource interface {
+	// CACerts returns the CA certificates of the broker channel in PEM format, nil when the
+	// channel isn't using TLS or the system CAs are trusted.
+	CACerts(b *eventingv1.Broker) *string
+}
+
+// InlineCACertSource reads the CA certificates inline in the broker status annotations.
+type InlineCACertSource struct{}
+
+func (InlineCACertSource) CACerts(b *eventingv1.Broker) *string {
+	certs, present := b.Status.Annotations[eventing.BrokerChannelCACertsStatusAnnotationKey]
+	if !present || certs == "" {
+		return nil
+	}
+	return pointer.String(certs)
+}
+
+func (h *Handler) caCertSource() CACertSource {
+	if h.CACertSource != nil {
+		return h.CACertSource
+	}
+	return InlineCACertSource{}
+}
+
+// NewSecretCACertSource returns a CACertSource reading the CA certificates from the secret
+// referenced by the broker BrokerChannelCACertsSecretAnnotationKey annotation, so that the
+// rotated certificates are trusted without a broker status update. The brokers without the
+// annotation, or referencing a secret without CA certificates, fall back to the inline
+// certificates. The certificates are cached until their secret changes.
+func NewSecretCACertSource(logger *zap.Logger, secretInformer coreinformersv1.SecretInformer) CACertSource {
+	s := &secretCACertSource{
+		logger: logger,
+		lister: secretInformer.Lister(),
+		certs:  make(map[types.NamespacedName]*string),
+	}
+	secretInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: s.invalidate,
+		UpdateFunc: func(_, obj interface{}) {
+			s.invalidate(obj)
+		},
+		DeleteFunc: s.invalidate,
+	})
+	return s
+}
+
+type secretCACertSource struct {
+	logger *zap.Logger
+	lister corev1listers.SecretLister
+
+	mu sync.RWMutex
+	// certs are the CA certificates read from each secret, nil when the secret holds none.
+	certs map[types.NamespacedName]*string
+}
+
+func (s *secretCACertSource) CACerts(b *eventingv1.Broker) *string {
+	name, present := b.Annotations[eventing.BrokerChannelCACertsSecretAnnotationKey]
+	if !present || name == "" {
+		return InlineCACertSource{}.CACerts(b)
+	}
+	key := types.NamespacedName{Namespace: b.Namespace, Name: name}
+
+	s.mu.RLock()
+	certs, cached := s.certs[key]
+	s.mu.RUnlock()
+	if !cached {
+		certs = s.read(key)
+	}
+	if certs == nil {
+		return InlineCACertSource{}.CACerts(b)
+	}
+	return certs
+}
+
+// read reads the CA certificates of the secret and caches them.
+func (s *secretCACertSource) read(key types.NamespacedName) *string {
+	secret, err := s.lister.Secrets(key.Namespace).Get(key.Name)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			// Not cached, the next event retries.
+			s.logger.Warn("failed to get the channel CA certificates secret",
+				zap.String("secret", key.String()), zap.Error(err))
+			return nil
+		}
+		s.logger.Warn("channel CA certificates secret not found, using the inline CA certificates",
+			zap.String("secret", key.String()))
+	}
+
+	var certs *string
+	if secret != nil {
+		if data := secret.Data[eventingtls.SecretCACert]; len(data) > 0 {
+			certs = pointer.String(string(data))
+		} else {
+			s.logger.Warn("no CA certificates in the channel CA certificates secret, using the inline CA certificates",
+				zap.String("secret", key.String()), zap.String("key", eventingtls.SecretCACert))
+		}
+	}
+
+	s.mu.Lock()
+	s.certs[key] = certs
+	s.mu.Unlock()
+	return certs
+}
+
+// invalidate removes the cached CA certificates of the changed secret.
+func (s *secretCACertSource) invalidate(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	delete(s.certs, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name})
+	s.mu.Unlock()
+}
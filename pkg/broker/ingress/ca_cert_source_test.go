@@ -0,0 +1,172 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/pointer"
+
+	"knative.dev/eventing/pkg/apis/eventing"
+	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
+	"knative.dev/eventing/pkg/eventingtls"
+)
+
+const (
+	inlineCACerts = "inline-ca-certs"
+	secretCACerts = "secret-ca-certs"
+)
+
+func TestSecretCACertSource(t *testing.T) {
+	tt := []struct {
+		name    string
+		secret  string
+		secrets []runtime.Object
+		want    *string
+	}{
+		{
+			name: "inline",
+			secrets: []runtime.Object{
+				makeCACertsSecret("ns", "certs", secretCACerts),
+			},
+			want: pointer.String(inlineCACerts),
+		},
+		{
+			name:   "secret",
+			secret: "certs",
+			secrets: []runtime.Object{
+				makeCACertsSecret("ns", "certs", secretCACerts),
+			},
+			want: pointer.String(secretCACerts),
+		},
+		{
+			name:   "secret in another namespace",
+			secret: "certs",
+			secrets: []runtime.Object{
+				makeCACertsSecret("other", "certs", secretCACerts),
+			},
+			want: pointer.String(inlineCACerts),
+		},
+		{
+			name:   "secret without CA certificates",
+			secret: "certs",
+			secrets: []runtime.Object{
+				makeCACertsSecret("ns", "certs", ""),
+			},
+			want: pointer.String(inlineCACerts),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(tc.secrets...), 0)
+			source := NewSecretCACertSource(zap.NewNop(), factory.Core().V1().Secrets())
+			factory.Start(ctx.Done())
+			factory.WaitForCacheSync(ctx.Done())
+
+			b := makeCACertsBroker(tc.secret)
+			assertCACerts(t, tc.want, source.CACerts(b))
+		})
+	}
+}
+
+func TestSecretCACertSourceRotation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fake.NewSimpleClientset(makeCACertsSecret("ns", "certs", "ca-1"))
+	factory := informers.NewSharedInformerFactory(client, 0)
+	source := NewSecretCACertSource(zap.NewNop(), factory.Core().V1().Secrets())
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+
+	b := makeCACertsBroker("certs")
+	assertCACerts(t, pointer.String("ca-1"), source.CACerts(b))
+
+	if _, err := client.CoreV1().Secrets("ns").Update(ctx, makeCACertsSecret("ns", "certs", "ca-2"), metav1.UpdateOptions{}); err != nil {
+		t.Fatal("failed to update the secret:", err)
+	}
+	waitForCACerts(t, source, b, pointer.String("ca-2"))
+
+	if err := client.CoreV1().Secrets("ns").Delete(ctx, "certs", metav1.DeleteOptions{}); err != nil {
+		t.Fatal("failed to delete the secret:", err)
+	}
+	waitForCACerts(t, source, b, pointer.String(inlineCACerts))
+}
+
+func TestInlineCACertSource(t *testing.T) {
+	assertCACerts(t, pointer.String(inlineCACerts), InlineCACertSource{}.CACerts(makeCACertsBroker("")))
+
+	b := makeBroker("name", "ns")
+	assertCACerts(t, nil, InlineCACertSource{}.CACerts(b))
+}
+
+func makeCACertsBroker(secret string) *eventingv1.Broker {
+	b := makeBroker("name", "ns")
+	b.Status.Annotations = map[string]string{
+		eventing.BrokerChannelCACertsStatusAnnotationKey: inlineCACerts,
+	}
+	if secret != "" {
+		b.Annotations = map[string]string{
+			eventing.BrokerChannelCACertsSecretAnnotationKey: secret,
+		}
+	}
+	return b
+}
+
+func makeCACertsSecret(namespace, name, caCerts string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Data:       map[string][]byte{},
+	}
+	if caCerts != "" {
+		secret.Data[eventingtls.SecretCACert] = []byte(caCerts)
+	}
+	return secret
+}
+
+func waitForCACerts(t *testing.T, source CACertSource, b *eventingv1.Broker, want *string) {
+	t.Helper()
+	var got *string
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		got = source.CACerts(b)
+		return cmp.Equal(want, got), nil
+	})
+	if err != nil {
+		assertCACerts(t, want, got)
+	}
+}
+
+func assertCACerts(t *testing.T, want, got *string) {
+	t.Helper()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("unexpected CA certificates (-want, +got):", diff)
+	}
+}
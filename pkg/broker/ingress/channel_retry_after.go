@@ -0,0 +1,106 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"knative.dev/eventing/pkg/kncloudevents"
+)
+
+// retryAfterHeader is the header a channel throttling the ingress with a 429 Too Many
+// Requests response sets to the delay it asks to wait before retrying.
+const retryAfterHeader = "Retry-After"
+
+// channelRetryAfter returns the delay a channel responding with 429 Too Many Requests asks
+// to wait before retrying, capped by MaxChannelRetryAfter, when HonorChannelRetryAfter is
+// set.
+func (h *Handler) channelRetryAfter(dispatchInfo *kncloudevents.DispatchInfo) (time.Duration, bool) {
+	if dispatchInfo == nil {
+		return 0, false
+	}
+	return h.responseRetryAfter(dispatchInfo.ResponseCode, dispatchInfo.ResponseHeader)
+}
+
+// responseRetryAfter is channelRetryAfter for the status code and headers of a channel
+// response.
+func (h *Handler) responseRetryAfter(statusCode int, header http.Header) (time.Duration, bool) {
+	if !h.HonorChannelRetryAfter || statusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	delay, ok := parseRetryAfter(header.Get(retryAfterHeader), h.retryClock().Now())
+	if !ok {
+		return 0, false
+	}
+	if h.MaxChannelRetryAfter > 0 && delay > h.MaxChannelRetryAfter {
+		delay = h.MaxChannelRetryAfter
+	}
+	return delay, true
+}
+
+// honorRetryAfter wraps the backoff of the synchronous retries so that they wait at least
+// the delay a channel responding with 429 Too Many Requests asks for.
+func (h *Handler) honorRetryAfter(backoff kncloudevents.Backoff) kncloudevents.Backoff {
+	return func(attemptNum int, resp *http.Response) time.Duration {
+		var delay time.Duration
+		if backoff != nil {
+			delay = backoff(attemptNum, resp)
+		}
+		if resp == nil {
+			return delay
+		}
+		if retryAfter, ok := h.responseRetryAfter(resp.StatusCode, resp.Header); ok && retryAfter > delay {
+			return retryAfter
+		}
+		return delay
+	}
+}
+
+// parseRetryAfter parses a Retry-After header value, either delay-seconds or an HTTP-date
+// (https://www.rfc-editor.org/rfc/rfc9110#field.retry-after). A date in the past is a zero
+// delay.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		if seconds > int64(math.MaxInt64/time.Second) {
+			return time.Duration(math.MaxInt64), true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+// formatRetryAfter formats the delay as Retry-After delay-seconds, rounded up so that the
+// producers don't retry too early.
+func formatRetryAfter(delay time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(delay.Seconds())), 10)
+}
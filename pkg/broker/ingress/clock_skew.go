@@ -0,0 +1,55 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+)
+
+// isStale returns whether the event is older than MaxEventAge. The age is computed from the
+// time attribute set with the producer clock, so up to ClockSkewTolerance is added to
+// MaxEventAge to not drop the borderline events of producers whose clock lags the ingress
+// clock. Events with a time ahead of the ingress clock by more than ClockSkewTolerance are
+// logged as coming from a skewed producer clock.
+func (h *Handler) isStale(event *cloudevents.Event, now time.Time) bool {
+	if h.MaxEventAge <= 0 || event.Time().IsZero() {
+		return false
+	}
+
+	age := now.Sub(event.Time())
+	if h.ClockSkewTolerance > 0 && -age > h.ClockSkewTolerance {
+		h.Logger.Info("event time is ahead of the ingress clock, the producer clock might be skewed",
+			zap.String("event.id", event.ID()),
+			zap.Time("event.time", event.Time()),
+			zap.String("clockSkewTolerance", h.ClockSkewTolerance.String()))
+	}
+	if age <= h.MaxEventAge {
+		return false
+	}
+	if age <= h.MaxEventAge+h.ClockSkewTolerance {
+		h.Logger.Info("keeping event older than the max event age within the clock skew tolerance",
+			zap.String("event.id", event.ID()),
+			zap.Time("event.time", event.Time()),
+			zap.String("maxEventAge", h.MaxEventAge.String()),
+			zap.String("clockSkewTolerance", h.ClockSkewTolerance.String()))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+// normalizeContentType rewrites the request Content-Type when its media type is a known alias
+// and reports whether the resulting media type is allowed.
+// Requests without a Content-Type are always allowed.
+func (h *Handler) normalizeContentType(header http.Header) bool {
+	contentType := header.Get(cehttp.ContentType)
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return len(h.AllowedContentTypes) == 0
+	}
+
+	if canonical, ok := h.ContentTypeAliases[mediaType]; ok {
+		if normalized := mime.FormatMediaType(canonical, params); normalized != "" {
+			mediaType = canonical
+			header.Set(cehttp.ContentType, normalized)
+		}
+	}
+
+	if len(h.AllowedContentTypes) == 0 {
+		return true
+	}
+	for _, allowed := range h.AllowedContentTypes {
+		if strings.EqualFold(allowed, mediaType) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+
+	"knative.dev/eventing/pkg/kncloudevents"
+)
+
+// DeliveryGuarantee is the reliability of the dispatch of an event to the channel.
+type DeliveryGuarantee string
+
+const (
+	// DeliveryGuaranteeAtMostOnce sends the event to the channel once, without retrying,
+	// and responds with the channel response.
+	DeliveryGuaranteeAtMostOnce DeliveryGuarantee = "at-most-once"
+	// DeliveryGuaranteeAtLeastOnce retries sending the event to the channel according to
+	// Handler.RetryConfig, and only responds with a 2xx once the channel accepted it.
+	DeliveryGuaranteeAtLeastOnce DeliveryGuarantee = "at-least-once"
+
+	// DeliveryGuaranteeExtension is the event extension attribute producers set to request
+	// a delivery guarantee for the event (the Ce-Deliveryguarantee header in binary mode).
+	DeliveryGuaranteeExtension = "deliveryguarantee"
+)
+
+// defaultAtLeastOnceRetryConfig retries 3 times with an exponential backoff starting at
+// 200ms, following the retryable channel responses.
+var defaultAtLeastOnceRetryConfig = kncloudevents.RetryConfig{
+	RetryMax:   3,
+	CheckRetry: kncloudevents.SelectiveRetry,
+	Backoff: func(attemptNum int, _ *http.Response) time.Duration {
+		return 200 * time.Millisecond * time.Duration(math.Exp2(float64(attemptNum)))
+	},
+}
+
+// deliveryGuarantee returns the delivery guarantee requested by the event, or the handler
+// default when it doesn't request one or requests an unknown one.
+func (h *Handler) deliveryGuarantee(event *cloudevents.Event) DeliveryGuarantee {
+	if value, ok := event.Extensions()[DeliveryGuaranteeExtension]; ok {
+		switch guarantee := DeliveryGuarantee(fmt.Sprint(value)); guarantee {
+		case DeliveryGuaranteeAtMostOnce, DeliveryGuaranteeAtLeastOnce:
+			return guarantee
+		default:
+			h.Logger.Debug("ignoring unknown delivery guarantee",
+				zap.String("event.id", event.ID()),
+				zap.String("deliveryGuarantee", string(guarantee)))
+		}
+	}
+	if h.DeliveryGuarantee == "" {
+		return DeliveryGuaranteeAtMostOnce
+	}
+	return h.DeliveryGuarantee
+}
+
+// deliveryOptions returns the options sending the event with the given delivery guarantee
+// and the dispatch options of the broker.
+func (h *Handler) deliveryOptions(guarantee DeliveryGuarantee, dispatchOpts dispatchOptions) []kncloudevents.SendOption {
+	if guarantee != DeliveryGuaranteeAtLeastOnce {
+		if dispatchOpts.timeout == 0 {
+			return nil
+		}
+		retryConfig := kncloudevents.NoRetries()
+		retryConfig.RequestTimeout = dispatchOpts.timeout
+		return []kncloudevents.SendOption{kncloudevents.WithRetryConfig(&retryConfig)}
+	}
+	retryConfig := h.RetryConfig
+	if retryConfig == nil {
+		retryConfig = &defaultAtLeastOnceRetryConfig
+	}
+	if dispatchOpts.timeout > 0 || dispatchOpts.retries != nil || h.HonorChannelRetryAfter {
+		overridden := *retryConfig
+		if dispatchOpts.timeout > 0 {
+			overridden.RequestTimeout = dispatchOpts.timeout
+		}
+		if dispatchOpts.retries != nil {
+			overridden.RetryMax = *dispatchOpts.retries
+		}
+		if h.HonorChannelRetryAfter {
+			overridden.Backoff = h.honorRetryAfter(retryConfig.Backoff)
+		}
+		retryConfig = &overridden
+	}
+	return []kncloudevents.SendOption{kncloudevents.WithRetryConfig(retryConfig)}
+}
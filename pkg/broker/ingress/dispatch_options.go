@@ -0,0 +1,147 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+
+	"knative.dev/eventing/pkg/apis/eventing"
+	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
+)
+
+const (
+	// contentModeBinary dispatches the events to the channel in binary mode.
+	contentModeBinary = "binary"
+	// contentModeStructured dispatches the events to the channel in structured mode.
+	contentModeStructured = "structured"
+)
+
+// dispatchOptions are the per-broker dispatch options read from the broker annotations,
+// overriding the handler-wide defaults. The zero value keeps the defaults.
+type dispatchOptions struct {
+	// timeout is the timeout of each request to the channel, 0 for no timeout.
+	timeout time.Duration
+	// retries is the number of retries of the at-least-once deliveries, nil for the
+	// Handler.RetryConfig retries.
+	retries *int
+	// contentMode is contentModeBinary or contentModeStructured, empty for the default.
+	contentMode string
+}
+
+// dispatchOptionsEntry is the dispatch options parsed from the annotation values.
+type dispatchOptionsEntry struct {
+	timeout, retries, contentMode string
+	options                       dispatchOptions
+}
+
+// dispatchOptionsCache caches the parsed dispatch options of the most recently used
+// brokers, so that they're parsed and invalid values are reported once per change.
+type dispatchOptionsCache struct {
+	lock    sync.Mutex
+	entries *simplelru.LRU
+}
+
+func (h *Handler) getDispatchOptionsCache() *dispatchOptionsCache {
+	h.brokerOptionsOnce.Do(func() {
+		maxBrokers := h.MaxCachedBrokers
+		if maxBrokers <= 0 {
+			maxBrokers = defaultMaxCachedBrokers
+		}
+		// NewLRU only fails for a non-positive size.
+		entries, _ := simplelru.NewLRU(maxBrokers, nil)
+		h.brokerOptions = &dispatchOptionsCache{entries: entries}
+	})
+	return h.brokerOptions
+}
+
+// brokerDispatchOptions returns the dispatch options of the broker annotations. Invalid
+// values are ignored with a warning, keeping the default.
+func (h *Handler) brokerDispatchOptions(b *eventingv1.Broker) dispatchOptions {
+	annotations := b.GetAnnotations()
+	timeout := annotations[eventing.BrokerDispatchTimeoutAnnotationKey]
+	retries := annotations[eventing.BrokerDispatchRetryAnnotationKey]
+	contentMode := annotations[eventing.BrokerDispatchContentModeAnnotationKey]
+	if timeout == "" && retries == "" && contentMode == "" {
+		return dispatchOptions{}
+	}
+
+	cache := h.getDispatchOptionsCache()
+	key := types.NamespacedName{Namespace: b.Namespace, Name: b.Name}
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if v, ok := cache.entries.Get(key); ok {
+		if e := v.(*dispatchOptionsEntry); e.timeout == timeout && e.retries == retries && e.contentMode == contentMode {
+			return e.options
+		}
+	}
+
+	logger := h.Logger.With(zap.String("broker", key.String()))
+	var options dispatchOptions
+	if timeout != "" {
+		if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+			logger.Warn("ignoring invalid dispatch timeout annotation",
+				zap.String(eventing.BrokerDispatchTimeoutAnnotationKey, timeout))
+		} else {
+			options.timeout = d
+		}
+	}
+	if retries != "" {
+		if n, err := strconv.Atoi(retries); err != nil || n < 0 {
+			logger.Warn("ignoring invalid dispatch retry annotation",
+				zap.String(eventing.BrokerDispatchRetryAnnotationKey, retries))
+		} else {
+			options.retries = &n
+		}
+	}
+	switch contentMode {
+	case "", contentModeBinary, contentModeStructured:
+		options.contentMode = contentMode
+	default:
+		logger.Warn("ignoring invalid dispatch content mode annotation",
+			zap.String(eventing.BrokerDispatchContentModeAnnotationKey, contentMode))
+	}
+
+	cache.entries.Add(key, &dispatchOptionsEntry{
+		timeout:     timeout,
+		retries:     retries,
+		contentMode: contentMode,
+		options:     options,
+	})
+	return options
+}
+
+// withContentMode returns the context dispatching the events to the channel in the given
+// content mode, or in the handler default content mode when empty.
+func (h *Handler) withContentMode(ctx context.Context, contentMode string) context.Context {
+	switch {
+	case contentMode == contentModeBinary:
+		return binding.WithForceBinary(ctx)
+	case contentMode == contentModeStructured || h.StructuredWithCharset:
+		return binding.WithForceStructured(ctx)
+	}
+	return ctx
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"net/http"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+	"go.opencensus.io/plugin/ochttp"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/tracing/propagation/tracecontextb3"
+
+	"knative.dev/eventing/pkg/eventingtls"
+	"knative.dev/eventing/pkg/kncloudevents"
+)
+
+// maxDispatchTransports bounds the number of CA certificates for which a transport is kept,
+// evicting the least recently used one first and closing its idle connections.
+const maxDispatchTransports = 100
+
+// hasDispatchTLSConfig returns whether TLS options are configured for the connections to
+// the channels.
+func (h *Handler) hasDispatchTLSConfig() bool {
+	return h.TLSMinVersion != 0 || len(h.TLSCipherSuites) > 0
+}
+
+// dispatchTransport returns the transport sending events to the given channel address with
+// the configured TLS options. The transports are shared by the addresses with the same CA
+// certificates, so that they share their connection pool, and are configured with the
+// connection args of the kncloudevents clients.
+func (h *Handler) dispatchTransport(address duckv1.Addressable) (http.RoundTripper, error) {
+	var caCerts string
+	if address.CACerts != nil {
+		caCerts = *address.CACerts
+	}
+
+	h.tlsTransportsMu.Lock()
+	defer h.tlsTransportsMu.Unlock()
+
+	if h.tlsTransports == nil {
+		// NewLRU only fails for a non-positive size.
+		h.tlsTransports, _ = simplelru.NewLRU(maxDispatchTransports, func(_, value interface{}) {
+			value.(*ochttp.Transport).Base.(*http.Transport).CloseIdleConnections()
+		})
+	}
+	if transport, ok := h.tlsTransports.Get(caCerts); ok {
+		return transport.(*ochttp.Transport), nil
+	}
+
+	clientConfig := eventingtls.NewDefaultClientConfig()
+	clientConfig.CACerts = address.CACerts
+	clientConfig.MinVersion = h.TLSMinVersion
+	clientConfig.CipherSuites = h.TLSCipherSuites
+	tlsConfig, err := eventingtls.GetTLSClientConfig(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	base := kncloudevents.NewTransport()
+	base.TLSClientConfig = tlsConfig
+	transport := &ochttp.Transport{
+		Base:        base,
+		Propagation: tracecontextb3.TraceContextEgress,
+	}
+	h.tlsTransports.Add(caCerts, transport)
+	return transport, nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+)
+
+// defaultEmptyBodyMessage is the default Handler.EmptyBodyMessage.
+const defaultEmptyBodyMessage = "empty request body: expected a CloudEvent in structured or binary mode"
+
+// specVersionHeader is the header carrying the specversion of binary mode events.
+const specVersionHeader = "Ce-Specversion"
+
+// isEmptyBody returns whether the request has no body while not carrying a binary mode
+// event, which can legitimately have no data. When the length of the body is unknown, the
+// first byte is read ahead and restored.
+func (h *Handler) isEmptyBody(request *http.Request) bool {
+	if request.Header.Get(specVersionHeader) != "" {
+		return false
+	}
+	if request.Body == nil || request.Body == http.NoBody || request.ContentLength == 0 {
+		return true
+	}
+	if request.ContentLength > 0 {
+		return false
+	}
+
+	reader := bufio.NewReader(request.Body)
+	if _, err := reader.Peek(1); err == io.EOF {
+		return true
+	}
+	request.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, request.Body}
+	return false
+}
+
+func (h *Handler) emptyBodyMessage() string {
+	if h.EmptyBodyMessage != "" {
+		return h.EmptyBodyMessage
+	}
+	return defaultEmptyBodyMessage
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	discoveryv1listers "k8s.io/client-go/listers/discovery/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+
+	"knative.dev/eventing/pkg/apis/eventing"
+	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
+)
+
+// maxExternalNameHops bounds the ExternalName services followed from the channel address
+// (e.g. a channel service pointing to the channel dispatcher service).
+const maxExternalNameHops = 3
+
+// EndpointAwareResolver returns a ChannelAddressesResolver resolving the ready endpoints
+// (pods) of the Kubernetes service of the broker channel, so that events are dispatched
+// directly to them with client-side load balancing instead of going through kube-proxy.
+//
+// It resolves no address, falling back to the channel address, when the channel address
+// isn't the plain HTTP address of a cluster local service or when the service has no ready
+// endpoints. HTTPS addresses are never resolved since the endpoint addresses wouldn't match
+// the channel certificate.
+func EndpointAwareResolver(services corev1listers.ServiceLister, endpointSlices discoveryv1listers.EndpointSliceLister) ChannelAddressesResolver {
+	return func(b *eventingv1.Broker) ([]WeightedAddress, error) {
+		address, ok := b.Status.Annotations[eventing.BrokerChannelAddressStatusAnnotationKey]
+		if !ok {
+			return nil, nil
+		}
+		url, err := apis.ParseURL(address)
+		if err != nil {
+			return nil, err
+		}
+		if url.Scheme != "http" {
+			return nil, nil
+		}
+		return resolveEndpoints(services, endpointSlices, url)
+	}
+}
+
+func resolveEndpoints(services corev1listers.ServiceLister, endpointSlices discoveryv1listers.EndpointSliceLister, url *apis.URL) ([]WeightedAddress, error) {
+	port := int32(80)
+	if p := url.URL().Port(); p != "" {
+		n, err := strconv.ParseInt(p, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		port = int32(n)
+	}
+
+	host := url.URL().Hostname()
+	for hop := 0; hop <= maxExternalNameHops; hop++ {
+		name, namespace, ok := parseServiceHost(host)
+		if !ok {
+			return nil, nil
+		}
+		svc, err := services.Services(namespace).Get(name)
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if svc.Spec.Type == corev1.ServiceTypeExternalName {
+			host = svc.Spec.ExternalName
+			continue
+		}
+
+		for i := range svc.Spec.Ports {
+			if svc.Spec.Ports[i].Port == port {
+				return endpointAddresses(endpointSlices, svc, svc.Spec.Ports[i].Name, url)
+			}
+		}
+		return nil, nil
+	}
+	return nil, nil
+}
+
+// parseServiceHost returns the name and namespace of the service of a cluster local
+// hostname (<name>.<namespace>.svc[.<cluster domain>]).
+func parseServiceHost(host string) (string, string, bool) {
+	parts := strings.Split(strings.TrimSuffix(host, "."), ".")
+	if len(parts) < 3 || parts[2] != "svc" || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// endpointAddresses returns the addresses of the ready endpoints of the service port.
+func endpointAddresses(endpointSlices discoveryv1listers.EndpointSliceLister, svc *corev1.Service, portName string, url *apis.URL) ([]WeightedAddress, error) {
+	slices, err := endpointSlices.EndpointSlices(svc.Namespace).List(labels.SelectorFromSet(labels.Set{
+		discoveryv1.LabelServiceName: svc.Name,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []WeightedAddress
+	hosts := sets.NewString()
+	for _, slice := range slices {
+		port := endpointSlicePort(slice, portName)
+		if port == nil {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, ip := range endpoint.Addresses {
+				host := net.JoinHostPort(ip, strconv.Itoa(int(*port)))
+				if hosts.Has(host) {
+					// Endpoints might be listed in several slices while they're updated.
+					continue
+				}
+				hosts.Insert(host)
+
+				endpointURL := *url
+				endpointURL.Host = host
+				addresses = append(addresses, WeightedAddress{
+					Address: duckv1.Addressable{URL: &endpointURL},
+					Weight:  1,
+				})
+			}
+		}
+	}
+	return addresses, nil
+}
+
+func endpointSlicePort(slice *discoveryv1.EndpointSlice, name string) *int32 {
+	for _, port := range slice.Ports {
+		if port.Port == nil {
+			continue
+		}
+		if (port.Name == nil && name == "") || (port.Name != nil && *port.Name == name) {
+			return port.Port
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	discoveryv1listers "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/pointer"
+
+	"knative.dev/eventing/pkg/apis/eventing"
+)
+
+func TestEndpointAwareResolver(t *testing.T) {
+	service := func(name string, ports ...corev1.ServicePort) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name},
+			Spec:       corev1.ServiceSpec{Ports: ports},
+		}
+	}
+	externalName := func(name, target string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: target},
+		}
+	}
+	endpoint := func(ready bool, ips ...string) discoveryv1.Endpoint {
+		return discoveryv1.Endpoint{Addresses: ips, Conditions: discoveryv1.EndpointConditions{Ready: pointer.Bool(ready)}}
+	}
+	slice := func(name, svc string, portName string, port int32, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+		return &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns",
+				Name:      name,
+				Labels:    map[string]string{discoveryv1.LabelServiceName: svc},
+			},
+			Ports:     []discoveryv1.EndpointPort{{Name: pointer.String(portName), Port: pointer.Int32(port)}},
+			Endpoints: endpoints,
+		}
+	}
+	httpPort := corev1.ServicePort{Name: "http", Port: 80}
+
+	tt := []struct {
+		name           string
+		channelAddress string
+		services       []*corev1.Service
+		slices         []*discoveryv1.EndpointSlice
+		want           []string
+	}{
+		{
+			name:           "ready endpoints",
+			channelAddress: "http://channel.ns.svc.cluster.local/path",
+			services:       []*corev1.Service{service("channel", httpPort)},
+			slices: []*discoveryv1.EndpointSlice{
+				slice("channel-a", "channel", "http", 8080, endpoint(true, "10.0.0.1"), endpoint(false, "10.0.0.2")),
+				slice("channel-b", "channel", "http", 8080, endpoint(true, "10.0.0.3"), endpoint(true, "10.0.0.1")),
+			},
+			want: []string{"http://10.0.0.1:8080/path", "http://10.0.0.3:8080/path"},
+		},
+		{
+			name:           "named service port",
+			channelAddress: "http://channel.ns.svc.cluster.local:9090",
+			services:       []*corev1.Service{service("channel", httpPort, corev1.ServicePort{Name: "other", Port: 9090})},
+			slices: []*discoveryv1.EndpointSlice{
+				slice("channel-http", "channel", "http", 8080, endpoint(true, "10.0.0.1")),
+				slice("channel-other", "channel", "other", 9091, endpoint(true, "10.0.0.1")),
+			},
+			want: []string{"http://10.0.0.1:9091"},
+		},
+		{
+			name:           "external name service",
+			channelAddress: "http://channel.ns.svc.cluster.local",
+			services: []*corev1.Service{
+				externalName("channel", "dispatcher.ns.svc.cluster.local"),
+				service("dispatcher", httpPort),
+			},
+			slices: []*discoveryv1.EndpointSlice{
+				slice("dispatcher", "dispatcher", "http", 8080, endpoint(true, "10.0.0.1", "fd00::1")),
+			},
+			want: []string{"http://10.0.0.1:8080", "http://[fd00::1]:8080"},
+		},
+		{
+			name:           "no ready endpoints",
+			channelAddress: "http://channel.ns.svc.cluster.local",
+			services:       []*corev1.Service{service("channel", httpPort)},
+			slices: []*discoveryv1.EndpointSlice{
+				slice("channel", "channel", "http", 8080, endpoint(false, "10.0.0.1")),
+			},
+		},
+		{
+			name:           "service not found",
+			channelAddress: "http://channel.ns.svc.cluster.local",
+		},
+		{
+			name:           "not a service address",
+			channelAddress: "http://channel.example.com",
+			services:       []*corev1.Service{service("channel", httpPort)},
+		},
+		{
+			name:           "https address",
+			channelAddress: "https://channel.ns.svc.cluster.local",
+			services:       []*corev1.Service{service("channel", corev1.ServicePort{Name: "https", Port: 443})},
+			slices: []*discoveryv1.EndpointSlice{
+				slice("channel", "channel", "https", 8443, endpoint(true, "10.0.0.1")),
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			serviceIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, svc := range tc.services {
+				_ = serviceIndexer.Add(svc)
+			}
+			sliceIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, s := range tc.slices {
+				_ = sliceIndexer.Add(s)
+			}
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: tc.channelAddress,
+			}
+
+			resolver := EndpointAwareResolver(corev1listers.NewServiceLister(serviceIndexer), discoveryv1listers.NewEndpointSliceLister(sliceIndexer))
+			addresses, err := resolver(b)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			var got []string
+			for _, a := range addresses {
+				if a.Weight != 1 {
+					t.Errorf("unexpected weight %d for %s", a.Weight, a.Address.URL)
+				}
+				got = append(got, a.Address.URL.String())
+			}
+			// The slices are listed in no particular order.
+			if diff := cmp.Diff(tc.want, got, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+				t.Errorf("unexpected addresses (-want, +got): %s", diff)
+			}
+		})
+	}
+}
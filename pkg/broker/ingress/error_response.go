@@ -0,0 +1,63 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode"
+
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+// maxErrorMessageLength is the maximum number of characters of the error message returned
+// to the producer.
+const maxErrorMessageLength = 256
+
+// errorResponse is the body of the responses to requests with an invalid event.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeBadRequest responds with 400 Bad Request and a JSON body describing err.
+func writeBadRequest(writer http.ResponseWriter, err error) {
+	writeError(writer, http.StatusBadRequest, err)
+}
+
+// writeError responds with the given status code and a JSON body describing err.
+func writeError(writer http.ResponseWriter, statusCode int, err error) {
+	writer.Header().Set(cehttp.ContentType, "application/json")
+	writer.WriteHeader(statusCode)
+	_ = json.NewEncoder(writer).Encode(errorResponse{Error: sanitizeErrorMessage(err.Error())})
+}
+
+// sanitizeErrorMessage replaces the non printable characters of an error message, which
+// might contain data sent by the producer, and truncates it to maxErrorMessageLength.
+func sanitizeErrorMessage(message string) string {
+	message = strings.Map(func(r rune) rune {
+		if !unicode.IsPrint(r) {
+			return '?'
+		}
+		return r
+	}, strings.TrimSpace(message))
+
+	if runes := []rune(message); len(runes) > maxErrorMessageLength {
+		message = string(runes[:maxErrorMessageLength-3]) + "..."
+	}
+	return message
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"go.opencensus.io/tag"
+)
+
+// otherEventType is the eventType tag of the event types not in the allow list.
+const otherEventType = "other"
+
+// StatsReporterOption configures the reporter created by NewStatsReporter.
+type StatsReporterOption func(*reporter)
+
+// WithoutEventTypeTag omits the eventType tag from the metrics.
+func WithoutEventTypeTag() StatsReporterOption {
+	return func(r *reporter) {
+		r.eventTypeTag = func(string) (string, bool) { return "", false }
+	}
+}
+
+// WithHashedEventTypeTag tags the metrics with the bucket the hash of the event type falls
+// into instead of the event type, bounding the eventType tag to the given number of values.
+// Non-positive numbers of buckets are ignored.
+func WithHashedEventTypeTag(buckets int) StatsReporterOption {
+	return func(r *reporter) {
+		if buckets <= 0 {
+			return
+		}
+		r.eventTypeTag = func(eventType string) (string, bool) {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(eventType))
+			return fmt.Sprintf("bucket-%d", h.Sum32()%uint32(buckets)), true
+		}
+	}
+}
+
+// WithEventTypeTagAllowList tags the metrics with the event types in the given list, the
+// metrics of any other event type are tagged with "other".
+func WithEventTypeTagAllowList(eventTypes ...string) StatsReporterOption {
+	return func(r *reporter) {
+		allowed := make(map[string]struct{}, len(eventTypes))
+		for _, t := range eventTypes {
+			allowed[t] = struct{}{}
+		}
+		r.eventTypeTag = func(eventType string) (string, bool) {
+			if _, ok := allowed[eventType]; ok {
+				return eventType, true
+			}
+			return otherEventType, true
+		}
+	}
+}
+
+// eventTypeMutator returns the mutator setting the eventType tag of the event type of args,
+// according to the configured granularity.
+func (r *reporter) eventTypeMutator(args *ReportArgs) tag.Mutator {
+	if r.eventTypeTag == nil {
+		return tag.Insert(eventTypeKey, args.eventType)
+	}
+	if value, ok := r.eventTypeTag(args.eventType); ok {
+		return tag.Insert(eventTypeKey, value)
+	}
+	return tag.Delete(eventTypeKey)
+}
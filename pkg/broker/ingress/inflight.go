@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+)
+
+// receiveOnce dispatches the event unless an event with the same source and id is being
+// dispatched to the same broker, in which case it waits for that dispatch and returns its
+// outcome. The dispatch runs with the values of the context of the request that started it,
+// but isn't cancelled with it: the producer of the first request going away must not fail the
+// duplicates waiting for the dispatch.
+func (h *Handler) receiveOnce(ctx context.Context, headers http.Header, event *cloudevents.Event, args *ReportArgs) receiveResult {
+	key := inFlightKey(args, event)
+	result, _, shared := h.inFlight.Do(key, func() (interface{}, error) {
+		return h.dispatch(detachedContext{ctx}, headers, event, args), nil
+	})
+	if shared {
+		h.Logger.Debug("collapsed in-flight duplicate event",
+			zap.String("event.id", event.ID()),
+			zap.String("event.source", event.Source()))
+	}
+	return result.(receiveResult)
+}
+
+// inFlightKey identifies the events sent to a broker by source and id, joined with a
+// character not expected in any of them.
+func inFlightKey(args *ReportArgs, event *cloudevents.Event) string {
+	return strings.Join([]string{args.ns, args.broker, event.Source(), event.ID()}, "\x00")
+}
+
+// detachedContext carries the values of the wrapped context, without its deadline and
+// cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+)
+
+// ExtensionValue returns the value of an extension the ingress sets on the given event.
+type ExtensionValue func(event *cloudevents.Event) string
+
+// StaticExtensionValue returns an ExtensionValue setting the same value on every event,
+// e.g. the region of the ingress.
+func StaticExtensionValue(value string) ExtensionValue {
+	return func(*cloudevents.Event) string {
+		return value
+	}
+}
+
+// setIngressExtensions sets the IngressExtensions on the event, overriding the extensions
+// with the same names set by the producer. The extensions with an invalid name are skipped.
+func (h *Handler) setIngressExtensions(event *cloudevents.Event) {
+	for name, value := range h.IngressExtensions {
+		if err := event.Context.SetExtension(name, value(event)); err != nil {
+			h.Logger.Error("failed to set ingress extension",
+				zap.String("event.id", event.ID()),
+				zap.String("extension", name),
+				zap.Error(err))
+		}
+	}
+}
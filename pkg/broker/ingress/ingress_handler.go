@@ -18,21 +18,33 @@ package ingress
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	opencensusclient "github.com/cloudevents/sdk-go/observability/opencensus/v2/client"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/cloudevents/sdk-go/v2/binding"
 	"github.com/cloudevents/sdk-go/v2/client"
+	"github.com/cloudevents/sdk-go/v2/extensions"
 	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
 	"go.opencensus.io/trace"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/utils/pointer"
+	"k8s.io/utils/clock"
 
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
@@ -51,8 +63,41 @@ import (
 const (
 	defaultMaxIdleConnections        = 1000
 	defaultMaxIdleConnectionsPerHost = 1000
+
+	// structuredContentTypeWithCharset is the content type of structured messages sent when
+	// Handler.StructuredWithCharset is enabled.
+	structuredContentTypeWithCharset = cloudevents.ApplicationCloudEventsJSON + "; charset=utf-8"
+
+	// channelAddressFromAnnotation signals that the channel address was resolved from the
+	// broker status annotations.
+	channelAddressFromAnnotation = "annotation"
+
+	// Response headers describing the dispatch outcome, set when
+	// Handler.DispatchOutcomeHeaders is enabled.
+	dispatchDurationHeader = "Ce-Ingress-Dispatch-Duration"
+	channelHostHeader      = "Ce-Ingress-Channel-Host"
+
+	// Response headers describing the status of the broker the event was sent to, set when
+	// Handler.BrokerStatusHeaders is enabled.
+	brokerObservedGenerationHeader = "Ce-Ingress-Broker-Observed-Generation"
+	brokerReadyHeader              = "Ce-Ingress-Broker-Ready"
+
+	// resolvedBrokerHeader is the response header with the namespace/name of the broker
+	// parsed from the request path, set when Handler.ResolvedBrokerHeader is enabled.
+	resolvedBrokerHeader = "Ce-Ingress-Broker"
+
+	// receiveSpanName is the name of the span started with Handler.TraceRequestReceive,
+	// until it's named after the broker the request is sent to.
+	receiveSpanName = "broker-ingress"
+	// eventExtractedAnnotation marks when the event was extracted from the request in the
+	// spans started with Handler.TraceRequestReceive.
+	eventExtractedAnnotation = "event extracted"
 )
 
+// defaultAllowedMethods are the HTTP methods accepted for sending events when
+// Handler.AllowedMethods is not set.
+var defaultAllowedMethods = []string{http.MethodPost}
+
 type Handler struct {
 	// Defaults sets default values to incoming events
 	Defaulter client.EventDefaulter
@@ -64,6 +109,241 @@ type Handler struct {
 	EvenTypeHandler *eventtype.EventTypeAutoHandler
 
 	Logger *zap.Logger
+
+	// Transport, when set, sends the events to the channels instead of the shared clients
+	// configured by NewHandler (e.g. for instrumentation or proxies). It's responsible for
+	// trusting the channels CA certificates.
+	Transport http.RoundTripper
+
+	// TLSMinVersion, when set, is the minimum TLS version of the connections to the
+	// channels, e.g. tls.VersionTLS13. Defaults to TLS 1.2. Ignored when Transport is set.
+	TLSMinVersion uint16
+	// TLSCipherSuites, when set, are the cipher suites enabled for the TLS 1.2 connections
+	// to the channels, e.g. to meet compliance requirements. Defaults to the crypto/tls
+	// defaults. Ignored when Transport is set.
+	TLSCipherSuites []uint16
+	tlsTransportsMu sync.Mutex
+	tlsTransports   *simplelru.LRU
+
+	// CACertSource resolves the CA certificates trusted to dispatch to the broker channels,
+	// e.g. NewSecretCACertSource to read them from a secret referenced by the broker.
+	// Defaults to InlineCACertSource, reading them from the broker status.
+	CACertSource CACertSource
+
+	// AccessLog enables a sampled, structured access log entry for every request.
+	AccessLog bool
+
+	// StructuredWithCharset sends events to the channel in structured mode with an explicit
+	// `application/cloudevents+json; charset=utf-8` content type, for channels rejecting
+	// content types lacking a charset parameter. Brokers can override the content mode with
+	// the eventing.knative.dev/dispatchContentMode annotation (binary or structured).
+	StructuredWithCharset bool
+
+	// PathPrefix is stripped from the request path before extracting the broker namespace
+	// and name, to serve the brokers under a sub-path (e.g. /eventing/<namespace>/<name>).
+	PathPrefix string
+
+	// AllowedMethods are the HTTP methods accepted for sending events. OPTIONS is always
+	// accepted. Defaults to POST.
+	AllowedMethods   []string
+	allowHeaderOnce  sync.Once
+	allowHeaderValue string
+
+	// AllowedContentTypes, when set, are the media types accepted for incoming requests.
+	// Requests with any other Content-Type are rejected with 415 Unsupported Media Type.
+	AllowedContentTypes []string
+	// ContentTypeAliases maps lowercase media type aliases to their canonical media type.
+	// The request Content-Type is normalized before being validated and dispatched.
+	ContentTypeAliases map[string]string
+
+	// ChannelAddressesResolver, when set, resolves multiple equivalent channel addresses
+	// to spread the dispatched events across proportionally to their weights. When it
+	// resolves less than two addresses, the channel address from the broker status is used.
+	ChannelAddressesResolver ChannelAddressesResolver
+	// MaxCachedBrokers bounds the number of brokers for which per-broker state (e.g. the
+	// load spreading position or the parsed dispatch options) is kept, evicting the least
+	// recently used brokers first. Defaults to 1000.
+	MaxCachedBrokers int
+	spreaderOnce     sync.Once
+	spreader         *weightedRoundRobin
+
+	brokerOptionsOnce sync.Once
+	brokerOptions     *dispatchOptionsCache
+
+	// AllowRoutingOverride lets events be routed to a different address than the broker
+	// channel by setting the RoutingOverrideExtension attribute. The target must be listed
+	// in the broker eventing.knative.dev/routingOverrideTargets annotation, otherwise the
+	// event is routed to the broker channel.
+	AllowRoutingOverride bool
+	// RoutingOverrideExtension is the event extension attribute carrying the routing
+	// override target. Defaults to routingoverride.
+	RoutingOverrideExtension string
+
+	// DispatchOutcomeHeaders adds response headers describing how the event was dispatched
+	// (dispatch duration and channel host). Disabled by default to not expose the internal
+	// topology to producers.
+	DispatchOutcomeHeaders bool
+
+	// BrokerStatusHeaders adds response headers with the observed generation and the
+	// readiness of the broker status the channel address was resolved from, to detect
+	// brokers whose status lags their spec.
+	BrokerStatusHeaders bool
+
+	// ResolvedBrokerHeader adds a response header with the namespace/name of the broker the
+	// request path was parsed to, so that producers can check their routing. It's meant for
+	// debugging and is not set for requests whose path couldn't be parsed.
+	ResolvedBrokerHeader bool
+
+	// MaxEventAge, when set, drops the events whose time attribute is older than
+	// MaxEventAge (e.g. events buffered for hours by a producer recovering from an outage).
+	// Events without a time attribute are accepted.
+	MaxEventAge time.Duration
+
+	// ClockSkewTolerance is the maximum expected skew between the producer clocks and the
+	// ingress clock. The events are only dropped as stale when older than MaxEventAge plus
+	// ClockSkewTolerance, and the events whose time is ahead of the ingress clock by more
+	// than ClockSkewTolerance are logged. Defaults to no tolerance.
+	ClockSkewTolerance time.Duration
+
+	// DropResponseCode is the status code responded for the events the ingress intentionally
+	// drops as handled, i.e. the events whose TTL is exhausted and the events older than
+	// MaxEventAge, as opposed to the events rejected because of an error. Defaults to
+	// 400 Bad Request for the events whose TTL is exhausted, as before it was configurable,
+	// and to 200 OK for the stale events so that producers don't retry them.
+	DropResponseCode int
+
+	// ValidationMode controls how events failing the CloudEvents validation are handled.
+	// Defaults to ValidationModeStrict.
+	ValidationMode ValidationMode
+
+	// EmptyBodyMessage is the error message responded, with 400 Bad Request, to the requests
+	// without body that don't carry a binary mode event, e.g. a producer posting nothing.
+	// Defaults to defaultEmptyBodyMessage.
+	EmptyBodyMessage string
+
+	// MaxDataBytes, when set, rejects with 413 Request Entity Too Large the events whose data
+	// is larger than MaxDataBytes, regardless of the size of their context attributes. The
+	// size is the one of the data bytes of the event, e.g. the JSON value of the data of a
+	// structured mode event, or the decoded data_base64.
+	MaxDataBytes int
+
+	// FailOpen dispatches the original event when the Defaulter panics or produces an
+	// invalid event, instead of rejecting it with a 500. The original event is still
+	// dropped when it doesn't carry a valid TTL.
+	FailOpen bool
+
+	// NotAddressableRetryTimeout, when set, retries resolving the channel address of a broker
+	// found without one (e.g. freshly created) for up to NotAddressableRetryTimeout, with an
+	// exponential backoff. The events are then rejected with 503 Service Unavailable, so that
+	// producers retry them, instead of 400 Bad Request.
+	NotAddressableRetryTimeout time.Duration
+
+	// IngressExtensions are CloudEvents extensions set on every event before it's defaulted,
+	// validated and dispatched, by extension name, e.g. to record where and when the events
+	// were ingested. The values are strings and override the extensions set by producers.
+	IngressExtensions map[string]ExtensionValue
+
+	// TraceRequestReceive starts the event span as soon as the request is received instead of
+	// after the event was extracted, so that the span includes the time spent reading and
+	// parsing the request body. An annotation marks when the event was extracted. Disabled
+	// by default to keep the existing span timings.
+	TraceRequestReceive bool
+
+	// Sampler, when set, decides whether the spans started for the requests are recorded,
+	// e.g. trace.ProbabilitySampler(0.01) to reduce the tracing overhead at high volumes.
+	// The sampling decision of the request traceparent header, if any, is always honored.
+	// Defaults to the sampling decision of the parent span, or the global default sampler.
+	Sampler trace.Sampler
+
+	// WarmInterval enables WarmTargets, pre-connecting to the channel addresses of the known
+	// brokers at startup and then every WarmInterval.
+	WarmInterval time.Duration
+
+	// DeliveryGuarantee is the delivery guarantee of the events not requesting one with
+	// the deliveryguarantee extension. Defaults to DeliveryGuaranteeAtMostOnce.
+	DeliveryGuarantee DeliveryGuarantee
+
+	// RetryConfig is the retry policy of the at-least-once deliveries. Defaults to 3
+	// retries with an exponential backoff starting at 200ms. Brokers can override the number
+	// of retries with the eventing.knative.dev/dispatchRetry annotation, and the timeout of
+	// every request to the channel with the eventing.knative.dev/dispatchTimeout annotation.
+	RetryConfig *kncloudevents.RetryConfig
+
+	// RetryQueueMaxRetries, when set, queues the at-least-once deliveries failing with a
+	// retryable error instead of retrying them synchronously, and responds 202 Accepted right
+	// away. The queued events are retried in the background up to RetryQueueMaxRetries times,
+	// the last attempt falling back to the dead letter sink of the broker.
+	//
+	// The queue is kept in memory: FlushRetryQueue must be called when the ingress stops to
+	// dispatch the queued events a last time. The events still queued when the ingress is
+	// killed, or failing to be flushed in time, are lost although the producers were answered
+	// 202, so this mode weakens the at-least-once guarantee to trade it for latency.
+	RetryQueueMaxRetries int
+
+	// RetryQueueBackoff is the delay before the first retry of a queued event, doubled on
+	// each retry up to 5 minutes. Defaults to 1s.
+	RetryQueueBackoff time.Duration
+
+	// RetryQueueSize caps the number of events queued for retries. The events failing while
+	// the queue is full are rejected as without the queue. Defaults to 1000.
+	RetryQueueSize int
+
+	// HonorChannelRetryAfter makes the ingress follow the Retry-After header of a channel
+	// responding with 429 Too Many Requests: the synchronous retries of RetryConfig and the
+	// queued retries wait at least for the delay the channel asks for, and the events failing
+	// with a 429 are rejected with a 429 and the channel Retry-After, so that the backpressure
+	// flows to the producers. The events queued for retries are accepted with the channel
+	// Retry-After as a hint to slow down.
+	HonorChannelRetryAfter bool
+
+	// MaxChannelRetryAfter caps the delays honored by HonorChannelRetryAfter. 0 means no cap.
+	MaxChannelRetryAfter time.Duration
+
+	// OnDispatchSuccess, when set, is called with every event dispatched to the channel
+	// with a 2xx response, e.g. for custom accounting. It's called in the request goroutine,
+	// before responding, so it must not block. The events queued for retries are reported
+	// once, from the retry queue, after their last attempt.
+	OnDispatchSuccess func(e *cloudevents.Event, target duckv1.Addressable, d time.Duration)
+	// OnDispatchFailure, when set, is called with every event failing to be dispatched to
+	// the channel, under the same conditions as OnDispatchSuccess.
+	OnDispatchFailure func(e *cloudevents.Event, target duckv1.Addressable, err error)
+
+	// CollapseInFlightDuplicates dispatches the events with the same source and id sent
+	// concurrently to the same broker (e.g. double-submitted by a producer) once, all the
+	// requests sharing the outcome of the dispatch. Unlike a deduplication cache, the events
+	// received after the dispatch completed are dispatched again.
+	CollapseInFlightDuplicates bool
+	inFlight                   singleflight.Group
+
+	// PathRewrite, when set, returns the path of the request dispatching the event to the
+	// resolved channel address, e.g. to route the events by type to a path-partitioned
+	// channel. The path of the resolved address is kept when it returns an empty path.
+	PathRewrite func(target duckv1.Addressable, e *cloudevents.Event) string
+
+	// ReceiptSink, when set, receives a delivery receipt event for every event dispatched to
+	// the channel with a 2xx response, as an independent delivery ledger. The receipts are
+	// sent in the background, on a best-effort basis: failing to send them never affects
+	// the dispatch.
+	ReceiptSink *duckv1.Addressable
+
+	// ReplayEnabled enables the ReplayHandler debug endpoint.
+	ReplayEnabled bool
+	// ReplayAuthorizer authorizes the requests to the ReplayHandler debug endpoint, the
+	// requests are rejected when it returns an error. Every request is rejected when nil.
+	ReplayAuthorizer func(request *http.Request) error
+
+	retryQueueDepth atomic.Int64
+	// retryQueueMu guards the timers of the queued events and retryQueueFlushed.
+	retryQueueMu      sync.Mutex
+	retryTimers       map[*retryItem]clock.Timer
+	retryQueueFlushed bool
+	// retryQueueWG waits for the queued events to be done with.
+	retryQueueWG sync.WaitGroup
+	// clock schedules the retries of the queued events, so that tests can control time.
+	clock clock.WithDelayedExecution
+
+	accessLoggerOnce sync.Once
+	accessLogger     *zap.Logger
 }
 
 func NewHandler(logger *zap.Logger, reporter StatsReporter, defaulter client.EventDefaulter, brokerInformer v1.BrokerInformer) (*Handler, error) {
@@ -111,6 +391,7 @@ func NewHandler(logger *zap.Logger, reporter StatsReporter, defaulter client.Eve
 		Reporter:     reporter,
 		Logger:       logger,
 		BrokerLister: brokerInformer.Lister(),
+		clock:        clock.RealClock{},
 	}, nil
 }
 
@@ -123,38 +404,93 @@ func (h *Handler) getBroker(name, namespace string) (*eventingv1.Broker, error)
 	return broker, nil
 }
 
-func (h *Handler) getChannelAddress(name, namespace string) (*duckv1.Addressable, error) {
+// getChannelAddress returns the channel address of the given broker and the broker it was
+// resolved from. The broker is returned even when its status has no valid channel address.
+// The address is never guessed: events sent to a broker without a valid channel address in
+// its status are rejected.
+func (h *Handler) getChannelAddress(name, namespace string) (*duckv1.Addressable, *eventingv1.Broker, error) {
 	broker, err := h.getBroker(name, namespace)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if broker.Status.ObservedGeneration < broker.Generation {
+		h.Logger.Debug("resolving channel address from a stale broker status",
+			zap.String("broker", namespace+"/"+name),
+			zap.Int64("generation", broker.Generation),
+			zap.Int64("observedGeneration", broker.Status.ObservedGeneration))
 	}
 	if broker.Status.Annotations == nil {
-		return nil, fmt.Errorf("broker status annotations uninitialized")
+		return nil, broker, fmt.Errorf("broker status annotations uninitialized")
 	}
 	address, present := broker.Status.Annotations[eventing.BrokerChannelAddressStatusAnnotationKey]
 	if !present {
-		return nil, fmt.Errorf("channel address not found in broker status annotations")
+		return nil, broker, fmt.Errorf("channel address not found in broker status annotations")
 	}
 
 	url, err := apis.ParseURL(address)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse channel address url")
+		return nil, broker, fmt.Errorf("failed to parse channel address url")
 	}
 
-	var caCerts *string
-	certs, present := broker.Status.Annotations[eventing.BrokerChannelCACertsStatusAnnotationKey]
-	if present && certs != "" {
-		caCerts = pointer.String(certs)
-	}
 	addr := &duckv1.Addressable{
 		URL:     url,
-		CACerts: caCerts,
+		CACerts: h.caCertSource().CACerts(broker),
+	}
+	return addr, broker, nil
+}
+
+// notAddressableInitialBackoff is the first delay between the attempts to resolve the
+// channel address of a broker which isn't addressable yet.
+const notAddressableInitialBackoff = 50 * time.Millisecond
+
+// awaitChannelAddress resolves the channel address of a broker which isn't addressable yet,
+// retrying with an exponential backoff for up to NotAddressableRetryTimeout.
+func (h *Handler) awaitChannelAddress(ctx context.Context, args *ReportArgs) (*duckv1.Addressable, *eventingv1.Broker, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.NotAddressableRetryTimeout)
+	defer cancel()
+
+	backoff := notAddressableInitialBackoff
+	for {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			// Last attempt, the broker might have become addressable in the meantime.
+			return h.getChannelAddress(args.broker, args.ns)
+		case <-timer.C:
+		}
+
+		address, b, err := h.getChannelAddress(args.broker, args.ns)
+		if err == nil || b == nil {
+			return address, b, err
+		}
+		backoff *= 2
 	}
-	return addr, nil
 }
 
 func (h *Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	writer.Header().Set("Allow", "POST, OPTIONS")
+	// The entry is only allocated when access logging is enabled, nil otherwise.
+	var access *accessLogEntry
+	if h.AccessLog {
+		access = newAccessLogEntry(request)
+		recorder := &statusRecorder{ResponseWriter: writer, statusCode: http.StatusOK}
+		writer = recorder
+		defer func() {
+			access.statusCode = recorder.statusCode
+			h.logAccess(access)
+		}()
+	}
+
+	var receiveSpan *trace.Span
+	if h.TraceRequestReceive {
+		var ctx context.Context
+		ctx, receiveSpan = h.startSpan(request.Context(), request, receiveSpanName)
+		defer receiveSpan.End()
+		request = request.WithContext(ctx)
+	}
+
+	allowedMethods := h.allowedMethods()
+	writer.Header().Set("Allow", h.allowHeader())
 	// validate request method
 	if request.Method == http.MethodOptions {
 		writer.Header().Set("WebHook-Allowed-Origin", "*") // Accept from any Origin:
@@ -162,7 +498,7 @@ func (h *Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		writer.WriteHeader(http.StatusOK)
 		return
 	}
-	if request.Method != http.MethodPost {
+	if !isAllowedMethod(request.Method, allowedMethods) {
 		h.Logger.Warn("unexpected request method", zap.String("method", request.Method))
 		writer.WriteHeader(http.StatusMethodNotAllowed)
 		return
@@ -173,51 +509,97 @@ func (h *Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		writer.WriteHeader(http.StatusNotFound)
 		return
 	}
-	nsBrokerName := strings.Split(strings.TrimSuffix(request.RequestURI, "/"), "/")
-	if len(nsBrokerName) != 3 {
-		h.Logger.Info("Malformed uri", zap.String("URI", request.RequestURI))
+	brokerNamespace, brokerName, err := parseBrokerRef(request.RequestURI, h.PathPrefix)
+	if err != nil {
+		h.Logger.Info("Malformed uri", zap.String("URI", request.RequestURI), zap.Error(err))
 		writer.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if access != nil {
+		access.brokerNamespace, access.brokerName = brokerNamespace, brokerName
+	}
+	if h.ResolvedBrokerHeader {
+		// Set before any later rejection so that it's part of every response from now on.
+		writer.Header().Set(resolvedBrokerHeader, brokerNamespace+"/"+brokerName)
+	}
+
+	// validate request Content-Type
+	if !h.normalizeContentType(request.Header) {
+		h.Logger.Info("Unsupported content type", zap.String("Content-Type", request.Header.Get(cehttp.ContentType)))
+		writer.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
 
 	ctx := request.Context()
 
+	if h.isEmptyBody(request) {
+		h.Logger.Info("request without body", zap.String("URI", request.RequestURI))
+		_ = h.Reporter.ReportEventRejected(&ReportArgs{ns: brokerNamespace, broker: brokerName}, rejectReasonEmptyBody)
+		writeBadRequest(writer, errors.New(h.emptyBodyMessage()))
+		return
+	}
+
+	var invalidSource string
+	if h.ValidationMode == ValidationModeLenient && lenientAttributes.Has("source") {
+		if invalidSource, err = takeInvalidSource(request); err != nil {
+			h.Logger.Warn("failed to read request", zap.Error(err))
+			writeBadRequest(writer, err)
+			return
+		}
+	}
+
 	message := cehttp.NewMessageFromHttpRequest(request)
 	defer message.Finish(nil)
 
 	event, err := binding.ToEvent(ctx, message)
 	if err != nil {
 		h.Logger.Warn("failed to extract event from request", zap.Error(err))
-		writer.WriteHeader(http.StatusBadRequest)
+		writeBadRequest(writer, err)
 		return
 	}
+	if invalidSource != "" {
+		h.restoreInvalidSource(event, invalidSource)
+	}
+	if access != nil {
+		access.eventType, access.eventID = event.Type(), event.ID()
+	}
+	if receiveSpan != nil {
+		receiveSpan.Annotate(nil, eventExtractedAnnotation)
+	}
 
-	// run validation for the extracted event
-	validationErr := event.Validate()
-	if validationErr != nil {
-		h.Logger.Warn("failed to validate extracted event", zap.Error(validationErr))
-		writer.WriteHeader(http.StatusBadRequest)
+	if h.MaxDataBytes > 0 && len(event.Data()) > h.MaxDataBytes {
+		h.Logger.Info("event data too large",
+			zap.String("event.id", event.ID()),
+			zap.Int("dataBytes", len(event.Data())),
+			zap.Int("maxDataBytes", h.MaxDataBytes))
+		_ = h.Reporter.ReportEventRejected(&ReportArgs{ns: brokerNamespace, broker: brokerName, eventType: event.Type()}, rejectReasonDataTooLarge)
+		writeError(writer, http.StatusRequestEntityTooLarge,
+			fmt.Errorf("event data is %d bytes, exceeding the maximum of %d bytes", len(event.Data()), h.MaxDataBytes))
 		return
 	}
 
-	brokerNamespace := nsBrokerName[1]
-	brokerName := nsBrokerName[2]
 	brokerNamespacedName := types.NamespacedName{
 		Name:      brokerName,
 		Namespace: brokerNamespace,
 	}
 
-	ctx, span := trace.StartSpan(ctx, tracing.BrokerMessagingDestination(brokerNamespacedName))
-	defer span.End()
+	span := receiveSpan
+	if span != nil {
+		span.SetName(tracing.BrokerMessagingDestination(brokerNamespacedName))
+		addEventSpanAttributes(span, brokerNamespacedName, event)
+	} else {
+		ctx, span = h.startEventSpan(ctx, request, brokerNamespacedName, event)
+		defer span.End()
+	}
+	setTraceParent(event, span)
 
-	if span.IsRecordingEvents() {
-		span.AddAttributes(
-			tracing.MessagingSystemAttribute,
-			tracing.MessagingProtocolHTTP,
-			tracing.BrokerMessagingDestinationAttribute(brokerNamespacedName),
-			tracing.MessagingMessageIDAttribute(event.ID()),
-		)
-		span.AddAttributes(opencensusclient.EventTraceAttributes(event)...)
+	// run validation for the extracted event
+	validationErr := h.validate(event)
+	if validationErr != nil {
+		h.Logger.Warn("failed to validate extracted event", zap.Error(validationErr))
+		span.SetStatus(trace.Status{Code: trace.StatusCodeInvalidArgument, Message: validationErr.Error()})
+		writeBadRequest(writer, validationErr)
+		return
 	}
 
 	reporterArgs := &ReportArgs{
@@ -226,13 +608,27 @@ func (h *Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		eventType: event.Type(),
 	}
 
-	statusCode, dispatchTime := h.receive(ctx, utils.PassThroughHeaders(request.Header), event, brokerNamespace, brokerName)
-	if dispatchTime > kncloudevents.NoDuration {
-		_ = h.Reporter.ReportEventDispatchTime(reporterArgs, statusCode, dispatchTime)
+	result := h.receive(ctx, utils.PassThroughHeaders(request.Header), event, reporterArgs)
+	if access != nil {
+		access.dispatchTime = result.dispatchTime
+	}
+	if result.dispatchTime > kncloudevents.NoDuration {
+		_ = h.Reporter.ReportEventDispatchTime(reporterArgs, result.statusCode, result.dispatchTime)
 	}
-	_ = h.Reporter.ReportEventCount(reporterArgs, statusCode)
+	_ = h.Reporter.ReportEventCount(reporterArgs, result.statusCode)
 
-	writer.WriteHeader(statusCode)
+	if h.DispatchOutcomeHeaders && result.dispatchTime > kncloudevents.NoDuration {
+		writer.Header().Set(dispatchDurationHeader, result.dispatchTime.String())
+		writer.Header().Set(channelHostHeader, result.channelHost)
+	}
+	if h.BrokerStatusHeaders && result.broker != nil {
+		writer.Header().Set(brokerObservedGenerationHeader, strconv.FormatInt(result.broker.Status.ObservedGeneration, 10))
+		writer.Header().Set(brokerReadyHeader, brokerReadiness(result.broker))
+	}
+	if result.retryAfter > 0 {
+		writer.Header().Set(retryAfterHeader, formatRetryAfter(result.retryAfter))
+	}
+	writer.WriteHeader(result.statusCode)
 
 	// EventType auto-create feature handling
 	if h.EvenTypeHandler != nil {
@@ -246,6 +642,108 @@ func (h *Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	}
 }
 
+// setTraceParent sets the traceparent extension of the event, and the tracestate one when the
+// span carries a trace state, from the span context unless the event already carries a
+// traceparent, so that the trace correlation survives the protocol hops dropping the tracing
+// headers. The traceparent is formatted directly as it is set on every event.
+func setTraceParent(event *cloudevents.Event, span *trace.Span) {
+	if _, ok := event.Extensions()[extensions.TraceParentExtension]; ok {
+		return
+	}
+	sc := span.SpanContext()
+	event.SetExtension(extensions.TraceParentExtension, formatTraceParent(sc))
+	if sc.Tracestate != nil {
+		if _, traceState := (&tracecontext.HTTPFormat{}).SpanContextToHeaders(sc); traceState != "" {
+			event.SetExtension(extensions.TraceStateExtension, traceState)
+		}
+	}
+}
+
+// formatTraceParent formats the span context as a version 00 W3C traceparent:
+// 00-<trace id>-<span id>-<trace flags>.
+func formatTraceParent(sc trace.SpanContext) string {
+	var b [55]byte
+	b[0], b[1], b[2] = '0', '0', '-'
+	hex.Encode(b[3:35], sc.TraceID[:])
+	b[35] = '-'
+	hex.Encode(b[36:52], sc.SpanID[:])
+	b[52] = '-'
+	hex.Encode(b[53:], []byte{byte(sc.TraceOptions)})
+	return string(b[:])
+}
+
+// startEventSpan starts the span of a single event sent to the given broker. The span is a
+// child of the span in ctx, if any, so that the events of a single request (e.g. a batch)
+// each get their own span under the request span. Callers must end the returned span.
+func (h *Handler) startEventSpan(ctx context.Context, request *http.Request, broker types.NamespacedName, event *cloudevents.Event) (context.Context, *trace.Span) {
+	ctx, span := h.startSpan(ctx, request, tracing.BrokerMessagingDestination(broker))
+	addEventSpanAttributes(span, broker, event)
+	return ctx, span
+}
+
+// addEventSpanAttributes adds the attributes describing the event sent to the given broker.
+func addEventSpanAttributes(span *trace.Span, broker types.NamespacedName, event *cloudevents.Event) {
+	if span.IsRecordingEvents() {
+		span.AddAttributes(
+			tracing.MessagingSystemAttribute,
+			tracing.MessagingProtocolHTTP,
+			tracing.BrokerMessagingDestinationAttribute(broker),
+			tracing.MessagingMessageIDAttribute(event.ID()),
+		)
+		span.AddAttributes(opencensusclient.EventTraceAttributes(event)...)
+	}
+}
+
+// spreadChannelAddress picks one of the channel addresses resolved by the
+// ChannelAddressesResolver, if there are several of them.
+func (h *Handler) spreadChannelAddress(event *cloudevents.Event, args *ReportArgs) (*duckv1.Addressable, bool) {
+	b, err := h.getBroker(args.broker, args.ns)
+	if err != nil {
+		return nil, false
+	}
+	addresses, err := h.ChannelAddressesResolver(b)
+	if err != nil {
+		h.Logger.Warn("failed to resolve channel addresses, using the broker channel address", zap.Error(err))
+		return nil, false
+	}
+	if len(addresses) < 2 {
+		return nil, false
+	}
+	return h.getSpreader().pick(types.NamespacedName{Namespace: args.ns, Name: args.broker}, event, addresses)
+}
+
+func (h *Handler) getSpreader() *weightedRoundRobin {
+	h.spreaderOnce.Do(func() {
+		h.spreader = newWeightedRoundRobin(h.MaxCachedBrokers)
+	})
+	return h.spreader
+}
+
+func (h *Handler) allowedMethods() []string {
+	if len(h.AllowedMethods) == 0 {
+		return defaultAllowedMethods
+	}
+	return h.AllowedMethods
+}
+
+// allowHeader returns the value of the Allow response header, computed once as it's set on
+// every response.
+func (h *Handler) allowHeader() string {
+	h.allowHeaderOnce.Do(func() {
+		h.allowHeaderValue = strings.Join(h.allowedMethods(), ", ") + ", " + http.MethodOptions
+	})
+	return h.allowHeaderValue
+}
+
+func isAllowedMethod(method string, allowedMethods []string) bool {
+	for _, m := range allowedMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
 func toKReference(broker *eventingv1.Broker) *duckv1.KReference {
 	kref := &duckv1.KReference{
 		Kind:       broker.Kind,
@@ -263,30 +761,321 @@ func toKReference(broker *eventingv1.Broker) *duckv1.KReference {
 	return kref
 }
 
-func (h *Handler) receive(ctx context.Context, headers http.Header, event *cloudevents.Event, brokerNamespace, brokerName string) (int, time.Duration) {
+// brokerReadiness returns the status of the broker Ready condition.
+func brokerReadiness(b *eventingv1.Broker) string {
+	if c := b.Status.GetTopLevelCondition(); c != nil {
+		return string(c.Status)
+	}
+	return string(corev1.ConditionUnknown)
+}
+
+// receiveResult is the outcome of dispatching an event to the broker channel.
+type receiveResult struct {
+	statusCode   int
+	dispatchTime time.Duration
+	// channelHost is the host of the channel the event was dispatched to.
+	channelHost string
+	// broker is the broker the channel address was resolved from, nil when the event was
+	// rejected before resolving it or when the broker wasn't found.
+	broker *eventingv1.Broker
+	// retryAfter is the delay the producer is asked to wait before retrying, or before sending
+	// more events when the event is accepted, 0 for none.
+	retryAfter time.Duration
+}
+
+// isTLSVerificationError returns whether err is caused by the failure to verify the
+// certificate presented by the server, e.g. when it's not signed by the trusted CAs.
+func isTLSVerificationError(err error) bool {
+	var verificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verificationErr) ||
+		errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
+
+// applyDefaulter returns the event defaulted by the Defaulter, or an error when the
+// Defaulter panics or produces an invalid event. With FailOpen, the Defaulter is given a
+// copy of the event so that the original can still be dispatched.
+func (h *Handler) applyDefaulter(ctx context.Context, event *cloudevents.Event) (defaulted *cloudevents.Event, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			defaulted, err = nil, fmt.Errorf("defaulter panicked: %v", r)
+		}
+	}()
+
+	in := *event
+	if h.FailOpen {
+		in = event.Clone()
+	}
+	newEvent := h.Defaulter(ctx, in)
+	if err := h.validate(&newEvent); err != nil {
+		return nil, fmt.Errorf("defaulter produced an invalid event: %w", err)
+	}
+	return &newEvent, nil
+}
+
+// Reasons for the ingress to intentionally drop an event.
+const (
+	dropReasonTTLExhausted = "ttl_exhausted"
+)
+
+// Reasons for the ingress to reject a request before dispatch. Unlike the drops, these are
+// client errors.
+const (
+	rejectReasonEmptyBody    = "empty_body"
+	rejectReasonDataTooLarge = "data_too_large"
+)
+
+// reportDispatch calls the OnDispatchSuccess or OnDispatchFailure hook with the outcome of
+// the dispatch of the event to the target.
+func (h *Handler) reportDispatch(event *cloudevents.Event, target duckv1.Addressable, dispatchInfo *kncloudevents.DispatchInfo, err error) {
+	if err != nil {
+		if h.OnDispatchFailure != nil {
+			h.OnDispatchFailure(event, target, err)
+		}
+		return
+	}
+	if h.OnDispatchSuccess != nil {
+		h.OnDispatchSuccess(event, target, dispatchInfo.Duration)
+	}
+}
+
+// isRedirect returns true when the dispatch failed with a redirect response.
+func isRedirect(dispatchInfo *kncloudevents.DispatchInfo) bool {
+	return dispatchInfo != nil &&
+		dispatchInfo.ResponseCode >= http.StatusMultipleChoices &&
+		dispatchInfo.ResponseCode < http.StatusBadRequest
+}
+
+// dispatchFailureFields returns the log fields describing the response of a failed dispatch
+// to the channel.
+func dispatchFailureFields(channelAddress duckv1.Addressable, dispatchInfo *kncloudevents.DispatchInfo) []zap.Field {
+	fields := []zap.Field{zap.String("channel.host", channelAddress.URL.Host)}
+	if dispatchInfo != nil && dispatchInfo.ResponseCode > 0 {
+		fields = append(fields,
+			zap.Int("response.code", dispatchInfo.ResponseCode),
+			zap.String("response.status", http.StatusText(dispatchInfo.ResponseCode)))
+	}
+	return fields
+}
+
+// drop reports the event as intentionally dropped for the given reason and returns the
+// DropResponseCode, or defaultCode when it isn't set.
+func (h *Handler) drop(args *ReportArgs, reason string, defaultCode int) receiveResult {
+	_ = h.Reporter.ReportEventDropped(args, reason)
+	return h.dropResult(defaultCode)
+}
+
+// dropResult returns the result of an intentionally dropped event, responded with the
+// DropResponseCode, or defaultCode when it isn't set.
+func (h *Handler) dropResult(defaultCode int) receiveResult {
+	statusCode := h.DropResponseCode
+	if statusCode == 0 {
+		statusCode = defaultCode
+	}
+	return receiveResult{statusCode: statusCode, dispatchTime: kncloudevents.NoDuration}
+}
+
+// receive dispatches the event to the broker channel.
+func (h *Handler) receive(ctx context.Context, headers http.Header, event *cloudevents.Event, args *ReportArgs) receiveResult {
+	if h.CollapseInFlightDuplicates {
+		return h.receiveOnce(ctx, headers, event, args)
+	}
+	return h.dispatch(ctx, headers, event, args)
+}
+
+func (h *Handler) dispatch(ctx context.Context, headers http.Header, event *cloudevents.Event, args *ReportArgs) receiveResult {
 	// Setting the extension as a string as the CloudEvents sdk does not support non-string extensions.
 	event.SetExtension(broker.EventArrivalTime, cloudevents.Timestamp{Time: time.Now()})
+	if len(h.IngressExtensions) > 0 {
+		h.setIngressExtensions(event)
+	}
 	if h.Defaulter != nil {
-		newEvent := h.Defaulter(ctx, *event)
-		event = &newEvent
+		defaulted, err := h.applyDefaulter(ctx, event)
+		if err != nil {
+			_ = h.Reporter.ReportDefaulterError(args)
+			if !h.FailOpen {
+				h.Logger.Error("failed to default event", zap.String("event.id", event.ID()), zap.Error(err))
+				return receiveResult{statusCode: http.StatusInternalServerError, dispatchTime: kncloudevents.NoDuration}
+			}
+			h.Logger.Warn("failed to default event, dispatching the original event",
+				zap.String("event.id", event.ID()),
+				zap.Error(err))
+		} else {
+			event = defaulted
+		}
 	}
 
-	if ttl, err := broker.GetTTL(event.Context); err != nil || ttl <= 0 {
-		h.Logger.Debug("dropping event based on TTL status.", zap.Int32("TTL", ttl), zap.String("event.id", event.ID()), zap.Error(err))
-		return http.StatusBadRequest, kncloudevents.NoDuration
+	ttl, err := broker.GetTTL(event.Context)
+	if err != nil {
+		h.Logger.Debug("dropping event without a valid TTL.", zap.String("event.id", event.ID()), zap.Error(err))
+		return receiveResult{statusCode: http.StatusBadRequest, dispatchTime: kncloudevents.NoDuration}
+	}
+	if ttl <= 0 {
+		h.Logger.Debug("dropping event based on TTL status.", zap.Int32("TTL", ttl), zap.String("event.id", event.ID()))
+		return h.drop(args, dropReasonTTLExhausted, http.StatusBadRequest)
 	}
 
-	channelAddress, err := h.getChannelAddress(brokerName, brokerNamespace)
+	if h.isStale(event, time.Now()) {
+		h.Logger.Info("dropping stale event",
+			zap.String("event.id", event.ID()),
+			zap.Time("event.time", event.Time()),
+			zap.String("maxEventAge", h.MaxEventAge.String()))
+		// Stale events are only counted by the stale event metric.
+		_ = h.Reporter.ReportStaleEvent(args)
+		return h.dropResult(http.StatusOK)
+	}
+
+	channelAddress, b, err := h.getChannelAddress(args.broker, args.ns)
+	if err != nil && b != nil && h.NotAddressableRetryTimeout > 0 {
+		channelAddress, b, err = h.awaitChannelAddress(ctx, args)
+		if err != nil && b != nil {
+			_ = h.Reporter.ReportChannelResolution(args, channelAddressFromAnnotation, false)
+			_ = h.Reporter.ReportBrokerNotAddressable(args)
+			h.Logger.Warn("Broker not addressable yet", zap.String("event.id", event.ID()), zap.Error(err))
+			return receiveResult{statusCode: http.StatusServiceUnavailable, dispatchTime: kncloudevents.NoDuration, broker: b}
+		}
+	}
+	_ = h.Reporter.ReportChannelResolution(args, channelAddressFromAnnotation, err == nil)
 	if err != nil {
 		h.Logger.Warn("Broker not found in the namespace", zap.Error(err))
-		return http.StatusBadRequest, kncloudevents.NoDuration
+		return receiveResult{statusCode: http.StatusBadRequest, dispatchTime: kncloudevents.NoDuration, broker: b}
 	}
 
-	dispatchInfo, err := kncloudevents.SendEvent(ctx, *event, *channelAddress, kncloudevents.WithHeader(headers))
+	resolution := channelAddressFromAnnotation
+	overridden := false
+	if h.AllowRoutingOverride {
+		var addr *duckv1.Addressable
+		if addr, overridden = h.routingOverrideAddress(event, b); overridden {
+			_ = h.Reporter.ReportChannelResolution(args, channelAddressFromRoutingOverride, addr != nil)
+			if addr != nil {
+				channelAddress = addr
+				resolution = channelAddressFromRoutingOverride
+			}
+		}
+	}
+
+	if h.ChannelAddressesResolver != nil && resolution == channelAddressFromAnnotation {
+		if addr, ok := h.spreadChannelAddress(event, args); ok {
+			channelAddress = addr
+		}
+	}
+
+	channelAddress = h.rewritePath(channelAddress, event)
+
+	dispatchOpts := h.brokerDispatchOptions(b)
+	ctx = h.withContentMode(ctx, dispatchOpts.contentMode)
+	if h.StructuredWithCharset && dispatchOpts.contentMode != contentModeBinary {
+		headers.Set(cehttp.ContentType, structuredContentTypeWithCharset)
+	}
+
+	// Redirecting the dispatch to the channel is a misconfiguration, redirects are not followed
+	// and fail the dispatch instead.
+	opts := []kncloudevents.SendOption{kncloudevents.WithHeader(headers), kncloudevents.WithoutRedirects()}
+	if h.Transport != nil {
+		opts = append(opts, kncloudevents.WithTransport(h.Transport))
+	} else if h.hasDispatchTLSConfig() {
+		transport, err := h.dispatchTransport(*channelAddress)
+		if err != nil {
+			h.Logger.Error("failed to configure the channel TLS transport", zap.Error(err))
+			return receiveResult{statusCode: http.StatusInternalServerError, dispatchTime: kncloudevents.NoDuration, broker: b}
+		}
+		opts = append(opts, kncloudevents.WithTransport(transport))
+	}
+	guarantee := h.deliveryGuarantee(event)
+	queueRetries := h.RetryQueueMaxRetries > 0 && guarantee == DeliveryGuaranteeAtLeastOnce
+	if queueRetries {
+		// Queued events are retried by the queue, only the single requests are configured.
+		opts = append(opts, h.deliveryOptions(DeliveryGuaranteeAtMostOnce, dispatchOpts)...)
+	} else {
+		opts = append(opts, h.deliveryOptions(guarantee, dispatchOpts)...)
+	}
+	replay := replayOutcomeFrom(ctx)
+	if replay != nil {
+		replay.ResolutionMethod = resolution
+		replay.ChannelHost = channelAddress.URL.Host
+		dispatched := event.Clone()
+		replay.Event = &dispatched
+	}
+	dispatchInfo, err := kncloudevents.SendEvent(ctx, *event, *channelAddress, opts...)
+	if replay != nil && err != nil {
+		replay.Error = err.Error()
+	}
+	queued := false
+	defer func() {
+		if !queued {
+			h.reportDispatch(event, *channelAddress, dispatchInfo, err)
+		}
+	}()
+	if err != nil && isTLSVerificationError(err) {
+		h.Logger.Error("failed to verify the channel TLS certificate, check the broker channel CA certificates",
+			zap.String("channel.host", channelAddress.URL.Host),
+			zap.Error(err))
+		_ = h.Reporter.ReportTLSVerificationFailure(args)
+		return receiveResult{statusCode: http.StatusBadGateway, dispatchTime: kncloudevents.NoDuration, broker: b}
+	}
+	if err != nil && queueRetries && isRetryableDispatch(dispatchInfo, err) {
+		retryAfter, _ := h.channelRetryAfter(dispatchInfo)
+		queued = h.enqueueRetry(&retryItem{
+			event:          event.Clone(),
+			channelAddress: *channelAddress,
+			contentMode:    dispatchOpts.contentMode,
+			opts:           opts,
+			deadLetterSink: deadLetterSink(b),
+			broker:         types.NamespacedName{Namespace: args.ns, Name: args.broker},
+			retryAfter:     retryAfter,
+			span:           trace.FromContext(ctx),
+		})
+		if queued {
+			h.Logger.Info("failed to dispatch event, queued for retries",
+				append(dispatchFailureFields(*channelAddress, dispatchInfo), zap.String("event.id", event.ID()), zap.Error(err))...)
+			// The channel throttling the dispatch is passed on to the producer as a hint to slow
+			// down, even though the event is accepted.
+			return receiveResult{statusCode: http.StatusAccepted, dispatchTime: kncloudevents.NoDuration, broker: b, retryAfter: retryAfter}
+		}
+	}
+	if err != nil && isRedirect(dispatchInfo) {
+		h.Logger.Error("the channel responded with a redirect, redirects are not followed",
+			append(dispatchFailureFields(*channelAddress, dispatchInfo),
+				zap.String("location", dispatchInfo.ResponseHeader.Get("Location")),
+				zap.Error(err))...)
+		return receiveResult{statusCode: http.StatusBadGateway, dispatchTime: kncloudevents.NoDuration, broker: b}
+	}
+	if err != nil && h.HonorChannelRetryAfter && dispatchInfo != nil && dispatchInfo.ResponseCode == http.StatusTooManyRequests {
+		retryAfter, _ := h.channelRetryAfter(dispatchInfo)
+		h.Logger.Warn("the channel is throttling the dispatch, rejecting the event",
+			append(dispatchFailureFields(*channelAddress, dispatchInfo),
+				zap.Duration("retryAfter", retryAfter),
+				zap.Error(err))...)
+		return receiveResult{statusCode: http.StatusTooManyRequests, dispatchTime: kncloudevents.NoDuration, broker: b, retryAfter: retryAfter}
+	}
 	if err != nil {
-		h.Logger.Error("failed to dispatch event", zap.Error(err))
-		return http.StatusInternalServerError, kncloudevents.NoDuration
+		h.Logger.Error("failed to dispatch event", append(dispatchFailureFields(*channelAddress, dispatchInfo), zap.Error(err))...)
+		return receiveResult{statusCode: http.StatusInternalServerError, dispatchTime: kncloudevents.NoDuration, broker: b}
 	}
 
-	return dispatchInfo.ResponseCode, dispatchInfo.Duration
+	if span := trace.FromContext(ctx); span.IsRecordingEvents() {
+		span.AddAttributes(
+			tracing.ChannelHostAttribute(channelAddress.URL.Host),
+			tracing.ChannelAddressResolutionAttribute(resolution),
+		)
+	}
+	h.emitReceipt(event, types.NamespacedName{Namespace: args.ns, Name: args.broker}, dispatchInfo.ResponseCode)
+	// Check the level first to not allocate the fields of a disabled entry.
+	if ce := h.Logger.Check(zap.DebugLevel, "dispatched event to channel"); ce != nil {
+		ce.Write(
+			zap.String("event.id", event.ID()),
+			zap.String("channel.host", channelAddress.URL.Host),
+			zap.String("channel.resolution", resolution))
+	}
+
+	return receiveResult{
+		statusCode:   dispatchInfo.ResponseCode,
+		dispatchTime: dispatchInfo.Duration,
+		channelHost:  channelAddress.URL.Host,
+		broker:       b,
+	}
 }
@@ -18,25 +18,47 @@ package ingress
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"io"
 	nethttp "net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/cloudevents/sdk-go/v2/binding"
 	"github.com/cloudevents/sdk-go/v2/client"
 	"github.com/cloudevents/sdk-go/v2/event"
 	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/tracestate"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clocktesting "k8s.io/utils/clock/testing"
 
+	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 
 	"knative.dev/eventing/pkg/apis/eventing"
 	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
 	"knative.dev/eventing/pkg/broker"
+	"knative.dev/eventing/pkg/eventingtls/eventingtlstesting"
+	"knative.dev/eventing/pkg/kncloudevents"
 	reconcilertesting "knative.dev/pkg/reconciler/testing"
 
 	brokerinformerfake "knative.dev/eventing/pkg/client/injection/informers/eventing/v1/broker/fake"
@@ -63,6 +85,10 @@ func TestHandler_ServeHTTP(t *testing.T) {
 		reporter        StatsReporter
 		defaulter       client.EventDefaulter
 		brokers         []*eventingv1.Broker
+		// channelPath is appended to the channel address of the brokers.
+		channelPath  string
+		pathRewrite  func(target duckv1.Addressable, e *event.Event) string
+		expectedPath string
 	}{
 		{
 			name:       "invalid method PATCH",
@@ -130,7 +156,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 			},
 			statusCode: senderResponseStatusCode,
 			handler:    handler(),
-			reporter:   &mockReporter{StatusCode: senderResponseStatusCode, EventDispatchTimeReported: true},
+			reporter:   &mockReporter{StatusCode: senderResponseStatusCode, EventDispatchTimeReported: true, ChannelResolutionMethod: channelAddressFromAnnotation, ChannelResolved: true},
 			defaulter:  broker.TTLDefaulter(logger, 100),
 			brokers: []*eventingv1.Broker{
 				makeBroker("name", "ns"),
@@ -146,7 +172,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 			},
 			statusCode: senderResponseStatusCode,
 			handler:    handler(),
-			reporter:   &mockReporter{StatusCode: senderResponseStatusCode, EventDispatchTimeReported: true},
+			reporter:   &mockReporter{StatusCode: senderResponseStatusCode, EventDispatchTimeReported: true, ChannelResolutionMethod: channelAddressFromAnnotation, ChannelResolved: true},
 			defaulter:  broker.TTLDefaulter(logger, 100),
 			brokers: []*eventingv1.Broker{
 				makeBroker("name", "ns"),
@@ -207,7 +233,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 			body:       getValidEvent(),
 			statusCode: nethttp.StatusBadRequest,
 			handler:    handler(),
-			reporter:   &mockReporter{StatusCode: nethttp.StatusBadRequest, EventDispatchTimeReported: false},
+			reporter:   &mockReporter{StatusCode: nethttp.StatusBadRequest, EventDispatchTimeReported: false, ChannelResolutionMethod: channelAddressFromAnnotation, ChannelResolved: false},
 			defaulter:  broker.TTLDefaulter(logger, 100),
 			brokers: []*eventingv1.Broker{
 				withUninitializedAnnotations(makeBroker("name", "ns")),
@@ -243,79 +269,3795 @@ func TestHandler_ServeHTTP(t *testing.T) {
 				"Knative-Foo":  []string{"123"},
 				"X-Request-Id": []string{"123"},
 			},
-			reporter:  &mockReporter{StatusCode: senderResponseStatusCode, EventDispatchTimeReported: true},
+			reporter:  &mockReporter{StatusCode: senderResponseStatusCode, EventDispatchTimeReported: true, ChannelResolutionMethod: channelAddressFromAnnotation, ChannelResolved: true},
 			defaulter: broker.TTLDefaulter(logger, 100),
 			brokers: []*eventingv1.Broker{
 				makeBroker("name", "ns"),
 			},
 		},
+		{
+			name:       "resolved channel path",
+			method:     nethttp.MethodPost,
+			uri:        "/ns/name",
+			body:       getValidEvent(),
+			statusCode: senderResponseStatusCode,
+			headers: nethttp.Header{
+				cehttp.ContentType: []string{event.ApplicationCloudEventsJSON},
+			},
+			handler:   &svc{},
+			reporter:  &mockReporter{StatusCode: senderResponseStatusCode, EventDispatchTimeReported: true, ChannelResolutionMethod: channelAddressFromAnnotation, ChannelResolved: true},
+			defaulter: broker.TTLDefaulter(logger, 100),
+			brokers: []*eventingv1.Broker{
+				makeBroker("name", "ns"),
+			},
+			channelPath:  "/ns/channel",
+			expectedPath: "/ns/channel",
+		},
+		{
+			name:       "channel path rewritten by event type",
+			method:     nethttp.MethodPost,
+			uri:        "/ns/name",
+			body:       getValidEvent(),
+			statusCode: senderResponseStatusCode,
+			headers: nethttp.Header{
+				cehttp.ContentType: []string{event.ApplicationCloudEventsJSON},
+			},
+			handler:   &svc{},
+			reporter:  &mockReporter{StatusCode: senderResponseStatusCode, EventDispatchTimeReported: true, ChannelResolutionMethod: channelAddressFromAnnotation, ChannelResolved: true},
+			defaulter: broker.TTLDefaulter(logger, 100),
+			brokers: []*eventingv1.Broker{
+				makeBroker("name", "ns"),
+			},
+			channelPath: "/ns/channel",
+			pathRewrite: func(_ duckv1.Addressable, e *event.Event) string {
+				return "/ingest/" + e.Type()
+			},
+			expectedPath: "/ingest/type",
+		},
+		{
+			name:       "channel path rewritten relative to the resolved path",
+			method:     nethttp.MethodPost,
+			uri:        "/ns/name",
+			body:       getValidEvent(),
+			statusCode: senderResponseStatusCode,
+			headers: nethttp.Header{
+				cehttp.ContentType: []string{event.ApplicationCloudEventsJSON},
+			},
+			handler:   &svc{},
+			reporter:  &mockReporter{StatusCode: senderResponseStatusCode, EventDispatchTimeReported: true, ChannelResolutionMethod: channelAddressFromAnnotation, ChannelResolved: true},
+			defaulter: broker.TTLDefaulter(logger, 100),
+			brokers: []*eventingv1.Broker{
+				makeBroker("name", "ns"),
+			},
+			channelPath: "/ns/channel",
+			pathRewrite: func(target duckv1.Addressable, _ *event.Event) string {
+				return strings.TrimPrefix(target.URL.Path, "/") + "/ingest"
+			},
+			expectedPath: "/ns/channel/ingest",
+		},
+		{
+			name:       "empty rewritten channel path keeps the resolved path",
+			method:     nethttp.MethodPost,
+			uri:        "/ns/name",
+			body:       getValidEvent(),
+			statusCode: senderResponseStatusCode,
+			headers: nethttp.Header{
+				cehttp.ContentType: []string{event.ApplicationCloudEventsJSON},
+			},
+			handler:   &svc{},
+			reporter:  &mockReporter{StatusCode: senderResponseStatusCode, EventDispatchTimeReported: true, ChannelResolutionMethod: channelAddressFromAnnotation, ChannelResolved: true},
+			defaulter: broker.TTLDefaulter(logger, 100),
+			brokers: []*eventingv1.Broker{
+				makeBroker("name", "ns"),
+			},
+			channelPath: "/ns/channel",
+			pathRewrite: func(duckv1.Addressable, *event.Event) string {
+				return ""
+			},
+			expectedPath: "/ns/channel",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			s := httptest.NewServer(tc.handler)
+			defer s.Close()
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(tc.method, tc.uri, tc.body)
+			if tc.headers != nil {
+				request.Header = tc.headers
+			} else {
+				tc.expectedHeaders = nethttp.Header{
+					cehttp.ContentType: []string{event.ApplicationCloudEventsJSON},
+				}
+				request.Header.Add(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			}
+
+			for _, b := range tc.brokers {
+				// Write the channel address in the broker status annotation unless explicitly set to nil
+				if b.Status.Annotations != nil {
+					if _, set := b.Status.Annotations[eventing.BrokerChannelAddressStatusAnnotationKey]; !set {
+						b.Status.Annotations = map[string]string{
+							eventing.BrokerChannelAddressStatusAnnotationKey: s.URL + tc.channelPath,
+						}
+					}
+				}
+				brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+			}
+
+			h, err := NewHandler(logger, &mockReporter{}, tc.defaulter, brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.PathRewrite = tc.pathRewrite
+
+			h.ServeHTTP(recorder, request)
+
+			result := recorder.Result()
+			if result.StatusCode != tc.statusCode {
+				t.Errorf("expected status code %d got %d", tc.statusCode, result.StatusCode)
+			}
+
+			if svc, ok := tc.handler.(*svc); ok {
+				for k, expValue := range tc.expectedHeaders {
+					if v, ok := svc.receivedHeaders[k]; !ok {
+						t.Errorf("expected header %s - %v", k, svc.receivedHeaders)
+					} else if diff := cmp.Diff(expValue, v); diff != "" {
+						t.Error("(-want +got)", diff)
+					}
+				}
+
+				if tc.expectedPath != "" {
+					if svc.receivedPath != tc.expectedPath {
+						t.Errorf("expected path %q got %q", tc.expectedPath, svc.receivedPath)
+					}
+					// The resolved address must not be modified by the rewrite.
+					request := httptest.NewRequest(tc.method, tc.uri, getValidEvent())
+					request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+					h.ServeHTTP(httptest.NewRecorder(), request)
+					if svc.receivedPath != tc.expectedPath {
+						t.Errorf("expected path %q of the second event got %q", tc.expectedPath, svc.receivedPath)
+					}
+				}
+			}
+
+			if diff := cmp.Diff(tc.reporter, h.Reporter, cmpopts.IgnoreUnexported(mockReporter{})); diff != "" {
+				t.Errorf("expected reporter state %+v got %+v - diff %s", tc.reporter, h.Reporter, diff)
+			}
+		})
+	}
+}
+
+func TestHandler_AllowedMethods(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name           string
+		allowedMethods []string
+		method         string
+		statusCode     int
+		allow          string
+	}{
+		{
+			name:       "default, POST",
+			method:     nethttp.MethodPost,
+			statusCode: senderResponseStatusCode,
+			allow:      "POST, OPTIONS",
+		},
+		{
+			name:       "default, PUT",
+			method:     nethttp.MethodPut,
+			statusCode: nethttp.StatusMethodNotAllowed,
+			allow:      "POST, OPTIONS",
+		},
+		{
+			name:           "POST and PUT, POST",
+			allowedMethods: []string{nethttp.MethodPost, nethttp.MethodPut},
+			method:         nethttp.MethodPost,
+			statusCode:     senderResponseStatusCode,
+			allow:          "POST, PUT, OPTIONS",
+		},
+		{
+			name:           "POST and PUT, PUT",
+			allowedMethods: []string{nethttp.MethodPost, nethttp.MethodPut},
+			method:         nethttp.MethodPut,
+			statusCode:     senderResponseStatusCode,
+			allow:          "POST, PUT, OPTIONS",
+		},
+		{
+			name:           "POST and PUT, OPTIONS",
+			allowedMethods: []string{nethttp.MethodPost, nethttp.MethodPut},
+			method:         nethttp.MethodOptions,
+			statusCode:     nethttp.StatusOK,
+			allow:          "POST, PUT, OPTIONS",
+		},
+		{
+			name:           "POST and PUT, PATCH",
+			allowedMethods: []string{nethttp.MethodPost, nethttp.MethodPut},
+			method:         nethttp.MethodPatch,
+			statusCode:     nethttp.StatusMethodNotAllowed,
+			allow:          "POST, PUT, OPTIONS",
+		},
+		{
+			name:           "PUT only, POST",
+			allowedMethods: []string{nethttp.MethodPut},
+			method:         nethttp.MethodPost,
+			statusCode:     nethttp.StatusMethodNotAllowed,
+			allow:          "PUT, OPTIONS",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			s := httptest.NewServer(handler())
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.AllowedMethods = tc.allowedMethods
+
+			request := httptest.NewRequest(tc.method, "/ns/name", getValidEvent())
+			request.Header.Add(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			result := recorder.Result()
+			if result.StatusCode != tc.statusCode {
+				t.Errorf("expected status code %d got %d", tc.statusCode, result.StatusCode)
+			}
+			if got := result.Header.Get("Allow"); got != tc.allow {
+				t.Errorf("expected Allow header %q got %q", tc.allow, got)
+			}
+		})
+	}
+}
+
+func TestHandler_PathPrefix(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name       string
+		pathPrefix string
+		uri        string
+		statusCode int
+	}{
+		{
+			name:       "no prefix",
+			uri:        "/ns/name",
+			statusCode: senderResponseStatusCode,
+		},
+		{
+			name:       "prefixed path",
+			pathPrefix: "/eventing",
+			uri:        "/eventing/ns/name",
+			statusCode: senderResponseStatusCode,
+		},
+		{
+			name:       "missing prefix",
+			pathPrefix: "/eventing",
+			uri:        "/ns/name",
+			statusCode: nethttp.StatusBadRequest,
+		},
+		{
+			name:       "unexpected prefix",
+			uri:        "/eventing/ns/name",
+			statusCode: nethttp.StatusBadRequest,
+		},
+		{
+			name:       "prefixed path with extra segments",
+			pathPrefix: "/eventing",
+			uri:        "/eventing/ns/name/extra",
+			statusCode: nethttp.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			s := httptest.NewServer(handler())
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.PathPrefix = tc.pathPrefix
+
+			request := httptest.NewRequest(nethttp.MethodPost, tc.uri, getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.statusCode {
+				t.Errorf("expected status code %d got %d", tc.statusCode, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_ContentType(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name                string
+		allowedContentTypes []string
+		contentTypeAliases  map[string]string
+		binary              bool
+		contentType         string
+		statusCode          int
+		wantContentType     string
+	}{
+		{
+			name:        "no allowlist",
+			contentType: event.ApplicationCloudEventsJSON,
+			statusCode:  senderResponseStatusCode,
+		},
+		{
+			name:                "allowed structured",
+			allowedContentTypes: []string{event.ApplicationCloudEventsJSON},
+			contentType:         event.ApplicationCloudEventsJSON + "; charset=utf-8",
+			statusCode:          senderResponseStatusCode,
+		},
+		{
+			name:                "allowed binary",
+			allowedContentTypes: []string{event.ApplicationCloudEventsJSON, event.ApplicationJSON},
+			binary:              true,
+			contentType:         event.ApplicationJSON,
+			statusCode:          senderResponseStatusCode,
+			wantContentType:     event.ApplicationJSON,
+		},
+		{
+			name:                "disallowed binary",
+			allowedContentTypes: []string{event.ApplicationCloudEventsJSON, event.ApplicationJSON},
+			binary:              true,
+			contentType:         "application/vnd.vendor+json",
+			statusCode:          nethttp.StatusUnsupportedMediaType,
+		},
+		{
+			name:                "alias normalized",
+			allowedContentTypes: []string{event.ApplicationCloudEventsJSON, event.ApplicationJSON},
+			contentTypeAliases:  map[string]string{"application/vnd.vendor+json": event.ApplicationJSON},
+			binary:              true,
+			contentType:         "Application/Vnd.Vendor+JSON; charset=utf-8",
+			statusCode:          senderResponseStatusCode,
+			wantContentType:     "application/json; charset=utf-8",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			receiver := &svc{}
+			s := httptest.NewServer(receiver)
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.AllowedContentTypes = tc.allowedContentTypes
+			h.ContentTypeAliases = tc.contentTypeAliases
+
+			var request *nethttp.Request
+			if tc.binary {
+				request = httptest.NewRequest(nethttp.MethodPost, "/ns/name", strings.NewReader(`{"hello":"world"}`))
+				request.Header.Set("Ce-Specversion", "1.0")
+				request.Header.Set("Ce-Id", "1234")
+				request.Header.Set("Ce-Type", "type")
+				request.Header.Set("Ce-Source", "source")
+			} else {
+				request = httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			}
+			request.Header.Set(cehttp.ContentType, tc.contentType)
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.statusCode {
+				t.Fatalf("expected status code %d got %d", tc.statusCode, recorder.Code)
+			}
+			if tc.statusCode == nethttp.StatusUnsupportedMediaType {
+				if receiver.receivedHeaders != nil {
+					t.Errorf("expected no dispatch, got headers %v", receiver.receivedHeaders)
+				}
+				return
+			}
+			if receiver.receivedHeaders == nil {
+				t.Fatal("expected the event to be dispatched")
+			}
+			if tc.wantContentType == "" {
+				return
+			}
+			if got := receiver.receivedHeaders.Get(cehttp.ContentType); got != tc.wantContentType {
+				t.Errorf("expected dispatched Content-Type %q got %q", tc.wantContentType, got)
+			}
+		})
+	}
+}
+
+func TestHandler_ChannelAddressesResolver(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name    string
+		weights []int32
+		want    []int
+	}{
+		{
+			name:    "single address",
+			weights: []int32{1},
+			want:    []int{0, 0, 4},
+		},
+		{
+			name:    "spread across addresses",
+			weights: []int32{1, 1},
+			want:    []int{2, 2, 0},
+		},
+		{
+			name:    "weighted",
+			weights: []int32{3, 1},
+			want:    []int{3, 1, 0},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			// The last receiver is the broker channel address.
+			counts := make([]int, len(tc.want))
+			servers := make([]*httptest.Server, len(tc.want))
+			for i := range servers {
+				i := i
+				servers[i] = httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, _ *nethttp.Request) {
+					counts[i]++
+					w.WriteHeader(senderResponseStatusCode)
+				}))
+				defer servers[i].Close()
+			}
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: servers[len(servers)-1].URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.ChannelAddressesResolver = func(b *eventingv1.Broker) ([]WeightedAddress, error) {
+				addresses := make([]WeightedAddress, 0, len(tc.weights))
+				for i, w := range tc.weights {
+					u, _ := apis.ParseURL(servers[i].URL)
+					addresses = append(addresses, WeightedAddress{Address: duckv1.Addressable{URL: u}, Weight: w})
+				}
+				return addresses, nil
+			}
+
+			for i := 0; i < 4; i++ {
+				request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+				request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+				recorder := httptest.NewRecorder()
+				h.ServeHTTP(recorder, request)
+				if recorder.Code != senderResponseStatusCode {
+					t.Fatalf("expected status code %d got %d", senderResponseStatusCode, recorder.Code)
+				}
+			}
+
+			if diff := cmp.Diff(tc.want, counts); diff != "" {
+				t.Errorf("unexpected dispatched events per address (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestHandler_RoutingOverride(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name                 string
+		allowRoutingOverride bool
+		extensionName        string
+		// extension is the routing override extension set on the event, "canary" is replaced
+		// by the canary receiver URL.
+		extension    map[string]string
+		allowCanary  bool
+		wantCanary   bool
+		wantReporter *mockReporter
+	}{
+		{
+			name:                 "allowed override",
+			allowRoutingOverride: true,
+			extension:            map[string]string{"routingoverride": "canary"},
+			allowCanary:          true,
+			wantCanary:           true,
+			wantReporter:         &mockReporter{ChannelResolutionMethod: channelAddressFromRoutingOverride, ChannelResolved: true},
+		},
+		{
+			name:                 "custom extension",
+			allowRoutingOverride: true,
+			extensionName:        "canarytarget",
+			extension:            map[string]string{"canarytarget": "canary"},
+			allowCanary:          true,
+			wantCanary:           true,
+			wantReporter:         &mockReporter{ChannelResolutionMethod: channelAddressFromRoutingOverride, ChannelResolved: true},
+		},
+		{
+			name:                 "target not allowed by the broker",
+			allowRoutingOverride: true,
+			extension:            map[string]string{"routingoverride": "canary"},
+			wantReporter:         &mockReporter{ChannelResolutionMethod: channelAddressFromRoutingOverride},
+		},
+		{
+			name:                 "invalid target",
+			allowRoutingOverride: true,
+			extension:            map[string]string{"routingoverride": "not-a-url"},
+			allowCanary:          true,
+			wantReporter:         &mockReporter{ChannelResolutionMethod: channelAddressFromRoutingOverride},
+		},
+		{
+			name:                 "no override",
+			allowRoutingOverride: true,
+			allowCanary:          true,
+			wantReporter:         &mockReporter{ChannelResolutionMethod: channelAddressFromAnnotation, ChannelResolved: true},
+		},
+		{
+			name:         "override disabled",
+			extension:    map[string]string{"routingoverride": "canary"},
+			allowCanary:  true,
+			wantReporter: &mockReporter{ChannelResolutionMethod: channelAddressFromAnnotation, ChannelResolved: true},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			channel := &svc{}
+			channelServer := httptest.NewServer(channel)
+			defer channelServer.Close()
+			canary := &svc{}
+			canaryServer := httptest.NewServer(canary)
+			defer canaryServer.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: channelServer.URL,
+			}
+			if tc.allowCanary {
+				b.Annotations = map[string]string{
+					eventing.BrokerRoutingOverrideTargetsAnnotationKey: "http://other.example.com, " + canaryServer.URL,
+				}
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			reporter := &mockReporter{}
+			h, err := NewHandler(logger, reporter, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.AllowRoutingOverride = tc.allowRoutingOverride
+			h.RoutingOverrideExtension = tc.extensionName
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", strings.NewReader(`{"hello":"world"}`))
+			request.Header.Set(cehttp.ContentType, event.ApplicationJSON)
+			request.Header.Set("Ce-Specversion", "1.0")
+			request.Header.Set("Ce-Id", "1234")
+			request.Header.Set("Ce-Type", "type")
+			request.Header.Set("Ce-Source", "source")
+			for k, v := range tc.extension {
+				if v == "canary" {
+					v = canaryServer.URL
+				}
+				request.Header.Set("Ce-"+k, v)
+			}
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != senderResponseStatusCode {
+				t.Fatalf("expected status code %d got %d", senderResponseStatusCode, recorder.Code)
+			}
+			if gotCanary := canary.receivedHeaders != nil; gotCanary != tc.wantCanary {
+				t.Errorf("expected event dispatched to the canary %v, got %v", tc.wantCanary, gotCanary)
+			}
+			if gotChannel := channel.receivedHeaders != nil; gotChannel == tc.wantCanary {
+				t.Errorf("expected event dispatched to the channel %v, got %v", !tc.wantCanary, gotChannel)
+			}
+			tc.wantReporter.StatusCode = senderResponseStatusCode
+			tc.wantReporter.EventDispatchTimeReported = true
+			if diff := cmp.Diff(tc.wantReporter, reporter, cmpopts.IgnoreUnexported(mockReporter{})); diff != "" {
+				t.Errorf("expected reporter state (-want, +got) %s", diff)
+			}
+		})
+	}
+}
+
+func TestHandler_DispatchOutcomeHeaders(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name                   string
+		dispatchOutcomeHeaders bool
+		uri                    string
+		defaulter              client.EventDefaulter
+		statusCode             int
+		wantHeaders            bool
+	}{
+		{
+			name:                   "enabled",
+			dispatchOutcomeHeaders: true,
+			uri:                    "/ns/name",
+			defaulter:              broker.TTLDefaulter(logger, 100),
+			statusCode:             senderResponseStatusCode,
+			wantHeaders:            true,
+		},
+		{
+			name:                   "disabled",
+			dispatchOutcomeHeaders: false,
+			uri:                    "/ns/name",
+			defaulter:              broker.TTLDefaulter(logger, 100),
+			statusCode:             senderResponseStatusCode,
+		},
+		{
+			name:                   "rejected event",
+			dispatchOutcomeHeaders: true,
+			uri:                    "/ns/name",
+			statusCode:             nethttp.StatusBadRequest,
+		},
+		{
+			name:                   "malformed request URI",
+			dispatchOutcomeHeaders: true,
+			uri:                    "/knative/ns/name",
+			defaulter:              broker.TTLDefaulter(logger, 100),
+			statusCode:             nethttp.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			s := httptest.NewServer(handler())
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, tc.defaulter, brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.DispatchOutcomeHeaders = tc.dispatchOutcomeHeaders
+
+			request := httptest.NewRequest(nethttp.MethodPost, tc.uri, getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.statusCode {
+				t.Fatalf("expected status code %d got %d", tc.statusCode, recorder.Code)
+			}
+
+			duration := recorder.Header().Get(dispatchDurationHeader)
+			host := recorder.Header().Get(channelHostHeader)
+			if !tc.wantHeaders {
+				if duration != "" || host != "" {
+					t.Errorf("expected no dispatch outcome headers, got %s=%q %s=%q", dispatchDurationHeader, duration, channelHostHeader, host)
+				}
+				return
+			}
+
+			if d, err := time.ParseDuration(duration); err != nil || d <= 0 {
+				t.Errorf("expected a positive %s header, got %q", dispatchDurationHeader, duration)
+			}
+			if want := strings.TrimPrefix(s.URL, "http://"); host != want {
+				t.Errorf("expected %s header %q, got %q", channelHostHeader, want, host)
+			}
+		})
+	}
+}
+
+func TestHandler_BrokerStatusHeaders(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name                   string
+		brokerStatusHeaders    bool
+		generation             int64
+		observedGeneration     int64
+		ready                  corev1.ConditionStatus
+		channelAddress         bool
+		statusCode             int
+		wantObservedGeneration string
+		wantReady              string
+	}{
+		{
+			name:                   "up to date broker",
+			brokerStatusHeaders:    true,
+			generation:             2,
+			observedGeneration:     2,
+			ready:                  corev1.ConditionTrue,
+			channelAddress:         true,
+			statusCode:             senderResponseStatusCode,
+			wantObservedGeneration: "2",
+			wantReady:              "True",
+		},
+		{
+			name:                   "status lagging spec",
+			brokerStatusHeaders:    true,
+			generation:             3,
+			observedGeneration:     2,
+			ready:                  corev1.ConditionUnknown,
+			channelAddress:         true,
+			statusCode:             senderResponseStatusCode,
+			wantObservedGeneration: "2",
+			wantReady:              "Unknown",
+		},
+		{
+			name:                   "no channel address",
+			brokerStatusHeaders:    true,
+			generation:             1,
+			observedGeneration:     0,
+			statusCode:             nethttp.StatusBadRequest,
+			wantObservedGeneration: "0",
+			wantReady:              "Unknown",
+		},
+		{
+			name:               "disabled",
+			generation:         2,
+			observedGeneration: 2,
+			ready:              corev1.ConditionTrue,
+			channelAddress:     true,
+			statusCode:         senderResponseStatusCode,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			s := httptest.NewServer(handler())
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Generation = tc.generation
+			b.Status.ObservedGeneration = tc.observedGeneration
+			if tc.ready != "" {
+				b.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionReady, Status: tc.ready}}
+			}
+			if tc.channelAddress {
+				b.Status.Annotations = map[string]string{
+					eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+				}
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.BrokerStatusHeaders = tc.brokerStatusHeaders
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.statusCode {
+				t.Fatalf("expected status code %d got %d", tc.statusCode, recorder.Code)
+			}
+			if got := recorder.Header().Get(brokerObservedGenerationHeader); got != tc.wantObservedGeneration {
+				t.Errorf("expected %s header %q, got %q", brokerObservedGenerationHeader, tc.wantObservedGeneration, got)
+			}
+			if got := recorder.Header().Get(brokerReadyHeader); got != tc.wantReady {
+				t.Errorf("expected %s header %q, got %q", brokerReadyHeader, tc.wantReady, got)
+			}
+		})
+	}
+}
+
+func TestHandler_ResolvedBrokerHeader(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name                 string
+		resolvedBrokerHeader bool
+		path                 string
+		contentType          string
+		statusCode           int
+		want                 string
+	}{
+		{
+			name:                 "dispatched event",
+			resolvedBrokerHeader: true,
+			path:                 "/ns/name",
+			contentType:          event.ApplicationCloudEventsJSON,
+			statusCode:           senderResponseStatusCode,
+			want:                 "ns/name",
+		},
+		{
+			name:                 "unknown broker",
+			resolvedBrokerHeader: true,
+			path:                 "/ns/other",
+			contentType:          event.ApplicationCloudEventsJSON,
+			statusCode:           nethttp.StatusBadRequest,
+			want:                 "ns/other",
+		},
+		{
+			name:                 "rejected after parsing the broker",
+			resolvedBrokerHeader: true,
+			path:                 "/ns/name",
+			contentType:          "text/plain",
+			statusCode:           nethttp.StatusUnsupportedMediaType,
+			want:                 "ns/name",
+		},
+		{
+			name:                 "malformed path",
+			resolvedBrokerHeader: true,
+			path:                 "/ns/name/extra",
+			contentType:          event.ApplicationCloudEventsJSON,
+			statusCode:           nethttp.StatusBadRequest,
+		},
+		{
+			name:        "disabled",
+			path:        "/ns/name",
+			contentType: event.ApplicationCloudEventsJSON,
+			statusCode:  senderResponseStatusCode,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			s := httptest.NewServer(handler())
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.ResolvedBrokerHeader = tc.resolvedBrokerHeader
+			h.AllowedContentTypes = []string{event.ApplicationCloudEventsJSON}
+
+			request := httptest.NewRequest(nethttp.MethodPost, tc.path, getValidEvent())
+			request.Header.Set(cehttp.ContentType, tc.contentType)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.statusCode {
+				t.Fatalf("expected status code %d got %d", tc.statusCode, recorder.Code)
+			}
+			if got := recorder.Header().Get(resolvedBrokerHeader); got != tc.want {
+				t.Errorf("expected %s header %q, got %q", resolvedBrokerHeader, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestHandler_EventSpans(t *testing.T) {
+	exporter := &spanRecorder{}
+	trace.RegisterExporter(exporter)
+	defer trace.UnregisterExporter(exporter)
+
+	ctx, _ := reconcilertesting.SetupFakeContext(t)
+	logger := zap.NewNop()
+
+	s := httptest.NewServer(handler())
+	defer s.Close()
+
+	b := makeBroker("name", "ns")
+	b.Status.Annotations = map[string]string{
+		eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+	}
+	brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+	h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+	if err != nil {
+		t.Fatal("Unable to create receiver:", err)
+	}
+
+	// The request span, e.g. the span of a batch.
+	requestCtx, requestSpan := trace.StartSpan(context.Background(), "request", trace.WithSampler(trace.AlwaysSample()))
+
+	validRequest := func() *nethttp.Request {
+		request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+		request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+		return request
+	}
+	// A binary event without source, failing validation after being extracted.
+	invalidRequest := func() *nethttp.Request {
+		request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", strings.NewReader(""))
+		request.Header.Set("Ce-Specversion", "1.0")
+		request.Header.Set("Ce-Id", "1234")
+		request.Header.Set("Ce-Type", "type")
+		return request
+	}
+
+	requests := []*nethttp.Request{validRequest(), invalidRequest(), validRequest()}
+	wantStatus := []int32{trace.StatusCodeOK, trace.StatusCodeInvalidArgument, trace.StatusCodeOK}
+	for _, request := range requests {
+		h.ServeHTTP(httptest.NewRecorder(), request.WithContext(requestCtx))
+	}
+	requestSpan.End()
+
+	spans := exporter.children(requestSpan.SpanContext())
+	if len(spans) != len(requests) {
+		t.Fatalf("expected %d event spans, got %d", len(requests), len(spans))
+	}
+	for i, span := range spans {
+		if span.Name != "broker:name.ns" {
+			t.Errorf("unexpected span name %q", span.Name)
+		}
+		if span.Attributes["messaging.message_id"] != "1234" {
+			t.Errorf("expected message id attribute, got %v", span.Attributes)
+		}
+		if span.Status.Code != wantStatus[i] {
+			t.Errorf("span %d: expected status code %d, got %d", i, wantStatus[i], span.Status.Code)
+		}
+	}
+}
+
+func TestHandler_TraceParent(t *testing.T) {
+	exporter := &spanRecorder{}
+	trace.RegisterExporter(exporter)
+	defer trace.UnregisterExporter(exporter)
+
+	ctx, _ := reconcilertesting.SetupFakeContext(t)
+	logger := zap.NewNop()
+
+	receiver := &svc{}
+	s := httptest.NewServer(receiver)
+	defer s.Close()
+
+	b := makeBroker("name", "ns")
+	b.Status.Annotations = map[string]string{
+		eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+	}
+	brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+	h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+	if err != nil {
+		t.Fatal("Unable to create receiver:", err)
+	}
+
+	t.Run("set from the event span", func(t *testing.T) {
+		requestCtx, requestSpan := trace.StartSpan(context.Background(), "request", trace.WithSampler(trace.AlwaysSample()))
+
+		request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+		request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+		h.ServeHTTP(httptest.NewRecorder(), request.WithContext(requestCtx))
+		requestSpan.End()
+
+		spans := exporter.children(requestSpan.SpanContext())
+		if len(spans) != 1 {
+			t.Fatalf("expected 1 event span, got %d", len(spans))
+		}
+		want := fmt.Sprintf("00-%s-%s-01", spans[0].TraceID, spans[0].SpanID)
+		if got := receiver.receivedHeaders.Get("Ce-Traceparent"); got != want {
+			t.Errorf("expected traceparent extension %q, got %q", want, got)
+		}
+	})
+
+	t.Run("tracestate from the request span", func(t *testing.T) {
+		state, err := tracestate.New(nil, tracestate.Entry{Key: "vendor", Value: "value"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		requestCtx, requestSpan := trace.StartSpanWithRemoteParent(context.Background(), "request", trace.SpanContext{
+			TraceID:      trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+			SpanID:       trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+			TraceOptions: 1,
+			Tracestate:   state,
+		})
+
+		request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+		request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+		h.ServeHTTP(httptest.NewRecorder(), request.WithContext(requestCtx))
+		requestSpan.End()
+
+		if got := receiver.receivedHeaders.Get("Ce-Traceparent"); !strings.HasPrefix(got, "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+			t.Errorf("expected traceparent extension in the request trace, got %q", got)
+		}
+		if got := receiver.receivedHeaders.Get("Ce-Tracestate"); got != "vendor=value" {
+			t.Errorf("expected tracestate extension %q, got %q", "vendor=value", got)
+		}
+	})
+
+	t.Run("kept when present", func(t *testing.T) {
+		traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		e := event.New()
+		e.SetType("type")
+		e.SetSource("source")
+		e.SetID("1234")
+		e.SetExtension("traceparent", traceParent)
+		body, _ := e.MarshalJSON()
+
+		request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", bytes.NewReader(body))
+		request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+		h.ServeHTTP(httptest.NewRecorder(), request)
+
+		if got := receiver.receivedHeaders.Get("Ce-Traceparent"); got != traceParent {
+			t.Errorf("expected traceparent extension %q, got %q", traceParent, got)
+		}
+	})
+}
+
+func TestHandler_Sampler(t *testing.T) {
+	traceID := trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	parentSpanID := trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}
+
+	tt := []struct {
+		name          string
+		sample        bool
+		traceParent   string
+		wantConsulted bool
+		wantRecorded  bool
+	}{
+		{
+			name:          "sampled in",
+			sample:        true,
+			wantConsulted: true,
+			wantRecorded:  true,
+		},
+		{
+			name:          "sampled out",
+			wantConsulted: true,
+		},
+		{
+			name:         "sampled traceparent",
+			traceParent:  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantRecorded: true,
+		},
+		{
+			name:        "not sampled traceparent",
+			sample:      true,
+			traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter := &spanRecorder{}
+			trace.RegisterExporter(exporter)
+			defer trace.UnregisterExporter(exporter)
+
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+			logger := zap.NewNop()
+
+			s := httptest.NewServer(handler())
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			consulted := false
+			h.Sampler = func(trace.SamplingParameters) trace.SamplingDecision {
+				consulted = true
+				return trace.SamplingDecision{Sample: tc.sample}
+			}
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			if tc.traceParent != "" {
+				request.Header.Set("traceparent", tc.traceParent)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != senderResponseStatusCode {
+				t.Fatalf("expected status code %d got %d", senderResponseStatusCode, recorder.Code)
+			}
+			if consulted != tc.wantConsulted {
+				t.Errorf("expected sampler consulted %v, got %v", tc.wantConsulted, consulted)
+			}
+			var spans []*trace.SpanData
+			exporter.lock.Lock()
+			for _, span := range exporter.spans {
+				if span.Name == "broker:name.ns" {
+					spans = append(spans, span)
+				}
+			}
+			exporter.lock.Unlock()
+			if recorded := len(spans) == 1; recorded != tc.wantRecorded {
+				t.Fatalf("expected event span recorded %v, got %d event spans", tc.wantRecorded, len(spans))
+			}
+			if tc.wantRecorded && tc.traceParent != "" {
+				if spans[0].TraceID != traceID || spans[0].ParentSpanID != parentSpanID {
+					t.Errorf("expected the span to continue the traceparent trace, got trace %s and parent %s", spans[0].TraceID, spans[0].ParentSpanID)
+				}
+			}
+		})
+	}
+}
+
+// spanRecorder records the exported spans.
+func TestHandler_TraceRequestReceive(t *testing.T) {
+	tt := []struct {
+		name                string
+		traceRequestReceive bool
+		wantAnnotation      bool
+	}{
+		{
+			name:                "enabled",
+			traceRequestReceive: true,
+			wantAnnotation:      true,
+		},
+		{
+			name: "disabled",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter := &spanRecorder{}
+			trace.RegisterExporter(exporter)
+			defer trace.UnregisterExporter(exporter)
+
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+			logger := zap.NewNop()
+
+			s := httptest.NewServer(handler())
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.TraceRequestReceive = tc.traceRequestReceive
+
+			requestCtx, requestSpan := trace.StartSpan(context.Background(), "request", trace.WithSampler(trace.AlwaysSample()))
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request.WithContext(requestCtx))
+			requestSpan.End()
+
+			if recorder.Code != senderResponseStatusCode {
+				t.Fatalf("expected status code %d got %d", senderResponseStatusCode, recorder.Code)
+			}
+			spans := exporter.children(requestSpan.SpanContext())
+			if len(spans) != 1 {
+				t.Fatalf("expected a single event span, got %d", len(spans))
+			}
+			span := spans[0]
+			if span.Name != "broker:name.ns" {
+				t.Errorf("unexpected span name %q", span.Name)
+			}
+			if span.Attributes["messaging.message_id"] != "1234" {
+				t.Errorf("expected message id attribute, got %v", span.Attributes)
+			}
+			annotated := false
+			for _, a := range span.Annotations {
+				if a.Message == eventExtractedAnnotation {
+					annotated = true
+					if a.Time.Before(span.StartTime) || a.Time.After(span.EndTime) {
+						t.Errorf("expected the annotation within the span, got %v not in [%v, %v]", a.Time, span.StartTime, span.EndTime)
+					}
+				}
+			}
+			if annotated != tc.wantAnnotation {
+				t.Errorf("expected event extracted annotation %v, got %v", tc.wantAnnotation, span.Annotations)
+			}
+		})
+	}
+}
+
+type spanRecorder struct {
+	lock  sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *trace.SpanData) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+// children returns the ended spans whose parent is the given span, in the order they ended.
+func (r *spanRecorder) children(parent trace.SpanContext) []*trace.SpanData {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var children []*trace.SpanData
+	for _, s := range r.spans {
+		if s.TraceID == parent.TraceID && s.ParentSpanID == parent.SpanID {
+			children = append(children, s)
+		}
+	}
+	return children
+}
+
+func TestHandler_BadRequestBody(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name      string
+		headers   map[string]string
+		body      string
+		wantError string
+	}{
+		{
+			name: "missing type",
+			headers: map[string]string{
+				"Ce-Specversion": "1.0",
+				"Ce-Id":          "1234",
+				"Ce-Source":      "source",
+			},
+			wantError: "type",
+		},
+		{
+			name: "missing source",
+			headers: map[string]string{
+				"Ce-Specversion": "1.0",
+				"Ce-Id":          "1234",
+				"Ce-Type":        "type",
+			},
+			wantError: "source",
+		},
+		{
+			name: "unknown specversion",
+			headers: map[string]string{
+				cehttp.ContentType: event.ApplicationCloudEventsJSON,
+			},
+			body:      `{"specversion":"0.1","id":"1234","type":"type","source":"source"}`,
+			wantError: "specversion: unknown value: 0.1",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			receiver := &svc{}
+			s := httptest.NewServer(receiver)
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+
+			body := tc.body
+			if body == "" {
+				body = `{"hello":"world"}`
+			}
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", strings.NewReader(body))
+			request.Header.Set(cehttp.ContentType, event.ApplicationJSON)
+			for k, v := range tc.headers {
+				request.Header.Set(k, v)
+			}
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != nethttp.StatusBadRequest {
+				t.Fatalf("expected status code %d got %d", nethttp.StatusBadRequest, recorder.Code)
+			}
+			if got := recorder.Header().Get(cehttp.ContentType); got != "application/json" {
+				t.Errorf("expected Content-Type application/json got %q", got)
+			}
+			var response errorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to decode body %q: %v", recorder.Body.String(), err)
+			}
+			if !strings.Contains(response.Error, tc.wantError) {
+				t.Errorf("expected error to mention %q got %q", tc.wantError, response.Error)
+			}
+			if receiver.receivedHeaders != nil {
+				t.Errorf("expected no dispatch, got headers %v", receiver.receivedHeaders)
+			}
+		})
+	}
+}
+
+func TestHandler_ValidationMode(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name       string
+		mode       ValidationMode
+		body       string
+		headers    map[string]string
+		wantStatus int
+		wantSource string
+	}{
+		{
+			name:       "valid event, strict",
+			mode:       ValidationModeStrict,
+			body:       `{"specversion":"1.0","id":"1234","type":"type","source":"source"}`,
+			wantStatus: nethttp.StatusAccepted,
+		},
+		{
+			name:       "blank subject, default",
+			body:       `{"specversion":"1.0","id":"1234","type":"type","source":"source","subject":" "}`,
+			wantStatus: nethttp.StatusBadRequest,
+		},
+		{
+			name:       "blank subject, strict",
+			mode:       ValidationModeStrict,
+			body:       `{"specversion":"1.0","id":"1234","type":"type","source":"source","subject":" "}`,
+			wantStatus: nethttp.StatusBadRequest,
+		},
+		{
+			name:       "blank subject, lenient",
+			mode:       ValidationModeLenient,
+			body:       `{"specversion":"1.0","id":"1234","type":"type","source":"source","subject":" "}`,
+			wantStatus: nethttp.StatusAccepted,
+		},
+		{
+			name:       "relative dataschema, strict",
+			mode:       ValidationModeStrict,
+			body:       `{"specversion":"1.0","id":"1234","type":"type","source":"source","dataschema":"schemas/v1"}`,
+			wantStatus: nethttp.StatusBadRequest,
+		},
+		{
+			name:       "relative dataschema, lenient",
+			mode:       ValidationModeLenient,
+			body:       `{"specversion":"1.0","id":"1234","type":"type","source":"source","dataschema":"schemas/v1"}`,
+			wantStatus: nethttp.StatusAccepted,
+		},
+		{
+			name:       "missing id, lenient",
+			mode:       ValidationModeLenient,
+			body:       `{"specversion":"1.0","type":"type","source":"source"}`,
+			wantStatus: nethttp.StatusBadRequest,
+		},
+		{
+			name:       "missing type, lenient",
+			mode:       ValidationModeLenient,
+			body:       `{"specversion":"1.0","id":"1234","source":"source"}`,
+			wantStatus: nethttp.StatusBadRequest,
+		},
+		{
+			name:       "non URI-reference source, strict",
+			mode:       ValidationModeStrict,
+			body:       `{"specversion":"1.0","id":"1234","type":"type","source":"10.0.0.1:8080"}`,
+			wantStatus: nethttp.StatusBadRequest,
+		},
+		{
+			name:       "non URI-reference source, lenient",
+			mode:       ValidationModeLenient,
+			body:       `{"specversion":"1.0","id":"1234","type":"type","source":"10.0.0.1:8080","data":{"a":1}}`,
+			wantStatus: nethttp.StatusAccepted,
+			wantSource: "10.0.0.1:8080",
+		},
+		{
+			name: "binary non URI-reference source, strict",
+			mode: ValidationModeStrict,
+			headers: map[string]string{
+				"Ce-Specversion": "1.0",
+				"Ce-Id":          "1234",
+				"Ce-Type":        "type",
+				"Ce-Source":      "10.0.0.1:8080",
+			},
+			wantStatus: nethttp.StatusBadRequest,
+		},
+		{
+			name: "binary non URI-reference source, lenient",
+			mode: ValidationModeLenient,
+			headers: map[string]string{
+				"Ce-Specversion": "1.0",
+				"Ce-Id":          "1234",
+				"Ce-Type":        "type",
+				"Ce-Source":      "10.0.0.1:8080",
+			},
+			wantStatus: nethttp.StatusAccepted,
+			wantSource: "10.0.0.1:8080",
+		},
+		{
+			name:       "missing source, lenient",
+			mode:       ValidationModeLenient,
+			body:       `{"specversion":"1.0","id":"1234","type":"type"}`,
+			wantStatus: nethttp.StatusBadRequest,
+		},
+		{
+			name:       "missing type and blank subject, lenient",
+			mode:       ValidationModeLenient,
+			body:       `{"specversion":"1.0","id":"1234","source":"source","subject":" "}`,
+			wantStatus: nethttp.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			receiver := &svc{}
+			s := httptest.NewServer(receiver)
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.ValidationMode = tc.mode
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", strings.NewReader(tc.body))
+			if tc.headers == nil {
+				request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			}
+			for k, v := range tc.headers {
+				request.Header.Set(k, v)
+			}
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatus {
+				t.Errorf("expected status code %d got %d: %s", tc.wantStatus, recorder.Code, recorder.Body.String())
+			}
+			if tc.wantSource != "" {
+				if got := receiver.receivedHeaders.Get("Ce-Source"); got != tc.wantSource {
+					t.Errorf("expected source %q got %q", tc.wantSource, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_DatalessEvent(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name                string
+		headers             map[string]string
+		body                string
+		allowedContentTypes []string
+	}{
+		{
+			name: "binary",
+			headers: map[string]string{
+				"Ce-Specversion": "1.0",
+				"Ce-Id":          "1234",
+				"Ce-Type":        "type",
+				"Ce-Source":      "source",
+			},
+		},
+		{
+			name: "binary with allowed content types",
+			headers: map[string]string{
+				"Ce-Specversion": "1.0",
+				"Ce-Id":          "1234",
+				"Ce-Type":        "type",
+				"Ce-Source":      "source",
+			},
+			allowedContentTypes: []string{event.ApplicationJSON},
+		},
+		{
+			name: "structured",
+			headers: map[string]string{
+				cehttp.ContentType: event.ApplicationCloudEventsJSON,
+			},
+			body: `{"specversion":"1.0","id":"1234","type":"type","source":"source"}`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			var receivedHeaders nethttp.Header
+			var receivedBody []byte
+			s := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, req *nethttp.Request) {
+				receivedHeaders = req.Header
+				receivedBody, _ = io.ReadAll(req.Body)
+				w.WriteHeader(senderResponseStatusCode)
+			}))
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.AllowedContentTypes = tc.allowedContentTypes
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", strings.NewReader(tc.body))
+			for k, v := range tc.headers {
+				request.Header.Set(k, v)
+			}
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != senderResponseStatusCode {
+				t.Fatalf("expected status code %d got %d: %s", senderResponseStatusCode, recorder.Code, recorder.Body.String())
+			}
+			if receivedHeaders == nil {
+				t.Fatal("expected the event to be dispatched")
+			}
+			for k, v := range map[string]string{"Ce-Id": "1234", "Ce-Type": "type", "Ce-Source": "source", "Ce-Specversion": "1.0"} {
+				if got := receivedHeaders.Get(k); got != v {
+					t.Errorf("expected header %s %q got %q", k, v, got)
+				}
+			}
+			if got := receivedHeaders.Get(cehttp.ContentType); got != "" {
+				t.Errorf("expected no Content-Type got %q", got)
+			}
+			if len(receivedBody) != 0 {
+				t.Errorf("expected no data got %q", receivedBody)
+			}
+		})
+	}
+}
+
+func TestHandler_DefaulterFailure(t *testing.T) {
+	logger := zap.NewNop()
+
+	panicking := func(context.Context, event.Event) event.Event {
+		panic("defaulter bug")
+	}
+	invalidating := func(_ context.Context, e event.Event) event.Event {
+		e.SetSource("")
+		return e
+	}
+
+	tt := []struct {
+		name         string
+		defaulter    client.EventDefaulter
+		failOpen     bool
+		wantStatus   int
+		wantReported bool
+	}{
+		{
+			name:       "valid defaulted event",
+			defaulter:  broker.TTLDefaulter(logger, 100),
+			wantStatus: senderResponseStatusCode,
+		},
+		{
+			name:         "panicking defaulter",
+			defaulter:    panicking,
+			wantStatus:   nethttp.StatusInternalServerError,
+			wantReported: true,
+		},
+		{
+			name:         "invalid defaulted event",
+			defaulter:    invalidating,
+			wantStatus:   nethttp.StatusInternalServerError,
+			wantReported: true,
+		},
+		{
+			name:         "panicking defaulter fail open",
+			defaulter:    panicking,
+			failOpen:     true,
+			wantStatus:   senderResponseStatusCode,
+			wantReported: true,
+		},
+		{
+			name:         "invalid defaulted event fail open",
+			defaulter:    invalidating,
+			failOpen:     true,
+			wantStatus:   senderResponseStatusCode,
+			wantReported: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			receiver := &svc{}
+			s := httptest.NewServer(receiver)
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			reporter := &mockReporter{}
+			h, err := NewHandler(logger, reporter, tc.defaulter, brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.FailOpen = tc.failOpen
+
+			// The original event carries a TTL so that it can be dispatched without defaulting.
+			e := event.New()
+			e.SetType("type")
+			e.SetSource("source")
+			e.SetID("1234")
+			_ = broker.SetTTL(e.Context, 10)
+			body, _ := e.MarshalJSON()
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", bytes.NewReader(body))
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatus {
+				t.Errorf("expected status code %d got %d", tc.wantStatus, recorder.Code)
+			}
+			if reporter.DefaulterErrorReported != tc.wantReported {
+				t.Errorf("expected defaulter error reported %v got %v", tc.wantReported, reporter.DefaulterErrorReported)
+			}
+			if dispatched := receiver.receivedHeaders != nil; dispatched != (tc.wantStatus == senderResponseStatusCode) {
+				t.Errorf("unexpected dispatch of the event, dispatched %v", dispatched)
+			}
+		})
+	}
+}
+
+func TestHandler_NotAddressableRetry(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name                 string
+		retryTimeout         time.Duration
+		brokerName           string
+		addressableAfter     time.Duration
+		statusCode           int
+		brokerNotAddressable bool
+	}{
+		{
+			name:       "disabled",
+			brokerName: "name",
+			statusCode: nethttp.StatusBadRequest,
+		},
+		{
+			name:                 "not addressable in time",
+			retryTimeout:         100 * time.Millisecond,
+			brokerName:           "name",
+			statusCode:           nethttp.StatusServiceUnavailable,
+			brokerNotAddressable: true,
+		},
+		{
+			name:             "becomes addressable",
+			retryTimeout:     5 * time.Second,
+			brokerName:       "name",
+			addressableAfter: 100 * time.Millisecond,
+			statusCode:       senderResponseStatusCode,
+		},
+		{
+			name:         "broker not found",
+			retryTimeout: 5 * time.Second,
+			brokerName:   "other",
+			statusCode:   nethttp.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			s := httptest.NewServer(handler())
+			defer s.Close()
+
+			b := withUninitializedAnnotations(makeBroker("name", "ns"))
+			store := brokerinformerfake.Get(ctx).Informer().GetStore()
+			store.Add(b)
+
+			if tc.addressableAfter > 0 {
+				addressable := makeBroker("name", "ns")
+				addressable.Status.Annotations = map[string]string{
+					eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+				}
+				timer := time.AfterFunc(tc.addressableAfter, func() {
+					store.Update(addressable)
+				})
+				defer timer.Stop()
+			}
+
+			reporter := &mockReporter{}
+			h, err := NewHandler(logger, reporter, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.NotAddressableRetryTimeout = tc.retryTimeout
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/"+tc.brokerName, getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.statusCode {
+				t.Fatalf("expected status code %d got %d", tc.statusCode, recorder.Code)
+			}
+			if reporter.BrokerNotAddressable != tc.brokerNotAddressable {
+				t.Errorf("expected broker not addressable reported %v, got %v", tc.brokerNotAddressable, reporter.BrokerNotAddressable)
+			}
+		})
+	}
+}
+
+func TestHandler_MaxEventAge(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name               string
+		maxEventAge        time.Duration
+		clockSkewTolerance time.Duration
+		eventTime          time.Time
+		wantStatus         int
+		wantStale          bool
+	}{
+		{
+			name:        "fresh event",
+			maxEventAge: time.Hour,
+			eventTime:   time.Now().Add(-time.Minute),
+			wantStatus:  senderResponseStatusCode,
+		},
+		{
+			name:        "stale event",
+			maxEventAge: time.Hour,
+			eventTime:   time.Now().Add(-2 * time.Hour),
+			wantStatus:  nethttp.StatusOK,
+			wantStale:   true,
+		},
+		{
+			name:        "event without time",
+			maxEventAge: time.Hour,
+			wantStatus:  senderResponseStatusCode,
+		},
+		{
+			name:       "max event age disabled",
+			eventTime:  time.Now().Add(-24 * time.Hour),
+			wantStatus: senderResponseStatusCode,
+		},
+		{
+			name:               "producer clock behind within the skew tolerance",
+			maxEventAge:        time.Hour,
+			clockSkewTolerance: 10 * time.Minute,
+			eventTime:          time.Now().Add(-time.Hour - 5*time.Minute),
+			wantStatus:         senderResponseStatusCode,
+		},
+		{
+			name:               "producer clock behind beyond the skew tolerance",
+			maxEventAge:        time.Hour,
+			clockSkewTolerance: 10 * time.Minute,
+			eventTime:          time.Now().Add(-time.Hour - 15*time.Minute),
+			wantStatus:         nethttp.StatusOK,
+			wantStale:          true,
+		},
+		{
+			name:        "producer clock behind without skew tolerance",
+			maxEventAge: time.Hour,
+			eventTime:   time.Now().Add(-time.Hour - 5*time.Minute),
+			wantStatus:  nethttp.StatusOK,
+			wantStale:   true,
+		},
+		{
+			name:               "producer clock ahead",
+			maxEventAge:        time.Hour,
+			clockSkewTolerance: 10 * time.Minute,
+			eventTime:          time.Now().Add(time.Hour),
+			wantStatus:         senderResponseStatusCode,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			receiver := &svc{}
+			s := httptest.NewServer(receiver)
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			reporter := &mockReporter{}
+			h, err := NewHandler(logger, reporter, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.MaxEventAge = tc.maxEventAge
+			h.ClockSkewTolerance = tc.clockSkewTolerance
+
+			e := event.New()
+			e.SetType("type")
+			e.SetSource("source")
+			e.SetID("1234")
+			if !tc.eventTime.IsZero() {
+				e.SetTime(tc.eventTime)
+			}
+			body, _ := e.MarshalJSON()
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", bytes.NewReader(body))
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatus {
+				t.Errorf("expected status code %d got %d", tc.wantStatus, recorder.Code)
+			}
+			if reporter.StaleEventReported != tc.wantStale {
+				t.Errorf("expected stale event reported %v got %v", tc.wantStale, reporter.StaleEventReported)
+			}
+			if reporter.DropReason != "" {
+				t.Errorf("expected no drop, got drop reason %q", reporter.DropReason)
+			}
+			if dispatched := receiver.receivedHeaders != nil; dispatched == tc.wantStale {
+				t.Errorf("expected dispatched %v got %v", !tc.wantStale, dispatched)
+			}
+		})
+	}
+}
+
+func TestHandler_DropResponseCode(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name             string
+		dropResponseCode int
+		ttl              int32
+		eventTime        time.Time
+		wantStatus       int
+		wantDropReason   string
+		wantStale        bool
+	}{
+		{
+			name:       "dispatched event",
+			ttl:        10,
+			wantStatus: senderResponseStatusCode,
+		},
+		{
+			name:           "exhausted TTL",
+			ttl:            1,
+			wantStatus:     nethttp.StatusBadRequest,
+			wantDropReason: dropReasonTTLExhausted,
+		},
+		{
+			name:             "exhausted TTL with drop response code",
+			dropResponseCode: nethttp.StatusNoContent,
+			ttl:              0,
+			wantStatus:       nethttp.StatusNoContent,
+			wantDropReason:   dropReasonTTLExhausted,
+		},
+		{
+			name:       "stale event",
+			ttl:        10,
+			eventTime:  time.Now().Add(-2 * time.Hour),
+			wantStatus: nethttp.StatusOK,
+			wantStale:  true,
+		},
+		{
+			name:             "stale event with drop response code",
+			dropResponseCode: nethttp.StatusNoContent,
+			ttl:              10,
+			eventTime:        time.Now().Add(-2 * time.Hour),
+			wantStatus:       nethttp.StatusNoContent,
+			wantStale:        true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			receiver := &svc{}
+			s := httptest.NewServer(receiver)
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			reporter := &mockReporter{}
+			h, err := NewHandler(logger, reporter, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.MaxEventAge = time.Hour
+			h.DropResponseCode = tc.dropResponseCode
+
+			e := event.New()
+			e.SetType("type")
+			e.SetSource("source")
+			e.SetID("1234")
+			_ = broker.SetTTL(e.Context, tc.ttl)
+			if !tc.eventTime.IsZero() {
+				e.SetTime(tc.eventTime)
+			}
+			body, _ := e.MarshalJSON()
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", bytes.NewReader(body))
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatus {
+				t.Errorf("expected status code %d got %d", tc.wantStatus, recorder.Code)
+			}
+			if reporter.DropReason != tc.wantDropReason {
+				t.Errorf("expected drop reason %q got %q", tc.wantDropReason, reporter.DropReason)
+			}
+			if reporter.StaleEventReported != tc.wantStale {
+				t.Errorf("expected stale event reported %v got %v", tc.wantStale, reporter.StaleEventReported)
+			}
+			wantDispatched := tc.wantDropReason == "" && !tc.wantStale
+			if dispatched := receiver.receivedHeaders != nil; dispatched != wantDispatched {
+				t.Errorf("expected dispatched %v got %v", wantDispatched, dispatched)
+			}
+		})
+	}
+}
+func TestHandler_EmptyBody(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name             string
+		body             io.Reader
+		contentLength    int64
+		headers          map[string]string
+		emptyBodyMessage string
+		wantStatus       int
+		wantRejectReason string
+		wantError        string
+	}{
+		{
+			name:             "empty body",
+			body:             bytes.NewReader(nil),
+			headers:          map[string]string{cehttp.ContentType: event.ApplicationCloudEventsJSON},
+			wantStatus:       nethttp.StatusBadRequest,
+			wantRejectReason: rejectReasonEmptyBody,
+			wantError:        defaultEmptyBodyMessage,
+		},
+		{
+			name:             "empty body of unknown length",
+			body:             io.NopCloser(strings.NewReader("")),
+			contentLength:    -1,
+			headers:          map[string]string{cehttp.ContentType: event.ApplicationCloudEventsJSON},
+			wantStatus:       nethttp.StatusBadRequest,
+			wantRejectReason: rejectReasonEmptyBody,
+			wantError:        defaultEmptyBodyMessage,
+		},
+		{
+			name:             "custom message",
+			body:             bytes.NewReader(nil),
+			headers:          map[string]string{cehttp.ContentType: event.ApplicationCloudEventsJSON},
+			emptyBodyMessage: "post a CloudEvent",
+			wantStatus:       nethttp.StatusBadRequest,
+			wantRejectReason: rejectReasonEmptyBody,
+			wantError:        "post a CloudEvent",
+		},
+		{
+			name:          "binary mode event without data",
+			body:          io.NopCloser(strings.NewReader("")),
+			contentLength: -1,
+			headers: map[string]string{
+				"Ce-Specversion": "1.0",
+				"Ce-Id":          "1234",
+				"Ce-Type":        "type",
+				"Ce-Source":      "source",
+			},
+			wantStatus: senderResponseStatusCode,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			receiver := &svc{}
+			s := httptest.NewServer(receiver)
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			reporter := &mockReporter{}
+			h, err := NewHandler(logger, reporter, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.EmptyBodyMessage = tc.emptyBodyMessage
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", tc.body)
+			if tc.contentLength != 0 {
+				request.ContentLength = tc.contentLength
+			}
+			for k, v := range tc.headers {
+				request.Header.Set(k, v)
+			}
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatus {
+				t.Errorf("expected status code %d got %d", tc.wantStatus, recorder.Code)
+			}
+			if reporter.RejectReason != tc.wantRejectReason {
+				t.Errorf("expected reject reason %q got %q", tc.wantRejectReason, reporter.RejectReason)
+			}
+			if reporter.DropReason != "" {
+				t.Errorf("expected no drop, got drop reason %q", reporter.DropReason)
+			}
+			if tc.wantError != "" {
+				var resp errorResponse
+				if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+					t.Fatal("failed to decode the response body:", err)
+				}
+				if resp.Error != tc.wantError {
+					t.Errorf("expected error %q got %q", tc.wantError, resp.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_MaxDataBytes(t *testing.T) {
+	logger := zap.NewNop()
+
+	binaryRequest := func(data string) *nethttp.Request {
+		request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", strings.NewReader(data))
+		request.Header.Set("Ce-Specversion", "1.0")
+		request.Header.Set("Ce-Id", "1234")
+		request.Header.Set("Ce-Type", "type")
+		request.Header.Set("Ce-Source", "source")
+		request.Header.Set(cehttp.ContentType, "text/plain")
+		return request
+	}
+	structuredRequest := func(data string, extension string) *nethttp.Request {
+		e := event.New()
+		e.SetType("type")
+		e.SetSource("source")
+		e.SetID("1234")
+		e.SetExtension("large", extension)
+		_ = e.SetData("text/plain", data)
+		body, _ := e.MarshalJSON()
+		request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", bytes.NewReader(body))
+		request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+		return request
+	}
+
+	tt := []struct {
+		name             string
+		maxDataBytes     int
+		request          *nethttp.Request
+		wantStatus       int
+		wantRejectReason string
+	}{
+		{
+			name:         "binary at the limit",
+			maxDataBytes: 10,
+			request:      binaryRequest(strings.Repeat("a", 10)),
+			wantStatus:   senderResponseStatusCode,
+		},
+		{
+			name:             "binary above the limit",
+			maxDataBytes:     10,
+			request:          binaryRequest(strings.Repeat("a", 11)),
+			wantStatus:       nethttp.StatusRequestEntityTooLarge,
+			wantRejectReason: rejectReasonDataTooLarge,
+		},
+		{
+			name:         "structured with large attributes",
+			maxDataBytes: 10,
+			request:      structuredRequest(strings.Repeat("a", 8), strings.Repeat("b", 1000)),
+			wantStatus:   senderResponseStatusCode,
+		},
+		{
+			name:             "structured above the limit",
+			maxDataBytes:     10,
+			request:          structuredRequest(strings.Repeat("a", 20), "small"),
+			wantStatus:       nethttp.StatusRequestEntityTooLarge,
+			wantRejectReason: rejectReasonDataTooLarge,
+		},
+		{
+			name:       "limit disabled",
+			request:    binaryRequest(strings.Repeat("a", 1000)),
+			wantStatus: senderResponseStatusCode,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			receiver := &svc{}
+			s := httptest.NewServer(receiver)
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			reporter := &mockReporter{}
+			h, err := NewHandler(logger, reporter, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.MaxDataBytes = tc.maxDataBytes
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, tc.request)
+
+			if recorder.Code != tc.wantStatus {
+				t.Errorf("expected status code %d got %d", tc.wantStatus, recorder.Code)
+			}
+			if reporter.RejectReason != tc.wantRejectReason {
+				t.Errorf("expected reject reason %q got %q", tc.wantRejectReason, reporter.RejectReason)
+			}
+			if reporter.DropReason != "" {
+				t.Errorf("expected no drop, got drop reason %q", reporter.DropReason)
+			}
+			if dispatched := receiver.receivedHeaders != nil; dispatched != (tc.wantRejectReason == "") {
+				t.Errorf("expected dispatched %v got %v", tc.wantRejectReason == "", dispatched)
+			}
+			if tc.wantRejectReason != "" {
+				var resp errorResponse
+				if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+					t.Fatal("failed to decode the response body:", err)
+				}
+				if resp.Error == "" {
+					t.Error("expected an error message")
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_CollapseInFlightDuplicates(t *testing.T) {
+	logger := zap.NewNop()
+	const requests = 5
+
+	tt := []struct {
+		name           string
+		collapse       bool
+		wantDispatches int32
+	}{
+		{
+			name:           "concurrent duplicates dispatched once",
+			collapse:       true,
+			wantDispatches: 1,
+		},
+		{
+			name:           "concurrent duplicates dispatched without the guard",
+			wantDispatches: requests,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			var dispatches atomic.Int32
+			arrived := make(chan struct{}, requests)
+			release := make(chan struct{})
+			s := httptest.NewServer(nethttp.HandlerFunc(func(writer nethttp.ResponseWriter, request *nethttp.Request) {
+				dispatches.Add(1)
+				arrived <- struct{}{}
+				<-release
+				writer.WriteHeader(senderResponseStatusCode)
+			}))
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.CollapseInFlightDuplicates = tc.collapse
+
+			body, _ := io.ReadAll(getValidEvent())
+			post := func() int {
+				request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", bytes.NewReader(body))
+				request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+				recorder := httptest.NewRecorder()
+				h.ServeHTTP(recorder, request)
+				return recorder.Code
+			}
+
+			codes := make([]int, requests)
+			var wg sync.WaitGroup
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					codes[i] = post()
+				}(i)
+			}
+			// Give the duplicates time to join the in-flight dispatch before completing it.
+			<-arrived
+			time.Sleep(100 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			if got := dispatches.Load(); got != tc.wantDispatches {
+				t.Errorf("expected %d dispatches got %d", tc.wantDispatches, got)
+			}
+			for i, code := range codes {
+				if code != senderResponseStatusCode {
+					t.Errorf("request %d: expected status code %d got %d", i, senderResponseStatusCode, code)
+				}
+			}
+
+			// Once completed, the event is dispatched again.
+			if code := post(); code != senderResponseStatusCode {
+				t.Errorf("expected status code %d got %d", senderResponseStatusCode, code)
+			}
+			if got := dispatches.Load(); got != tc.wantDispatches+1 {
+				t.Errorf("expected %d dispatches got %d", tc.wantDispatches+1, got)
+			}
+		})
+	}
+
+	t.Run("first producer going away", func(t *testing.T) {
+		ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+		var dispatches atomic.Int32
+		arrived := make(chan struct{}, 1)
+		release := make(chan struct{})
+		s := httptest.NewServer(nethttp.HandlerFunc(func(writer nethttp.ResponseWriter, request *nethttp.Request) {
+			dispatches.Add(1)
+			arrived <- struct{}{}
+			<-release
+			writer.WriteHeader(senderResponseStatusCode)
+		}))
+		defer s.Close()
+
+		b := makeBroker("name", "ns")
+		b.Status.Annotations = map[string]string{
+			eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+		}
+		brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+		h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+		if err != nil {
+			t.Fatal("Unable to create receiver:", err)
+		}
+		h.CollapseInFlightDuplicates = true
+
+		body, _ := io.ReadAll(getValidEvent())
+		post := func(ctx context.Context) int {
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", bytes.NewReader(body)).WithContext(ctx)
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+			return recorder.Code
+		}
+
+		firstCtx, cancelFirst := context.WithCancel(context.Background())
+		defer cancelFirst()
+		go post(firstCtx)
+		<-arrived
+
+		duplicate := make(chan int, 1)
+		go func() {
+			duplicate <- post(context.Background())
+		}()
+		// Give the duplicate time to join the in-flight dispatch, then cancel the request
+		// which started it before completing it.
+		time.Sleep(100 * time.Millisecond)
+		cancelFirst()
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+
+		if code := <-duplicate; code != senderResponseStatusCode {
+			t.Errorf("expected status code %d got %d", senderResponseStatusCode, code)
+		}
+		if got := dispatches.Load(); got != 1 {
+			t.Errorf("expected 1 dispatch got %d", got)
+		}
+	})
+}
+
+func TestHandler_ChannelTLSVerification(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name                string
+		caCerts             func(s *httptest.Server) string
+		wantStatus          int
+		wantVerificationErr bool
+	}{
+		{
+			name: "trusted certificate",
+			caCerts: func(s *httptest.Server) string {
+				return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}))
+			},
+			wantStatus: senderResponseStatusCode,
+		},
+		{
+			name: "mismatched certificate",
+			caCerts: func(_ *httptest.Server) string {
+				return string(eventingtlstesting.CA)
+			},
+			wantStatus:          nethttp.StatusBadGateway,
+			wantVerificationErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			receiver := &svc{}
+			s := httptest.NewTLSServer(receiver)
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+				eventing.BrokerChannelCACertsStatusAnnotationKey: tc.caCerts(s),
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			reporter := &mockReporter{}
+			h, err := NewHandler(logger, reporter, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatus {
+				t.Errorf("expected status code %d got %d", tc.wantStatus, recorder.Code)
+			}
+			if reporter.TLSVerificationFailed != tc.wantVerificationErr {
+				t.Errorf("expected TLS verification failure reported %v got %v", tc.wantVerificationErr, reporter.TLSVerificationFailed)
+			}
+			if dispatched := receiver.receivedHeaders != nil; dispatched == tc.wantVerificationErr {
+				t.Errorf("expected dispatched %v got %v", !tc.wantVerificationErr, dispatched)
+			}
+		})
+	}
+}
+
+type recordingTransport struct {
+	hosts []string
+}
+
+func (t *recordingTransport) RoundTrip(req *nethttp.Request) (*nethttp.Response, error) {
+	t.hosts = append(t.hosts, req.URL.Host)
+	return nethttp.DefaultTransport.RoundTrip(req)
+}
+
+func TestHandler_Transport(t *testing.T) {
+	logger := zap.NewNop()
+	ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+	receiver := &svc{}
+	s := httptest.NewServer(receiver)
+	defer s.Close()
+
+	b := makeBroker("name", "ns")
+	b.Status.Annotations = map[string]string{
+		eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+	}
+	brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+	h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+	if err != nil {
+		t.Fatal("Unable to create receiver:", err)
+	}
+	transport := &recordingTransport{}
+	h.Transport = transport
+
+	request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+	request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, request)
+
+	if recorder.Code != senderResponseStatusCode {
+		t.Fatalf("expected status code %d got %d", senderResponseStatusCode, recorder.Code)
+	}
+	if receiver.receivedHeaders == nil {
+		t.Fatal("expected the event to be dispatched")
+	}
+	want := []string{strings.TrimPrefix(s.URL, "http://")}
+	if diff := cmp.Diff(want, transport.hosts); diff != "" {
+		t.Errorf("unexpected requests sent with the transport (-want, +got): %s", diff)
+	}
+}
+
+func TestHandler_ChannelRedirect(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name       string
+		statusCode int
+	}{
+		{name: "found", statusCode: nethttp.StatusFound},
+		{name: "temporary redirect", statusCode: nethttp.StatusTemporaryRedirect},
+		{name: "permanent redirect", statusCode: nethttp.StatusPermanentRedirect},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			receiver := &svc{}
+			s := httptest.NewServer(receiver)
+			defer s.Close()
+
+			redirector := httptest.NewServer(nethttp.RedirectHandler(s.URL, tc.statusCode))
+			defer redirector.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: redirector.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != nethttp.StatusBadGateway {
+				t.Errorf("expected status code %d got %d", nethttp.StatusBadGateway, recorder.Code)
+			}
+			if receiver.receivedHeaders != nil {
+				t.Error("expected the redirect not to be followed")
+			}
+		})
+	}
+}
+
+func TestHandler_Replay(t *testing.T) {
+	logger := zap.NewNop()
+	errUnauthorized := errors.New("unauthorized")
+	authorizer := func(request *nethttp.Request) error {
+		if request.Header.Get("Authorization") != "Bearer token" {
+			return errUnauthorized
+		}
+		return nil
+	}
+
+	tt := []struct {
+		name          string
+		enabled       bool
+		authorizer    func(*nethttp.Request) error
+		authorization string
+		method        string
+		path          string
+		wantStatus    int
+		wantOutcome   bool
+	}{
+		{
+			name:          "disabled",
+			authorizer:    authorizer,
+			authorization: "Bearer token",
+			wantStatus:    nethttp.StatusNotFound,
+		},
+		{
+			name:          "without authorizer",
+			enabled:       true,
+			authorization: "Bearer token",
+			wantStatus:    nethttp.StatusUnauthorized,
+		},
+		{
+			name:          "unauthorized",
+			enabled:       true,
+			authorizer:    authorizer,
+			authorization: "Bearer other",
+			wantStatus:    nethttp.StatusUnauthorized,
+		},
+		{
+			name:          "not a post",
+			enabled:       true,
+			authorizer:    authorizer,
+			authorization: "Bearer token",
+			method:        nethttp.MethodGet,
+			wantStatus:    nethttp.StatusMethodNotAllowed,
+		},
+		{
+			name:          "malformed path",
+			enabled:       true,
+			authorizer:    authorizer,
+			authorization: "Bearer token",
+			path:          "/ns",
+			wantStatus:    nethttp.StatusBadRequest,
+		},
+		{
+			name:          "replayed",
+			enabled:       true,
+			authorizer:    authorizer,
+			authorization: "Bearer token",
+			wantStatus:    nethttp.StatusOK,
+			wantOutcome:   true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			receiver := &svc{}
+			s := httptest.NewServer(receiver)
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.ReplayEnabled = tc.enabled
+			h.ReplayAuthorizer = tc.authorizer
+
+			method := tc.method
+			if method == "" {
+				method = nethttp.MethodPost
+			}
+			path := tc.path
+			if path == "" {
+				path = "/ns/name"
+			}
+			request := httptest.NewRequest(method, path, getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			request.Header.Set("Authorization", tc.authorization)
+
+			recorder := httptest.NewRecorder()
+			h.ReplayHandler().ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatus {
+				t.Fatalf("expected status code %d got %d", tc.wantStatus, recorder.Code)
+			}
+			if !tc.wantOutcome {
+				if receiver.receivedHeaders != nil {
+					t.Error("expected the event not to be replayed")
+				}
+				return
+			}
+
+			var outcome ReplayOutcome
+			if err := json.NewDecoder(recorder.Body).Decode(&outcome); err != nil {
+				t.Fatal("failed to decode the replay outcome:", err)
+			}
+			if outcome.StatusCode != senderResponseStatusCode {
+				t.Errorf("expected status code %d got %d", senderResponseStatusCode, outcome.StatusCode)
+			}
+			if outcome.DispatchTime <= 0 {
+				t.Errorf("expected a dispatch time, got %v", outcome.DispatchTime)
+			}
+			if outcome.ResolutionMethod != channelAddressFromAnnotation {
+				t.Errorf("expected resolution method %q got %q", channelAddressFromAnnotation, outcome.ResolutionMethod)
+			}
+			if want := strings.TrimPrefix(s.URL, "http://"); outcome.ChannelHost != want {
+				t.Errorf("expected channel host %q got %q", want, outcome.ChannelHost)
+			}
+			if outcome.Error != "" {
+				t.Errorf("unexpected error %q", outcome.Error)
+			}
+			if outcome.Event == nil {
+				t.Fatal("expected the dispatched event")
+			}
+			if outcome.Event.ID() != "1234" {
+				t.Errorf("expected event id %q got %q", "1234", outcome.Event.ID())
+			}
+			if ttl, err := broker.GetTTL(outcome.Event.Context); err != nil || ttl != 100 {
+				t.Errorf("expected the dispatched event to be defaulted with a TTL of 100, got %d (%v)", ttl, err)
+			}
+			if receiver.receivedHeaders == nil {
+				t.Error("expected the event to be dispatched to the channel")
+			}
+		})
+	}
+}
+
+func TestHandler_Receipts(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name              string
+		channelStatusCode int
+		sinkStatusCode    int
+		wantStatus        int
+		wantReceipt       bool
+	}{
+		{
+			name:              "dispatched",
+			channelStatusCode: nethttp.StatusAccepted,
+			sinkStatusCode:    nethttp.StatusAccepted,
+			wantStatus:        nethttp.StatusAccepted,
+			wantReceipt:       true,
+		},
+		{
+			name:              "receipt sink failing",
+			channelStatusCode: nethttp.StatusAccepted,
+			sinkStatusCode:    nethttp.StatusInternalServerError,
+			wantStatus:        nethttp.StatusAccepted,
+			wantReceipt:       true,
+		},
+		{
+			name:              "dispatch failed",
+			channelStatusCode: nethttp.StatusBadRequest,
+			sinkStatusCode:    nethttp.StatusAccepted,
+			wantStatus:        nethttp.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			channel := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, _ *nethttp.Request) {
+				w.WriteHeader(tc.channelStatusCode)
+			}))
+			defer channel.Close()
+
+			receipts := make(chan *event.Event, 1)
+			sink := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				receipt, err := binding.ToEvent(r.Context(), cehttp.NewMessageFromHttpRequest(r))
+				if err != nil {
+					t.Error("failed to read the receipt:", err)
+				}
+				receipts <- receipt
+				w.WriteHeader(tc.sinkStatusCode)
+			}))
+			defer sink.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: channel.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			sinkURL, _ := apis.ParseURL(sink.URL)
+			h.ReceiptSink = &duckv1.Addressable{URL: sinkURL}
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatus {
+				t.Errorf("expected status code %d got %d", tc.wantStatus, recorder.Code)
+			}
+
+			if !tc.wantReceipt {
+				select {
+				case receipt := <-receipts:
+					t.Errorf("unexpected receipt %v", receipt)
+				case <-time.After(100 * time.Millisecond):
+				}
+				return
+			}
+
+			var receipt *event.Event
+			select {
+			case receipt = <-receipts:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected a delivery receipt")
+			}
+			if receipt.Type() != ReceiptEventType {
+				t.Errorf("expected receipt type %q got %q", ReceiptEventType, receipt.Type())
+			}
+			var data Receipt
+			if err := receipt.DataAs(&data); err != nil {
+				t.Fatal("failed to decode the receipt data:", err)
+			}
+			if data.EventID != "1234" || data.EventSource != "source" || data.EventType != "type" {
+				t.Errorf("unexpected receipt event attributes %+v", data)
+			}
+			if data.Broker != "ns/name" {
+				t.Errorf("expected receipt broker %q got %q", "ns/name", data.Broker)
+			}
+			if data.StatusCode != tc.channelStatusCode {
+				t.Errorf("expected receipt status code %d got %d", tc.channelStatusCode, data.StatusCode)
+			}
+			if data.Time.IsZero() {
+				t.Error("expected the receipt time to be set")
+			}
+		})
+	}
+}
+
+func TestHandler_DispatchHooks(t *testing.T) {
+	tt := []struct {
+		name          string
+		channelStatus int
+		wantSuccess   bool
+		wantFailure   bool
+	}{
+		{
+			name:          "dispatched",
+			channelStatus: senderResponseStatusCode,
+			wantSuccess:   true,
+		},
+		{
+			name:          "rejected by the channel",
+			channelStatus: nethttp.StatusBadRequest,
+			wantFailure:   true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			logger := zap.NewNop()
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			s := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, _ *nethttp.Request) {
+				w.WriteHeader(tc.channelStatus)
+			}))
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			var successes, failures []string
+			h.OnDispatchSuccess = func(e *event.Event, target duckv1.Addressable, d time.Duration) {
+				successes = append(successes, e.ID()+" "+target.URL.String())
+			}
+			h.OnDispatchFailure = func(e *event.Event, target duckv1.Addressable, err error) {
+				if err == nil {
+					t.Error("expected the dispatch error")
+				}
+				failures = append(failures, e.ID()+" "+target.URL.String())
+			}
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			var wantSuccesses, wantFailures []string
+			if tc.wantSuccess {
+				wantSuccesses = []string{"1234 " + s.URL}
+			}
+			if tc.wantFailure {
+				wantFailures = []string{"1234 " + s.URL}
+			}
+			if diff := cmp.Diff(wantSuccesses, successes); diff != "" {
+				t.Errorf("unexpected successes (-want, +got): %s", diff)
+			}
+			if diff := cmp.Diff(wantFailures, failures); diff != "" {
+				t.Errorf("unexpected failures (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestHandler_TLSConfig(t *testing.T) {
+	logger := zap.NewNop()
+	ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+	receiver := &svc{}
+	s := httptest.NewTLSServer(receiver)
+	defer s.Close()
+
+	caCerts := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}))
+	b := makeBroker("name", "ns")
+	b.Status.Annotations = map[string]string{
+		eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+		eventing.BrokerChannelCACertsStatusAnnotationKey: caCerts,
+	}
+	brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+	h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+	if err != nil {
+		t.Fatal("Unable to create receiver:", err)
+	}
+	h.TLSMinVersion = tls.VersionTLS13
+	h.TLSCipherSuites = []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+
+	request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+	request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, request)
+
+	if recorder.Code != senderResponseStatusCode {
+		t.Fatalf("expected status code %d got %d", senderResponseStatusCode, recorder.Code)
+	}
+	if receiver.receivedHeaders == nil {
+		t.Fatal("expected the event to be dispatched")
+	}
+
+	transport, err := h.dispatchTransport(duckv1.Addressable{CACerts: &caCerts})
+	if err != nil {
+		t.Fatal("Unable to get the dispatch transport:", err)
+	}
+	tlsConfig := transport.(*ochttp.Transport).Base.(*nethttp.Transport).TLSClientConfig
+	if tlsConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("expected min version %d got %d", tls.VersionTLS13, tlsConfig.MinVersion)
+	}
+	if diff := cmp.Diff(h.TLSCipherSuites, tlsConfig.CipherSuites); diff != "" {
+		t.Errorf("unexpected cipher suites (-want, +got): %s", diff)
+	}
+	if h.tlsTransports.Len() != 1 {
+		t.Errorf("expected the transport to be shared, got %d transports", h.tlsTransports.Len())
+	}
+}
+
+func TestHandler_DispatchTransports(t *testing.T) {
+	logger := zap.NewNop()
+	ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+	s := httptest.NewTLSServer(&svc{})
+	defer s.Close()
+	caCerts := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}))
+
+	h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+	if err != nil {
+		t.Fatal("Unable to create receiver:", err)
+	}
+	h.TLSMinVersion = tls.VersionTLS13
+
+	transport, err := h.dispatchTransport(duckv1.Addressable{CACerts: &caCerts})
+	if err != nil {
+		t.Fatal("Unable to get the dispatch transport:", err)
+	}
+	base := transport.(*ochttp.Transport).Base.(*nethttp.Transport)
+	if base.MaxIdleConns != defaultMaxIdleConnections || base.MaxIdleConnsPerHost != defaultMaxIdleConnectionsPerHost {
+		t.Errorf("expected the connection args of NewHandler, got MaxIdleConns %d MaxIdleConnsPerHost %d", base.MaxIdleConns, base.MaxIdleConnsPerHost)
+	}
+
+	// The same CA certificates with a different trailing padding are different cache keys.
+	for i := 1; i <= maxDispatchTransports; i++ {
+		padded := caCerts + strings.Repeat("\n", i)
+		if _, err := h.dispatchTransport(duckv1.Addressable{CACerts: &padded}); err != nil {
+			t.Fatal("Unable to get the dispatch transport:", err)
+		}
+	}
+	if h.tlsTransports.Len() != maxDispatchTransports {
+		t.Errorf("expected %d transports got %d", maxDispatchTransports, h.tlsTransports.Len())
+	}
+	if h.tlsTransports.Contains(caCerts) {
+		t.Error("expected the least recently used transport to be evicted")
+	}
+}
+
+func TestHandler_WarmTargets(t *testing.T) {
+	logger := zap.NewNop()
+	ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+	var mu sync.Mutex
+	preconnects := map[string]int{}
+	for _, name := range []string{"first", "second"} {
+		name := name
+		s := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, req *nethttp.Request) {
+			if req.Method == nethttp.MethodOptions {
+				mu.Lock()
+				preconnects[name]++
+				mu.Unlock()
+			}
+			w.WriteHeader(nethttp.StatusOK)
+		}))
+		defer s.Close()
+
+		b := makeBroker(name, "ns")
+		b.Status.Annotations = map[string]string{
+			eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+		}
+		brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+	}
+
+	h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+	if err != nil {
+		t.Fatal("Unable to create receiver:", err)
+	}
+	h.WarmInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.WarmTargets(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	warmed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return preconnects["first"] >= 2 && preconnects["second"] >= 2
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !warmed() {
+		if time.Now().After(deadline) {
+			mu.Lock()
+			defer mu.Unlock()
+			t.Fatalf("expected repeated pre-connects to every channel, got %v", preconnects)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandler_WarmTargetsDisabled(t *testing.T) {
+	logger := zap.NewNop()
+	ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+	h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+	if err != nil {
+		t.Fatal("Unable to create receiver:", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.WarmTargets(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected WarmTargets to return when WarmInterval isn't set")
+	}
+}
+
+func TestHandler_DeliveryGuarantee(t *testing.T) {
+	tt := []struct {
+		name             string
+		extension        string
+		defaultGuarantee DeliveryGuarantee
+		wantStatusCode   int
+		wantRequests     int
+	}{
+		{
+			name:           "at-least-once retries until the channel accepts",
+			extension:      "at-least-once",
+			wantStatusCode: senderResponseStatusCode,
+			wantRequests:   2,
+		},
+		{
+			name:           "at-most-once fails on the first channel failure",
+			extension:      "at-most-once",
+			wantStatusCode: nethttp.StatusInternalServerError,
+			wantRequests:   1,
+		},
+		{
+			name:           "absent uses the at-most-once default",
+			wantStatusCode: nethttp.StatusInternalServerError,
+			wantRequests:   1,
+		},
+		{
+			name:             "absent uses the handler default",
+			defaultGuarantee: DeliveryGuaranteeAtLeastOnce,
+			wantStatusCode:   senderResponseStatusCode,
+			wantRequests:     2,
+		},
+		{
+			name:             "unknown uses the handler default",
+			extension:        "exactly-once",
+			defaultGuarantee: DeliveryGuaranteeAtLeastOnce,
+			wantStatusCode:   senderResponseStatusCode,
+			wantRequests:     2,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			logger := zap.NewNop()
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			var mu sync.Mutex
+			requests := 0
+			s := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				requests++
+				if requests == 1 {
+					w.WriteHeader(nethttp.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(senderResponseStatusCode)
+			}))
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.DeliveryGuarantee = tc.defaultGuarantee
+			h.RetryConfig = &kncloudevents.RetryConfig{
+				RetryMax:   3,
+				CheckRetry: kncloudevents.SelectiveRetry,
+				Backoff: func(int, *nethttp.Response) time.Duration {
+					return time.Millisecond
+				},
+			}
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			if tc.extension != "" {
+				e := event.New()
+				e.SetID("id")
+				e.SetType("type")
+				e.SetSource("source")
+				e.SetExtension(DeliveryGuaranteeExtension, tc.extension)
+				body, _ := json.Marshal(e)
+				request = httptest.NewRequest(nethttp.MethodPost, "/ns/name", bytes.NewReader(body))
+				request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			}
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatusCode {
+				t.Errorf("expected status code %d got %d", tc.wantStatusCode, recorder.Code)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if requests != tc.wantRequests {
+				t.Errorf("expected %d requests to the channel got %d", tc.wantRequests, requests)
+			}
+		})
+	}
+}
+
+func TestHandler_BrokerDispatchOptions(t *testing.T) {
+	tt := []struct {
+		name                  string
+		annotations           map[string]string
+		guarantee             DeliveryGuarantee
+		structuredWithCharset bool
+		channelDelay          time.Duration
+		channelStatus         int
+		wantStatusCode        int
+		wantRequests          int
+		wantStructured        bool
+	}{
+		{
+			name:           "no annotations",
+			guarantee:      DeliveryGuaranteeAtLeastOnce,
+			channelStatus:  nethttp.StatusServiceUnavailable,
+			wantStatusCode: nethttp.StatusInternalServerError,
+			wantRequests:   4,
+		},
+		{
+			name: "retries",
+			annotations: map[string]string{
+				eventing.BrokerDispatchRetryAnnotationKey: "1",
+			},
+			guarantee:      DeliveryGuaranteeAtLeastOnce,
+			channelStatus:  nethttp.StatusServiceUnavailable,
+			wantStatusCode: nethttp.StatusInternalServerError,
+			wantRequests:   2,
+		},
+		{
+			name: "no retries",
+			annotations: map[string]string{
+				eventing.BrokerDispatchRetryAnnotationKey: "0",
+			},
+			guarantee:      DeliveryGuaranteeAtLeastOnce,
+			channelStatus:  nethttp.StatusServiceUnavailable,
+			wantStatusCode: nethttp.StatusInternalServerError,
+			wantRequests:   1,
+		},
+		{
+			name: "invalid retries",
+			annotations: map[string]string{
+				eventing.BrokerDispatchRetryAnnotationKey: "-1",
+			},
+			guarantee:      DeliveryGuaranteeAtLeastOnce,
+			channelStatus:  nethttp.StatusServiceUnavailable,
+			wantStatusCode: nethttp.StatusInternalServerError,
+			wantRequests:   4,
+		},
+		{
+			name: "timeout",
+			annotations: map[string]string{
+				eventing.BrokerDispatchTimeoutAnnotationKey: "10ms",
+			},
+			channelDelay:   200 * time.Millisecond,
+			channelStatus:  senderResponseStatusCode,
+			wantStatusCode: nethttp.StatusInternalServerError,
+			wantRequests:   1,
+		},
+		{
+			name: "timeout and retries",
+			annotations: map[string]string{
+				eventing.BrokerDispatchTimeoutAnnotationKey: "10ms",
+				eventing.BrokerDispatchRetryAnnotationKey:   "2",
+			},
+			guarantee:      DeliveryGuaranteeAtLeastOnce,
+			channelDelay:   200 * time.Millisecond,
+			channelStatus:  senderResponseStatusCode,
+			wantStatusCode: nethttp.StatusInternalServerError,
+			wantRequests:   3,
+		},
+		{
+			name: "invalid timeout",
+			annotations: map[string]string{
+				eventing.BrokerDispatchTimeoutAnnotationKey: "soon",
+			},
+			channelDelay:   50 * time.Millisecond,
+			channelStatus:  senderResponseStatusCode,
+			wantStatusCode: senderResponseStatusCode,
+			wantRequests:   1,
+		},
+		{
+			name: "structured content mode",
+			annotations: map[string]string{
+				eventing.BrokerDispatchContentModeAnnotationKey: "structured",
+			},
+			channelStatus:  senderResponseStatusCode,
+			wantStatusCode: senderResponseStatusCode,
+			wantRequests:   1,
+			wantStructured: true,
+		},
+		{
+			name: "binary content mode overriding the structured default",
+			annotations: map[string]string{
+				eventing.BrokerDispatchContentModeAnnotationKey: "binary",
+			},
+			structuredWithCharset: true,
+			channelStatus:         senderResponseStatusCode,
+			wantStatusCode:        senderResponseStatusCode,
+			wantRequests:          1,
+		},
+		{
+			name: "invalid content mode",
+			annotations: map[string]string{
+				eventing.BrokerDispatchContentModeAnnotationKey: "xml",
+			},
+			structuredWithCharset: true,
+			channelStatus:         senderResponseStatusCode,
+			wantStatusCode:        senderResponseStatusCode,
+			wantRequests:          1,
+			wantStructured:        true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			logger := zap.NewNop()
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			var mu sync.Mutex
+			requests := 0
+			var contentType string
+			s := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				mu.Lock()
+				requests++
+				contentType = r.Header.Get(cehttp.ContentType)
+				mu.Unlock()
+				time.Sleep(tc.channelDelay)
+				w.WriteHeader(tc.channelStatus)
+			}))
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Annotations = tc.annotations
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.DeliveryGuarantee = tc.guarantee
+			h.StructuredWithCharset = tc.structuredWithCharset
+			h.RetryConfig = &kncloudevents.RetryConfig{
+				RetryMax:   3,
+				CheckRetry: kncloudevents.SelectiveRetry,
+				Backoff: func(int, *nethttp.Response) time.Duration {
+					return time.Millisecond
+				},
+			}
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatusCode {
+				t.Errorf("expected status code %d got %d", tc.wantStatusCode, recorder.Code)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if requests != tc.wantRequests {
+				t.Errorf("expected %d requests to the channel got %d", tc.wantRequests, requests)
+			}
+			if structured := strings.HasPrefix(contentType, event.ApplicationCloudEventsJSON); structured != tc.wantStructured {
+				t.Errorf("expected structured mode %v, got content type %q", tc.wantStructured, contentType)
+			}
+		})
+	}
+}
+
+func TestHandler_RetryQueue(t *testing.T) {
+	tt := []struct {
+		name             string
+		guarantee        DeliveryGuarantee
+		channelFailures  int
+		channelStatus    int
+		deadLetterSink   bool
+		retryQueueSize   int
+		wantStatusCode   int
+		wantRequests     int
+		wantDeadLettered bool
+	}{
+		{
+			name:            "queued until the channel accepts",
+			guarantee:       DeliveryGuaranteeAtLeastOnce,
+			channelFailures: 2,
+			channelStatus:   nethttp.StatusServiceUnavailable,
+			wantStatusCode:  nethttp.StatusAccepted,
+			wantRequests:    3,
+		},
+		{
+			name:             "retries exhausted go to the dead letter sink",
+			guarantee:        DeliveryGuaranteeAtLeastOnce,
+			channelFailures:  10,
+			channelStatus:    nethttp.StatusServiceUnavailable,
+			deadLetterSink:   true,
+			wantStatusCode:   nethttp.StatusAccepted,
+			wantRequests:     4,
+			wantDeadLettered: true,
+		},
+		{
+			name:            "retries exhausted without dead letter sink",
+			guarantee:       DeliveryGuaranteeAtLeastOnce,
+			channelFailures: 10,
+			channelStatus:   nethttp.StatusServiceUnavailable,
+			wantStatusCode:  nethttp.StatusAccepted,
+			wantRequests:    4,
+		},
+		{
+			name:            "non retryable failure",
+			guarantee:       DeliveryGuaranteeAtLeastOnce,
+			channelFailures: 1,
+			channelStatus:   nethttp.StatusBadRequest,
+			wantStatusCode:  nethttp.StatusInternalServerError,
+			wantRequests:    1,
+		},
+		{
+			name:            "at-most-once not queued",
+			guarantee:       DeliveryGuaranteeAtMostOnce,
+			channelFailures: 1,
+			channelStatus:   nethttp.StatusServiceUnavailable,
+			wantStatusCode:  nethttp.StatusInternalServerError,
+			wantRequests:    1,
+		},
+		{
+			name:            "queue full",
+			guarantee:       DeliveryGuaranteeAtLeastOnce,
+			channelFailures: 1,
+			channelStatus:   nethttp.StatusServiceUnavailable,
+			retryQueueSize:  -1,
+			wantStatusCode:  nethttp.StatusInternalServerError,
+			wantRequests:    1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			logger := zap.NewNop()
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			var mu sync.Mutex
+			requests := 0
+			traceIDs := sets.NewString()
+			s := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				requests++
+				// traceparent is 00-<trace id>-<span id>-<flags>.
+				if traceParent := strings.Split(r.Header.Get("traceparent"), "-"); len(traceParent) == 4 {
+					traceIDs.Insert(traceParent[1])
+				}
+				if requests <= tc.channelFailures {
+					w.WriteHeader(tc.channelStatus)
+					return
+				}
+				w.WriteHeader(senderResponseStatusCode)
+			}))
+			defer s.Close()
+
+			deadLettered := make(chan struct{}, 1)
+			dls := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				deadLettered <- struct{}{}
+				w.WriteHeader(nethttp.StatusAccepted)
+			}))
+			defer dls.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			if tc.deadLetterSink {
+				b.Status.DeadLetterSinkURI, _ = apis.ParseURL(dls.URL)
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			reporter := &retryQueueReporter{}
+			h, err := NewHandler(logger, reporter, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.DeliveryGuarantee = tc.guarantee
+			h.RetryQueueMaxRetries = 3
+			h.RetryQueueSize = tc.retryQueueSize
+			fakeClock := clocktesting.NewFakeClock(time.Now())
+			h.clock = fakeClock
+			if tc.retryQueueSize < 0 {
+				// Fill the queue.
+				h.retryQueueDepth.Store(defaultRetryQueueSize)
+			}
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatusCode {
+				t.Errorf("expected status code %d got %d", tc.wantStatusCode, recorder.Code)
+			}
+
+			if tc.wantStatusCode == nethttp.StatusAccepted {
+				// Fire the retries as they are scheduled, until the queue is empty.
+				err := wait.PollImmediate(5*time.Millisecond, 5*time.Second, func() (bool, error) {
+					if fakeClock.HasWaiters() {
+						fakeClock.Step(maxRetryQueueBackoff)
+					}
+					return reporter.retryQueueDepth.Load() == 0, nil
+				})
+				if err != nil {
+					t.Fatal("timeout waiting for the retry queue to be empty")
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if requests != tc.wantRequests {
+				t.Errorf("expected %d requests to the channel got %d", tc.wantRequests, requests)
+			}
+			// The retries are part of the trace of the request.
+			if traceIDs.Len() != 1 {
+				t.Errorf("expected the requests to the channel in a single trace got traces %v", traceIDs.List())
+			}
+			select {
+			case <-deadLettered:
+				if !tc.wantDeadLettered {
+					t.Error("unexpected event sent to the dead letter sink")
+				}
+			default:
+				if tc.wantDeadLettered {
+					t.Error("expected event sent to the dead letter sink")
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_RetryQueueFlush(t *testing.T) {
+	logger := zap.NewNop()
+	ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+	var requests atomic.Int32
+	s := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(nethttp.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(senderResponseStatusCode)
+	}))
+	defer s.Close()
+
+	b := makeBroker("name", "ns")
+	b.Status.Annotations = map[string]string{
+		eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+	}
+	brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+	reporter := &retryQueueReporter{}
+	h, err := NewHandler(logger, reporter, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+	if err != nil {
+		t.Fatal("Unable to create receiver:", err)
+	}
+	h.DeliveryGuarantee = DeliveryGuaranteeAtLeastOnce
+	h.RetryQueueMaxRetries = 3
+	// The fake clock never fires the retry, only the flush lets it happen.
+	h.clock = clocktesting.NewFakeClock(time.Now())
+
+	post := func() int {
+		request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+		request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	if code := post(); code != nethttp.StatusAccepted {
+		t.Fatalf("expected status code %d got %d", nethttp.StatusAccepted, code)
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	h.FlushRetryQueue(flushCtx)
+
+	if got := requests.Load(); got != 2 {
+		t.Errorf("expected 2 requests to the channel got %d", got)
+	}
+	if depth := reporter.retryQueueDepth.Load(); depth != 0 {
+		t.Errorf("expected an empty retry queue got %d events", depth)
+	}
+
+	// Once flushed, the failing events aren't queued anymore.
+	requests.Store(0)
+	if code := post(); code != nethttp.StatusInternalServerError {
+		t.Errorf("expected status code %d got %d", nethttp.StatusInternalServerError, code)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tt := []struct {
+		name     string
+		initial  time.Duration
+		attempts int
+		want     time.Duration
+	}{
+		{name: "default", attempts: 0, want: defaultRetryQueueBackoff},
+		{name: "doubled", initial: time.Second, attempts: 3, want: 8 * time.Second},
+		{name: "capped", initial: time.Second, attempts: 20, want: maxRetryQueueBackoff},
+		{name: "no overflow", initial: time.Second, attempts: 1000, want: maxRetryQueueBackoff},
+		{name: "initial above the cap", initial: time.Hour, attempts: 3, want: time.Hour},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := retryBackoff(tc.initial, tc.attempts); got != tc.want {
+				t.Errorf("expected backoff %v got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestHandler_IngressExtensions(t *testing.T) {
+	tt := []struct {
+		name              string
+		ingressExtensions map[string]ExtensionValue
+		eventExtensions   map[string]string
+		wantHeaders       map[string]string
+	}{
+		{
+			name: "static and computed values",
+			ingressExtensions: map[string]ExtensionValue{
+				"ingestregion": StaticExtensionValue("eu-west-1"),
+				"ingesttype": func(e *event.Event) string {
+					return "ingested-" + e.Type()
+				},
+			},
+			wantHeaders: map[string]string{
+				"Ce-Ingestregion": "eu-west-1",
+				"Ce-Ingesttype":   "ingested-type",
+			},
+		},
+		{
+			name: "producer extensions overridden",
+			ingressExtensions: map[string]ExtensionValue{
+				"ingestregion": StaticExtensionValue("eu-west-1"),
+			},
+			eventExtensions: map[string]string{
+				"ingestregion": "spoofed",
+				"other":        "kept",
+			},
+			wantHeaders: map[string]string{
+				"Ce-Ingestregion": "eu-west-1",
+				"Ce-Other":        "kept",
+			},
+		},
+		{
+			name: "invalid names skipped",
+			ingressExtensions: map[string]ExtensionValue{
+				"ingest-region": StaticExtensionValue("eu-west-1"),
+				"id":            StaticExtensionValue("overridden"),
+				"ingestregion":  StaticExtensionValue("eu-west-1"),
+			},
+			wantHeaders: map[string]string{
+				"Ce-Ingestregion": "eu-west-1",
+				"Ce-Id":           "1234",
+			},
+		},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
+			logger := zap.NewNop()
 			ctx, _ := reconcilertesting.SetupFakeContext(t)
 
-			s := httptest.NewServer(tc.handler)
+			receiver := &svc{}
+			s := httptest.NewServer(receiver)
 			defer s.Close()
 
-			recorder := httptest.NewRecorder()
-			request := httptest.NewRequest(tc.method, tc.uri, tc.body)
-			if tc.headers != nil {
-				request.Header = tc.headers
-			} else {
-				tc.expectedHeaders = nethttp.Header{
-					cehttp.ContentType: []string{event.ApplicationCloudEventsJSON},
-				}
-				request.Header.Add(cehttp.ContentType, event.ApplicationCloudEventsJSON)
-			}
-
-			for _, b := range tc.brokers {
-				// Write the channel address in the broker status annotation unless explicitly set to nil
-				if b.Status.Annotations != nil {
-					if _, set := b.Status.Annotations[eventing.BrokerChannelAddressStatusAnnotationKey]; !set {
-						b.Status.Annotations = map[string]string{
-							eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
-						}
-					}
-				}
-				brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
 			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
 
-			h, err := NewHandler(logger, &mockReporter{}, tc.defaulter, brokerinformerfake.Get(ctx))
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
 			if err != nil {
 				t.Fatal("Unable to create receiver:", err)
 			}
+			h.IngressExtensions = tc.ingressExtensions
+
+			e := event.New()
+			e.SetID("1234")
+			e.SetType("type")
+			e.SetSource("source")
+			for name, value := range tc.eventExtensions {
+				e.SetExtension(name, value)
+			}
+			body, _ := json.Marshal(e)
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", bytes.NewReader(body))
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
 
+			recorder := httptest.NewRecorder()
 			h.ServeHTTP(recorder, request)
 
-			result := recorder.Result()
-			if result.StatusCode != tc.statusCode {
-				t.Errorf("expected status code %d got %d", tc.statusCode, result.StatusCode)
+			if recorder.Code != senderResponseStatusCode {
+				t.Fatalf("expected status code %d got %d", senderResponseStatusCode, recorder.Code)
 			}
-
-			if svc, ok := tc.handler.(*svc); ok {
-				for k, expValue := range tc.expectedHeaders {
-					if v, ok := svc.receivedHeaders[k]; !ok {
-						t.Errorf("expected header %s - %v", k, svc.receivedHeaders)
-					} else if diff := cmp.Diff(expValue, v); diff != "" {
-						t.Error("(-want +got)", diff)
-					}
+			if receiver.receivedHeaders == nil {
+				t.Fatal("expected the event to be dispatched")
+			}
+			for header, want := range tc.wantHeaders {
+				if got := receiver.receivedHeaders.Get(header); got != want {
+					t.Errorf("expected %s header %q, got %q", header, want, got)
 				}
 			}
+		})
+	}
+}
 
-			if diff := cmp.Diff(tc.reporter, h.Reporter); diff != "" {
-				t.Errorf("expected reporter state %+v got %+v - diff %s", tc.reporter, h.Reporter, diff)
+func TestSanitizeErrorMessage(t *testing.T) {
+	tt := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "printable",
+			message: "source: REQUIRED",
+			want:    "source: REQUIRED",
+		},
+		{
+			name:    "trailing new line",
+			message: "specversion: unknown value: 0.1\n",
+			want:    "specversion: unknown value: 0.1",
+		},
+		{
+			name:    "control characters",
+			message: "bad\r\nheader\x00",
+			want:    "bad??header?",
+		},
+		{
+			name:    "truncated",
+			message: strings.Repeat("a", maxErrorMessageLength+1),
+			want:    strings.Repeat("a", maxErrorMessageLength-3) + "...",
+		},
+		{
+			name:    "at limit",
+			message: strings.Repeat("a", maxErrorMessageLength),
+			want:    strings.Repeat("a", maxErrorMessageLength),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sanitizeErrorMessage(tc.message); got != tc.want {
+				t.Errorf("expected %q got %q", tc.want, got)
 			}
 		})
 	}
 }
 
+func TestHandler_AccessLog(t *testing.T) {
+	ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+	s := httptest.NewServer(handler())
+	defer s.Close()
+
+	b := makeBroker("name", "ns")
+	b.Status.Annotations = map[string]string{
+		eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+	}
+	brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+	buf := &bytes.Buffer{}
+	logger := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(buf), zap.InfoLevel))
+
+	h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(zap.NewNop(), 100), brokerinformerfake.Get(ctx))
+	if err != nil {
+		t.Fatal("Unable to create receiver:", err)
+	}
+	h.AccessLog = true
+
+	request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+	request.Header.Add(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+	h.ServeHTTP(httptest.NewRecorder(), request)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON access log entry, got %q: %v", buf.String(), err)
+	}
+	want := map[string]interface{}{
+		"msg":        "access",
+		"method":     nethttp.MethodPost,
+		"uri":        "/ns/name",
+		"sourceIP":   "192.0.2.1",
+		"namespace":  "ns",
+		"broker":     "name",
+		"event.type": "type",
+		"event.id":   "1234",
+		"statusCode": float64(senderResponseStatusCode),
+	}
+	for k, v := range want {
+		if diff := cmp.Diff(v, entry[k]); diff != "" {
+			t.Errorf("unexpected access log field %q (-want +got): %s", k, diff)
+		}
+	}
+	if _, ok := entry["dispatchTime"]; !ok {
+		t.Errorf("expected dispatchTime in access log entry %v", entry)
+	}
+
+	buf.Reset()
+	h.AccessLog = false
+	request = httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+	request.Header.Add(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+	h.ServeHTTP(httptest.NewRecorder(), request)
+	if buf.Len() != 0 {
+		t.Errorf("expected no access log entry when disabled, got %q", buf.String())
+	}
+}
+
+func TestHandler_StructuredWithCharset(t *testing.T) {
+	ctx, _ := reconcilertesting.SetupFakeContext(t)
+	logger := zap.NewNop()
+
+	receiver := &svc{}
+	s := httptest.NewServer(receiver)
+	defer s.Close()
+
+	b := makeBroker("name", "ns")
+	b.Status.Annotations = map[string]string{
+		eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+	}
+	brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+	h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+	if err != nil {
+		t.Fatal("Unable to create receiver:", err)
+	}
+	h.StructuredWithCharset = true
+
+	request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+	request.Header.Add(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, request)
+
+	if recorder.Code != senderResponseStatusCode {
+		t.Fatalf("expected status code %d got %d", senderResponseStatusCode, recorder.Code)
+	}
+	if got := receiver.receivedHeaders.Get(cehttp.ContentType); got != "application/cloudevents+json; charset=utf-8" {
+		t.Errorf("unexpected outbound Content-Type %q", got)
+	}
+	if got := receiver.receivedHeaders.Get("Ce-Id"); got != "" {
+		t.Errorf("expected structured mode, got binary header Ce-Id %q", got)
+	}
+}
+
 type svc struct {
 	receivedHeaders nethttp.Header
+	receivedPath    string
 }
 
 func (s *svc) ServeHTTP(w nethttp.ResponseWriter, req *nethttp.Request) {
 	s.receivedHeaders = req.Header
+	s.receivedPath = req.URL.Path
 	w.WriteHeader(senderResponseStatusCode)
 }
 
@@ -325,21 +4067,103 @@ func handler() nethttp.Handler {
 	})
 }
 
+// mockReporter records the reported metrics. It's safe for concurrent use, the fields must
+// only be read once the requests are served.
 type mockReporter struct {
+	mu sync.Mutex
+
 	StatusCode                int
 	EventDispatchTimeReported bool
+	ChannelResolutionMethod   string
+	ChannelResolved           bool
+	StaleEventReported        bool
+	TLSVerificationFailed     bool
+	DefaulterErrorReported    bool
+	BrokerNotAddressable      bool
+	DropReason                string
+	RejectReason              string
 }
 
 func (r *mockReporter) ReportEventCount(_ *ReportArgs, responseCode int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.StatusCode = responseCode
 	return nil
 }
 
 func (r *mockReporter) ReportEventDispatchTime(_ *ReportArgs, _ int, _ time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.EventDispatchTimeReported = true
 	return nil
 }
 
+func (r *mockReporter) ReportChannelResolution(_ *ReportArgs, method string, success bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ChannelResolutionMethod = method
+	r.ChannelResolved = success
+	return nil
+}
+
+func (r *mockReporter) ReportStaleEvent(_ *ReportArgs) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.StaleEventReported = true
+	return nil
+}
+
+func (r *mockReporter) ReportTLSVerificationFailure(_ *ReportArgs) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.TLSVerificationFailed = true
+	return nil
+}
+
+func (r *mockReporter) ReportDefaulterError(_ *ReportArgs) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.DefaulterErrorReported = true
+	return nil
+}
+
+func (r *mockReporter) ReportBrokerNotAddressable(_ *ReportArgs) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.BrokerNotAddressable = true
+	return nil
+}
+
+func (r *mockReporter) ReportEventDropped(_ *ReportArgs, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.DropReason = reason
+	return nil
+}
+
+func (r *mockReporter) ReportEventRejected(_ *ReportArgs, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.RejectReason = reason
+	return nil
+}
+
+func (r *mockReporter) ReportRetryQueueDepth(_ int64) error {
+	return nil
+}
+
+// retryQueueReporter records the retry queue depth, which is reported from the retries
+// running in the background.
+type retryQueueReporter struct {
+	mockReporter
+	retryQueueDepth atomic.Int64
+}
+
+func (r *retryQueueReporter) ReportRetryQueueDepth(depth int64) error {
+	r.retryQueueDepth.Store(depth)
+	return nil
+}
+
 func getValidEvent() io.Reader {
 	e := event.New()
 	e.SetType("type")
@@ -380,3 +4204,305 @@ func withUninitializedAnnotations(b *eventingv1.Broker) *eventingv1.Broker {
 	b.Status.Annotations = nil
 	return b
 }
+
+// BenchmarkServeHTTP measures the ingress path of a binary mode event, including the dispatch
+// to the channel. Run it with -benchmem to check the allocations per event.
+func BenchmarkServeHTTP(b *testing.B) {
+	logger := zap.NewNop()
+	ctx, _ := reconcilertesting.SetupFakeContext(b)
+
+	s := httptest.NewServer(handler())
+	defer s.Close()
+
+	br := makeBroker("name", "ns")
+	br.Status.Annotations = map[string]string{
+		eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+	}
+	brokerinformerfake.Get(ctx).Informer().GetStore().Add(br)
+
+	h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+	if err != nil {
+		b.Fatal("Unable to create receiver:", err)
+	}
+
+	body := []byte(`{"hello":"world"}`)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", bytes.NewReader(body))
+		request.Header.Set(cehttp.ContentType, event.ApplicationJSON)
+		request.Header.Set("Ce-Specversion", "1.0")
+		request.Header.Set("Ce-Id", "1234")
+		request.Header.Set("Ce-Type", "type")
+		request.Header.Set("Ce-Source", "source")
+		request.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		request.Header.Set("X-Request-Id", "request-id")
+		request.Header.Set("User-Agent", "benchmark")
+
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, request)
+		if recorder.Code != senderResponseStatusCode {
+			b.Fatalf("expected status code %d got %d", senderResponseStatusCode, recorder.Code)
+		}
+	}
+}
+
+func TestHandler_ChannelRetryAfter(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name           string
+		disabled       bool
+		maxRetryAfter  time.Duration
+		retryAfter     func() string
+		wantStatusCode int
+		// wantRetryAfter is the range of the Retry-After seconds the producer is asked to
+		// wait, the HTTP-dates being precise to the second.
+		wantRetryAfter []int64
+	}{
+		{
+			name:           "seconds",
+			retryAfter:     func() string { return "3" },
+			wantStatusCode: nethttp.StatusTooManyRequests,
+			wantRetryAfter: []int64{3, 3},
+		},
+		{
+			name: "HTTP-date",
+			retryAfter: func() string {
+				return time.Now().Add(time.Minute).UTC().Format(nethttp.TimeFormat)
+			},
+			wantStatusCode: nethttp.StatusTooManyRequests,
+			wantRetryAfter: []int64{59, 60},
+		},
+		{
+			name:           "capped",
+			maxRetryAfter:  10 * time.Second,
+			retryAfter:     func() string { return "120" },
+			wantStatusCode: nethttp.StatusTooManyRequests,
+			wantRetryAfter: []int64{10, 10},
+		},
+		{
+			name:           "invalid",
+			retryAfter:     func() string { return "soon" },
+			wantStatusCode: nethttp.StatusTooManyRequests,
+		},
+		{
+			name:           "disabled",
+			disabled:       true,
+			retryAfter:     func() string { return "3" },
+			wantStatusCode: nethttp.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			s := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				w.Header().Set("Retry-After", tc.retryAfter())
+				w.WriteHeader(nethttp.StatusTooManyRequests)
+			}))
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.HonorChannelRetryAfter = !tc.disabled
+			h.MaxChannelRetryAfter = tc.maxRetryAfter
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatusCode {
+				t.Errorf("expected status code %d got %d", tc.wantStatusCode, recorder.Code)
+			}
+			got := recorder.Header().Get("Retry-After")
+			if tc.wantRetryAfter == nil {
+				if got != "" {
+					t.Errorf("unexpected Retry-After %q", got)
+				}
+				return
+			}
+			seconds, err := strconv.ParseInt(got, 10, 64)
+			if err != nil || seconds < tc.wantRetryAfter[0] || seconds > tc.wantRetryAfter[1] {
+				t.Errorf("expected Retry-After in %v got %q", tc.wantRetryAfter, got)
+			}
+		})
+	}
+}
+
+func TestHandler_ChannelRetryAfterSynchronousRetries(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name     string
+		disabled bool
+		// wantDelay is the range of the delay between the request and its retry.
+		wantDelay []time.Duration
+	}{
+		{
+			name:      "honored",
+			wantDelay: []time.Duration{time.Second - 100*time.Millisecond, 5 * time.Second},
+		},
+		{
+			name:      "disabled",
+			disabled:  true,
+			wantDelay: []time.Duration{0, 500 * time.Millisecond},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			requests := make(chan time.Time, 2)
+			var attempts atomic.Int32
+			s := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				requests <- time.Now()
+				if attempts.Add(1) == 1 {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(nethttp.StatusTooManyRequests)
+					return
+				}
+				w.WriteHeader(senderResponseStatusCode)
+			}))
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &mockReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.DeliveryGuarantee = DeliveryGuaranteeAtLeastOnce
+			h.RetryConfig = &kncloudevents.RetryConfig{
+				RetryMax:   1,
+				CheckRetry: kncloudevents.SelectiveRetry,
+				Backoff: func(int, *nethttp.Response) time.Duration {
+					return 0
+				},
+			}
+			h.HonorChannelRetryAfter = !tc.disabled
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != senderResponseStatusCode {
+				t.Fatalf("expected status code %d got %d", senderResponseStatusCode, recorder.Code)
+			}
+			if len(requests) != 2 {
+				t.Fatalf("expected the event to be retried once, got %d requests", len(requests))
+			}
+			first, retried := <-requests, <-requests
+			if delay := retried.Sub(first); delay < tc.wantDelay[0] || delay > tc.wantDelay[1] {
+				t.Errorf("expected the retry after a delay in %v got %v", tc.wantDelay, delay)
+			}
+		})
+	}
+}
+
+func TestHandler_RetryQueueChannelRetryAfter(t *testing.T) {
+	logger := zap.NewNop()
+
+	tt := []struct {
+		name       string
+		retryAfter func(now time.Time) string
+		wantDelay  time.Duration
+	}{
+		{
+			name:       "seconds",
+			retryAfter: func(time.Time) string { return "1" },
+			wantDelay:  time.Second,
+		},
+		{
+			name: "HTTP-date",
+			retryAfter: func(now time.Time) string {
+				return now.Add(2 * time.Second).Format(nethttp.TimeFormat)
+			},
+			wantDelay: 2 * time.Second,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := reconcilertesting.SetupFakeContext(t)
+
+			// The HTTP-date has a precision of a second.
+			fakeClock := clocktesting.NewFakeClock(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+
+			var requests atomic.Int32
+			retried := make(chan struct{})
+			s := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				if requests.Add(1) == 1 {
+					w.Header().Set("Retry-After", tc.retryAfter(fakeClock.Now()))
+					w.WriteHeader(nethttp.StatusTooManyRequests)
+					return
+				}
+				w.WriteHeader(senderResponseStatusCode)
+				close(retried)
+			}))
+			defer s.Close()
+
+			b := makeBroker("name", "ns")
+			b.Status.Annotations = map[string]string{
+				eventing.BrokerChannelAddressStatusAnnotationKey: s.URL,
+			}
+			brokerinformerfake.Get(ctx).Informer().GetStore().Add(b)
+
+			h, err := NewHandler(logger, &retryQueueReporter{}, broker.TTLDefaulter(logger, 100), brokerinformerfake.Get(ctx))
+			if err != nil {
+				t.Fatal("Unable to create receiver:", err)
+			}
+			h.DeliveryGuarantee = DeliveryGuaranteeAtLeastOnce
+			h.RetryQueueMaxRetries = 3
+			// Only the delay asked by the channel lets the retry happen during the test.
+			h.RetryQueueBackoff = time.Hour
+			h.HonorChannelRetryAfter = true
+			h.clock = fakeClock
+
+			request := httptest.NewRequest(nethttp.MethodPost, "/ns/name", getValidEvent())
+			request.Header.Set(cehttp.ContentType, event.ApplicationCloudEventsJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, request)
+
+			if recorder.Code != nethttp.StatusAccepted {
+				t.Fatalf("expected status code %d got %d", nethttp.StatusAccepted, recorder.Code)
+			}
+			if seconds, err := strconv.ParseInt(recorder.Header().Get("Retry-After"), 10, 64); err != nil || seconds < 1 {
+				t.Errorf("expected the channel Retry-After to be passed on, got %q", recorder.Header().Get("Retry-After"))
+			}
+
+			err = wait.PollImmediate(5*time.Millisecond, 5*time.Second, func() (bool, error) {
+				return fakeClock.HasWaiters(), nil
+			})
+			if err != nil {
+				t.Fatal("timeout waiting for the retry to be scheduled")
+			}
+			fakeClock.Step(tc.wantDelay - time.Millisecond)
+			if !fakeClock.HasWaiters() || requests.Load() != 1 {
+				t.Fatalf("expected no retry before %v", tc.wantDelay)
+			}
+			fakeClock.Step(time.Millisecond)
+			select {
+			case <-retried:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for the retry honoring the channel Retry-After")
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"k8s.io/apimachinery/pkg/types"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+
+	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
+)
+
+const (
+	// partitionKeyExtension is the CloudEvents partitioning extension attribute.
+	partitionKeyExtension = "partitionkey"
+
+	// defaultMaxCachedBrokers is the default number of brokers for which per-broker state
+	// is kept when Handler.MaxCachedBrokers is not set.
+	defaultMaxCachedBrokers = 1000
+)
+
+// WeightedAddress is a channel address with its relative weight for load spreading.
+type WeightedAddress struct {
+	Address duckv1.Addressable
+	// Weight is the share of events dispatched to Address relative to the other
+	// addresses. Addresses with a non-positive weight never receive events.
+	Weight int32
+}
+
+// ChannelAddressesResolver resolves the equivalent channel addresses of a broker
+// (e.g. one per channel replica) to spread the dispatched events across.
+type ChannelAddressesResolver func(b *eventingv1.Broker) ([]WeightedAddress, error)
+
+// weightedRoundRobin distributes events across weighted addresses proportionally to
+// their weights.
+type weightedRoundRobin struct {
+	lock sync.Mutex
+	// counters holds the round-robin position of the most recently used brokers.
+	// Evicting a broker only resets its position.
+	counters *simplelru.LRU
+}
+
+func newWeightedRoundRobin(maxBrokers int) *weightedRoundRobin {
+	if maxBrokers <= 0 {
+		maxBrokers = defaultMaxCachedBrokers
+	}
+	// NewLRU only fails for a non-positive size.
+	counters, _ := simplelru.NewLRU(maxBrokers, nil)
+	return &weightedRoundRobin{counters: counters}
+}
+
+// pick returns the address the event should be dispatched to, or false when there are
+// no addresses with a positive weight.
+// Events with a partition key are always dispatched to the same address as long as the
+// addresses don't change, other events are dispatched in a round-robin fashion.
+func (w *weightedRoundRobin) pick(broker types.NamespacedName, event *cloudevents.Event, addresses []WeightedAddress) (*duckv1.Addressable, bool) {
+	var total uint64
+	for _, a := range addresses {
+		if a.Weight > 0 {
+			total += uint64(a.Weight)
+		}
+	}
+	if total == 0 {
+		return nil, false
+	}
+
+	var n uint64
+	if key, ok := event.Extensions()[partitionKeyExtension]; ok {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(fmt.Sprint(key)))
+		n = h.Sum64() % total
+	} else {
+		n = w.next(broker) % total
+	}
+
+	for i := range addresses {
+		if addresses[i].Weight <= 0 {
+			continue
+		}
+		if n < uint64(addresses[i].Weight) {
+			return &addresses[i].Address, true
+		}
+		n -= uint64(addresses[i].Weight)
+	}
+	// Unreachable, n is always lower than the total weight.
+	return nil, false
+}
+
+func (w *weightedRoundRobin) next(broker types.NamespacedName) uint64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var n uint64
+	if v, ok := w.counters.Get(broker); ok {
+		n = v.(uint64)
+	}
+	w.counters.Add(broker, n+1)
+	return n
+}
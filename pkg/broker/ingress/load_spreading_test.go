@@ -0,0 +1,136 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+func TestWeightedRoundRobin(t *testing.T) {
+	broker := types.NamespacedName{Namespace: "ns", Name: "name"}
+	address := func(host string, weight int32) WeightedAddress {
+		return WeightedAddress{
+			Address: duckv1.Addressable{URL: apis.HTTP(host)},
+			Weight:  weight,
+		}
+	}
+
+	tt := []struct {
+		name         string
+		addresses    []WeightedAddress
+		partitionKey string
+		events       int
+		want         map[string]int
+	}{
+		{
+			name:      "no addresses",
+			addresses: nil,
+			events:    4,
+			want:      map[string]int{},
+		},
+		{
+			name:      "no positive weight",
+			addresses: []WeightedAddress{address("a", 0), address("b", -1)},
+			events:    4,
+			want:      map[string]int{},
+		},
+		{
+			name:      "equal weights",
+			addresses: []WeightedAddress{address("a", 1), address("b", 1)},
+			events:    8,
+			want:      map[string]int{"a": 4, "b": 4},
+		},
+		{
+			name:      "proportional to weights",
+			addresses: []WeightedAddress{address("a", 3), address("b", 1), address("c", 0)},
+			events:    8,
+			want:      map[string]int{"a": 6, "b": 2},
+		},
+		{
+			name:         "partition key",
+			addresses:    []WeightedAddress{address("a", 1), address("b", 1), address("c", 1)},
+			partitionKey: "key",
+			events:       8,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := newWeightedRoundRobin(0)
+
+			got := make(map[string]int)
+			for i := 0; i < tc.events; i++ {
+				e := event.New()
+				if tc.partitionKey != "" {
+					e.SetExtension(partitionKeyExtension, tc.partitionKey)
+				}
+				addr, ok := w.pick(broker, &e, tc.addresses)
+				if !ok {
+					continue
+				}
+				got[addr.URL.Host]++
+			}
+
+			if tc.partitionKey != "" {
+				if len(got) != 1 {
+					t.Errorf("expected events with the same partition key to be dispatched to a single address, got %v", got)
+				}
+				return
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected distribution (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestWeightedRoundRobin_MaxBrokers(t *testing.T) {
+	const maxBrokers = 10
+
+	w := newWeightedRoundRobin(maxBrokers)
+	addresses := []WeightedAddress{
+		{Address: duckv1.Addressable{URL: apis.HTTP("a")}, Weight: 1},
+		{Address: duckv1.Addressable{URL: apis.HTTP("b")}, Weight: 1},
+	}
+
+	e := event.New()
+	for i := 0; i < 10*maxBrokers; i++ {
+		broker := types.NamespacedName{Namespace: "ns", Name: fmt.Sprintf("broker-%d", i)}
+		if _, ok := w.pick(broker, &e, addresses); !ok {
+			t.Fatalf("expected an address for broker %s", broker)
+		}
+		if w.counters.Len() > maxBrokers {
+			t.Fatalf("expected at most %d brokers, got %d", maxBrokers, w.counters.Len())
+		}
+	}
+	if w.counters.Len() != maxBrokers {
+		t.Errorf("expected %d brokers, got %d", maxBrokers, w.counters.Len())
+	}
+
+	// The most recently used broker keeps its position.
+	last := types.NamespacedName{Namespace: "ns", Name: fmt.Sprintf("broker-%d", 10*maxBrokers-1)}
+	if addr, _ := w.pick(last, &e, addresses); addr.URL.Host != "b" {
+		t.Errorf("expected the second address for broker %s, got %s", last, addr.URL.Host)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.opencensus.io/stats/view"
+)
+
+// MetricDescriptor describes a metric exported by the ingress.
+type MetricDescriptor struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Type is the aggregation of the metric: count, distribution, lastvalue or sum.
+	Type string `json:"type"`
+	Unit string `json:"unit"`
+	// LabelKeys are the keys of the labels the metric is broken down by.
+	LabelKeys []string `json:"labelKeys"`
+}
+
+// MetricsDescriber describes the metrics a StatsReporter exports.
+type MetricsDescriber interface {
+	DescribeMetrics() []MetricDescriptor
+}
+
+// DescribeMetrics returns the descriptors of the registered ingress views, so that they
+// never drift from the exported metrics.
+func (r *reporter) DescribeMetrics() []MetricDescriptor {
+	descriptors := make([]MetricDescriptor, 0, len(views))
+	for _, v := range views {
+		registered := view.Find(v.Measure.Name())
+		if registered == nil {
+			continue
+		}
+		labelKeys := make([]string, 0, len(registered.TagKeys))
+		for _, k := range registered.TagKeys {
+			labelKeys = append(labelKeys, k.Name())
+		}
+		descriptors = append(descriptors, MetricDescriptor{
+			Name:        registered.Name,
+			Description: registered.Description,
+			Type:        strings.ToLower(registered.Aggregation.Type.String()),
+			Unit:        registered.Measure.Unit(),
+			LabelKeys:   labelKeys,
+		})
+	}
+	return descriptors
+}
+
+// NewMetricsDescriptorHandler returns a handler responding with the JSON descriptors of
+// the metrics described by d, to help building dashboards.
+func NewMetricsDescriptorHandler(d MetricsDescriber) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(d.DescribeMetrics())
+	})
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+// rewritePath returns the address the event is dispatched to, with the path returned by
+// PathRewrite, if any. The resolved address is kept when PathRewrite isn't set or returns
+// an empty path.
+func (h *Handler) rewritePath(target *duckv1.Addressable, event *cloudevents.Event) *duckv1.Addressable {
+	if h.PathRewrite == nil || target.URL == nil {
+		return target
+	}
+	path := h.PathRewrite(*target, event)
+	if path == "" {
+		return target
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	// The resolved address might be shared, e.g. cached from the broker status.
+	rewritten := target.DeepCopy()
+	rewritten.URL.Path = path
+	rewritten.URL.RawPath = ""
+	return rewritten
+}
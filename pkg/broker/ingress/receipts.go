@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+
+	"knative.dev/eventing/pkg/kncloudevents"
+)
+
+const (
+	// ReceiptEventType is the type of the delivery receipts sent to the ReceiptSink.
+	ReceiptEventType = "dev.knative.eventing.broker.receipt"
+
+	// receiptTimeout bounds the time spent sending a delivery receipt.
+	receiptTimeout = 10 * time.Second
+)
+
+// Receipt is the data of a delivery receipt, recording the dispatch of an event to the
+// channel of a broker.
+type Receipt struct {
+	EventID     string    `json:"eventId"`
+	EventSource string    `json:"eventSource"`
+	EventType   string    `json:"eventType"`
+	Broker      string    `json:"broker"`
+	Time        time.Time `json:"time"`
+	StatusCode  int       `json:"statusCode"`
+}
+
+// emitReceipt sends, in the background, the delivery receipt of the event dispatched to the
+// channel of the broker to the ReceiptSink, if any. Failing to send the receipt is logged
+// and doesn't affect the dispatch.
+func (h *Handler) emitReceipt(event *cloudevents.Event, broker types.NamespacedName, statusCode int) {
+	if h.ReceiptSink == nil {
+		return
+	}
+
+	receipt := cloudevents.NewEvent()
+	receipt.SetID(uuid.New().String())
+	receipt.SetType(ReceiptEventType)
+	receipt.SetSource("/apis/eventing.knative.dev/v1/namespaces/" + broker.Namespace + "/brokers/" + broker.Name)
+	receipt.SetSubject(event.ID())
+	if err := receipt.SetData(cloudevents.ApplicationJSON, Receipt{
+		EventID:     event.ID(),
+		EventSource: event.Source(),
+		EventType:   event.Type(),
+		Broker:      broker.String(),
+		Time:        time.Now(),
+		StatusCode:  statusCode,
+	}); err != nil {
+		h.Logger.Warn("failed to encode the delivery receipt", zap.String("event.id", event.ID()), zap.Error(err))
+		return
+	}
+
+	sink := *h.ReceiptSink
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), receiptTimeout)
+		defer cancel()
+		if _, err := kncloudevents.SendEvent(ctx, receipt, sink); err != nil {
+			h.Logger.Warn("failed to send the delivery receipt",
+				zap.String("event.id", event.ID()),
+				zap.String("broker", broker.String()),
+				zap.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.uber.org/zap"
+
+	"knative.dev/eventing/pkg/kncloudevents"
+)
+
+// ReplayOutcome describes how a replayed event went through the ingress.
+type ReplayOutcome struct {
+	// StatusCode is the status code the ingress would have responded to the producer.
+	StatusCode int `json:"statusCode"`
+	// Duration is the time spent receiving the event, including the dispatch.
+	Duration time.Duration `json:"duration"`
+	// DispatchTime is the duration of the dispatch to the channel, or
+	// kncloudevents.NoDuration when the event wasn't dispatched.
+	DispatchTime time.Duration `json:"dispatchTime"`
+	// ResolutionMethod is how the channel address was resolved, empty when the event was
+	// rejected before resolving it.
+	ResolutionMethod string `json:"resolutionMethod,omitempty"`
+	// ChannelHost is the host of the channel address the event was dispatched to.
+	ChannelHost string `json:"channelHost,omitempty"`
+	// Event is the event as dispatched to the channel, i.e. defaulted and with the ingress
+	// extensions set, nil when the event wasn't dispatched.
+	Event *cloudevents.Event `json:"event,omitempty"`
+	// Error is the error of the dispatch, if any.
+	Error string `json:"error,omitempty"`
+}
+
+type replayOutcomeKey struct{}
+
+// withReplayOutcome returns a context recording the dispatch details into outcome.
+func withReplayOutcome(ctx context.Context, outcome *ReplayOutcome) context.Context {
+	return context.WithValue(ctx, replayOutcomeKey{}, outcome)
+}
+
+// replayOutcomeFrom returns the outcome of the replay the context belongs to, if any.
+func replayOutcomeFrom(ctx context.Context) *ReplayOutcome {
+	outcome, _ := ctx.Value(replayOutcomeKey{}).(*ReplayOutcome)
+	return outcome
+}
+
+// Replay runs the event through the receive path of the ingress (defaulting, TTL check,
+// channel address resolution and dispatch) to the given broker and returns the detailed
+// outcome, to reproduce a problematic delivery. Replayed events are never collapsed with
+// the in-flight events and their outcome isn't reported in the metrics.
+func (h *Handler) Replay(ctx context.Context, namespace, name string, event cloudevents.Event) ReplayOutcome {
+	outcome := ReplayOutcome{DispatchTime: kncloudevents.NoDuration}
+	if err := h.validate(&event); err != nil {
+		outcome.StatusCode = http.StatusBadRequest
+		outcome.Error = err.Error()
+		return outcome
+	}
+
+	args := &ReportArgs{
+		ns:        namespace,
+		broker:    name,
+		eventType: event.Type(),
+	}
+	start := time.Now()
+	result := h.dispatch(withReplayOutcome(ctx, &outcome), make(http.Header), &event, args)
+	outcome.Duration = time.Since(start)
+	outcome.StatusCode = result.statusCode
+	outcome.DispatchTime = result.dispatchTime
+	return outcome
+}
+
+// ReplayHandler returns the debug endpoint replaying the events posted to /<namespace>/<name>
+// through the ingress and responding with their ReplayOutcome as JSON. The endpoint responds
+// 404 Not Found unless ReplayEnabled is set, and rejects the requests not authorized by
+// ReplayAuthorizer.
+func (h *Handler) ReplayHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !h.ReplayEnabled {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if h.ReplayAuthorizer == nil {
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := h.ReplayAuthorizer(request); err != nil {
+			h.Logger.Warn("unauthorized replay request", zap.Error(err))
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if request.Method != http.MethodPost {
+			writer.Header().Set("Allow", http.MethodPost)
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		namespace, name, err := parseBrokerRef(request.URL.RequestURI(), "")
+		if err != nil {
+			writeBadRequest(writer, err)
+			return
+		}
+
+		message := cehttp.NewMessageFromHttpRequest(request)
+		defer message.Finish(nil)
+		event, err := binding.ToEvent(request.Context(), message)
+		if err != nil {
+			writeBadRequest(writer, err)
+			return
+		}
+		if event == nil {
+			writeBadRequest(writer, errors.New("no event to replay"))
+			return
+		}
+
+		h.Logger.Info("replaying event",
+			zap.String("event.id", event.ID()),
+			zap.String("broker", namespace+"/"+name))
+		outcome := h.Replay(request.Context(), namespace, name, *event)
+
+		writer.Header().Set(cehttp.ContentType, "application/json")
+		_ = json.NewEncoder(writer).Encode(outcome)
+	})
+}
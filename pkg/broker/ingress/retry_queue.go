@@ -0,0 +1,253 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+
+	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
+	"knative.dev/eventing/pkg/kncloudevents"
+)
+
+const (
+	// defaultRetryQueueBackoff is the delay before the first retry of a queued event.
+	defaultRetryQueueBackoff = time.Second
+	// maxRetryQueueBackoff caps the backoff doubling with each retry of a queued event.
+	maxRetryQueueBackoff = 5 * time.Minute
+	// defaultRetryQueueSize caps the number of events queued for retries.
+	defaultRetryQueueSize = 1000
+)
+
+// retryItem is an event queued to be dispatched again to the broker channel.
+type retryItem struct {
+	event          cloudevents.Event
+	channelAddress duckv1.Addressable
+	contentMode    string
+	opts           []kncloudevents.SendOption
+	// deadLetterSink receives the event once the retries are exhausted, nil when the broker
+	// has no dead letter sink.
+	deadLetterSink *duckv1.Addressable
+	// broker is the broker the event was sent to.
+	broker types.NamespacedName
+	// retryAfter is the delay before the next retry asked by the channel, 0 to back off
+	// following RetryQueueBackoff.
+	retryAfter time.Duration
+	// span is the span of the request the event was received with, the retries are traced
+	// as part of its trace.
+	span     *trace.Span
+	attempts int
+	// final makes the next attempt the last one, when the queue is flushed.
+	final bool
+}
+
+// isRetryableDispatch returns whether the failure to dispatch an event to the channel is
+// worth retrying, following the same rules as the synchronous retries.
+func isRetryableDispatch(dispatchInfo *kncloudevents.DispatchInfo, err error) bool {
+	if err == nil {
+		return false
+	}
+	if dispatchInfo == nil {
+		return true
+	}
+	retry, _ := kncloudevents.SelectiveRetry(context.Background(), &http.Response{StatusCode: dispatchInfo.ResponseCode}, nil)
+	return retry
+}
+
+// deadLetterSink returns the resolved dead letter sink of the broker, if any.
+func deadLetterSink(b *eventingv1.Broker) *duckv1.Addressable {
+	if b == nil || b.Status.DeadLetterSinkURI == nil {
+		return nil
+	}
+	return &duckv1.Addressable{
+		URL:     b.Status.DeadLetterSinkURI,
+		CACerts: b.Status.DeadLetterSinkCACerts,
+	}
+}
+
+// enqueueRetry queues the event for retries in the background, unless the queue is full or
+// flushed.
+func (h *Handler) enqueueRetry(item *retryItem) bool {
+	// The event is counted under the lock checking the flush, so that FlushRetryQueue waits
+	// for every queued event.
+	h.retryQueueMu.Lock()
+	if h.retryQueueFlushed {
+		h.retryQueueMu.Unlock()
+		return false
+	}
+	h.retryQueueWG.Add(1)
+	h.retryQueueMu.Unlock()
+
+	size := h.RetryQueueSize
+	if size <= 0 {
+		size = defaultRetryQueueSize
+	}
+	depth := h.retryQueueDepth.Add(1)
+	if depth > int64(size) {
+		h.retryQueueDepth.Add(-1)
+		h.retryQueueWG.Done()
+		h.Logger.Warn("retry queue full, not queuing the event",
+			zap.String("event.id", item.event.ID()),
+			zap.Int("retryQueueSize", size))
+		return false
+	}
+	_ = h.Reporter.ReportRetryQueueDepth(depth)
+
+	h.scheduleRetry(item)
+	return true
+}
+
+// scheduleRetry retries the event after a backoff doubling with each attempt, or after the
+// delay asked by the channel. Once the queue is flushed, the event is retried right away a
+// last time.
+func (h *Handler) scheduleRetry(item *retryItem) {
+	h.retryQueueMu.Lock()
+	defer h.retryQueueMu.Unlock()
+	if h.retryQueueFlushed {
+		item.final = true
+		go h.retry(item)
+		return
+	}
+
+	delay := retryBackoff(h.RetryQueueBackoff, item.attempts)
+	if item.retryAfter > 0 {
+		delay = item.retryAfter
+	}
+	if h.retryTimers == nil {
+		h.retryTimers = make(map[*retryItem]clock.Timer)
+	}
+	h.retryTimers[item] = h.retryClock().AfterFunc(delay, func() {
+		h.retryQueueMu.Lock()
+		_, scheduled := h.retryTimers[item]
+		delete(h.retryTimers, item)
+		h.retryQueueMu.Unlock()
+		// Otherwise it's retried by FlushRetryQueue. The retry doesn't hold up the clock
+		// firing the timer, as the fake clocks of the tests run it synchronously.
+		if scheduled {
+			go h.retry(item)
+		}
+	})
+}
+
+// retryClock returns the clock scheduling the retries, the real clock unless set.
+func (h *Handler) retryClock() clock.WithDelayedExecution {
+	if h.clock == nil {
+		return clock.RealClock{}
+	}
+	return h.clock
+}
+
+// retryBackoff returns the backoff before the retry following the given number of attempts,
+// doubling with each attempt up to maxRetryQueueBackoff, or the initial backoff if larger.
+func retryBackoff(initial time.Duration, attempts int) time.Duration {
+	if initial <= 0 {
+		initial = defaultRetryQueueBackoff
+	}
+	limit := maxRetryQueueBackoff
+	if initial > limit {
+		limit = initial
+	}
+	backoff := initial
+	for i := 0; i < attempts && backoff < limit; i++ {
+		backoff *= 2
+	}
+	if backoff > limit {
+		return limit
+	}
+	return backoff
+}
+
+// FlushRetryQueue retries the events queued for retries right away, a last time, and waits
+// for them until ctx is done. It's meant to be called once the ingress stopped receiving
+// events, as the queue is kept in memory. The events queued afterwards are rejected as
+// without the queue.
+func (h *Handler) FlushRetryQueue(ctx context.Context) {
+	h.retryQueueMu.Lock()
+	h.retryQueueFlushed = true
+	items := make([]*retryItem, 0, len(h.retryTimers))
+	for item, timer := range h.retryTimers {
+		timer.Stop()
+		items = append(items, item)
+	}
+	h.retryTimers = nil
+	h.retryQueueMu.Unlock()
+
+	for _, item := range items {
+		item.final = true
+		go h.retry(item)
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		h.retryQueueWG.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		h.Logger.Info("flushed the retry queue", zap.Int("events", len(items)))
+	case <-ctx.Done():
+		h.Logger.Error("failed to flush the retry queue, the events left are lost",
+			zap.Int64("events", h.retryQueueDepth.Load()),
+			zap.Error(ctx.Err()))
+	}
+}
+
+// retry dispatches the queued event again. The last attempt falls back to the dead letter
+// sink of the broker.
+func (h *Handler) retry(item *retryItem) {
+	item.attempts++
+	last := item.attempts >= h.RetryQueueMaxRetries || item.final
+
+	opts := item.opts
+	if last && item.deadLetterSink != nil {
+		opts = append(opts[:len(opts):len(opts)], kncloudevents.WithDeadLetterSink(item.deadLetterSink))
+	}
+	ctx := context.Background()
+	if item.span != nil {
+		ctx = trace.NewContext(ctx, item.span)
+	}
+	ctx = h.withContentMode(ctx, item.contentMode)
+	dispatchInfo, err := kncloudevents.SendEvent(ctx, item.event, item.channelAddress, opts...)
+	if err != nil && !last && isRetryableDispatch(dispatchInfo, err) {
+		item.retryAfter, _ = h.channelRetryAfter(dispatchInfo)
+		h.scheduleRetry(item)
+		return
+	}
+
+	defer h.retryQueueWG.Done()
+	_ = h.Reporter.ReportRetryQueueDepth(h.retryQueueDepth.Add(-1))
+	h.reportDispatch(&item.event, item.channelAddress, dispatchInfo, err)
+	if err != nil {
+		h.Logger.Error("failed to dispatch queued event",
+			zap.String("event.id", item.event.ID()),
+			zap.Int("attempts", item.attempts),
+			zap.Error(err))
+		return
+	}
+	h.emitReceipt(&item.event, item.broker, dispatchInfo.ResponseCode)
+	h.Logger.Debug("dispatched queued event",
+		zap.String("event.id", item.event.ID()),
+		zap.Int("attempts", item.attempts))
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+
+	"knative.dev/eventing/pkg/apis/eventing"
+	eventingv1 "knative.dev/eventing/pkg/apis/eventing/v1"
+)
+
+const (
+	// defaultRoutingOverrideExtension is the event extension attribute carrying the routing
+	// override target when Handler.RoutingOverrideExtension is not set.
+	defaultRoutingOverrideExtension = "routingoverride"
+
+	// channelAddressFromRoutingOverride signals that the channel address was resolved from
+	// the routing override extension of the event.
+	channelAddressFromRoutingOverride = "routingoverride"
+)
+
+// routingOverrideAddress returns the address the event requests to be routed to, when the
+// broker allows it, and whether the event requested a routing override at all.
+func (h *Handler) routingOverrideAddress(event *cloudevents.Event, b *eventingv1.Broker) (*duckv1.Addressable, bool) {
+	extension := h.RoutingOverrideExtension
+	if extension == "" {
+		extension = defaultRoutingOverrideExtension
+	}
+	value, ok := event.Extensions()[extension]
+	if !ok {
+		return nil, false
+	}
+
+	addr, err := allowedRoutingOverride(fmt.Sprint(value), b)
+	if err != nil {
+		h.Logger.Warn("ignoring routing override, using the broker channel address",
+			zap.String("event.id", event.ID()),
+			zap.Error(err))
+		return nil, true
+	}
+	return addr, true
+}
+
+// allowedRoutingOverride parses the override target and checks that it is one of the targets
+// allowed by the broker.
+func allowedRoutingOverride(target string, b *eventingv1.Broker) (*duckv1.Addressable, error) {
+	url := parseAbsoluteURL(target)
+	if url == nil {
+		return nil, fmt.Errorf("invalid routing override target %q", target)
+	}
+
+	allowed := b.GetAnnotations()[eventing.BrokerRoutingOverrideTargetsAnnotationKey]
+	for _, a := range strings.Split(allowed, ",") {
+		if allowedURL := parseAbsoluteURL(strings.TrimSpace(a)); allowedURL != nil && allowedURL.String() == url.String() {
+			return &duckv1.Addressable{URL: url}, nil
+		}
+	}
+	return nil, fmt.Errorf("routing override target %q not allowed by the broker", target)
+}
+
+// parseAbsoluteURL parses an absolute URL, returning nil if it isn't one.
+func parseAbsoluteURL(s string) *apis.URL {
+	url, err := apis.ParseURL(s)
+	if err != nil || url == nil || url.Scheme == "" || url.Host == "" {
+		return nil
+	}
+	return url
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"net/http"
+
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
+	"go.opencensus.io/trace"
+)
+
+// startSpan starts a span with the given name. When the Sampler is set, it decides whether
+// the span is recorded, unless the request traceparent header carries a sampling decision,
+// which is honored so that the traces of the producers stay complete.
+func (h *Handler) startSpan(ctx context.Context, request *http.Request, name string) (context.Context, *trace.Span) {
+	if h.Sampler == nil {
+		return trace.StartSpan(ctx, name)
+	}
+
+	sc, ok := (&tracecontext.HTTPFormat{}).SpanContextFromRequest(request)
+	if !ok {
+		return trace.StartSpan(ctx, name, trace.WithSampler(h.Sampler))
+	}
+	sampler := trace.NeverSample()
+	if sc.IsSampled() {
+		sampler = trace.AlwaysSample()
+	}
+	if trace.FromContext(ctx) == nil {
+		// The request isn't traced by the server, continue the trace of the producer.
+		return trace.StartSpanWithRemoteParent(ctx, name, sc, trace.WithSampler(sampler))
+	}
+	return trace.StartSpan(ctx, name, trace.WithSampler(sampler))
+}
@@ -49,6 +49,69 @@ var (
 		stats.UnitMilliseconds,
 	)
 
+	// channelResolutionCountM is a counter which records the number of
+	// attempts to resolve the address of a Broker's Channel.
+	channelResolutionCountM = stats.Int64(
+		"channel_resolution_count",
+		"Number of attempts to resolve the address of a Broker's Channel",
+		stats.UnitDimensionless,
+	)
+
+	// staleEventCountM is a counter which records the number of events
+	// rejected because their time attribute is older than the maximum age.
+	staleEventCountM = stats.Int64(
+		"stale_event_count",
+		"Number of events rejected by a Broker because they are too old",
+		stats.UnitDimensionless,
+	)
+
+	// tlsVerificationFailedCountM is a counter which records the number of
+	// events not dispatched because the Channel TLS certificate couldn't be verified.
+	tlsVerificationFailedCountM = stats.Int64(
+		"tls_verification_failed",
+		"Number of events not dispatched because the Channel TLS certificate couldn't be verified",
+		stats.UnitDimensionless,
+	)
+
+	// defaulterErrorCountM is a counter which records the number of events
+	// the Defaulter panicked on or produced an invalid event for.
+	defaulterErrorCountM = stats.Int64(
+		"defaulter_error",
+		"Number of events the Broker defaulter failed to default",
+		stats.UnitDimensionless,
+	)
+
+	// brokerNotAddressableCountM is a counter which records the number of events
+	// sent to a Broker whose Channel address wasn't resolved in time.
+	brokerNotAddressableCountM = stats.Int64(
+		"broker_not_addressable",
+		"Number of events rejected because the Broker wasn't addressable yet",
+		stats.UnitDimensionless,
+	)
+
+	// eventDroppedCountM is a counter which records the number of events
+	// intentionally dropped by the Broker, e.g. because their TTL is exhausted.
+	eventDroppedCountM = stats.Int64(
+		"event_dropped_count",
+		"Number of events intentionally dropped by a Broker",
+		stats.UnitDimensionless,
+	)
+
+	// eventRejectedCountM is a counter which records the number of requests
+	// rejected by the Broker before dispatch, e.g. because they have no body.
+	eventRejectedCountM = stats.Int64(
+		"event_rejected_count",
+		"Number of requests rejected by a Broker before dispatch",
+		stats.UnitDimensionless,
+	)
+
+	// retryQueueDepthM records the number of events queued for retries.
+	retryQueueDepthM = stats.Int64(
+		"retry_queue_depth",
+		"Number of events queued for retries",
+		stats.UnitDimensionless,
+	)
+
 	// Create the tag keys that will be used to add tags to our measurements.
 	// Tag keys must conform to the restrictions described in
 	// go.opencensus.io/tag/validate.go. Currently those restrictions are:
@@ -57,6 +120,13 @@ var (
 	eventTypeKey         = tag.MustNewKey(eventingmetrics.LabelEventType)
 	responseCodeKey      = tag.MustNewKey(eventingmetrics.LabelResponseCode)
 	responseCodeClassKey = tag.MustNewKey(eventingmetrics.LabelResponseCodeClass)
+	resolutionMethodKey  = tag.MustNewKey(eventingmetrics.LabelResolutionMethod)
+	resolutionSuccessKey = tag.MustNewKey(eventingmetrics.LabelResolutionSuccess)
+	dropReasonKey        = tag.MustNewKey(eventingmetrics.LabelDropReason)
+	rejectReasonKey      = tag.MustNewKey(eventingmetrics.LabelRejectReason)
+
+	// views are the views of the ingress measurements, registered by register.
+	views []*view.View
 )
 
 type ReportArgs struct {
@@ -73,23 +143,40 @@ func init() {
 type StatsReporter interface {
 	ReportEventCount(args *ReportArgs, responseCode int) error
 	ReportEventDispatchTime(args *ReportArgs, responseCode int, d time.Duration) error
+	ReportChannelResolution(args *ReportArgs, method string, success bool) error
+	ReportStaleEvent(args *ReportArgs) error
+	ReportTLSVerificationFailure(args *ReportArgs) error
+	ReportDefaulterError(args *ReportArgs) error
+	ReportBrokerNotAddressable(args *ReportArgs) error
+	ReportEventDropped(args *ReportArgs, reason string) error
+	ReportEventRejected(args *ReportArgs, reason string) error
+	ReportRetryQueueDepth(depth int64) error
 }
 
 var _ StatsReporter = (*reporter)(nil)
+var _ MetricsDescriber = (*reporter)(nil)
 var emptyContext = context.Background()
 
 // Reporter holds cached metric objects to report ingress metrics.
 type reporter struct {
 	container  string
 	uniqueName string
+
+	// eventTypeTag maps the event type to the value of the eventType tag, the tag is omitted
+	// when it returns false. When nil, the metrics are tagged with the event type.
+	eventTypeTag func(eventType string) (string, bool)
 }
 
 // NewStatsReporter creates a reporter that collects and reports ingress metrics.
-func NewStatsReporter(container, uniqueName string) StatsReporter {
-	return &reporter{
+func NewStatsReporter(container, uniqueName string, opts ...StatsReporterOption) StatsReporter {
+	r := &reporter{
 		container:  container,
 		uniqueName: uniqueName,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 func register() {
@@ -101,20 +188,93 @@ func register() {
 		broker.UniqueTagKey}
 
 	// Create view to see our measurements.
-	err := metrics.RegisterResourceView(
-		&view.View{
+	views = []*view.View{
+		{
 			Description: eventCountM.Description(),
 			Measure:     eventCountM,
 			Aggregation: view.Count(),
 			TagKeys:     tagKeys,
 		},
-		&view.View{
+		{
 			Description: dispatchTimeInMsecM.Description(),
 			Measure:     dispatchTimeInMsecM,
 			Aggregation: view.Distribution(metrics.Buckets125(1, 10000)...), // 1, 2, 5, 10, 20, 50, 100, 500, 1000, 5000, 10000
 			TagKeys:     tagKeys,
 		},
-	)
+		{
+			Description: channelResolutionCountM.Description(),
+			Measure:     channelResolutionCountM,
+			Aggregation: view.Count(),
+			TagKeys: []tag.Key{
+				resolutionMethodKey,
+				resolutionSuccessKey,
+				broker.ContainerTagKey,
+				broker.UniqueTagKey},
+		},
+		{
+			Description: staleEventCountM.Description(),
+			Measure:     staleEventCountM,
+			Aggregation: view.Count(),
+			TagKeys: []tag.Key{
+				eventTypeKey,
+				broker.ContainerTagKey,
+				broker.UniqueTagKey},
+		},
+		{
+			Description: tlsVerificationFailedCountM.Description(),
+			Measure:     tlsVerificationFailedCountM,
+			Aggregation: view.Count(),
+			TagKeys: []tag.Key{
+				broker.ContainerTagKey,
+				broker.UniqueTagKey},
+		},
+		{
+			Description: defaulterErrorCountM.Description(),
+			Measure:     defaulterErrorCountM,
+			Aggregation: view.Count(),
+			TagKeys: []tag.Key{
+				eventTypeKey,
+				broker.ContainerTagKey,
+				broker.UniqueTagKey},
+		},
+		{
+			Description: brokerNotAddressableCountM.Description(),
+			Measure:     brokerNotAddressableCountM,
+			Aggregation: view.Count(),
+			TagKeys: []tag.Key{
+				broker.ContainerTagKey,
+				broker.UniqueTagKey},
+		},
+		{
+			Description: eventDroppedCountM.Description(),
+			Measure:     eventDroppedCountM,
+			Aggregation: view.Count(),
+			TagKeys: []tag.Key{
+				eventTypeKey,
+				dropReasonKey,
+				broker.ContainerTagKey,
+				broker.UniqueTagKey},
+		},
+		{
+			Description: eventRejectedCountM.Description(),
+			Measure:     eventRejectedCountM,
+			Aggregation: view.Count(),
+			TagKeys: []tag.Key{
+				eventTypeKey,
+				rejectReasonKey,
+				broker.ContainerTagKey,
+				broker.UniqueTagKey},
+		},
+		{
+			Description: retryQueueDepthM.Description(),
+			Measure:     retryQueueDepthM,
+			Aggregation: view.LastValue(),
+			TagKeys: []tag.Key{
+				broker.ContainerTagKey,
+				broker.UniqueTagKey},
+		},
+	}
+	err := metrics.RegisterResourceView(views...)
 	if err != nil {
 		log.Printf("failed to register opencensus views, %s", err)
 	}
@@ -141,19 +301,135 @@ func (r *reporter) ReportEventDispatchTime(args *ReportArgs, responseCode int, d
 	return nil
 }
 
-func (r *reporter) generateTag(args *ReportArgs, responseCode int) (context.Context, error) {
-	ctx := metricskey.WithResource(emptyContext, resource.Resource{
+// ReportChannelResolution captures the outcome of resolving the Channel address.
+func (r *reporter) ReportChannelResolution(args *ReportArgs, method string, success bool) error {
+	ctx, err := tag.New(
+		r.resourceContext(args),
+		tag.Insert(broker.ContainerTagKey, r.container),
+		tag.Insert(broker.UniqueTagKey, r.uniqueName),
+		tag.Insert(resolutionMethodKey, method),
+		tag.Insert(resolutionSuccessKey, strconv.FormatBool(success)))
+	if err != nil {
+		return err
+	}
+	metrics.Record(ctx, channelResolutionCountM.M(1))
+	return nil
+}
+
+// ReportStaleEvent captures the rejection of an event older than the maximum age.
+func (r *reporter) ReportStaleEvent(args *ReportArgs) error {
+	ctx, err := tag.New(
+		r.resourceContext(args),
+		tag.Insert(broker.ContainerTagKey, r.container),
+		tag.Insert(broker.UniqueTagKey, r.uniqueName),
+		r.eventTypeMutator(args))
+	if err != nil {
+		return err
+	}
+	metrics.Record(ctx, staleEventCountM.M(1))
+	return nil
+}
+
+// ReportTLSVerificationFailure captures the failure to verify the Channel TLS certificate.
+func (r *reporter) ReportTLSVerificationFailure(args *ReportArgs) error {
+	ctx, err := tag.New(
+		r.resourceContext(args),
+		tag.Insert(broker.ContainerTagKey, r.container),
+		tag.Insert(broker.UniqueTagKey, r.uniqueName))
+	if err != nil {
+		return err
+	}
+	metrics.Record(ctx, tlsVerificationFailedCountM.M(1))
+	return nil
+}
+
+// ReportDefaulterError captures the failure of the Defaulter to default an event.
+func (r *reporter) ReportDefaulterError(args *ReportArgs) error {
+	ctx, err := tag.New(
+		r.resourceContext(args),
+		tag.Insert(broker.ContainerTagKey, r.container),
+		tag.Insert(broker.UniqueTagKey, r.uniqueName),
+		r.eventTypeMutator(args))
+	if err != nil {
+		return err
+	}
+	metrics.Record(ctx, defaulterErrorCountM.M(1))
+	return nil
+}
+
+// ReportBrokerNotAddressable captures the rejection of an event sent to a Broker without a
+// Channel address yet.
+func (r *reporter) ReportBrokerNotAddressable(args *ReportArgs) error {
+	ctx, err := tag.New(
+		r.resourceContext(args),
+		tag.Insert(broker.ContainerTagKey, r.container),
+		tag.Insert(broker.UniqueTagKey, r.uniqueName))
+	if err != nil {
+		return err
+	}
+	metrics.Record(ctx, brokerNotAddressableCountM.M(1))
+	return nil
+}
+
+// ReportEventDropped captures an event intentionally dropped for the given reason.
+func (r *reporter) ReportEventDropped(args *ReportArgs, reason string) error {
+	ctx, err := tag.New(
+		r.resourceContext(args),
+		tag.Insert(broker.ContainerTagKey, r.container),
+		tag.Insert(broker.UniqueTagKey, r.uniqueName),
+		r.eventTypeMutator(args),
+		tag.Insert(dropReasonKey, reason))
+	if err != nil {
+		return err
+	}
+	metrics.Record(ctx, eventDroppedCountM.M(1))
+	return nil
+}
+
+// ReportEventRejected captures a request rejected before dispatch for the given reason.
+func (r *reporter) ReportEventRejected(args *ReportArgs, reason string) error {
+	ctx, err := tag.New(
+		r.resourceContext(args),
+		tag.Insert(broker.ContainerTagKey, r.container),
+		tag.Insert(broker.UniqueTagKey, r.uniqueName),
+		r.eventTypeMutator(args),
+		tag.Insert(rejectReasonKey, reason))
+	if err != nil {
+		return err
+	}
+	metrics.Record(ctx, eventRejectedCountM.M(1))
+	return nil
+}
+
+// ReportRetryQueueDepth captures the number of events queued for retries.
+func (r *reporter) ReportRetryQueueDepth(depth int64) error {
+	ctx, err := tag.New(
+		emptyContext,
+		tag.Insert(broker.ContainerTagKey, r.container),
+		tag.Insert(broker.UniqueTagKey, r.uniqueName))
+	if err != nil {
+		return err
+	}
+	metrics.Record(ctx, retryQueueDepthM.M(depth))
+	return nil
+}
+
+func (r *reporter) resourceContext(args *ReportArgs) context.Context {
+	return metricskey.WithResource(emptyContext, resource.Resource{
 		Type: eventingmetrics.ResourceTypeKnativeBroker,
 		Labels: map[string]string{
 			eventingmetrics.LabelNamespaceName: args.ns,
 			eventingmetrics.LabelBrokerName:    args.broker,
 		},
 	})
+}
+
+func (r *reporter) generateTag(args *ReportArgs, responseCode int) (context.Context, error) {
 	return tag.New(
-		ctx,
+		r.resourceContext(args),
 		tag.Insert(broker.ContainerTagKey, r.container),
 		tag.Insert(broker.UniqueTagKey, r.uniqueName),
-		tag.Insert(eventTypeKey, args.eventType),
+		r.eventTypeMutator(args),
 		tag.Insert(responseCodeKey, strconv.Itoa(responseCode)),
 		tag.Insert(responseCodeClassKey, metrics.ResponseCodeClass(responseCode)))
 }
@@ -17,10 +17,15 @@ limitations under the License.
 package ingress
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"go.opencensus.io/resource"
 	broker "knative.dev/eventing/pkg/broker"
 	"knative.dev/eventing/pkg/metrics"
@@ -73,6 +78,242 @@ func TestStatsReporter(t *testing.T) {
 	})
 	metricstest.AssertMetric(t, metricstest.DistributionCountOnlyMetric("event_dispatch_latencies", 2, wantTags))
 	metricstest.CheckDistributionData(t, "event_dispatch_latencies", wantTags, 2, 1100.0, 9100.0)
+
+	// test ReportChannelResolution
+	expectSuccess(t, func() error {
+		return r.ReportChannelResolution(args, "annotation", true)
+	})
+	expectSuccess(t, func() error {
+		return r.ReportChannelResolution(args, "annotation", false)
+	})
+	expectSuccess(t, func() error {
+		return r.ReportChannelResolution(args, "annotation", false)
+	})
+	resolutionTags := func(success string) map[string]string {
+		return map[string]string{
+			metrics.LabelResolutionMethod:  "annotation",
+			metrics.LabelResolutionSuccess: success,
+			broker.LabelUniqueName:         "testpod",
+			broker.LabelContainerName:      "testcontainer",
+		}
+	}
+	resolutionMetric := metricstest.IntMetric("channel_resolution_count", 1, resolutionTags("true")).WithResource(&resource)
+	resolutionMetric.Values = append(resolutionMetric.Values,
+		metricstest.IntMetric("channel_resolution_count", 2, resolutionTags("false")).Values...)
+	metricstest.AssertMetric(t, resolutionMetric)
+
+	// test ReportStaleEvent
+	expectSuccess(t, func() error {
+		return r.ReportStaleEvent(args)
+	})
+	staleTags := map[string]string{
+		metrics.LabelEventType:    "testeventtype",
+		broker.LabelUniqueName:    "testpod",
+		broker.LabelContainerName: "testcontainer",
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric("stale_event_count", 1, staleTags).WithResource(&resource))
+
+	// test ReportTLSVerificationFailure
+	expectSuccess(t, func() error {
+		return r.ReportTLSVerificationFailure(args)
+	})
+	tlsTags := map[string]string{
+		broker.LabelUniqueName:    "testpod",
+		broker.LabelContainerName: "testcontainer",
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric("tls_verification_failed", 1, tlsTags).WithResource(&resource))
+
+	// test ReportDefaulterError
+	expectSuccess(t, func() error {
+		return r.ReportDefaulterError(args)
+	})
+	metricstest.AssertMetric(t, metricstest.IntMetric("defaulter_error", 1, staleTags).WithResource(&resource))
+
+	// test ReportBrokerNotAddressable
+	expectSuccess(t, func() error {
+		return r.ReportBrokerNotAddressable(args)
+	})
+	metricstest.AssertMetric(t, metricstest.IntMetric("broker_not_addressable", 1, tlsTags).WithResource(&resource))
+
+	// test ReportEventDropped
+	expectSuccess(t, func() error {
+		return r.ReportEventDropped(args, "ttl_exhausted")
+	})
+	droppedTags := map[string]string{
+		metrics.LabelEventType:    "testeventtype",
+		metrics.LabelDropReason:   "ttl_exhausted",
+		broker.LabelUniqueName:    "testpod",
+		broker.LabelContainerName: "testcontainer",
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric("event_dropped_count", 1, droppedTags).WithResource(&resource))
+
+	// test ReportEventRejected
+	expectSuccess(t, func() error {
+		return r.ReportEventRejected(args, "empty_body")
+	})
+	rejectedTags := map[string]string{
+		metrics.LabelEventType:    "testeventtype",
+		metrics.LabelRejectReason: "empty_body",
+		broker.LabelUniqueName:    "testpod",
+		broker.LabelContainerName: "testcontainer",
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric("event_rejected_count", 1, rejectedTags).WithResource(&resource))
+
+	// test ReportRetryQueueDepth
+	expectSuccess(t, func() error {
+		return r.ReportRetryQueueDepth(3)
+	})
+	expectSuccess(t, func() error {
+		return r.ReportRetryQueueDepth(2)
+	})
+	metricstest.AssertMetric(t, metricstest.IntMetric("retry_queue_depth", 2, tlsTags))
+}
+
+func TestStatsReporterEventTypeTag(t *testing.T) {
+	args := &ReportArgs{
+		ns:        "testns",
+		broker:    "testbroker",
+		eventType: "testeventtype",
+	}
+
+	tt := []struct {
+		name          string
+		opts          []StatsReporterOption
+		wantEventType string
+		wantOmitted   bool
+	}{
+		{
+			name:          "full",
+			wantEventType: "testeventtype",
+		},
+		{
+			name:        "omitted",
+			opts:        []StatsReporterOption{WithoutEventTypeTag()},
+			wantOmitted: true,
+		},
+		{
+			name:          "hashed",
+			opts:          []StatsReporterOption{WithHashedEventTypeTag(1)},
+			wantEventType: "bucket-0",
+		},
+		{
+			name:          "hashed without buckets",
+			opts:          []StatsReporterOption{WithHashedEventTypeTag(0)},
+			wantEventType: "testeventtype",
+		},
+		{
+			name:          "allowed",
+			opts:          []StatsReporterOption{WithEventTypeTagAllowList("testeventtype")},
+			wantEventType: "testeventtype",
+		},
+		{
+			name:          "not allowed",
+			opts:          []StatsReporterOption{WithEventTypeTagAllowList("othereventtype")},
+			wantEventType: otherEventType,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			setup()
+			r := NewStatsReporter("testcontainer", "testpod", tc.opts...)
+
+			wantTags := map[string]string{
+				metrics.LabelResponseCode:      "202",
+				metrics.LabelResponseCodeClass: "2xx",
+				broker.LabelUniqueName:         "testpod",
+				broker.LabelContainerName:      "testcontainer",
+			}
+			droppedTags := map[string]string{
+				metrics.LabelDropReason:   "ttl_exhausted",
+				broker.LabelUniqueName:    "testpod",
+				broker.LabelContainerName: "testcontainer",
+			}
+			if !tc.wantOmitted {
+				wantTags[metrics.LabelEventType] = tc.wantEventType
+				droppedTags[metrics.LabelEventType] = tc.wantEventType
+			}
+
+			expectSuccess(t, func() error {
+				return r.ReportEventCount(args, http.StatusAccepted)
+			})
+			metricstest.CheckCountData(t, "event_count", wantTags, 1)
+
+			expectSuccess(t, func() error {
+				return r.ReportEventDropped(args, "ttl_exhausted")
+			})
+			metricstest.CheckCountData(t, "event_dropped_count", droppedTags, 1)
+		})
+	}
+}
+
+func TestHashedEventTypeTagIsBounded(t *testing.T) {
+	r := &reporter{}
+	WithHashedEventTypeTag(4)(r)
+
+	values := map[string]struct{}{}
+	for i := 0; i < 100; i++ {
+		value, ok := r.eventTypeTag(fmt.Sprintf("dev.knative.type.%d", i))
+		if !ok {
+			t.Fatal("expected the eventType tag to be set")
+		}
+		values[value] = struct{}{}
+	}
+	if len(values) > 4 {
+		t.Errorf("expected at most 4 eventType tag values, got %d", len(values))
+	}
+
+	first, _ := r.eventTypeTag("dev.knative.type")
+	second, _ := r.eventTypeTag("dev.knative.type")
+	if first != second {
+		t.Errorf("expected the same eventType tag for the same event type, got %q and %q", first, second)
+	}
+}
+
+func TestDescribeMetrics(t *testing.T) {
+	setup()
+
+	r := NewStatsReporter("testcontainer", "testpod").(MetricsDescriber)
+
+	recorder := httptest.NewRecorder()
+	NewMetricsDescriptorHandler(r).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics/descriptors", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, recorder.Code)
+	}
+	var descriptors []MetricDescriptor
+	if err := json.Unmarshal(recorder.Body.Bytes(), &descriptors); err != nil {
+		t.Fatal("failed to unmarshal the metrics descriptors:", err)
+	}
+
+	got := make(map[string]MetricDescriptor, len(descriptors))
+	for _, d := range descriptors {
+		got[d.Name] = d
+	}
+	for _, v := range views {
+		if _, ok := got[v.Measure.Name()]; !ok {
+			t.Errorf("registered view %q not described", v.Measure.Name())
+		}
+	}
+	if len(descriptors) != len(views) {
+		t.Errorf("expected %d metrics described, got %d", len(views), len(descriptors))
+	}
+
+	want := MetricDescriptor{
+		Name:        "event_dispatch_latencies",
+		Description: "The time spent dispatching an event to a Channel",
+		Type:        "distribution",
+		Unit:        "ms",
+		LabelKeys: []string{
+			metrics.LabelEventType,
+			metrics.LabelResponseCode,
+			metrics.LabelResponseCodeClass,
+			broker.LabelContainerName,
+			broker.LabelUniqueName,
+		},
+	}
+	if diff := cmp.Diff(want, got["event_dispatch_latencies"], cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+		t.Errorf("unexpected descriptor (-want, +got): %s", diff)
+	}
 }
 
 func expectSuccess(t *testing.T, f func() error) {
@@ -90,6 +331,14 @@ func resetMetrics() {
 	// OpenCensus metrics carry global state that need to be reset between unit tests.
 	metricstest.Unregister(
 		"event_count",
-		"event_dispatch_latencies")
+		"event_dispatch_latencies",
+		"channel_resolution_count",
+		"stale_event_count",
+		"tls_verification_failed",
+		"defaulter_error",
+		"broker_not_addressable",
+		"event_dropped_count",
+		"event_rejected_count",
+		"retry_queue_depth")
 	register()
 }
@@ -0,0 +1,156 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/event"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// ValidationMode is the strictness of the incoming events validation.
+type ValidationMode string
+
+const (
+	// ValidationModeStrict rejects the events failing any CloudEvents validation.
+	ValidationModeStrict ValidationMode = "Strict"
+	// ValidationModeLenient only rejects the events with invalid required attributes
+	// (id, source, specversion and type), except for sources that aren't URI-references.
+	// Those and invalid optional attributes are logged and the event is dispatched anyway.
+	ValidationModeLenient ValidationMode = "Lenient"
+)
+
+// lenientAttributes are the attributes whose validation failures are downgraded to
+// warnings in ValidationModeLenient. The source is only downgraded when it isn't a
+// URI-reference, as emitted by some legacy producers. Missing required attributes are never
+// downgraded since the event can't be routed, filtered or deduplicated without them.
+var lenientAttributes = sets.NewString(
+	"datacontenttype",
+	"dataschema",
+	"source",
+	"subject",
+	"time",
+)
+
+// sourceHeader is the header carrying the source of binary mode events.
+const sourceHeader = "Ce-Source"
+
+// validate validates the event according to the handler ValidationMode, returning the
+// validation failures the event must be rejected for.
+func (h *Handler) validate(e *cloudevents.Event) error {
+	err := e.Validate()
+	if err == nil || h.ValidationMode != ValidationModeLenient {
+		return err
+	}
+
+	var validationErr event.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	rejected := event.ValidationError{}
+	for attribute, attributeErr := range validationErr {
+		if !lenientAttributes.Has(attribute) || (attribute == "source" && strings.TrimSpace(e.Source()) == "") {
+			rejected[attribute] = attributeErr
+			continue
+		}
+		h.Logger.Warn("dispatching event with invalid attribute",
+			zap.String("id", e.ID()),
+			zap.String("attribute", attribute),
+			zap.Error(attributeErr))
+	}
+	if len(rejected) == 0 {
+		return nil
+	}
+	return rejected
+}
+
+// takeInvalidSource removes the source of the event in the request when it isn't a
+// URI-reference, since the event can't be read with it, and returns it. The source is looked
+// up in the headers of binary mode events and in the body of structured mode events, which is
+// buffered for that.
+func takeInvalidSource(request *http.Request) (string, error) {
+	if request.Header.Get(specVersionHeader) != "" {
+		source := request.Header.Get(sourceHeader)
+		if source == "" || types.ParseURIRef(source) != nil {
+			return "", nil
+		}
+		request.Header.Del(sourceHeader)
+		return source, nil
+	}
+	if !strings.HasPrefix(request.Header.Get(cehttp.ContentType), cloudevents.ApplicationCloudEventsJSON) {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return "", err
+	}
+	source, stripped := stripInvalidSource(body)
+	if source != "" {
+		body = stripped
+	}
+	request.Body = io.NopCloser(bytes.NewReader(body))
+	request.ContentLength = int64(len(body))
+	return source, nil
+}
+
+// stripInvalidSource returns the source of the structured mode event when it isn't a
+// URI-reference, and the event without it.
+func stripInvalidSource(body []byte) (string, []byte) {
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(body, &attributes); err != nil {
+		return "", nil
+	}
+	var source string
+	if err := json.Unmarshal(attributes["source"], &source); err != nil || source == "" || types.ParseURIRef(source) != nil {
+		return "", nil
+	}
+	delete(attributes, "source")
+	stripped, err := json.Marshal(attributes)
+	if err != nil {
+		return "", nil
+	}
+	return source, stripped
+}
+
+// restoreInvalidSource sets the source taken by takeInvalidSource back on the event read
+// without it. The source is kept as is, as an opaque URI-reference.
+func (h *Handler) restoreInvalidSource(e *cloudevents.Event, source string) {
+	ref := types.URIRef{URL: url.URL{Opaque: source}}
+	switch ec := e.Context.(type) {
+	case *event.EventContextV1:
+		ec.Source = ref
+	case *event.EventContextV03:
+		ec.Source = ref
+	}
+	h.Logger.Warn("dispatching event with invalid attribute",
+		zap.String("id", e.ID()),
+		zap.String("attribute", "source"),
+		zap.Error(errors.New("not a URI-reference")))
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/labels"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+
+	"knative.dev/eventing/pkg/kncloudevents"
+)
+
+// warmTimeout bounds the time spent pre-connecting to a single channel address.
+const warmTimeout = 5 * time.Second
+
+// WarmTargets pre-connects to the channel addresses of the known brokers when called and
+// then every WarmInterval, until ctx is done, so that idle connections are ready for the
+// first events after a restart or a scale up. It returns immediately when WarmInterval
+// isn't set.
+func (h *Handler) WarmTargets(ctx context.Context) {
+	if h.WarmInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(h.WarmInterval)
+	defer ticker.Stop()
+	for {
+		h.warmTargets(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *Handler) warmTargets(ctx context.Context) {
+	brokers, err := h.BrokerLister.List(labels.Everything())
+	if err != nil {
+		h.Logger.Warn("failed to list brokers to warm their channel connections", zap.Error(err))
+		return
+	}
+
+	var opts []kncloudevents.SendOption
+	if h.Transport != nil {
+		opts = append(opts, kncloudevents.WithTransport(h.Transport))
+	}
+	for _, b := range brokers {
+		if ctx.Err() != nil {
+			return
+		}
+		address, _, err := h.getChannelAddress(b.Name, b.Namespace)
+		if err != nil {
+			continue
+		}
+
+		addresses := []duckv1.Addressable{*address}
+		if h.ChannelAddressesResolver != nil {
+			if spread, err := h.ChannelAddressesResolver(b); err == nil {
+				for _, a := range spread {
+					addresses = append(addresses, a.Address)
+				}
+			}
+		}
+		for _, a := range addresses {
+			h.warmTarget(ctx, a, opts)
+		}
+	}
+}
+
+func (h *Handler) warmTarget(ctx context.Context, address duckv1.Addressable, opts []kncloudevents.SendOption) {
+	ctx, cancel := context.WithTimeout(ctx, warmTimeout)
+	defer cancel()
+
+	if h.Transport == nil && h.hasDispatchTLSConfig() {
+		transport, err := h.dispatchTransport(address)
+		if err != nil {
+			h.Logger.Debug("failed to configure the channel TLS transport",
+				zap.String("channel.host", address.URL.Host),
+				zap.Error(err))
+			return
+		}
+		opts = append(opts[:len(opts):len(opts)], kncloudevents.WithTransport(transport))
+	}
+
+	if err := kncloudevents.Preconnect(ctx, address, opts...); err != nil {
+		h.Logger.Debug("failed to warm the channel connection",
+			zap.String("channel.host", address.URL.Host),
+			zap.Error(err))
+	}
+}
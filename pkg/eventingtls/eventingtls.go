@@ -57,6 +57,11 @@ type ClientConfig struct {
 	// CACerts are Certification Authority (CA) certificates in PEM format
 	// according to https://www.rfc-editor.org/rfc/rfc7468.
 	CACerts *string
+	// MinVersion is the minimum TLS version. Defaults to DefaultMinTLSVersion.
+	MinVersion uint16
+	// CipherSuites are the enabled TLS 1.0-1.2 cipher suites. Defaults to the crypto/tls
+	// defaults. TLS 1.3 cipher suites aren't configurable.
+	CipherSuites []uint16
 }
 
 type ServerConfig struct {
@@ -160,9 +165,15 @@ func GetTLSClientConfig(config ClientConfig) (*tls.Config, error) {
 		return nil, err
 	}
 
+	minVersion := config.MinVersion
+	if minVersion == 0 {
+		minVersion = DefaultMinTLSVersion
+	}
+
 	return &tls.Config{
-		RootCAs:    pool,
-		MinVersion: DefaultMinTLSVersion,
+		RootCAs:      pool,
+		MinVersion:   minVersion,
+		CipherSuites: config.CipherSuites,
 	}, nil
 }
 
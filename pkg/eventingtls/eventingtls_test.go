@@ -19,6 +19,7 @@ package eventingtls
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"reflect"
 	"testing"
 
 	"k8s.io/utils/pointer"
@@ -88,6 +89,18 @@ wSdZWoEx7ye2kUHEyRKdRGbHyJtY9YYvaROznzxqVpIqHxnRQnE/If7kcN4t/7vi
 				RootCAs:    WithCerts(sysCertPool, pemCaCert),
 			},
 		},
+		{
+			name: "Min version and cipher suites",
+			cfg: ClientConfig{
+				MinVersion:   tls.VersionTLS13,
+				CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+			},
+			expected: tls.Config{
+				MinVersion:   tls.VersionTLS13,
+				CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+				RootCAs:      sysCertPool,
+			},
+		},
 		{
 			name: "Additional broken CA certs",
 			cfg: ClientConfig{
@@ -118,6 +131,10 @@ wSdZWoEx7ye2kUHEyRKdRGbHyJtY9YYvaROznzxqVpIqHxnRQnE/If7kcN4t/7vi
 			if got.MinVersion != tc.expected.MinVersion {
 				t.Fatalf("want MinVersion %v, got %v", tc.expected.MinVersion, got.MinVersion)
 			}
+
+			if !reflect.DeepEqual(got.CipherSuites, tc.expected.CipherSuites) {
+				t.Fatalf("want CipherSuites %v, got %v", tc.expected.CipherSuites, got.CipherSuites)
+			}
 		})
 	}
 }
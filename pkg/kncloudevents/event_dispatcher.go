@@ -93,6 +93,26 @@ func WithHeader(header http.Header) SendOption {
 	}
 }
 
+// WithTransport sends the requests with the given transport instead of the shared clients.
+// The transport is responsible for trusting the CA certificates of the destinations.
+func WithTransport(transport http.RoundTripper) SendOption {
+	return func(sc *senderConfig) error {
+		sc.transport = transport
+
+		return nil
+	}
+}
+
+// WithoutRedirects does not follow the redirects responded by the destinations, the
+// redirect response is returned as a failed dispatch instead.
+func WithoutRedirects() SendOption {
+	return func(sc *senderConfig) error {
+		sc.disableRedirects = true
+
+		return nil
+	}
+}
+
 func WithTransformers(transformers ...binding.Transformer) SendOption {
 	return func(sc *senderConfig) error {
 		sc.transformers = transformers
@@ -107,6 +127,8 @@ type senderConfig struct {
 	additionalHeaders http.Header
 	retryConfig       *RetryConfig
 	transformers      binding.Transformers
+	transport         http.RoundTripper
+	disableRedirects  bool
 }
 
 // SendEvent sends the given event to the given destination.
@@ -116,6 +138,39 @@ func SendEvent(ctx context.Context, event event.Event, destination duckv1.Addres
 	return SendMessage(ctx, message, destination, options...)
 }
 
+// Preconnect sends an OPTIONS request to the destination with the client events are sent
+// with, so that a connection is established and kept idle in the client pool ahead of the
+// first event. The response status is ignored. Only the WithHeader and WithTransport
+// options apply.
+func Preconnect(ctx context.Context, destination duckv1.Addressable, options ...SendOption) error {
+	config := &senderConfig{}
+	for _, opt := range options {
+		if err := opt(config); err != nil {
+			return fmt.Errorf("could not apply option: %w", err)
+		}
+	}
+
+	client, err := newClient(destination, config.transport)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodOptions, destination.URL.String(), nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range config.additionalHeaders {
+		req.Header[k] = v
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	// Drain the body so that the connection is reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
 // SendMessage sends the given message to the given destination.
 // SendMessage is kept for compatibility and SendEvent should be used whenever possible.
 func SendMessage(ctx context.Context, message binding.Message, destination duckv1.Addressable, options ...SendOption) (*DispatchInfo, error) {
@@ -163,12 +218,12 @@ func send(ctx context.Context, message binding.Message, destination duckv1.Addre
 	}
 	additionalHeadersForDestination.Set("Prefer", "reply")
 
-	ctx, responseMessage, dispatchExecutionInfo, err := executeRequest(ctx, destination, message, additionalHeadersForDestination, config.retryConfig, config.transformers)
+	ctx, responseMessage, dispatchExecutionInfo, err := executeRequest(ctx, destination, message, additionalHeadersForDestination, config.retryConfig, config.transport, config.disableRedirects, config.transformers)
 	if err != nil {
 		// If DeadLetter is configured, then send original message with knative error extensions
 		if config.deadLetterSink != nil {
 			dispatchTransformers := dispatchExecutionInfoTransformers(destination.URL, dispatchExecutionInfo)
-			_, deadLetterResponse, dispatchExecutionInfo, deadLetterErr := executeRequest(ctx, *config.deadLetterSink, message, config.additionalHeaders, config.retryConfig, append(config.transformers, dispatchTransformers))
+			_, deadLetterResponse, dispatchExecutionInfo, deadLetterErr := executeRequest(ctx, *config.deadLetterSink, message, config.additionalHeaders, config.retryConfig, config.transport, config.disableRedirects, append(config.transformers, dispatchTransformers))
 			if deadLetterErr != nil {
 				return dispatchExecutionInfo, fmt.Errorf("unable to complete request to either %s (%v) or %s (%v)", destination.URL, err, config.deadLetterSink.URL, deadLetterErr)
 			}
@@ -204,12 +259,12 @@ func send(ctx context.Context, message binding.Message, destination duckv1.Addre
 
 	// send reply
 
-	ctx, responseResponseMessage, dispatchExecutionInfo, err := executeRequest(ctx, *config.reply, responseMessage, responseAdditionalHeaders, config.retryConfig, config.transformers)
+	ctx, responseResponseMessage, dispatchExecutionInfo, err := executeRequest(ctx, *config.reply, responseMessage, responseAdditionalHeaders, config.retryConfig, config.transport, config.disableRedirects, config.transformers)
 	if err != nil {
 		// If DeadLetter is configured, then send original message with knative error extensions
 		if config.deadLetterSink != nil {
 			dispatchTransformers := dispatchExecutionInfoTransformers(config.reply.URL, dispatchExecutionInfo)
-			_, deadLetterResponse, dispatchExecutionInfo, deadLetterErr := executeRequest(ctx, *config.deadLetterSink, message, responseAdditionalHeaders, config.retryConfig, append(config.transformers, dispatchTransformers))
+			_, deadLetterResponse, dispatchExecutionInfo, deadLetterErr := executeRequest(ctx, *config.deadLetterSink, message, responseAdditionalHeaders, config.retryConfig, config.transport, config.disableRedirects, append(config.transformers, dispatchTransformers))
 			if deadLetterErr != nil {
 				return dispatchExecutionInfo, fmt.Errorf("failed to forward reply to %s (%v) and failed to send it to the dead letter sink %s (%v)", config.reply.URL, err, config.deadLetterSink.URL, deadLetterErr)
 			}
@@ -229,7 +284,7 @@ func send(ctx context.Context, message binding.Message, destination duckv1.Addre
 	return dispatchExecutionInfo, nil
 }
 
-func executeRequest(ctx context.Context, target duckv1.Addressable, message cloudevents.Message, additionalHeaders http.Header, retryConfig *RetryConfig, transformers ...binding.Transformer) (context.Context, cloudevents.Message, *DispatchInfo, error) {
+func executeRequest(ctx context.Context, target duckv1.Addressable, message cloudevents.Message, additionalHeaders http.Header, retryConfig *RetryConfig, transport http.RoundTripper, disableRedirects bool, transformers ...binding.Transformer) (context.Context, cloudevents.Message, *DispatchInfo, error) {
 	dispatchInfo := DispatchInfo{
 		Duration:       NoDuration,
 		ResponseCode:   NoResponse,
@@ -248,10 +303,15 @@ func executeRequest(ctx context.Context, target duckv1.Addressable, message clou
 		return ctx, nil, &dispatchInfo, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	client, err := newClient(target)
+	client, err := newClient(target, transport)
 	if err != nil {
 		return ctx, nil, &dispatchInfo, fmt.Errorf("failed to create http client: %w", err)
 	}
+	if disableRedirects {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
 
 	start := time.Now()
 	response, err := client.DoWithRetries(req, retryConfig)
@@ -323,7 +383,13 @@ type client struct {
 	http.Client
 }
 
-func newClient(target duckv1.Addressable) (*client, error) {
+func newClient(target duckv1.Addressable, transport http.RoundTripper) (*client, error) {
+	if transport != nil {
+		return &client{
+			Client: http.Client{Transport: transport},
+		}, nil
+	}
+
 	c, err := getClientForAddressable(target)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get http client for addressable: %w", err)
@@ -914,6 +914,65 @@ func TestSendEvent(t *testing.T) {
 	}
 }
 
+func TestSendEventWithoutRedirects(t *testing.T) {
+	testCases := map[string]struct {
+		options          []kncloudevents.SendOption
+		wantErr          bool
+		wantResponseCode int
+		wantReceived     bool
+	}{
+		"follows redirects by default": {
+			wantResponseCode: http.StatusAccepted,
+			wantReceived:     true,
+		},
+		"does not follow redirects": {
+			options:          []kncloudevents.SendOption{kncloudevents.WithoutRedirects()},
+			wantErr:          true,
+			wantResponseCode: http.StatusTemporaryRedirect,
+		},
+		"does not follow redirects with retries": {
+			options: []kncloudevents.SendOption{
+				kncloudevents.WithoutRedirects(),
+				kncloudevents.WithRetryConfig(&kncloudevents.RetryConfig{
+					RetryMax:       1,
+					CheckRetry:     kncloudevents.SelectiveRetry,
+					RequestTimeout: time.Second,
+				}),
+			},
+			wantErr:          true,
+			wantResponseCode: http.StatusTemporaryRedirect,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			ctx := context.Background()
+
+			received := false
+			destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = true
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer destination.Close()
+
+			redirector := httptest.NewServer(http.RedirectHandler(destination.URL, http.StatusTemporaryRedirect))
+			defer redirector.Close()
+
+			event := test.FullEvent()
+			info, err := kncloudevents.SendEvent(ctx, event, duckv1.Addressable{URL: apis.HTTP(strings.TrimPrefix(redirector.URL, "http://"))}, tc.options...)
+			if tc.wantErr != (err != nil) {
+				t.Errorf("expected error %v got %v", tc.wantErr, err)
+			}
+			if info.ResponseCode != tc.wantResponseCode {
+				t.Errorf("expected response code %d got %d", tc.wantResponseCode, info.ResponseCode)
+			}
+			if received != tc.wantReceived {
+				t.Errorf("expected received %v got %v", tc.wantReceived, received)
+			}
+		})
+	}
+}
+
 func TestDispatchMessageToTLSEndpoint(t *testing.T) {
 	var wg sync.WaitGroup
 	ctx, _ := rectesting.SetupFakeContext(t)
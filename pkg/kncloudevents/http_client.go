@@ -80,7 +80,7 @@ func getClientForAddressable(addressable duckv1.Addressable) (*nethttp.Client, e
 }
 
 func createNewClient(addressable duckv1.Addressable) (*nethttp.Client, error) {
-	var base = nethttp.DefaultTransport.(*nethttp.Transport).Clone()
+	var base = newTransport()
 
 	if addressable.CACerts != nil && *addressable.CACerts != "" {
 		var err error
@@ -94,7 +94,6 @@ func createNewClient(addressable duckv1.Addressable) (*nethttp.Client, error) {
 		}
 	}
 
-	clients.connectionArgs.configureTransport(base)
 	client := &nethttp.Client{
 		// Add output tracing.
 		Transport: &ochttp.Transport{
@@ -106,6 +105,21 @@ func createNewClient(addressable duckv1.Addressable) (*nethttp.Client, error) {
 	return client, nil
 }
 
+// NewTransport returns a new transport configured with the connection args of the
+// clients, see ConfigureConnectionArgs.
+func NewTransport() *nethttp.Transport {
+	clients.clientsMu.Lock()
+	defer clients.clientsMu.Unlock()
+
+	return newTransport()
+}
+
+func newTransport() *nethttp.Transport {
+	base := nethttp.DefaultTransport.(*nethttp.Transport).Clone()
+	clients.connectionArgs.configureTransport(base)
+	return base
+}
+
 func AddOrUpdateAddressableHandler(addressable duckv1.Addressable) {
 	clients.clientsMu.Lock()
 	defer clients.clientsMu.Unlock()
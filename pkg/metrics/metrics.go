@@ -68,4 +68,25 @@ const (
 
 	// LabelResponseTimeout is the label timeout.
 	LabelResponseTimeout = metricskey.LabelResponseTimeout
+
+	// LabelResolutionMethod is the label for the method used to resolve an address. For example, "annotation".
+	LabelResolutionMethod = "resolution_method"
+
+	// LabelResolutionSuccess is the label for whether an address was resolved successfully.
+	LabelResolutionSuccess = "resolution_success"
+
+	// LabelStatefulSetName is the label for the name of the StatefulSet.
+	LabelStatefulSetName = "statefulset_name"
+
+	// LabelAutoscaleOutcome is the label for the outcome of an autoscaling cycle. For example, "scaleup".
+	LabelAutoscaleOutcome = "autoscale_outcome"
+
+	// LabelPodName is the label for the name of the Pod.
+	LabelPodName = "pod_name"
+
+	// LabelDropReason is the label for the reason an event was intentionally dropped. For example, "ttl_exhausted".
+	LabelDropReason = "drop_reason"
+
+	// LabelRejectReason is the label for the reason a request was rejected before dispatch. For example, "empty_body".
+	LabelRejectReason = "reject_reason"
 )
@@ -17,6 +17,8 @@ limitations under the License.
 package scheduler
 
 import (
+	"errors"
+
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 
@@ -77,11 +79,21 @@ type PriorityPolicy struct {
 // VPodLister is the function signature for returning a list of VPods
 type VPodLister func() ([]VPod, error)
 
+// ErrVPodsNotSynced can be returned (or wrapped) by a VPodLister when the VPods can't be
+// listed yet, for instance because the informer has not synced, so that callers can retry
+// later instead of failing.
+var ErrVPodsNotSynced = errors.New("vpods not synced")
+
 // Evictor allows for vreplicas to be evicted.
 // For instance, the evictor is used by the statefulset scheduler to
 // move vreplicas to pod with a lower ordinal.
 type Evictor func(pod *corev1.Pod, vpod VPod, from *duckv1alpha1.Placement) error
 
+// TargetedEvictor allows for vreplicas to be evicted like an Evictor, steering the evicted
+// vreplicas to the preferred pods, in order of preference. The evictor is expected to hint
+// the scheduler (e.g. with an affinity on the vpod) to place the vreplicas on those pods.
+type TargetedEvictor func(pod *corev1.Pod, vpod VPod, from *duckv1alpha1.Placement, preferredPods []string) error
+
 // Scheduler is responsible for placing VPods into real Kubernetes pods
 type Scheduler interface {
 	// Schedule computes the new set of placements for vpod.
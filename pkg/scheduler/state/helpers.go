@@ -17,6 +17,7 @@ limitations under the License.
 package state
 
 import (
+	"fmt"
 	"math"
 	"strconv"
 	"strings"
@@ -31,12 +32,28 @@ func PodNameFromOrdinal(name string, ordinal int32) string {
 	return name + "-" + strconv.Itoa(int(ordinal))
 }
 
+// OrdinalFromPodName returns the ordinal of the given statefulset pod name, or math.MaxInt32
+// when the name does not match the `name-N` pattern.
 func OrdinalFromPodName(podName string) int32 {
-	ordinal, err := strconv.ParseInt(podName[strings.LastIndex(podName, "-")+1:], 10, 32)
+	ordinal, err := ParseOrdinalFromPodName(podName)
 	if err != nil {
 		return math.MaxInt32
 	}
-	return int32(ordinal)
+	return ordinal
+}
+
+// ParseOrdinalFromPodName returns the ordinal of the given statefulset pod name or an error
+// when the name does not match the `name-N` pattern.
+func ParseOrdinalFromPodName(podName string) (int32, error) {
+	idx := strings.LastIndex(podName, "-")
+	if idx <= 0 {
+		return -1, fmt.Errorf("pod name %q does not match the <name>-<ordinal> pattern", podName)
+	}
+	ordinal, err := strconv.ParseUint(podName[idx+1:], 10, 31)
+	if err != nil {
+		return -1, fmt.Errorf("pod name %q does not end with a valid ordinal", podName)
+	}
+	return int32(ordinal), nil
 }
 
 // Get retrieves the VPod from the vpods lister for a given namespace and name.
@@ -0,0 +1,96 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOrdinalFromPodName(t *testing.T) {
+	testCases := []struct {
+		name        string
+		podName     string
+		wantOrdinal int32
+		wantErr     bool
+	}{
+		{
+			name:        "valid pod name",
+			podName:     "statefulset-name-3",
+			wantOrdinal: 3,
+		},
+		{
+			name:        "valid pod name, ordinal 0",
+			podName:     "sfs-0",
+			wantOrdinal: 0,
+		},
+		{
+			name:    "no separator",
+			podName: "12",
+			wantErr: true,
+		},
+		{
+			name:    "no name",
+			podName: "-1",
+			wantErr: true,
+		},
+		{
+			name:    "no ordinal",
+			podName: "statefulset-name-",
+			wantErr: true,
+		},
+		{
+			name:    "not a number",
+			podName: "statefulset-name-abc",
+			wantErr: true,
+		},
+		{
+			name:    "signed ordinal",
+			podName: "statefulset-name-+1",
+			wantErr: true,
+		},
+		{
+			name:    "ordinal overflow",
+			podName: "statefulset-name-4294967296",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ordinal, err := ParseOrdinalFromPodName(tc.podName)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("unexpected error, want error %v, got %v", tc.wantErr, err)
+			}
+
+			want := tc.wantOrdinal
+			if tc.wantErr {
+				want = -1
+			}
+			if ordinal != want {
+				t.Errorf("unexpected ordinal, got %d, want %d", ordinal, want)
+			}
+
+			if tc.wantErr {
+				want = math.MaxInt32
+			}
+			if got := OrdinalFromPodName(tc.podName); got != want {
+				t.Errorf("unexpected ordinal from OrdinalFromPodName, got %d, want %d", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+const bearerPrefix = "Bearer "
+
+// autoscaleHandler triggers the autoscaler on authenticated POST requests.
+type autoscaleHandler struct {
+	autoscaler Autoscaler
+	token      []byte
+}
+
+// NewAutoscaleHandler returns an http.Handler triggering the autoscaler on POST requests, so
+// that external systems (e.g. CI or ops tooling) can prompt an immediate autoscaling cycle.
+// Requests must carry token in an "Authorization: Bearer <token>" header. An empty token
+// rejects all the requests.
+//
+// Only the leader autoscales, the other instances respond with 503 so that the request is
+// retried against the leader.
+func NewAutoscaleHandler(autoscaler Autoscaler, token string) http.Handler {
+	return &autoscaleHandler{
+		autoscaler: autoscaler,
+		token:      []byte(token),
+	}
+}
+
+func (h *autoscaleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if !h.autoscaler.IsLeader() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	h.autoscaler.Autoscale(r.Context())
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *autoscaleHandler) authorized(r *http.Request) bool {
+	if len(h.token) == 0 {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, bearerPrefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, bearerPrefix)), h.token) == 1
+}
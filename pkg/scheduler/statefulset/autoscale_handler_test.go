@@ -0,0 +1,110 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"knative.dev/pkg/reconciler"
+)
+
+func TestAutoscaleHandler(t *testing.T) {
+	testCases := []struct {
+		name           string
+		token          string
+		method         string
+		authorization  string
+		notLeader      bool
+		wantStatusCode int
+		wantAutoscaled int32
+	}{
+		{
+			name:           "triggers the autoscaler",
+			token:          "secret",
+			method:         http.MethodPost,
+			authorization:  "Bearer secret",
+			wantStatusCode: http.StatusAccepted,
+			wantAutoscaled: 1,
+		},
+		{
+			name:           "wrong method",
+			token:          "secret",
+			method:         http.MethodGet,
+			authorization:  "Bearer secret",
+			wantStatusCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "missing token",
+			token:          "secret",
+			method:         http.MethodPost,
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "wrong token",
+			token:          "secret",
+			method:         http.MethodPost,
+			authorization:  "Bearer other",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "not a bearer token",
+			token:          "secret",
+			method:         http.MethodPost,
+			authorization:  "secret",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "empty token rejects all requests",
+			method:         http.MethodPost,
+			authorization:  "Bearer ",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "not leader",
+			token:          "secret",
+			method:         http.MethodPost,
+			authorization:  "Bearer secret",
+			notLeader:      true,
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			autoscaler := newFakeAutoscaler()
+			if !tc.notLeader {
+				_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+			}
+
+			request := httptest.NewRequest(tc.method, "/autoscale", nil)
+			if tc.authorization != "" {
+				request.Header.Set("Authorization", tc.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			NewAutoscaleHandler(autoscaler, tc.token).ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.wantStatusCode {
+				t.Errorf("unexpected status code, got %d, want %d", recorder.Code, tc.wantStatusCode)
+			}
+			if got := autoscaler.autoscaled.Load(); got != tc.wantAutoscaled {
+				t.Errorf("unexpected number of autoscale triggers, got %d, want %d", got, tc.wantAutoscaled)
+			}
+		})
+	}
+}
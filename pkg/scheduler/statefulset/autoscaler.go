@@ -18,22 +18,36 @@ package statefulset
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"go.uber.org/zap"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 	clientappsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/clock"
 	"knative.dev/pkg/reconciler"
 
 	kubeclient "knative.dev/pkg/client/injection/kube/client"
 	"knative.dev/pkg/logging"
 
+	duckv1alpha1 "knative.dev/eventing/pkg/apis/duck/v1alpha1"
 	"knative.dev/eventing/pkg/scheduler"
 	st "knative.dev/eventing/pkg/scheduler/state"
 )
@@ -48,22 +62,123 @@ var (
 	}
 )
 
+// scaleVerificationInterval is how often the statefulset ready replicas are checked after
+// a scale up, when the scale verification is enabled.
+const scaleVerificationInterval = 5 * time.Second
+
+// persistentScaleConflicts is the number of consecutive conflicts updating the scale
+// subresource after which the autoscaler warns about another controller scaling the statefulset.
+const persistentScaleConflicts = 3
+
 type Autoscaler interface {
 	// Start runs the autoscaler until cancelled.
 	Start(ctx context.Context)
 
-	// Autoscale is used to immediately trigger the autoscaler.
+	// Autoscale is used to immediately trigger the autoscaler. It never blocks, the trigger
+	// is dropped when another one is already pending since autoscaling is idempotent.
 	Autoscale(ctx context.Context)
+
+	// AutoscaleBlocking triggers the autoscaler like Autoscale, but waits for the pending
+	// trigger to be consumed when there is one. It returns the context error when ctx is
+	// done first.
+	AutoscaleBlocking(ctx context.Context) error
+
+	// ForceReconcile immediately triggers the autoscaler allowing both scale up and scale down,
+	// regardless of the refresh period. The compaction grace period is still honored.
+	ForceReconcile(ctx context.Context)
+
+	// Reload validates and applies the tunable fields of cfg, picked up on the next cycle.
+	Reload(cfg *Config) error
+
+	// IsLeader returns whether the autoscaler is the active (leader) instance.
+	IsLeader() bool
+
+	// Pause stops the automatic scaling and compaction until Resume is called, without
+	// giving up the leadership (e.g. during a manual intervention).
+	Pause()
+
+	// Resume restarts the automatic scaling and compaction stopped by Pause.
+	Resume()
+
+	// IsPaused returns whether the autoscaler is paused.
+	IsPaused() bool
+
+	// CompactPod evicts all the vreplicas placed on the given pod, if the other pods
+	// have enough free capacity to hold them.
+	CompactPod(ctx context.Context, podName string) error
+
+	// DrainNode evicts all the vreplicas placed on the pods running on the given node, for
+	// instance before decommissioning it, if the pods on the other nodes have enough free
+	// capacity to hold them.
+	DrainNode(ctx context.Context, nodeName string) error
+
+	// Drain moves the vreplicas off the pods from the highest ordinal down, scaling the
+	// statefulset down as its last pods are emptied, until it's scaled to zero or no more
+	// progress is possible. The automatic scaling is suspended while draining, and the drain
+	// stops when the autoscaler is paused.
+	Drain(ctx context.Context) error
+
+	// PlanCompaction returns the placements a compaction of the given state would evict,
+	// without evicting them.
+	PlanCompaction(s *st.State, scaleUpFactor int32) ([]EvictionPlanItem, error)
+
+	// ExportState serializes the runtime decision state of the autoscaler (e.g. the last
+	// scale and compaction times, the smoothed demand) to JSON.
+	ExportState() ([]byte, error)
+
+	// ImportState restores the runtime decision state serialized by ExportState.
+	ImportState(data []byte) error
+
+	// ExplainCompaction returns a human-readable explanation of whether the placements of
+	// the given vpod ("namespace/name") are eligible for compaction this cycle, and why not.
+	ExplainCompaction(vpodKey string) (string, error)
+
+	// ConfigSnapshot returns the effective configuration of the autoscaler, including the
+	// changes applied by Reload.
+	ConfigSnapshot() AutoscalerConfigSnapshot
+}
+
+// EvictionPlanItem is a placement planned to be evicted.
+type EvictionPlanItem struct {
+	// VPod is the key of the vpod the placement belongs to.
+	VPod types.NamespacedName `json:"vpod"`
+	// Placement is the placement whose vreplicas are all evicted.
+	Placement duckv1alpha1.Placement `json:"placement"`
+
+	vpod      scheduler.VPod
+	placement *duckv1alpha1.Placement
+}
+
+// QueueDepthSource reports the pending work of the statefulset pods, for adapters whose load
+// isn't reflected by the number of vreplicas placed on each pod.
+type QueueDepthSource interface {
+	// QueueDepth returns the backlog (e.g. pending events) of each pod, by pod name.
+	QueueDepth(ctx context.Context) (map[string]int64, error)
+}
+
+// DemandForecaster predicts the number of vreplicas expected to be needed in the near future.
+// It allows pods to be provisioned ahead of a known spike (e.g. scheduled batch jobs).
+type DemandForecaster interface {
+	// Forecast returns the predicted total number of vreplicas.
+	Forecast(ctx context.Context) (int32, error)
 }
 
 type autoscaler struct {
-	statefulSetClient clientappsv1.StatefulSetInterface
-	statefulSetName   string
-	vpodLister        scheduler.VPodLister
-	logger            *zap.SugaredLogger
-	stateAccessor     st.StateAccessor
-	trigger           chan struct{}
-	evictor           scheduler.Evictor
+	// kubeClient is namespace agnostic, the clients of the statefulset namespace are
+	// selected per call.
+	kubeClient           kubernetes.Interface
+	statefulSetName      string
+	statefulSetNamespace string
+	vpodLister           scheduler.VPodLister
+	logger               *zap.SugaredLogger
+	stateAccessor        st.StateAccessor
+	trigger              chan struct{}
+	forceTrigger         chan struct{}
+	evictor              scheduler.Evictor
+	// targetedEvictor optionally replaces the evictor, steering the evicted vreplicas to
+	// the pods returned by evictionTargets.
+	targetedEvictor scheduler.TargetedEvictor
+	evictionTargets func(s *st.State) []string
 
 	// capacity is the total number of virtual replicas available per pod.
 	capacity int32
@@ -77,10 +192,138 @@ type autoscaler struct {
 	// bucket where we've been promoted.
 	isLeader atomic.Bool
 
+	// paused signals whether the automatic scaling is paused by an operator.
+	paused atomic.Bool
+	// draining signals whether the statefulset is being drained, suspending the automatic
+	// scaling.
+	draining atomic.Bool
+
 	// getReserved returns reserved replicas.
 	getReserved GetReserved
 
+	// forecaster optionally predicts the upcoming vreplica demand.
+	forecaster DemandForecaster
+
+	// queueDepthSource optionally reports the backlog of each pod.
+	queueDepthSource QueueDepthSource
+	// queueDepthThreshold is the average backlog per pod above which the autoscaler scales up.
+	queueDepthThreshold int64
+
+	// maxScaleUpStep is the maximum number of replicas added per cycle, 0 means unlimited.
+	maxScaleUpStep int32
+	// maxScaleDownStep is the maximum number of replicas removed per cycle, 0 means unlimited.
+	maxScaleDownStep int32
+	// maxReplicas is the maximum number of replicas computed, 0 means unlimited.
+	maxReplicas int32
+
+	// demandSmoothingFactor is the weight of the latest demand in smoothedDemand, 0 disables
+	// the smoothing.
+	demandSmoothingFactor float64
+	// smoothedDemand is the moving average of the total expected vreplicas, valid once
+	// demandObserved is set.
+	smoothedDemand float64
+	demandObserved bool
+
+	// vreplicaCost optionally weights the vreplicas of each vpod in the demand.
+	vreplicaCost func(vpod scheduler.VPod) float64
+
+	// eventsClient optionally sends lifecycle CloudEvents to eventsSink.
+	eventsClient cloudevents.Client
+	eventsSink   string
+	eventsSource string
+	eventTypes   AutoscalerEventTypes
+
+	// clock is used for all time reads and timers so that tests can control time.
+	clock clock.Clock
+
+	// staleStateThreshold is the number of consecutive state failures after which the
+	// last known good state is used to hold the current capacity. 0 disables the fallback.
+	staleStateThreshold int32
+	// stateFailures is the number of consecutive state failures.
+	stateFailures int32
+	// lastState is the last known good state.
+	lastState *st.State
+	// lastStateSummary summarizes the state of the previous cycle to log the state changes.
+	lastStateSummary *stateSummary
+
+	// pdbAware defers the compaction of pods whose PodDisruptionBudgets don't allow
+	// any further disruption.
+	pdbAware bool
+
+	// annotateCompactingPods annotates the pods drained by a compaction while evicting.
+	annotateCompactingPods bool
+
+	// nodeAware doesn't count the pods on cordoned nodes as remaining after a compaction.
+	nodeAware  bool
+	nodeLister corev1listers.NodeLister
+	// topologyKey is the node label whose values are the failure domains the statefulset is
+	// scaled across, empty to scale across the zones or nodes of the HA scheduling policies.
+	topologyKey string
+
+	// yieldToExternalScalers leaves scaling down to the other controllers writing the scale
+	// subresource (e.g. an HPA), the autoscaler only scales up.
+	yieldToExternalScalers bool
+	// scaleDownDisabled prevents the autoscaler from scaling down and compacting.
+	scaleDownDisabled bool
+	// scaleConflicts is the number of consecutive conflicts updating the scale subresource.
+	scaleConflicts int32
+
+	// onScaleApplied is optionally called with the target replicas after each scale update.
+	onScaleApplied func(target int32)
+	// onCapacityExhausted is optionally called with the pending vreplicas when maxReplicas
+	// prevents a scale up.
+	onCapacityExhausted func(pending int32)
+	// scaleVerificationTimeout is the time allowed for the statefulset to have as many ready
+	// replicas as targeted by a scale up. 0 disables the verification.
+	scaleVerificationTimeout time.Duration
+	// scaleUps identifies the latest scale up, so that superseded verifications stop.
+	scaleUps atomic.Int64
+	// readinessGapTimeout is the duration after which a gap between the spec and the ready
+	// replicas suppresses the scale ups. 0 disables the check.
+	readinessGapTimeout time.Duration
+	// readinessGapSince is when the current readiness gap was first observed, zero if the
+	// ready replicas matched the spec replicas on the last check.
+	readinessGapSince time.Time
+
+	// compactionHeadroom is the fraction of the capacity of the pods surviving a compaction
+	// that must remain free after moving the evicted vreplicas.
+	compactionHeadroom float64
+	// compactionBatchSize is the maximum number of groups of scaleUpFactor pods the policy
+	// based compaction evicts per cycle.
+	compactionBatchSize int32
+
+	// startupGracePeriod is the duration after Start during which the autoscaler only scales up.
+	startupGracePeriod time.Duration
+	// startedAt is when Start began, zero if the autoscaler hasn't been started.
+	startedAt time.Time
+
+	// scaleUpProtectionWindow is the duration after a scale up during which the autoscaler
+	// only scales up.
+	scaleUpProtectionWindow time.Duration
+	// lastScaleUpTime is when the autoscaler last scaled up, zero if it never did.
+	lastScaleUpTime time.Time
+
+	// minScaleInterval is the minimum duration between two changes of the replicas.
+	minScaleInterval time.Duration
+	// lastScaleTime is when the autoscaler last changed the replicas, zero if it never did.
+	lastScaleTime time.Time
+
 	lastCompactAttempt time.Time
+
+	statsReporter AutoscalerStatsReporter
+	auditSink     AuditSink
+	// auditIdentity identifies this instance as the leader in the audit entries.
+	auditIdentity string
+	// cycleOutcome is the outcome of the current autoscaling cycle.
+	cycleOutcome string
+
+	// stateStore optionally persists the runtime decision state across leader changes.
+	stateStore AutoscalerStateStore
+	// stateLoadPending signals that the state must be loaded from stateStore, after a
+	// promotion.
+	stateLoadPending atomic.Bool
+	// savedState is the state last loaded from or saved to stateStore.
+	savedState []byte
 }
 
 var (
@@ -91,6 +334,7 @@ var (
 func (a *autoscaler) Promote(b reconciler.Bucket, _ func(reconciler.Bucket, types.NamespacedName)) error {
 	if b.Has(ephemeralLeaderElectionObject) {
 		// The promoted bucket has the ephemeralLeaderElectionObject, so we are leader.
+		a.stateLoadPending.Store(true)
 		a.isLeader.Store(true)
 	}
 	return nil
@@ -104,38 +348,118 @@ func (a *autoscaler) Demote(b reconciler.Bucket) {
 	}
 }
 
+func (a *autoscaler) IsLeader() bool {
+	return a.isLeader.Load()
+}
+
+func (a *autoscaler) Pause() {
+	if !a.paused.Swap(true) {
+		a.logger.Info("autoscaler paused")
+	}
+}
+
+func (a *autoscaler) Resume() {
+	if a.paused.Swap(false) {
+		a.logger.Info("autoscaler resumed")
+	}
+}
+
+func (a *autoscaler) IsPaused() bool {
+	return a.paused.Load()
+}
+
 func newAutoscaler(ctx context.Context, cfg *Config, stateAccessor st.StateAccessor) *autoscaler {
+	var c clock.Clock = clock.RealClock{}
+	if cfg.clock != nil {
+		c = cfg.clock
+	}
+	reporter := cfg.StatsReporter
+	if reporter == nil {
+		reporter = NewAutoscalerStatsReporter(cfg.StatefulSetNamespace, cfg.StatefulSetName)
+	}
+	var auditSink AuditSink = noopAuditSink{}
+	if cfg.AuditSink != nil {
+		auditSink = cfg.AuditSink
+	}
+	auditIdentity := cfg.AuditIdentity
+	if auditIdentity == "" {
+		auditIdentity = defaultAuditIdentity()
+	}
 	return &autoscaler{
-		logger:            logging.FromContext(ctx),
-		statefulSetClient: kubeclient.Get(ctx).AppsV1().StatefulSets(cfg.StatefulSetNamespace),
-		statefulSetName:   cfg.StatefulSetName,
-		vpodLister:        cfg.VPodLister,
-		stateAccessor:     stateAccessor,
-		evictor:           cfg.Evictor,
-		trigger:           make(chan struct{}, 1),
-		capacity:          cfg.PodCapacity,
-		refreshPeriod:     cfg.RefreshPeriod,
-		lock:              new(sync.Mutex),
-		isLeader:          atomic.Bool{},
-		getReserved:       cfg.getReserved,
+		logger:                   logging.FromContext(ctx),
+		kubeClient:               kubeclient.Get(ctx),
+		statefulSetName:          cfg.StatefulSetName,
+		statefulSetNamespace:     cfg.StatefulSetNamespace,
+		vpodLister:               cfg.VPodLister,
+		stateAccessor:            stateAccessor,
+		evictor:                  cfg.Evictor,
+		targetedEvictor:          cfg.TargetedEvictor,
+		evictionTargets:          cfg.EvictionTargets,
+		trigger:                  make(chan struct{}, 1),
+		forceTrigger:             make(chan struct{}, 1),
+		capacity:                 cfg.PodCapacity,
+		refreshPeriod:            cfg.RefreshPeriod,
+		lock:                     new(sync.Mutex),
+		isLeader:                 atomic.Bool{},
+		getReserved:              combineReserved(logging.FromContext(ctx), cfg.getReserved, cfg.AdditionalReserved...),
+		forecaster:               cfg.DemandForecaster,
+		queueDepthSource:         cfg.QueueDepthSource,
+		queueDepthThreshold:      cfg.QueueDepthThreshold,
+		maxScaleUpStep:           cfg.MaxScaleUpStep,
+		maxScaleDownStep:         cfg.MaxScaleDownStep,
+		maxReplicas:              cfg.MaxReplicas,
+		demandSmoothingFactor:    cfg.DemandSmoothingFactor,
+		vreplicaCost:             cfg.VReplicaCost,
+		eventsClient:             cfg.EventsClient,
+		eventsSink:               cfg.EventsSink,
+		eventsSource:             "/apis/apps/v1/namespaces/" + cfg.StatefulSetNamespace + "/statefulsets/" + cfg.StatefulSetName,
+		eventTypes:               cfg.EventTypes.withDefaults(),
+		clock:                    c,
+		staleStateThreshold:      cfg.StaleStateThreshold,
+		pdbAware:                 cfg.PDBAware,
+		annotateCompactingPods:   cfg.AnnotateCompactingPods,
+		nodeAware:                cfg.NodeAware,
+		nodeLister:               cfg.NodeLister,
+		topologyKey:              cfg.TopologyKey,
+		startupGracePeriod:       cfg.StartupGracePeriod,
+		scaleUpProtectionWindow:  cfg.ScaleUpProtectionWindow,
+		minScaleInterval:         cfg.MinScaleInterval,
+		yieldToExternalScalers:   cfg.YieldToExternalScalers,
+		scaleDownDisabled:        cfg.ScaleDownDisabled,
+		onScaleApplied:           cfg.OnScaleApplied,
+		onCapacityExhausted:      cfg.OnCapacityExhausted,
+		scaleVerificationTimeout: cfg.ScaleVerificationTimeout,
+		readinessGapTimeout:      cfg.ReadinessGapTimeout,
+		compactionHeadroom:       cfg.CompactionHeadroom,
+		compactionBatchSize:      cfg.CompactionBatchSize,
+		statsReporter:            reporter,
+		auditSink:                auditSink,
+		auditIdentity:            auditIdentity,
+		stateStore:               cfg.StateStore,
 		// Anything that is less than now() - refreshPeriod, so that we will try to compact
 		// as soon as we start.
-		lastCompactAttempt: time.Now().
+		lastCompactAttempt: c.Now().
 			Add(-cfg.RefreshPeriod).
 			Add(-time.Minute),
 	}
 }
 
 func (a *autoscaler) Start(ctx context.Context) {
+	a.lock.Lock()
+	a.startedAt = a.clock.Now()
+	a.lock.Unlock()
+
 	attemptScaleDown := false
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(a.refreshPeriod):
+		case <-a.clock.After(a.getRefreshPeriod()):
 			attemptScaleDown = true
 		case <-a.trigger:
 			attemptScaleDown = false
+		case <-a.forceTrigger:
+			attemptScaleDown = true
 		}
 
 		// Retry a few times, just so that we don't have to wait for the next beat when
@@ -147,15 +471,160 @@ func (a *autoscaler) Start(ctx context.Context) {
 func (a *autoscaler) Autoscale(ctx context.Context) {
 	// We trigger the autoscaler asynchronously by using the channel so that the scale down refresh
 	// period is reset.
-	a.trigger <- struct{}{}
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+		// A trigger is already pending, e.g. the reconcilers of several vpods can't be
+		// scheduled, they mustn't block until the autoscaler loop catches up.
+	}
+}
+
+func (a *autoscaler) AutoscaleBlocking(ctx context.Context) error {
+	select {
+	case a.trigger <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *autoscaler) ForceReconcile(ctx context.Context) {
+	select {
+	case a.forceTrigger <- struct{}{}:
+	default:
+		// A forced reconcile is already pending.
+	}
+}
+
+// Reload applies the tunable fields of cfg: RefreshPeriod, PodCapacity, StaleStateThreshold,
+// StartupGracePeriod, ScaleUpProtectionWindow, MinScaleInterval, MaxScaleUpStep,
+// MaxScaleDownStep, MaxReplicas, DemandSmoothingFactor, PDBAware, AnnotateCompactingPods,
+// NodeAware, TopologyKey, YieldToExternalScalers, ScaleDownDisabled, CompactionHeadroom,
+// CompactionBatchSize, QueueDepthThreshold, ScaleVerificationTimeout, ReadinessGapTimeout and
+// EventTypes. The statefulset the autoscaler targets can't be changed, and the remaining
+// fields are ignored.
+func (a *autoscaler) Reload(cfg *Config) error {
+	if cfg == nil {
+		return errors.New("config is nil")
+	}
+	if cfg.StatefulSetName != a.statefulSetName || cfg.StatefulSetNamespace != a.statefulSetNamespace {
+		return fmt.Errorf("statefulset %s/%s can't be changed to %s/%s",
+			a.statefulSetNamespace, a.statefulSetName, cfg.StatefulSetNamespace, cfg.StatefulSetName)
+	}
+	if cfg.RefreshPeriod <= 0 {
+		return fmt.Errorf("refresh period must be positive, got %v", cfg.RefreshPeriod)
+	}
+	if cfg.PodCapacity <= 0 {
+		return fmt.Errorf("pod capacity must be positive, got %d", cfg.PodCapacity)
+	}
+	if cfg.StaleStateThreshold < 0 {
+		return fmt.Errorf("stale state threshold must not be negative, got %d", cfg.StaleStateThreshold)
+	}
+	if cfg.StartupGracePeriod < 0 {
+		return fmt.Errorf("startup grace period must not be negative, got %v", cfg.StartupGracePeriod)
+	}
+	if cfg.ScaleUpProtectionWindow < 0 {
+		return fmt.Errorf("scale up protection window must not be negative, got %v", cfg.ScaleUpProtectionWindow)
+	}
+	if cfg.MinScaleInterval < 0 {
+		return fmt.Errorf("min scale interval must not be negative, got %v", cfg.MinScaleInterval)
+	}
+	if cfg.MaxScaleUpStep < 0 {
+		return fmt.Errorf("max scale up step must not be negative, got %d", cfg.MaxScaleUpStep)
+	}
+	if cfg.MaxScaleDownStep < 0 {
+		return fmt.Errorf("max scale down step must not be negative, got %d", cfg.MaxScaleDownStep)
+	}
+	if cfg.MaxReplicas < 0 {
+		return fmt.Errorf("max replicas must not be negative, got %d", cfg.MaxReplicas)
+	}
+	if !(cfg.DemandSmoothingFactor >= 0 && cfg.DemandSmoothingFactor <= 1) {
+		return fmt.Errorf("demand smoothing factor must be in [0, 1], got %v", cfg.DemandSmoothingFactor)
+	}
+	if cfg.ScaleVerificationTimeout < 0 {
+		return fmt.Errorf("scale verification timeout must not be negative, got %v", cfg.ScaleVerificationTimeout)
+	}
+	if cfg.ReadinessGapTimeout < 0 {
+		return fmt.Errorf("readiness gap timeout must not be negative, got %v", cfg.ReadinessGapTimeout)
+	}
+	if cfg.CompactionBatchSize < 0 {
+		return fmt.Errorf("compaction batch size must not be negative, got %d", cfg.CompactionBatchSize)
+	}
+	if cfg.QueueDepthThreshold < 0 {
+		return fmt.Errorf("queue depth threshold must not be negative, got %d", cfg.QueueDepthThreshold)
+	}
+	if !(cfg.CompactionHeadroom >= 0 && cfg.CompactionHeadroom < 1) {
+		return fmt.Errorf("compaction headroom must be in [0, 1), got %v", cfg.CompactionHeadroom)
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.refreshPeriod = cfg.RefreshPeriod
+	a.capacity = cfg.PodCapacity
+	a.staleStateThreshold = cfg.StaleStateThreshold
+	a.startupGracePeriod = cfg.StartupGracePeriod
+	a.scaleUpProtectionWindow = cfg.ScaleUpProtectionWindow
+	a.minScaleInterval = cfg.MinScaleInterval
+	a.maxScaleUpStep = cfg.MaxScaleUpStep
+	a.maxScaleDownStep = cfg.MaxScaleDownStep
+	a.maxReplicas = cfg.MaxReplicas
+	a.demandSmoothingFactor = cfg.DemandSmoothingFactor
+	a.pdbAware = cfg.PDBAware
+	a.annotateCompactingPods = cfg.AnnotateCompactingPods
+	a.nodeAware = cfg.NodeAware
+	a.topologyKey = cfg.TopologyKey
+	a.yieldToExternalScalers = cfg.YieldToExternalScalers
+	a.scaleDownDisabled = cfg.ScaleDownDisabled
+	a.compactionHeadroom = cfg.CompactionHeadroom
+	a.compactionBatchSize = cfg.CompactionBatchSize
+	a.queueDepthThreshold = cfg.QueueDepthThreshold
+	a.scaleVerificationTimeout = cfg.ScaleVerificationTimeout
+	a.readinessGapTimeout = cfg.ReadinessGapTimeout
+	a.eventTypes = cfg.EventTypes.withDefaults()
+
+	a.logger.Infow("autoscaler config reloaded",
+		zap.String("refreshPeriod", a.refreshPeriod.String()),
+		zap.Int32("capacity", a.capacity),
+		zap.Int32("staleStateThreshold", a.staleStateThreshold),
+		zap.String("startupGracePeriod", a.startupGracePeriod.String()),
+		zap.String("scaleUpProtectionWindow", a.scaleUpProtectionWindow.String()),
+		zap.String("minScaleInterval", a.minScaleInterval.String()),
+		zap.Int32("maxScaleUpStep", a.maxScaleUpStep),
+		zap.Int32("maxScaleDownStep", a.maxScaleDownStep),
+		zap.Int32("maxReplicas", a.maxReplicas),
+		zap.Float64("demandSmoothingFactor", a.demandSmoothingFactor),
+		zap.Bool("pdbAware", a.pdbAware),
+		zap.Bool("annotateCompactingPods", a.annotateCompactingPods),
+		zap.Bool("nodeAware", a.nodeAware),
+		zap.String("topologyKey", a.topologyKey),
+		zap.Bool("yieldToExternalScalers", a.yieldToExternalScalers),
+		zap.Bool("scaleDownDisabled", a.scaleDownDisabled),
+		zap.Float64("compactionHeadroom", a.compactionHeadroom),
+		zap.Int32("compactionBatchSize", a.compactionBatchSize),
+		zap.Int64("queueDepthThreshold", a.queueDepthThreshold),
+		zap.String("scaleVerificationTimeout", a.scaleVerificationTimeout.String()),
+		zap.String("readinessGapTimeout", a.readinessGapTimeout.String()))
+	return nil
+}
+
+func (a *autoscaler) getRefreshPeriod() time.Duration {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.refreshPeriod
 }
 
 func (a *autoscaler) syncAutoscale(ctx context.Context, attemptScaleDown bool) error {
 	a.lock.Lock()
 	defer a.lock.Unlock()
 
+	a.loadState(ctx)
+	defer a.saveState(ctx)
+
+	start := a.clock.Now()
 	var lastErr error
 	wait.Poll(500*time.Millisecond, 5*time.Second, func() (bool, error) {
+		a.cycleOutcome = AutoscaleOutcomeNoop
 		err := a.doautoscale(ctx, attemptScaleDown)
 		if err != nil {
 			logging.FromContext(ctx).Errorw("Failed to autoscale", zap.Error(err))
@@ -163,6 +632,14 @@ func (a *autoscaler) syncAutoscale(ctx context.Context, attemptScaleDown bool) e
 		lastErr = err
 		return err == nil, nil
 	})
+
+	outcome := a.cycleOutcome
+	if lastErr != nil {
+		outcome = AutoscaleOutcomeError
+	}
+	if err := a.statsReporter.ReportAutoscaleCycle(outcome, a.clock.Since(start)); err != nil {
+		a.logger.Warnw("failed to report the autoscaling cycle", zap.Error(err))
+	}
 	return lastErr
 }
 
@@ -170,168 +647,1415 @@ func (a *autoscaler) doautoscale(ctx context.Context, attemptScaleDown bool) err
 	if !a.isLeader.Load() {
 		return nil
 	}
-	state, err := a.stateAccessor.State(a.getReserved())
+	if a.paused.Load() {
+		a.logger.Info("autoscaler is paused, skipping autoscaling")
+		return nil
+	}
+	if a.draining.Load() {
+		a.logger.Info("statefulset is being drained, skipping autoscaling")
+		return nil
+	}
+	reserved := a.getReserved()
+	state, err := a.stateAccessor.State(reserved)
 	if err != nil {
 		a.logger.Info("error while refreshing scheduler state (will retry)", zap.Error(err))
-		return err
+		state, err = a.staleState(err)
+		if err != nil {
+			return err
+		}
+		// Never scale down nor compact based on a stale state.
+		attemptScaleDown = false
+	} else {
+		a.stateFailures = 0
+		a.lastState = state
+		a.observeDemand(state)
+		a.logStateDiff(state)
+		if err := a.statsReporter.ReportPodCapacity(state, reserved); err != nil {
+			a.logger.Warnw("failed to report the pods capacity", zap.Error(err))
+		}
 	}
 
-	scale, err := a.statefulSetClient.GetScale(ctx, a.statefulSetName, metav1.GetOptions{})
-	if err != nil {
-		// skip a beat
-		a.logger.Infow("failed to get scale subresource", zap.Error(err))
-		return err
+	if attemptScaleDown && a.scaleDownDisabled {
+		// Scaling down and compacting are left to a separate process.
+		attemptScaleDown = false
 	}
 
-	a.logger.Debugw("checking adapter capacity",
-		zap.Int32("replicas", scale.Spec.Replicas),
-		zap.Any("state", state))
-
-	var scaleUpFactor, newreplicas, minNumPods int32
-	scaleUpFactor = 1                                                                                         // Non-HA scaling
-	if state.SchedPolicy != nil && contains(nil, state.SchedPolicy.Priorities, st.AvailabilityZonePriority) { //HA scaling across zones
-		scaleUpFactor = state.NumZones
+	if attemptScaleDown && a.inStartupGracePeriod() {
+		// The informers might not be fully synced yet, only allow scaling up.
+		a.logger.Debugw("skipping scale down during the startup grace period",
+			zap.Time("startedAt", a.startedAt),
+			zap.String("startupGracePeriod", a.startupGracePeriod.String()))
+		attemptScaleDown = false
 	}
-	if state.SchedPolicy != nil && contains(nil, state.SchedPolicy.Priorities, st.AvailabilityNodePriority) { //HA scaling across nodes
-		scaleUpFactor = state.NumNodes
+
+	if attemptScaleDown && a.inScaleUpProtectionWindow() {
+		// The capacity was just added for a burst, keep it for the protection window.
+		a.logger.Debugw("skipping scale down after a recent scale up",
+			zap.Time("lastScaleUpTime", a.lastScaleUpTime),
+			zap.String("scaleUpProtectionWindow", a.scaleUpProtectionWindow.String()))
+		attemptScaleDown = false
 	}
 
-	newreplicas = state.LastOrdinal + 1 // Ideal number
+	var newreplicas int32
+	scaleUpFactor := a.scaleUpFactorFor(state)
 
-	if state.SchedulerPolicy == scheduler.MAXFILLUP {
-		newreplicas = int32(math.Ceil(float64(state.TotalExpectedVReplicas()) / float64(state.Capacity)))
-	} else {
-		// Take into account pending replicas and pods that are already filled (for even pod spread)
-		pending := state.TotalPending()
-		if pending > 0 {
-			// Make sure to allocate enough pods for holding all pending replicas.
-			if state.SchedPolicy != nil && contains(state.SchedPolicy.Predicates, nil, st.EvenPodSpread) && len(state.FreeCap) > 0 { //HA scaling across pods
-				leastNonZeroCapacity := a.minNonZeroInt(state.FreeCap)
-				minNumPods = int32(math.Ceil(float64(pending) / float64(leastNonZeroCapacity)))
-			} else {
-				minNumPods = int32(math.Ceil(float64(pending) / float64(a.capacity)))
-			}
-			newreplicas += int32(math.Ceil(float64(minNumPods)/float64(scaleUpFactor)) * float64(scaleUpFactor))
-		}
+	// Vreplicas forecasted on top of the actual demand. Never negative so that a forecast
+	// never causes a scale down below the actual demand.
+	forecasted := a.forecastedExcess(ctx, state)
 
-		if newreplicas <= state.LastOrdinal {
-			// Make sure to never scale down past the last ordinal
-			newreplicas = state.LastOrdinal + scaleUpFactor
+	newreplicas, err = a.desiredReplicas(state, scaleUpFactor, float64(forecasted))
+	if err != nil {
+		return err
+	}
+	// The replicas for the smoothed demand, bounded by the replicas for the actual demand
+	// once the current replicas are known.
+	smoothedreplicas := newreplicas
+	if a.demandSmoothingFactor > 0 && a.demandObserved {
+		excess := a.smoothedDemand - float64(state.TotalExpectedVReplicas())
+		smoothedreplicas, err = a.desiredReplicas(state, scaleUpFactor, float64(forecasted)+excess)
+		if err != nil {
+			return err
 		}
 	}
 
-	// Only scale down if permitted
-	if !attemptScaleDown && newreplicas < scale.Spec.Replicas {
-		newreplicas = scale.Spec.Replicas
+	// Scale up on a high backlog even when the vreplicas look balanced
+	reason := AuditReasonDemand
+	if queued := a.queueDepthReplicas(ctx, scaleUpFactor); queued > newreplicas {
+		newreplicas = queued
+		if smoothedreplicas < queued {
+			smoothedreplicas = queued
+		}
+		reason = AuditReasonQueueDepth
 	}
 
-	if newreplicas != scale.Spec.Replicas {
-		scale.Spec.Replicas = newreplicas
-		a.logger.Infow("updating adapter replicas", zap.Int32("replicas", scale.Spec.Replicas))
+	readinessGap := a.persistentReadinessGap(ctx)
 
-		_, err = a.statefulSetClient.UpdateScale(ctx, a.statefulSetName, scale, metav1.UpdateOptions{})
+	// The scale subresource might also be written by another controller (e.g. an HPA),
+	// on conflicts the scale is fetched again and the update retried.
+	var oldreplicas, wantedreplicas, updatedreplicas int32
+	var deferred bool
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		scale, err := a.statefulSets().GetScale(ctx, a.statefulSetName, metav1.GetOptions{})
 		if err != nil {
-			a.logger.Errorw("updating scale subresource failed", zap.Error(err))
+			// skip a beat
+			a.logger.Infow("failed to get scale subresource", zap.Error(err))
 			return err
 		}
-	} else if attemptScaleDown {
+
+		a.logger.Debugw("checking adapter capacity",
+			zap.Int32("replicas", scale.Spec.Replicas),
+			zap.Any("state", state))
+
+		oldreplicas = scale.Spec.Replicas
+		wantedreplicas = smoothReplicas(newreplicas, smoothedreplicas, scale.Spec.Replicas)
+		updatedreplicas = a.limitReplicas(wantedreplicas, scale.Spec.Replicas, scaleUpFactor, attemptScaleDown)
+		if updatedreplicas == scale.Spec.Replicas {
+			return nil
+		}
+		if readinessGap && updatedreplicas > scale.Spec.Replicas {
+			a.logger.Warnw("suppressing the scale up, the statefulset replicas aren't becoming ready",
+				zap.Int32("replicas", scale.Spec.Replicas),
+				zap.Int32("wantedReplicas", updatedreplicas),
+				zap.Time("readinessGapSince", a.readinessGapSince),
+				zap.String("readinessGapTimeout", a.readinessGapTimeout.String()))
+			updatedreplicas = scale.Spec.Replicas
+			deferred = true
+			return nil
+		}
+		if a.inMinScaleInterval() {
+			a.logger.Infow("deferring the replicas change after a recent scale",
+				zap.Int32("replicas", scale.Spec.Replicas),
+				zap.Int32("wantedReplicas", updatedreplicas),
+				zap.Time("lastScaleTime", a.lastScaleTime),
+				zap.String("minScaleInterval", a.minScaleInterval.String()))
+			updatedreplicas = scale.Spec.Replicas
+			deferred = true
+			return nil
+		}
+
+		scale.Spec.Replicas = updatedreplicas
+		a.logger.Infow("updating adapter replicas", zap.Int32("replicas", scale.Spec.Replicas))
+
+		_, err = a.statefulSets().UpdateScale(ctx, a.statefulSetName, scale, metav1.UpdateOptions{})
+		if apierrors.IsConflict(err) {
+			a.scaleConflicts++
+			if a.scaleConflicts >= persistentScaleConflicts {
+				a.logger.Warnw("persistent conflicts updating the scale subresource, another controller might be scaling the statefulset",
+					zap.Int32("consecutiveConflicts", a.scaleConflicts),
+					zap.Error(err))
+			}
+		} else if err != nil {
+			a.logger.Errorw("updating scale subresource failed", zap.Error(err))
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	a.scaleConflicts = 0
+
+	if pending := state.TotalPending(); pending > 0 && a.maxReplicas > 0 &&
+		newreplicas >= a.maxReplicas && updatedreplicas >= a.maxReplicas {
+		a.logger.Warnw("pending vreplicas can't be scheduled, the statefulset is scaled to the max replicas",
+			zap.Int32("pending", pending),
+			zap.Int32("maxReplicas", a.maxReplicas))
+		if a.onCapacityExhausted != nil {
+			a.onCapacityExhausted(pending)
+		}
+	}
+
+	if updatedreplicas != oldreplicas {
+		a.lastScaleTime = a.clock.Now()
+		if a.onScaleApplied != nil {
+			a.onScaleApplied(updatedreplicas)
+		}
+		if updatedreplicas > oldreplicas {
+			a.lastScaleUpTime = a.clock.Now()
+		}
+		if updatedreplicas > oldreplicas && a.scaleVerificationTimeout > 0 {
+			go a.verifyScale(ctx, a.scaleUps.Add(1), updatedreplicas, a.scaleVerificationTimeout)
+		}
+
+		eventType := a.eventTypes.ScaledUp
+		a.cycleOutcome = AutoscaleOutcomeScaleUp
+		if updatedreplicas < oldreplicas {
+			eventType = a.eventTypes.ScaledDown
+			a.cycleOutcome = AutoscaleOutcomeScaleDown
+		}
+		a.emitEvent(eventType, scaleEventData{StatefulSet: a.statefulSetName, From: oldreplicas, To: updatedreplicas})
+		a.audit(ctx, state, AuditEntry{
+			Action:      AuditActionScale,
+			Reason:      reason,
+			OldReplicas: oldreplicas,
+			NewReplicas: updatedreplicas,
+		})
+	} else if attemptScaleDown && !deferred {
+		if pending := state.TotalPending(); wantedreplicas > oldreplicas || pending > 0 {
+			// The replicas only stayed the same because the target was limited (e.g. by the
+			// max replicas), compacting would fight the demand.
+			a.logger.Debugw("skipping compaction, the demand exceeds the replicas",
+				zap.Int32("replicas", oldreplicas),
+				zap.Int32("wantedReplicas", wantedreplicas),
+				zap.Int32("pending", pending))
+			return nil
+		}
 		// since the number of replicas hasn't changed and time has approached to scale down,
 		// take the opportunity to compact the vreplicas
-		a.mayCompact(state, scaleUpFactor)
+		a.mayCompact(ctx, state, scaleUpFactor)
 	}
 	return nil
 }
 
-func (a *autoscaler) mayCompact(s *st.State, scaleUpFactor int32) {
+// verifyScale waits for the statefulset to have target ready replicas and warns when it
+// doesn't within timeout, e.g. because the new pods can't be scheduled. The verification
+// stops early when a newer scale up happens.
+func (a *autoscaler) verifyScale(ctx context.Context, scaleUp int64, target int32, timeout time.Duration) {
+	deadline := a.clock.NewTimer(timeout)
+	defer deadline.Stop()
 
-	// This avoids a too aggressive scale down by adding a "grace period" based on the refresh
-	// period
-	nextAttempt := a.lastCompactAttempt.Add(a.refreshPeriod)
-	if time.Now().Before(nextAttempt) {
-		a.logger.Debugw("Compact was retried before refresh period",
-			zap.Time("lastCompactAttempt", a.lastCompactAttempt),
-			zap.Time("nextAttempt", nextAttempt),
-			zap.String("refreshPeriod", a.refreshPeriod.String()),
-		)
-		return
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C():
+			if a.scaleUps.Load() != scaleUp {
+				return
+			}
+			ready, err := a.readyReplicas(ctx)
+			if err != nil || ready >= target {
+				return
+			}
+			a.logger.Warnw("statefulset didn't reach the scaled up replicas, some pods might not be ready",
+				zap.Int32("target", target),
+				zap.Int32("readyReplicas", ready),
+				zap.String("timeout", timeout.String()))
+			a.emitEvent(a.eventTypes.ScaleNotReached, scaleNotReachedEventData{StatefulSet: a.statefulSetName, Target: target, ReadyReplicas: ready})
+			return
+		case <-a.clock.After(scaleVerificationInterval):
+			if a.scaleUps.Load() != scaleUp {
+				return
+			}
+			if ready, err := a.readyReplicas(ctx); err == nil && ready >= target {
+				a.logger.Debugw("statefulset reached the scaled up replicas", zap.Int32("target", target))
+				return
+			}
+		}
 	}
+}
 
-	a.logger.Debugw("Trying to compact and scale down",
-		zap.Int32("scaleUpFactor", scaleUpFactor),
-		zap.Any("state", s),
-	)
+// statefulSets returns the client of the statefulsets in the namespace of the target
+// statefulset.
+func (a *autoscaler) statefulSets() clientappsv1.StatefulSetInterface {
+	return a.kubeClient.AppsV1().StatefulSets(a.statefulSetNamespace)
+}
 
-	// when there is only one pod there is nothing to move or number of pods is just enough!
-	if s.LastOrdinal < 1 || len(s.SchedulablePods) <= int(scaleUpFactor) {
-		return
+func (a *autoscaler) readyReplicas(ctx context.Context) (int32, error) {
+	statefulSet, err := a.statefulSets().Get(ctx, a.statefulSetName, metav1.GetOptions{})
+	if err != nil {
+		a.logger.Infow("failed to get the statefulset to verify the scale", zap.Error(err))
+		return 0, err
 	}
+	return statefulSet.Status.ReadyReplicas, nil
+}
 
-	if s.SchedulerPolicy == scheduler.MAXFILLUP {
-		// Determine if there is enough free capacity to
-		// move all vreplicas placed in the last pod to pods with a lower ordinal
-		freeCapacity := s.FreeCapacity() - s.Free(s.LastOrdinal)
-		usedInLastPod := s.Capacity - s.Free(s.LastOrdinal)
+// persistentReadinessGap returns whether the statefulset has had fewer ready replicas than
+// its spec replicas for at least readinessGapTimeout. A failure to get the statefulset
+// never suppresses the scale ups.
+func (a *autoscaler) persistentReadinessGap(ctx context.Context) bool {
+	if a.readinessGapTimeout <= 0 {
+		a.readinessGapSince = time.Time{}
+		return false
+	}
+	statefulSet, err := a.statefulSets().Get(ctx, a.statefulSetName, metav1.GetOptions{})
+	if err != nil {
+		a.logger.Infow("failed to get the statefulset to check the ready replicas", zap.Error(err))
+		return false
+	}
 
-		if freeCapacity >= usedInLastPod {
-			a.lastCompactAttempt = time.Now()
-			err := a.compact(s, scaleUpFactor)
-			if err != nil {
-				a.logger.Errorw("vreplicas compaction failed", zap.Error(err))
-			}
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	if statefulSet.Status.ReadyReplicas >= replicas {
+		a.readinessGapSince = time.Time{}
+		return false
+	}
+	if a.readinessGapSince.IsZero() {
+		a.readinessGapSince = a.clock.Now()
+	}
+	return a.clock.Since(a.readinessGapSince) >= a.readinessGapTimeout
+}
+
+// limitReplicas returns the number of replicas to scale the statefulset to, given the
+// wanted and the current number of replicas.
+func (a *autoscaler) limitReplicas(wanted, replicas, scaleUpFactor int32, attemptScaleDown bool) int32 {
+	// Scale up gradually if the step is limited
+	if a.maxScaleUpStep > 0 && wanted-replicas > a.maxScaleUpStep {
+		// Keep adding a multiple of the scale up factor for HA scaling
+		step := a.maxScaleUpStep / scaleUpFactor * scaleUpFactor
+		if step < scaleUpFactor {
+			step = scaleUpFactor
 		}
+		a.logger.Debugw("limiting scale up step",
+			zap.Int32("wantReplicas", wanted),
+			zap.Int32("step", step))
+		wanted = replicas + step
+	}
 
-		// only do 1 replica at a time to avoid overloading the scheduler with too many
-		// rescheduling requests.
-	} else if s.SchedPolicy != nil {
-		//Below calculation can be optimized to work for recovery scenarios when nodes/zones are lost due to failure
-		freeCapacity := s.FreeCapacity()
-		usedInLastXPods := s.Capacity * scaleUpFactor
-		for i := int32(0); i < scaleUpFactor && s.LastOrdinal-i >= 0; i++ {
-			freeCapacity = freeCapacity - s.Free(s.LastOrdinal-i)
-			usedInLastXPods = usedInLastXPods - s.Free(s.LastOrdinal-i)
+	// Only scale down if permitted. Scaling down is left to the external scalers when
+	// yielding to them.
+	if (!attemptScaleDown || a.yieldToExternalScalers) && wanted < replicas {
+		wanted = replicas
+	}
+
+	// Scale down gradually if the step is limited
+	if a.maxScaleDownStep > 0 && replicas-wanted > a.maxScaleDownStep {
+		// Keep removing a multiple of the scale up factor for HA scaling
+		step := a.maxScaleDownStep / scaleUpFactor * scaleUpFactor
+		if step < scaleUpFactor {
+			step = scaleUpFactor
 		}
+		a.logger.Debugw("limiting scale down step",
+			zap.Int32("wantReplicas", wanted),
+			zap.Int32("step", step))
+		wanted = replicas - step
+	}
+	return wanted
+}
 
-		if (freeCapacity >= usedInLastXPods) && //remaining pods can hold all vreps from evicted pods
-			(s.Replicas-scaleUpFactor >= scaleUpFactor) { //remaining # of pods is enough for HA scaling
-			a.lastCompactAttempt = time.Now()
-			err := a.compact(s, scaleUpFactor)
-			if err != nil {
-				a.logger.Errorw("vreplicas compaction failed", zap.Error(err))
-			}
+// clampReplicas converts a computed number of replicas to int32, clamped to
+// [0, maxReplicas]. Values out of the int32 range, caused by pathological demands or a
+// misconfigured capacity, are logged as errors.
+func (a *autoscaler) clampReplicas(replicas float64) int32 {
+	max := int32(math.MaxInt32)
+	if a.maxReplicas > 0 {
+		max = a.maxReplicas
+	}
+
+	switch {
+	case math.IsNaN(replicas):
+		a.logger.Errorw("computed replicas are not a number, using 0")
+		return 0
+	case replicas > math.MaxInt32 || replicas < math.MinInt32:
+		a.logger.Errorw("computed replicas overflow int32, clamping",
+			zap.Float64("replicas", replicas),
+			zap.Int32("maxReplicas", max))
+	}
+
+	if replicas < 0 {
+		return 0
+	}
+	if replicas > float64(max) {
+		return max
+	}
+	return int32(replicas)
+}
+
+// scaleUpFactorFor returns the number of pods the statefulset is scaled by at once: the
+// number of failure domains of the topology key when one is configured and the schedulable
+// pods run on known nodes, otherwise the number of zones or nodes the HA scheduling policies
+// spread the vreplicas across.
+func (a *autoscaler) scaleUpFactorFor(s *st.State) int32 {
+	if a.topologyKey != "" {
+		if domains := a.topologyDomains(s); domains > 0 {
+			return domains
 		}
 	}
+	scaleUpFactor := int32(1)                                                                         // Non-HA scaling
+	if s.SchedPolicy != nil && contains(nil, s.SchedPolicy.Priorities, st.AvailabilityZonePriority) { //HA scaling across zones
+		scaleUpFactor = s.NumZones
+	}
+	if s.SchedPolicy != nil && contains(nil, s.SchedPolicy.Priorities, st.AvailabilityNodePriority) { //HA scaling across nodes
+		scaleUpFactor = s.NumNodes
+	}
+	return scaleUpFactor
 }
 
-func (a *autoscaler) compact(s *st.State, scaleUpFactor int32) error {
-	var pod *v1.Pod
-	vpods, err := a.vpodLister()
-	if err != nil {
-		return err
+// topologyDomains returns the number of distinct values of the topology key label across the
+// nodes running the schedulable pods, the nodes without the label counting as one domain.
+func (a *autoscaler) topologyDomains(s *st.State) int32 {
+	if a.nodeLister == nil || s.PodLister == nil {
+		return 0
 	}
 
-	for _, vpod := range vpods {
-		placements := vpod.GetPlacements()
-		for i := len(placements) - 1; i >= 0; i-- { //start from the last placement
-			for j := int32(0); j < scaleUpFactor; j++ {
-				ordinal := st.OrdinalFromPodName(placements[i].PodName)
-
-				if ordinal == s.LastOrdinal-j {
-					wait.PollImmediate(50*time.Millisecond, 5*time.Second, func() (bool, error) {
-						if s.PodLister != nil {
-							pod, err = s.PodLister.Get(placements[i].PodName)
-						}
-						return err == nil, nil
-					})
-
-					err = a.evictor(pod, vpod, &placements[i])
-					if err != nil {
-						return err
-					}
+	domains := sets.NewString()
+	for _, ordinal := range s.SchedulablePods {
+		pod, err := s.PodLister.Get(st.PodNameFromOrdinal(a.statefulSetName, ordinal))
+		if err != nil || pod.Spec.NodeName == "" {
+			continue
+		}
+		node, err := a.nodeLister.Get(pod.Spec.NodeName)
+		if err != nil {
+			continue
+		}
+		domain, ok := node.GetLabels()[a.topologyKey]
+		if !ok || domain == "" {
+			domain = scheduler.UnknownZone
+		}
+		domains.Insert(domain)
+	}
+	return int32(domains.Len())
+}
+
+// inStartupGracePeriod reports whether the autoscaler was started less than
+// startupGracePeriod ago.
+func (a *autoscaler) inStartupGracePeriod() bool {
+	if a.startupGracePeriod <= 0 || a.startedAt.IsZero() {
+		return false
+	}
+	return a.clock.Now().Before(a.startedAt.Add(a.startupGracePeriod))
+}
+
+// inScaleUpProtectionWindow reports whether the autoscaler scaled up less than
+// scaleUpProtectionWindow ago.
+func (a *autoscaler) inScaleUpProtectionWindow() bool {
+	if a.scaleUpProtectionWindow <= 0 || a.lastScaleUpTime.IsZero() {
+		return false
+	}
+	return a.clock.Now().Before(a.lastScaleUpTime.Add(a.scaleUpProtectionWindow))
+}
+
+// inMinScaleInterval reports whether the autoscaler changed the replicas less than
+// minScaleInterval ago.
+func (a *autoscaler) inMinScaleInterval() bool {
+	if a.minScaleInterval <= 0 || a.lastScaleTime.IsZero() {
+		return false
+	}
+	return a.clock.Now().Before(a.lastScaleTime.Add(a.minScaleInterval))
+}
+
+// staleState returns the last known good state once the state has been unavailable for
+// staleStateThreshold consecutive attempts, otherwise it returns err.
+func (a *autoscaler) staleState(err error) (*st.State, error) {
+	a.stateFailures++
+	if a.staleStateThreshold <= 0 || a.stateFailures < a.staleStateThreshold || a.lastState == nil {
+		return nil, err
+	}
+	a.logger.Errorw("scheduler state unavailable, operating on stale state to hold current capacity",
+		zap.Int32("consecutiveFailures", a.stateFailures),
+		zap.Error(err))
+	return a.lastState, nil
+}
+
+// combineReserved returns a GetReserved merging the reservations returned by primary and
+// additional. Reservations for the same vpod and pod are summed. A vpod reserved by more than one
+// source is logged as a conflict.
+func combineReserved(logger *zap.SugaredLogger, primary GetReserved, additional ...GetReserved) GetReserved {
+	if len(additional) == 0 {
+		return primary
+	}
+	sources := append([]GetReserved{primary}, additional...)
+
+	return func() map[types.NamespacedName]map[string]int32 {
+		merged := make(map[types.NamespacedName]map[string]int32)
+		reservedBy := make(map[types.NamespacedName]int)
+		for i, source := range sources {
+			if source == nil {
+				continue
+			}
+			for key, rps := range source() {
+				if j, ok := reservedBy[key]; ok {
+					logger.Warnw("vpod reserved by multiple sources, summing reservations",
+						zap.Any("vpod", key),
+						zap.Int("source", j),
+						zap.Int("conflictingSource", i))
+				} else {
+					reservedBy[key] = i
+				}
+
+				if _, ok := merged[key]; !ok {
+					merged[key] = make(map[string]int32, len(rps))
+				}
+				for podName, rvreplicas := range rps {
+					merged[key][podName] += rvreplicas
 				}
 			}
 		}
+		return merged
 	}
-	return nil
+}
+
+// desiredReplicas returns the number of replicas needed to hold the demand of the given
+// state plus excess vreplicas (negative to hold less).
+func (a *autoscaler) desiredReplicas(state *st.State, scaleUpFactor int32, excess float64) (int32, error) {
+	// The replicas are computed with floats and clamped, so that pathological demands or a
+	// misconfigured capacity never wrap around to a negative number of replicas.
+	if state.SchedulerPolicy == scheduler.MAXFILLUP {
+		demand, err := a.weightedDemand(state)
+		if err != nil {
+			return 0, err
+		}
+		return a.clampReplicas(math.Ceil((demand + excess) / float64(state.Capacity))), nil
+	}
+
+	newreplicas := state.LastOrdinal + 1 // Ideal number
+
+	// Take into account pending replicas and pods that are already filled (for even pod spread)
+	pending := excess
+	for _, p := range state.Pending {
+		pending += float64(p)
+	}
+	if pending > 0 {
+		// Make sure to allocate enough pods for holding all pending replicas.
+		var minNumPods float64
+		if state.SchedPolicy != nil && contains(state.SchedPolicy.Predicates, nil, st.EvenPodSpread) && len(state.FreeCap) > 0 { //HA scaling across pods
+			leastNonZeroCapacity := a.minNonZeroFreeCap(state)
+			minNumPods = math.Ceil(pending / float64(leastNonZeroCapacity))
+		} else {
+			minNumPods = math.Ceil(pending / float64(a.capacity))
+		}
+		newreplicas = a.clampReplicas(float64(newreplicas) + math.Ceil(minNumPods/float64(scaleUpFactor))*float64(scaleUpFactor))
+	}
+
+	if newreplicas <= state.LastOrdinal {
+		// Make sure to never scale down past the last ordinal
+		newreplicas = state.LastOrdinal + scaleUpFactor
+	}
+	return newreplicas, nil
+}
+
+// observeDemand updates the moving average of the total expected vreplicas with the demand
+// of a fresh state.
+func (a *autoscaler) observeDemand(state *st.State) {
+	if a.demandSmoothingFactor <= 0 {
+		// Start over from the actual demand when the smoothing is enabled again.
+		a.demandObserved = false
+		return
+	}
+	demand := float64(state.TotalExpectedVReplicas())
+	if !a.demandObserved {
+		a.smoothedDemand = demand
+		a.demandObserved = true
+		return
+	}
+	a.smoothedDemand = a.demandSmoothingFactor*demand + (1-a.demandSmoothingFactor)*a.smoothedDemand
+}
+
+// smoothReplicas returns the replicas for the smoothed demand bounded by the current replicas
+// and the replicas for the actual demand, so that the smoothing slows down both scale ups
+// and scale downs but never scales up past, nor down below, the actual demand.
+func smoothReplicas(actual, smoothed, replicas int32) int32 {
+	low, high := actual, replicas
+	if low > high {
+		low, high = high, low
+	}
+	if smoothed < low {
+		return low
+	}
+	if smoothed > high {
+		return high
+	}
+	return smoothed
+}
+
+// weightedDemand returns the total expected vreplicas, each weighted by the cost of its vpod.
+// Without a cost function, or for vpods without a valid cost, every vreplica costs 1.
+func (a *autoscaler) weightedDemand(state *st.State) (float64, error) {
+	if a.vreplicaCost == nil {
+		// Summed as floats since the int32 total might overflow.
+		demand := float64(0)
+		for _, vreplicas := range state.ExpectedVReplicaByVPod {
+			demand += float64(vreplicas)
+		}
+		return demand, nil
+	}
+
+	vpods, err := a.vpodLister()
+	if err != nil {
+		return 0, err
+	}
+	byKey := make(map[types.NamespacedName]scheduler.VPod, len(vpods))
+	for _, vpod := range vpods {
+		byKey[vpod.GetKey()] = vpod
+	}
+
+	demand := float64(0)
+	for key, vreplicas := range state.ExpectedVReplicaByVPod {
+		cost := float64(1)
+		if vpod, ok := byKey[key]; ok {
+			if c := a.vreplicaCost(vpod); c >= 0 && !math.IsInf(c, 1) {
+				cost = c
+			} else {
+				a.logger.Warnw("ignoring invalid vreplica cost", zap.Any("vpod", key), zap.Float64("cost", c))
+			}
+		}
+		demand += float64(vreplicas) * cost
+	}
+	return demand, nil
+}
+
+// forecastedExcess returns the number of vreplicas the forecaster predicts on top of the
+// current demand, or 0 when there is no forecaster or the forecast does not exceed the demand.
+func (a *autoscaler) forecastedExcess(ctx context.Context, state *st.State) int32 {
+	if a.forecaster == nil {
+		return 0
+	}
+	forecast, err := a.forecaster.Forecast(ctx)
+	if err != nil {
+		a.logger.Warnw("failed to forecast demand, ignoring forecast", zap.Error(err))
+		return 0
+	}
+	demand := state.TotalExpectedVReplicas()
+	if forecast <= demand {
+		return 0
+	}
+	a.logger.Debugw("forecasted demand exceeds current demand",
+		zap.Int32("forecast", forecast),
+		zap.Int32("demand", demand))
+	return forecast - demand
+}
+
+// queueDepthReplicas returns the number of replicas needed to keep the average backlog per pod
+// under the queue depth threshold, rounded up to a multiple of the scale up factor. It returns
+// 0 when the backlog is unknown.
+func (a *autoscaler) queueDepthReplicas(ctx context.Context, scaleUpFactor int32) int32 {
+	if a.queueDepthSource == nil || a.queueDepthThreshold <= 0 {
+		return 0
+	}
+	depths, err := a.queueDepthSource.QueueDepth(ctx)
+	if err != nil {
+		a.logger.Warnw("failed to get the pods queue depth, ignoring backlog", zap.Error(err))
+		return 0
+	}
+
+	var backlog int64
+	for _, depth := range depths {
+		if depth > 0 {
+			backlog += depth
+		}
+	}
+	if backlog == 0 {
+		return 0
+	}
+
+	pods := math.Ceil(float64(backlog) / float64(a.queueDepthThreshold))
+	replicas := a.clampReplicas(math.Ceil(pods/float64(scaleUpFactor)) * float64(scaleUpFactor))
+	a.logger.Debugw("replicas needed for the pods backlog",
+		zap.Int64("backlog", backlog),
+		zap.Int32("replicas", replicas))
+	return replicas
+}
+
+func (a *autoscaler) mayCompact(ctx context.Context, s *st.State, scaleUpFactor int32) {
+
+	// This avoids a too aggressive scale down by adding a "grace period" based on the refresh
+	// period
+	nextAttempt := a.lastCompactAttempt.Add(a.refreshPeriod)
+	if a.clock.Now().Before(nextAttempt) {
+		a.logger.Debugw("Compact was retried before refresh period",
+			zap.Time("lastCompactAttempt", a.lastCompactAttempt),
+			zap.Time("nextAttempt", nextAttempt),
+			zap.String("refreshPeriod", a.refreshPeriod.String()),
+		)
+		if err := a.statsReporter.ReportCompactionSuppressed(); err != nil {
+			a.logger.Warnw("failed to report the suppressed compaction", zap.Error(err))
+		}
+		return
+	}
+
+	a.logger.Debugw("Trying to compact and scale down",
+		zap.Int32("scaleUpFactor", scaleUpFactor),
+		zap.Any("state", s),
+	)
+
+	// when there is only one pod there is nothing to move or number of pods is just enough!
+	if s.LastOrdinal < 1 || len(s.SchedulablePods) <= int(scaleUpFactor) {
+		return
+	}
+
+	if s.SchedulerPolicy == scheduler.MAXFILLUP {
+		// Determine if there is enough free capacity to
+		// move all vreplicas placed in the last pod to pods with a lower ordinal
+		freeCapacity := a.survivingFreeCapacity(s, 1)
+		usedInLastPod := s.Capacity - s.Free(s.LastOrdinal)
+
+		if freeCapacity-usedInLastPod >= a.compactionHeadroomFor(s, int32(len(s.SchedulablePods))-1) {
+			a.lastCompactAttempt = a.clock.Now()
+			a.cycleOutcome = AutoscaleOutcomeCompaction
+			a.compactWithEvents(ctx, s, scaleUpFactor)
+		}
+
+		// only do 1 replica at a time to avoid overloading the scheduler with too many
+		// rescheduling requests.
+	} else if s.SchedPolicy != nil {
+		if pods, _ := a.policyCompactionPods(s, scaleUpFactor); pods > 0 {
+			a.lastCompactAttempt = a.clock.Now()
+			a.cycleOutcome = AutoscaleOutcomeCompaction
+			a.compactWithEvents(ctx, s, pods)
+		}
+	}
+}
+
+// policyCompactionPods returns the number of last pods the policy based compaction can
+// evict, in groups of scaleUpFactor pods and up to compactionBatchSize groups, or 0 and the
+// reason why when not even one group can be evicted.
+func (a *autoscaler) policyCompactionPods(s *st.State, scaleUpFactor int32) (int32, string) {
+	batches := a.compactionBatchSize
+	if batches < 1 {
+		batches = 1
+	}
+
+	var reason string
+	for ; batches > 0; batches-- {
+		pods := batches * scaleUpFactor
+
+		//Below calculation can be optimized to work for recovery scenarios when nodes/zones are lost due to failure
+		freeCapacity := a.survivingFreeCapacity(s, pods)
+		usedInLastXPods := s.Capacity * pods
+		for i := int32(0); i < pods && s.LastOrdinal-i >= 0; i++ {
+			usedInLastXPods = usedInLastXPods - s.Free(s.LastOrdinal-i)
+		}
+
+		remainingPods := s.Replicas - pods
+		if a.nodeAware {
+			// Pods on cordoned nodes are being drained, they don't count towards HA.
+			remainingPods -= a.cordonedPods(s, remainingPods)
+		}
+
+		switch {
+		case freeCapacity-usedInLastXPods < a.compactionHeadroomFor(s, int32(len(s.SchedulablePods))-pods): //remaining pods can hold all vreps from evicted pods, with headroom
+			reason = compactionReasonInsufficientCapacity
+		case remainingPods < scaleUpFactor: //remaining # of pods is enough for HA scaling
+			reason = compactionReasonNotEnoughPods
+		case !a.preservesZoneSpread(s, pods): //evicted vreps can be placed without reducing the zone spread
+			reason = compactionReasonZoneSpread
+		default:
+			return pods, ""
+		}
+	}
+	return 0, reason
+}
+
+// preservesZoneSpread simulates the placement of the vreplicas evicted from the given number
+// of last pods on the remaining pods, spreading them to the zones not covered yet first, and
+// returns whether every vpod still spans as many zones as before the compaction. It always
+// returns true when the zone spread isn't a scheduling priority.
+func (a *autoscaler) preservesZoneSpread(s *st.State, pods int32) bool {
+	if s.SchedPolicy == nil || !contains(nil, s.SchedPolicy.Priorities, st.AvailabilityZonePriority) {
+		return true
+	}
+	// Placements on pods with an unexpected name are skipped by the compaction, they're not evicted.
+	evicted := func(podName string) bool {
+		ordinal, err := st.ParseOrdinalFromPodName(podName)
+		if err != nil {
+			a.logger.Debugw("ignoring placement with unexpected pod name", zap.Error(err))
+			return false
+		}
+		return ordinal > s.LastOrdinal-pods
+	}
+
+	// The zone and free capacity of the schedulable pods left after the compaction.
+	var remaining []string
+	zones := make(map[string]string)
+	free := make(map[string]int32)
+	for _, ordinal := range s.SchedulablePods {
+		podName := st.PodNameFromOrdinal(a.statefulSetName, ordinal)
+		if evicted(podName) {
+			continue
+		}
+		zone, _, err := s.GetPodInfo(podName)
+		if err != nil {
+			continue
+		}
+		remaining = append(remaining, podName)
+		zones[podName] = zone
+		free[podName] = s.Free(ordinal)
+	}
+
+	keys := make([]types.NamespacedName, 0, len(s.PodSpread))
+	for key := range s.PodSpread {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	for _, key := range keys {
+		before := 0
+		for zone, vreplicas := range s.ZoneSpread[key] {
+			if zone != "" && vreplicas > 0 {
+				before++
+			}
+		}
+
+		covered := sets.NewString()
+		toPlace := int32(0)
+		for podName, vreplicas := range s.PodSpread[key] {
+			if evicted(podName) {
+				toPlace += vreplicas
+			} else if zone, ok := zones[podName]; ok && vreplicas > 0 {
+				covered.Insert(zone)
+			}
+		}
+
+		for ; toPlace > 0; toPlace-- {
+			target := ""
+			for _, podName := range remaining {
+				if free[podName] <= 0 {
+					continue
+				}
+				if target == "" {
+					target = podName
+				}
+				if !covered.Has(zones[podName]) {
+					target = podName
+					break
+				}
+			}
+			if target == "" {
+				break
+			}
+			free[target]--
+			covered.Insert(zones[target])
+		}
+
+		if covered.Len() < before {
+			a.logger.Infow("compaction would reduce the zone spread",
+				zap.Any("vpod", key),
+				zap.Int32("pods", pods),
+				zap.Int("zonesBefore", before),
+				zap.Int("zonesAfter", covered.Len()))
+			return false
+		}
+	}
+	return true
+}
+
+// survivingFreeCapacity returns the free capacity of the schedulable pods left after evicting
+// the given number of pods with the highest ordinals. When node aware, pods running on a
+// cordoned node are excluded, they are being drained and cannot take the evicted vreplicas.
+func (a *autoscaler) survivingFreeCapacity(s *st.State, evicted int32) int32 {
+	free := int32(0)
+	for _, ordinal := range s.SchedulablePods {
+		if ordinal > s.LastOrdinal-evicted || (a.nodeAware && a.onCordonedNode(s, ordinal)) {
+			continue
+		}
+		free += s.Free(ordinal)
+	}
+	return free
+}
+
+// cordonedPods returns the number of pods, among the given number of pods with the lowest
+// ordinals, running on a cordoned node.
+func (a *autoscaler) cordonedPods(s *st.State, pods int32) int32 {
+	cordoned := int32(0)
+	for ordinal := int32(0); ordinal < pods; ordinal++ {
+		if a.onCordonedNode(s, ordinal) {
+			cordoned++
+		}
+	}
+	return cordoned
+}
+
+// onCordonedNode returns whether the pod with the given ordinal runs on a cordoned node.
+func (a *autoscaler) onCordonedNode(s *st.State, ordinal int32) bool {
+	if a.nodeLister == nil || s.PodLister == nil {
+		return false
+	}
+	pod, err := s.PodLister.Get(st.PodNameFromOrdinal(a.statefulSetName, ordinal))
+	if err != nil || pod.Spec.NodeName == "" {
+		return false
+	}
+	node, err := a.nodeLister.Get(pod.Spec.NodeName)
+	if err != nil || !node.Spec.Unschedulable {
+		return false
+	}
+	a.logger.Debugw("pod is on a cordoned node",
+		zap.String("pod", pod.Name),
+		zap.String("node", node.Name))
+	return true
+}
+
+// compactionHeadroomFor returns the free capacity the given number of pods surviving a
+// compaction must retain.
+func (a *autoscaler) compactionHeadroomFor(s *st.State, survivingPods int32) int32 {
+	if !(a.compactionHeadroom > 0) || survivingPods <= 0 {
+		return 0
+	}
+	return int32(math.Ceil(a.compactionHeadroom * float64(s.Capacity*survivingPods)))
+}
+
+// compactWithEvents compacts the vreplicas and emits the compaction lifecycle events.
+func (a *autoscaler) compactWithEvents(ctx context.Context, s *st.State, scaleUpFactor int32) {
+	data := compactionEventData{StatefulSet: a.statefulSetName, LastOrdinal: s.LastOrdinal, ScaleUpFactor: scaleUpFactor}
+	a.emitEvent(a.eventTypes.CompactionStarted, data)
+
+	err := a.compact(ctx, s, scaleUpFactor)
+	if errors.Is(err, errCompactionSkipped) {
+		// The vpods can't be listed for now, let the next cycle retry the compaction
+		// without waiting for another refresh period.
+		a.logger.Infow("skipping vreplicas compaction", zap.Error(err))
+		a.lastCompactAttempt = time.Time{}
+		a.cycleOutcome = AutoscaleOutcomeNoop
+		data.Skipped = true
+	} else if err != nil {
+		a.logger.Errorw("vreplicas compaction failed", zap.Error(err))
+		data.Error = err.Error()
+	}
+	if !data.Skipped {
+		if err := a.statsReporter.ReportCompactionExecuted(); err != nil {
+			a.logger.Warnw("failed to report the executed compaction", zap.Error(err))
+		}
+	}
+	a.emitEvent(a.eventTypes.CompactionCompleted, data)
+}
+
+// errCompactionSkipped is returned by compact when the vpods lister fails with a transient
+// error.
+var errCompactionSkipped = errors.New("compaction skipped")
+
+// isTransientListerError returns whether the vpods lister error is expected to go away by
+// itself, e.g. while the informer is syncing or the API server is overloaded.
+func isTransientListerError(err error) bool {
+	return errors.Is(err, scheduler.ErrVPodsNotSynced) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTooManyRequests(err)
+}
+
+func (a *autoscaler) compact(ctx context.Context, s *st.State, scaleUpFactor int32) error {
+	plan, err := a.PlanCompaction(s, scaleUpFactor)
+	if err != nil {
+		if isTransientListerError(err) {
+			return fmt.Errorf("%w: %v", errCompactionSkipped, err)
+		}
+		return err
+	}
+
+	// The free capacity of the schedulable pods left after the compaction.
+	destinationCapacity := s.FreeCapacity()
+	for j := int32(0); j < scaleUpFactor; j++ {
+		if s.IsSchedulablePod(s.LastOrdinal - j) {
+			destinationCapacity -= s.Free(s.LastOrdinal - j)
+		}
+	}
+	a.logger.Infow("compaction plan",
+		zap.Any("evictions", plan),
+		zap.Int32("destinationFreeCapacity", destinationCapacity))
+
+	if a.annotateCompactingPods {
+		pods := compactedPods(plan)
+		a.annotateCompacting(ctx, pods, true)
+		// Cleared whatever the outcome of the evictions.
+		defer a.clearCompacting(pods)
+	}
+
+	evicted, err := a.evictPlacements(ctx, s, plan)
+	a.auditEvictions(ctx, s, AuditReasonCompaction, evicted, err)
+	return err
+}
+
+// auditEvictions records the evicted placements, along with the error which interrupted the
+// evictions if any.
+func (a *autoscaler) auditEvictions(ctx context.Context, s *st.State, reason string, evicted []EvictionPlanItem, err error) {
+	if len(evicted) == 0 && err == nil {
+		return
+	}
+	entry := AuditEntry{
+		Action:    AuditActionEviction,
+		Reason:    reason,
+		Evictions: evicted,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	a.audit(ctx, s, entry)
+}
+
+// PlanCompaction returns the placements on the last scaleUpFactor pods, which a compaction
+// evicts. The PodDisruptionBudgets are only checked when evicting.
+func (a *autoscaler) PlanCompaction(s *st.State, scaleUpFactor int32) ([]EvictionPlanItem, error) {
+	return a.planEvictions(func(ordinal int32) bool {
+		for j := int32(0); j < scaleUpFactor; j++ {
+			if ordinal == s.LastOrdinal-j {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Reasons why the last pods can't be compacted.
+const (
+	compactionReasonInsufficientCapacity = "insufficient free capacity elsewhere"
+	compactionReasonNotEnoughPods        = "not enough pods left for HA scaling"
+	compactionReasonZoneSpread           = "blocked by HA constraint: the zone spread would be reduced"
+)
+
+// ExplainCompaction goes through the same checks as mayCompact for the current state, without
+// compacting nor updating the autoscaler, and explains whether the placements of the given
+// vpod would be evicted. It's meant for debugging why a statefulset isn't scaled down.
+func (a *autoscaler) ExplainCompaction(vpodKey string) (string, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(vpodKey)
+	if err != nil {
+		return "", err
+	}
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	vpods, err := a.vpodLister()
+	if err != nil {
+		return "", err
+	}
+	var vpod scheduler.VPod
+	for _, v := range vpods {
+		if v.GetKey() == key {
+			vpod = v
+			break
+		}
+	}
+	if vpod == nil {
+		return "", fmt.Errorf("vpod %q not found", vpodKey)
+	}
+
+	switch {
+	case !a.isLeader.Load():
+		return "not compacted: autoscaler is not leader", nil
+	case a.paused.Load():
+		return "not compacted: autoscaler is paused", nil
+	case a.draining.Load():
+		return "not compacted: statefulset is being drained", nil
+	case a.inStartupGracePeriod():
+		return "not compacted: in the startup grace period", nil
+	case a.inScaleUpProtectionWindow():
+		return "not compacted: in the protection window after a scale up", nil
+	}
+	if nextAttempt := a.lastCompactAttempt.Add(a.refreshPeriod); a.clock.Now().Before(nextAttempt) {
+		return fmt.Sprintf("not compacted: last compaction attempted less than a refresh period ago, next attempt at %s",
+			nextAttempt.Format(time.RFC3339)), nil
+	}
+
+	s, err := a.stateAccessor.State(a.getReserved())
+	if err != nil {
+		return "", err
+	}
+	scaleUpFactor := a.scaleUpFactorFor(s)
+	if s.LastOrdinal < 1 || len(s.SchedulablePods) <= int(scaleUpFactor) {
+		return fmt.Sprintf("not compacted: not enough pods to compact (%d schedulable, scale up factor %d)",
+			len(s.SchedulablePods), scaleUpFactor), nil
+	}
+
+	var pods int32
+	if s.SchedulerPolicy == scheduler.MAXFILLUP {
+		freeCapacity := a.survivingFreeCapacity(s, 1)
+		usedInLastPod := s.Capacity - s.Free(s.LastOrdinal)
+		if freeCapacity-usedInLastPod < a.compactionHeadroomFor(s, int32(len(s.SchedulablePods))-1) {
+			return "not compacted: " + compactionReasonInsufficientCapacity, nil
+		}
+		pods = scaleUpFactor
+	} else if s.SchedPolicy != nil {
+		var reason string
+		if pods, reason = a.policyCompactionPods(s, scaleUpFactor); pods == 0 {
+			return "not compacted: " + reason, nil
+		}
+	} else {
+		return "not compacted: no scheduling policy", nil
+	}
+
+	var evicted []string
+	for _, p := range vpod.GetPlacements() {
+		ordinal, err := st.ParseOrdinalFromPodName(p.PodName)
+		if err == nil && ordinal > s.LastOrdinal-pods {
+			evicted = append(evicted, fmt.Sprintf("%s (%d vreplicas)", p.PodName, p.VReplicas))
+		}
+	}
+	if len(evicted) == 0 {
+		return fmt.Sprintf("not compacted: not on the last %d pod(s)", pods), nil
+	}
+	return fmt.Sprintf("eligible for compaction: placements on %s would be evicted", strings.Join(evicted, ", ")), nil
+}
+
+// CompactPod evicts all the vreplicas placed on the given pod, provided the other pods have
+// enough free capacity to hold them. The scheduler might place vreplicas on the pod again
+// unless the pod is made unschedulable, for instance by cordoning its node.
+func (a *autoscaler) CompactPod(ctx context.Context, podName string) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !a.isLeader.Load() {
+		return errors.New("autoscaler is not leader")
+	}
+
+	ordinal, err := st.ParseOrdinalFromPodName(podName)
+	if err == nil && st.PodNameFromOrdinal(a.statefulSetName, ordinal) != podName {
+		err = fmt.Errorf("pod %q does not belong to statefulset %q", podName, a.statefulSetName)
+	}
+	if err != nil {
+		return err
+	}
+
+	s, err := a.stateAccessor.State(a.getReserved())
+	if err != nil {
+		return err
+	}
+
+	freeCapacity := s.FreeCapacity() - s.Free(ordinal)
+	usedInPod := s.Capacity - s.Free(ordinal)
+	if freeCapacity < usedInPod {
+		return fmt.Errorf("not enough free capacity to compact pod %q: %d vreplicas placed, %d free in other pods",
+			podName, usedInPod, freeCapacity)
+	}
+
+	plan, err := a.planEvictions(func(o int32) bool {
+		return o == ordinal
+	})
+	if err != nil {
+		return err
+	}
+
+	a.logger.Infow("compacting pod", zap.String("pod", podName), zap.Int32("vreplicas", usedInPod))
+	evicted, err := a.evictPlacements(ctx, s, plan)
+	a.auditEvictions(ctx, s, AuditReasonPodCompaction, evicted, err)
+	return err
+}
+
+// DrainNode evicts all the vreplicas placed on the pods running on the given node, provided
+// the schedulable pods on the other nodes have enough free capacity to hold them. Like
+// CompactPod, the node should be cordoned first so that the vreplicas aren't placed back on
+// its pods.
+func (a *autoscaler) DrainNode(ctx context.Context, nodeName string) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !a.isLeader.Load() {
+		return errors.New("autoscaler is not leader")
+	}
+
+	s, err := a.stateAccessor.State(a.getReserved())
+	if err != nil {
+		return err
+	}
+	if s.PodLister == nil {
+		return errors.New("pod lister not available")
+	}
+
+	pods, err := s.PodLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	onNode := sets.NewInt32()
+	for _, pod := range pods {
+		if pod.Spec.NodeName != nodeName {
+			continue
+		}
+		ordinal, err := st.ParseOrdinalFromPodName(pod.Name)
+		if err != nil || st.PodNameFromOrdinal(a.statefulSetName, ordinal) != pod.Name {
+			continue // not a pod of the statefulset
+		}
+		onNode.Insert(ordinal)
+	}
+
+	var usedOnNode, freeCapacity int32
+	for _, ordinal := range onNode.UnsortedList() {
+		usedOnNode += s.Capacity - s.Free(ordinal)
+	}
+	for _, ordinal := range s.SchedulablePods {
+		if !onNode.Has(ordinal) {
+			freeCapacity += s.Free(ordinal)
+		}
+	}
+	if freeCapacity < usedOnNode {
+		return fmt.Errorf("not enough free capacity to drain node %q: %d vreplicas placed, %d free on the other nodes",
+			nodeName, usedOnNode, freeCapacity)
+	}
+
+	plan, err := a.planEvictions(onNode.Has)
+	if err != nil {
+		return err
+	}
+
+	a.logger.Infow("draining node",
+		zap.String("node", nodeName),
+		zap.Int("pods", onNode.Len()),
+		zap.Int32("vreplicas", usedOnNode))
+	evicted, err := a.evictPlacements(ctx, s, plan)
+	a.auditEvictions(ctx, s, AuditReasonNodeDrain, evicted, err)
+	return err
+}
+
+// planEvictions returns the placements on the pods whose ordinal matches, starting from the
+// last placement of each vpod.
+func (a *autoscaler) planEvictions(matches func(ordinal int32) bool) ([]EvictionPlanItem, error) {
+	vpods, err := a.vpodLister()
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []EvictionPlanItem
+	for _, vpod := range vpods {
+		placements := vpod.GetPlacements()
+		for i := len(placements) - 1; i >= 0; i-- { //start from the last placement
+			ordinal, err := st.ParseOrdinalFromPodName(placements[i].PodName)
+			if err == nil && st.PodNameFromOrdinal(a.statefulSetName, ordinal) != placements[i].PodName {
+				err = fmt.Errorf("pod %q does not belong to statefulset %q", placements[i].PodName, a.statefulSetName)
+			}
+			if err != nil {
+				a.logger.Warnw("skipping placement with unexpected pod name",
+					zap.Any("vpod", vpod.GetKey()),
+					zap.Error(err))
+				continue
+			}
+
+			if !matches(ordinal) {
+				continue
+			}
+
+			plan = append(plan, EvictionPlanItem{
+				VPod:      vpod.GetKey(),
+				Placement: placements[i],
+				vpod:      vpod,
+				placement: &placements[i],
+			})
+		}
+	}
+	return plan, nil
+}
+
+// ErrDrainIncomplete is returned by Drain when vreplicas can't be moved off the last pods.
+var ErrDrainIncomplete = errors.New("statefulset not fully drained")
+
+// ErrDrainPaused is returned by Drain when the autoscaler is paused, before or during the drain.
+var ErrDrainPaused = errors.New("autoscaler is paused")
+
+// Drain empties the last pod, waits for its vreplicas to be rescheduled on pods with a lower
+// ordinal and scales the statefulset down, one pod per refresh period like the compaction,
+// until the statefulset is scaled to zero. It returns ErrDrainIncomplete when the vreplicas
+// placed on the last pod don't fit on the other pods. Like the automatic scaling, the drain
+// doesn't evict nor scale while the autoscaler is paused, it returns ErrDrainPaused.
+func (a *autoscaler) Drain(ctx context.Context) error {
+	if !a.isLeader.Load() {
+		return errors.New("autoscaler is not leader")
+	}
+	if !a.draining.CompareAndSwap(false, true) {
+		return errors.New("statefulset is already being drained")
+	}
+	defer a.draining.Store(false)
+
+	for {
+		if a.paused.Load() {
+			a.logger.Info("autoscaler is paused, stopping the drain")
+			return ErrDrainPaused
+		}
+		done, err := a.drainStep(ctx)
+		if done || err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.clock.After(a.getRefreshPeriod()):
+		}
+	}
+}
+
+// drainStep scales the statefulset down to the pods holding vreplicas and evicts the
+// vreplicas of the last pod. It returns true once the statefulset is scaled to zero.
+func (a *autoscaler) drainStep(ctx context.Context) (bool, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	s, err := a.stateAccessor.State(a.getReserved())
+	if err != nil {
+		return false, err
+	}
+
+	// The last pod holding vreplicas, -1 when none is.
+	ordinal := s.LastOrdinal
+	for ordinal >= 0 && s.Free(ordinal) >= s.Capacity {
+		ordinal--
+	}
+
+	if replicas := ordinal + 1; replicas < s.Replicas {
+		if err := a.drainScale(ctx, s, replicas); err != nil {
+			return false, err
+		}
+	}
+	if ordinal < 0 {
+		a.logger.Info("statefulset drained")
+		return true, nil
+	}
+
+	used := s.Capacity - s.Free(ordinal)
+	var free int32
+	for _, o := range s.SchedulablePods {
+		if o < ordinal {
+			free += s.Free(o)
+		}
+	}
+	a.logger.Infow("draining statefulset",
+		zap.Int32("replicas", ordinal+1),
+		zap.Int32("lastPodVReplicas", used),
+		zap.Int32("freeCapacity", free))
+	if free < used {
+		return false, fmt.Errorf("%w: %d vreplicas placed on pod %d, %d free in the pods with a lower ordinal",
+			ErrDrainIncomplete, used, ordinal, free)
+	}
+
+	plan, err := a.planEvictions(func(o int32) bool {
+		return o == ordinal
+	})
+	if err != nil {
+		return false, err
+	}
+	a.lastCompactAttempt = a.clock.Now()
+	evicted, err := a.evictPlacements(ctx, s, plan)
+	a.auditEvictions(ctx, s, AuditReasonDrain, evicted, err)
+	return false, err
+}
+
+// drainScale scales the statefulset down to the given replicas.
+func (a *autoscaler) drainScale(ctx context.Context, s *st.State, replicas int32) error {
+	var oldreplicas int32
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		scale, err := a.statefulSets().GetScale(ctx, a.statefulSetName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		oldreplicas = scale.Spec.Replicas
+		if oldreplicas <= replicas {
+			return nil
+		}
+		scale.Spec.Replicas = replicas
+		_, err = a.statefulSets().UpdateScale(ctx, a.statefulSetName, scale, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		a.logger.Errorw("updating scale subresource failed", zap.Error(err))
+		return err
+	}
+	if oldreplicas > replicas {
+		a.logger.Infow("scaled down drained statefulset", zap.Int32("replicas", replicas))
+		a.audit(ctx, s, AuditEntry{
+			Action:      AuditActionScale,
+			Reason:      AuditReasonDrain,
+			OldReplicas: oldreplicas,
+			NewReplicas: replicas,
+		})
+	}
+	return nil
+}
+
+// evictPlacements evicts the planned placements and returns the evicted ones, which exclude
+// the evictions deferred to honor the PodDisruptionBudgets.
+func (a *autoscaler) evictPlacements(ctx context.Context, s *st.State, plan []EvictionPlanItem) ([]EvictionPlanItem, error) {
+	var budgets *disruptionBudgets
+	if a.pdbAware {
+		budgets = newDisruptionBudgets(a.kubeClient.PolicyV1().PodDisruptionBudgets(a.statefulSetNamespace))
+	}
+
+	var preferredPods []string
+	if a.targetedEvictor != nil {
+		preferredPods = a.preferredPods(s, plan)
+	}
+
+	var evicted []EvictionPlanItem
+	for _, item := range plan {
+		var pod *v1.Pod
+		var err error
+		wait.PollImmediate(50*time.Millisecond, 5*time.Second, func() (bool, error) {
+			if s.PodLister != nil {
+				pod, err = s.PodLister.Get(item.Placement.PodName)
+			}
+			return err == nil, nil
+		})
+
+		if budgets != nil {
+			allowed, err := budgets.allowDisruption(ctx, pod)
+			if err != nil {
+				return evicted, err
+			}
+			if !allowed {
+				// Retry on the next compaction.
+				a.logger.Infow("deferring eviction to honor the pod disruption budget",
+					zap.String("pod", item.Placement.PodName),
+					zap.Any("vpod", item.VPod))
+				continue
+			}
+		}
+
+		if a.targetedEvictor != nil {
+			err = a.targetedEvictor(pod, item.vpod, item.placement, preferredPods)
+		} else {
+			err = a.evictor(pod, item.vpod, item.placement)
+		}
+		if err != nil {
+			return evicted, err
+		}
+		evicted = append(evicted, item)
+	}
+	return evicted, nil
+}
+
+// preferredPods returns the pods the vreplicas evicted by the plan should preferably be
+// placed on, excluding the pods being evicted.
+func (a *autoscaler) preferredPods(s *st.State, plan []EvictionPlanItem) []string {
+	evicted := sets.NewString()
+	for _, item := range plan {
+		evicted.Insert(item.Placement.PodName)
+	}
+
+	var candidates []string
+	if a.evictionTargets != nil {
+		candidates = a.evictionTargets(s)
+	} else {
+		for ordinal := int32(0); ordinal <= s.LastOrdinal; ordinal++ {
+			if s.IsSchedulablePod(ordinal) && s.Free(ordinal) > 0 {
+				candidates = append(candidates, st.PodNameFromOrdinal(a.statefulSetName, ordinal))
+			}
+		}
+	}
+
+	preferred := make([]string, 0, len(candidates))
+	for _, podName := range candidates {
+		if !evicted.Has(podName) {
+			preferred = append(preferred, podName)
+		}
+	}
+	return preferred
 }
 
 func contains(preds []scheduler.PredicatePolicy, priors []scheduler.PriorityPolicy, name string) bool {
@@ -349,10 +2073,14 @@ func contains(preds []scheduler.PredicatePolicy, priors []scheduler.PriorityPoli
 	return false
 }
 
-func (a *autoscaler) minNonZeroInt(slice []int32) int32 {
+// minNonZeroFreeCap returns the least non-zero free capacity of the schedulable pods, or the
+// pod capacity when none of them is partially filled.
+// FreeCap is indexed by ordinal and may still hold the entries of pods that were removed or
+// are no longer schedulable, only the ordinals in SchedulablePods are considered live.
+func (a *autoscaler) minNonZeroFreeCap(state *st.State) int32 {
 	min := a.capacity
-	for _, v := range slice {
-		if v < min && v > 0 {
+	for _, ordinal := range state.SchedulablePods {
+		if v := state.Free(ordinal); v < min && v > 0 {
 			min = v
 		}
 	}
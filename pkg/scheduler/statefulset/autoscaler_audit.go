@@ -0,0 +1,121 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	st "knative.dev/eventing/pkg/scheduler/state"
+)
+
+// AuditAction is the kind of action recorded in an AuditEntry.
+type AuditAction string
+
+const (
+	// AuditActionScale records an update of the statefulset replicas.
+	AuditActionScale AuditAction = "Scale"
+	// AuditActionEviction records the eviction of vreplicas by a compaction.
+	AuditActionEviction AuditAction = "Eviction"
+)
+
+const (
+	// AuditReasonDemand is the reason of the scale changes following the vreplicas demand.
+	AuditReasonDemand = "Demand"
+	// AuditReasonQueueDepth is the reason of the scale ups following the queue depth.
+	AuditReasonQueueDepth = "QueueDepth"
+	// AuditReasonCompaction is the reason of the evictions of the periodic compaction.
+	AuditReasonCompaction = "Compaction"
+	// AuditReasonPodCompaction is the reason of the evictions requested with CompactPod.
+	AuditReasonPodCompaction = "PodCompaction"
+	// AuditReasonDrain is the reason of the evictions and scale downs of Drain.
+	AuditReasonDrain = "Drain"
+	// AuditReasonNodeDrain is the reason of the evictions requested with DrainNode.
+	AuditReasonNodeDrain = "NodeDrain"
+)
+
+// AuditSink durably records the autoscaler decisions, e.g. for compliance. Unlike the logs
+// and the lifecycle events, the entries are meant for long-term storage.
+type AuditSink interface {
+	// Record records the entry. It's called synchronously by the autoscaler and must not
+	// block.
+	Record(ctx context.Context, entry AuditEntry)
+}
+
+// AuditEntry is a scaling or eviction decision of the autoscaler.
+type AuditEntry struct {
+	Time        time.Time            `json:"time"`
+	StatefulSet types.NamespacedName `json:"statefulSet"`
+	Action      AuditAction          `json:"action"`
+	Reason      string               `json:"reason"`
+	// Leader is the identity of the autoscaler instance which was leader.
+	Leader string `json:"leader"`
+
+	// OldReplicas and NewReplicas are the replicas before and after a scale action.
+	OldReplicas int32 `json:"oldReplicas,omitempty"`
+	NewReplicas int32 `json:"newReplicas,omitempty"`
+
+	// Evictions are the placements evicted by an eviction action.
+	Evictions []EvictionPlanItem `json:"evictions,omitempty"`
+	// Error is the failure of an eviction action, empty when it succeeded.
+	Error string `json:"error,omitempty"`
+
+	State AuditStateSummary `json:"state"`
+}
+
+// AuditStateSummary is the scheduler state the decision was taken on.
+type AuditStateSummary struct {
+	SchedulablePods   []int32 `json:"schedulablePods"`
+	LastOrdinal       int32   `json:"lastOrdinal"`
+	Replicas          int32   `json:"replicas"`
+	Capacity          int32   `json:"capacity"`
+	FreeCapacity      int32   `json:"freeCapacity"`
+	Pending           int32   `json:"pending"`
+	ExpectedVReplicas int32   `json:"expectedVReplicas"`
+}
+
+type noopAuditSink struct{}
+
+func (noopAuditSink) Record(context.Context, AuditEntry) {}
+
+// defaultAuditIdentity is the hostname, which is the pod name when running in Kubernetes.
+func defaultAuditIdentity() string {
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// audit records the entry, completed with the common fields, to the audit sink.
+func (a *autoscaler) audit(ctx context.Context, s *st.State, entry AuditEntry) {
+	entry.Time = a.clock.Now()
+	entry.StatefulSet = types.NamespacedName{Namespace: a.statefulSetNamespace, Name: a.statefulSetName}
+	entry.Leader = a.auditIdentity
+	if s != nil {
+		entry.State = AuditStateSummary{
+			SchedulablePods:   s.SchedulablePods,
+			LastOrdinal:       s.LastOrdinal,
+			Replicas:          s.Replicas,
+			Capacity:          s.Capacity,
+			FreeCapacity:      s.FreeCapacity(),
+			Pending:           s.TotalPending(),
+			ExpectedVReplicas: s.TotalExpectedVReplicas(),
+		}
+	}
+	a.auditSink.Record(ctx, entry)
+}
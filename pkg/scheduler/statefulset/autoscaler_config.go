@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"time"
+
+	"knative.dev/eventing/pkg/scheduler"
+)
+
+// AutoscalerConfigSnapshot is the effective configuration of a running autoscaler, including
+// the changes applied by Reload, for instance to publish it on a status or a debug endpoint.
+// It only holds serializable settings: the clients, listers and callbacks are reported as
+// whether they are set.
+type AutoscalerConfigSnapshot struct {
+	StatefulSetNamespace string `json:"statefulSetNamespace"`
+	StatefulSetName      string `json:"statefulSetName"`
+
+	PodCapacity   int32         `json:"podCapacity"`
+	RefreshPeriod time.Duration `json:"refreshPeriod"`
+	MaxReplicas   int32         `json:"maxReplicas"`
+
+	// SchedulerPolicy is the scheduling policy of the last observed state, empty until the
+	// autoscaler ran once.
+	SchedulerPolicy scheduler.SchedulerPolicyType `json:"schedulerPolicy,omitempty"`
+	// ScaleUpFactor is the number of pods the statefulset is scaled by at once, derived from
+	// the scheduling policies and the topology of the last observed state, 0 until the
+	// autoscaler ran once.
+	ScaleUpFactor int32  `json:"scaleUpFactor"`
+	TopologyKey   string `json:"topologyKey,omitempty"`
+
+	MaxScaleUpStep           int32         `json:"maxScaleUpStep"`
+	MaxScaleDownStep         int32         `json:"maxScaleDownStep"`
+	StaleStateThreshold      int32         `json:"staleStateThreshold"`
+	StartupGracePeriod       time.Duration `json:"startupGracePeriod"`
+	ScaleUpProtectionWindow  time.Duration `json:"scaleUpProtectionWindow"`
+	MinScaleInterval         time.Duration `json:"minScaleInterval"`
+	DemandSmoothingFactor    float64       `json:"demandSmoothingFactor"`
+	CompactionHeadroom       float64       `json:"compactionHeadroom"`
+	CompactionBatchSize      int32         `json:"compactionBatchSize"`
+	QueueDepthThreshold      int64         `json:"queueDepthThreshold"`
+	ScaleVerificationTimeout time.Duration `json:"scaleVerificationTimeout"`
+	ReadinessGapTimeout      time.Duration `json:"readinessGapTimeout"`
+
+	PDBAware               bool `json:"pdbAware"`
+	AnnotateCompactingPods bool `json:"annotateCompactingPods"`
+	NodeAware              bool `json:"nodeAware"`
+	YieldToExternalScalers bool `json:"yieldToExternalScalers"`
+	ScaleDownDisabled      bool `json:"scaleDownDisabled"`
+
+	Forecaster       bool `json:"forecaster"`
+	QueueDepthSource bool `json:"queueDepthSource"`
+	VReplicaCost     bool `json:"vreplicaCost"`
+	AuditSink        bool `json:"auditSink"`
+	StateStore       bool `json:"stateStore"`
+
+	EventsSink string               `json:"eventsSink,omitempty"`
+	EventTypes AutoscalerEventTypes `json:"eventTypes"`
+}
+
+// ConfigSnapshot returns the effective configuration of the autoscaler.
+func (a *autoscaler) ConfigSnapshot() AutoscalerConfigSnapshot {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	snapshot := AutoscalerConfigSnapshot{
+		StatefulSetNamespace:     a.statefulSetNamespace,
+		StatefulSetName:          a.statefulSetName,
+		PodCapacity:              a.capacity,
+		RefreshPeriod:            a.refreshPeriod,
+		MaxReplicas:              a.maxReplicas,
+		TopologyKey:              a.topologyKey,
+		MaxScaleUpStep:           a.maxScaleUpStep,
+		MaxScaleDownStep:         a.maxScaleDownStep,
+		StaleStateThreshold:      a.staleStateThreshold,
+		StartupGracePeriod:       a.startupGracePeriod,
+		ScaleUpProtectionWindow:  a.scaleUpProtectionWindow,
+		MinScaleInterval:         a.minScaleInterval,
+		DemandSmoothingFactor:    a.demandSmoothingFactor,
+		CompactionHeadroom:       a.compactionHeadroom,
+		CompactionBatchSize:      a.compactionBatchSize,
+		QueueDepthThreshold:      a.queueDepthThreshold,
+		ScaleVerificationTimeout: a.scaleVerificationTimeout,
+		ReadinessGapTimeout:      a.readinessGapTimeout,
+		PDBAware:                 a.pdbAware,
+		AnnotateCompactingPods:   a.annotateCompactingPods,
+		NodeAware:                a.nodeAware,
+		YieldToExternalScalers:   a.yieldToExternalScalers,
+		ScaleDownDisabled:        a.scaleDownDisabled,
+		Forecaster:               a.forecaster != nil,
+		QueueDepthSource:         a.queueDepthSource != nil,
+		VReplicaCost:             a.vreplicaCost != nil,
+		AuditSink:                !isNoopAuditSink(a.auditSink),
+		StateStore:               a.stateStore != nil,
+		EventsSink:               a.eventsSink,
+		EventTypes:               a.eventTypes,
+	}
+	if a.lastState != nil {
+		snapshot.SchedulerPolicy = a.lastState.SchedulerPolicy
+		snapshot.ScaleUpFactor = a.scaleUpFactorFor(a.lastState)
+	}
+	return snapshot
+}
+
+// isNoopAuditSink returns whether the audit sink is the default one, discarding the entries.
+func isNoopAuditSink(sink AuditSink) bool {
+	_, noop := sink.(noopAuditSink)
+	return noop
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"context"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// Default types of the CloudEvents emitted by the autoscaler.
+	ScaledUpEventType            = "dev.knative.scheduler.autoscaler.scaledup"
+	ScaledDownEventType          = "dev.knative.scheduler.autoscaler.scaleddown"
+	CompactionStartedEventType   = "dev.knative.scheduler.autoscaler.compaction.started"
+	CompactionCompletedEventType = "dev.knative.scheduler.autoscaler.compaction.completed"
+	ScaleNotReachedEventType     = "dev.knative.scheduler.autoscaler.scale.notreached"
+
+	// eventSendTimeout bounds the time spent sending a single lifecycle event.
+	eventSendTimeout = 10 * time.Second
+)
+
+// AutoscalerEventTypes configures the types of the CloudEvents emitted by the autoscaler.
+// Empty values fall back to the default event types.
+type AutoscalerEventTypes struct {
+	ScaledUp            string `json:"scaledUp"`
+	ScaledDown          string `json:"scaledDown"`
+	CompactionStarted   string `json:"compactionStarted"`
+	CompactionCompleted string `json:"compactionCompleted"`
+	ScaleNotReached     string `json:"scaleNotReached"`
+}
+
+func (t AutoscalerEventTypes) withDefaults() AutoscalerEventTypes {
+	if t.ScaledUp == "" {
+		t.ScaledUp = ScaledUpEventType
+	}
+	if t.ScaledDown == "" {
+		t.ScaledDown = ScaledDownEventType
+	}
+	if t.CompactionStarted == "" {
+		t.CompactionStarted = CompactionStartedEventType
+	}
+	if t.CompactionCompleted == "" {
+		t.CompactionCompleted = CompactionCompletedEventType
+	}
+	if t.ScaleNotReached == "" {
+		t.ScaleNotReached = ScaleNotReachedEventType
+	}
+	return t
+}
+
+// scaleEventData is the payload of the scaled up and scaled down events.
+type scaleEventData struct {
+	StatefulSet string `json:"statefulSet"`
+	From        int32  `json:"from"`
+	To          int32  `json:"to"`
+}
+
+// scaleNotReachedEventData is the payload of the scale not reached event.
+type scaleNotReachedEventData struct {
+	StatefulSet   string `json:"statefulSet"`
+	Target        int32  `json:"target"`
+	ReadyReplicas int32  `json:"readyReplicas"`
+}
+
+// compactionEventData is the payload of the compaction events.
+type compactionEventData struct {
+	StatefulSet   string `json:"statefulSet"`
+	LastOrdinal   int32  `json:"lastOrdinal"`
+	ScaleUpFactor int32  `json:"scaleUpFactor"`
+	Skipped       bool   `json:"skipped,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// emitEvent sends a lifecycle event to the configured sink, if any.
+// Sending is best-effort and asynchronous so that it never blocks the scaling path.
+func (a *autoscaler) emitEvent(eventType string, data interface{}) {
+	if a.eventsClient == nil || a.eventsSink == "" {
+		return
+	}
+
+	event := cloudevents.NewEvent()
+	event.SetID(uuid.New().String())
+	event.SetType(eventType)
+	event.SetSource(a.eventsSource)
+	event.SetSubject(a.statefulSetName)
+	event.SetTime(a.clock.Now())
+	if err := event.SetData(cloudevents.ApplicationJSON, data); err != nil {
+		a.logger.Warnw("failed to set autoscaler event data", zap.String("type", eventType), zap.Error(err))
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(cloudevents.ContextWithTarget(context.Background(), a.eventsSink), eventSendTimeout)
+		defer cancel()
+
+		if result := a.eventsClient.Send(ctx, event); !cloudevents.IsACK(result) {
+			a.logger.Warnw("failed to send autoscaler event",
+				zap.String("type", eventType),
+				zap.String("sink", a.eventsSink),
+				zap.Error(result))
+		}
+	}()
+}
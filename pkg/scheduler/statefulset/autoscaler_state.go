@@ -0,0 +1,185 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// autoscalerStateKey is the key of the autoscaler state in the data of the ConfigMap store.
+const autoscalerStateKey = "state"
+
+// AutoscalerState is the runtime decision state of the autoscaler, exported so that a newly
+// promoted leader resumes with the context of the previous one instead of starting cold,
+// e.g. without immediately attempting a compaction.
+type AutoscalerState struct {
+	// LastScaleTime is when the replicas were last changed.
+	LastScaleTime time.Time `json:"lastScaleTime,omitempty"`
+	// LastScaleUpTime is when the statefulset was last scaled up.
+	LastScaleUpTime time.Time `json:"lastScaleUpTime,omitempty"`
+	// LastCompactAttempt is when the vreplicas were last compacted.
+	LastCompactAttempt time.Time `json:"lastCompactAttempt,omitempty"`
+	// SmoothedDemand is the moving average of the total expected vreplicas, only valid when
+	// DemandObserved is set.
+	SmoothedDemand float64 `json:"smoothedDemand,omitempty"`
+	DemandObserved bool    `json:"demandObserved,omitempty"`
+	// ReadinessGapSince is when the current readiness gap was first observed.
+	ReadinessGapSince time.Time `json:"readinessGapSince,omitempty"`
+}
+
+// AutoscalerStateStore persists the autoscaler state across leader changes.
+type AutoscalerStateStore interface {
+	// Load returns the stored state, nil when none was saved yet.
+	Load(ctx context.Context) ([]byte, error)
+	// Save stores the state.
+	Save(ctx context.Context, data []byte) error
+}
+
+// NewConfigMapStateStore returns an AutoscalerStateStore keeping the state in the given
+// ConfigMap, which is created on the first save.
+func NewConfigMapStateStore(kubeClient kubernetes.Interface, namespace, name string) AutoscalerStateStore {
+	return &configMapStateStore{kubeClient: kubeClient, namespace: namespace, name: name}
+}
+
+type configMapStateStore struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	name       string
+}
+
+func (s *configMapStateStore) Load(ctx context.Context) ([]byte, error) {
+	cm, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data[autoscalerStateKey]
+	if !ok {
+		return nil, nil
+	}
+	return []byte(data), nil
+}
+
+func (s *configMapStateStore) Save(ctx context.Context, data []byte) error {
+	configMaps := s.kubeClient.CoreV1().ConfigMaps(s.namespace)
+	cm, err := configMaps.Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: s.namespace, Name: s.name},
+			Data:       map[string]string{autoscalerStateKey: string(data)},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string, 1)
+	}
+	cm.Data[autoscalerStateKey] = string(data)
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// ExportState serializes the runtime decision state of the autoscaler to JSON.
+func (a *autoscaler) ExportState() ([]byte, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.exportState()
+}
+
+// ImportState restores the runtime decision state serialized by ExportState.
+func (a *autoscaler) ImportState(data []byte) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.importState(data)
+}
+
+func (a *autoscaler) exportState() ([]byte, error) {
+	state := AutoscalerState{
+		LastScaleTime:      a.lastScaleTime,
+		LastScaleUpTime:    a.lastScaleUpTime,
+		LastCompactAttempt: a.lastCompactAttempt,
+		SmoothedDemand:     a.smoothedDemand,
+		DemandObserved:     a.demandObserved,
+		ReadinessGapSince:  a.readinessGapSince,
+	}
+	return json.Marshal(state)
+}
+
+func (a *autoscaler) importState(data []byte) error {
+	var state AutoscalerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid autoscaler state: %w", err)
+	}
+	a.lastScaleTime = state.LastScaleTime
+	a.lastScaleUpTime = state.LastScaleUpTime
+	a.lastCompactAttempt = state.LastCompactAttempt
+	a.smoothedDemand = state.SmoothedDemand
+	a.demandObserved = state.DemandObserved
+	a.readinessGapSince = state.ReadinessGapSince
+	return nil
+}
+
+// loadState imports the state saved by the previous leader, once after each promotion.
+func (a *autoscaler) loadState(ctx context.Context) {
+	if a.stateStore == nil || !a.stateLoadPending.Swap(false) {
+		return
+	}
+	data, err := a.stateStore.Load(ctx)
+	if err == nil && data != nil {
+		err = a.importState(data)
+	}
+	if err != nil {
+		a.logger.Warnw("failed to load the autoscaler state, starting cold", zap.Error(err))
+		return
+	}
+	a.savedState = data
+	if data != nil {
+		a.logger.Infow("autoscaler state loaded", zap.ByteString("state", data))
+	}
+}
+
+// saveState saves the state when it changed since it was last loaded or saved.
+func (a *autoscaler) saveState(ctx context.Context) {
+	if a.stateStore == nil || !a.isLeader.Load() {
+		return
+	}
+	data, err := a.exportState()
+	if err == nil && string(data) == string(a.savedState) {
+		return
+	}
+	if err == nil {
+		err = a.stateStore.Save(ctx, data)
+	}
+	if err != nil {
+		a.logger.Warnw("failed to save the autoscaler state", zap.Error(err))
+		return
+	}
+	a.savedState = data
+}
@@ -17,6 +17,9 @@ limitations under the License.
 package statefulset
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"reflect"
@@ -24,17 +27,28 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	v1 "k8s.io/client-go/listers/core/v1"
 	gtesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+	"knative.dev/pkg/logging"
 	"knative.dev/pkg/reconciler"
 
 	kubeclient "knative.dev/pkg/client/injection/kube/client/fake"
 	_ "knative.dev/pkg/client/injection/kube/informers/apps/v1/statefulset/fake"
 
+	adaptertest "knative.dev/eventing/pkg/adapter/v2/test"
 	listers "knative.dev/eventing/pkg/reconciler/testing/v1"
 
 	duckv1alpha1 "knative.dev/eventing/pkg/apis/duck/v1alpha1"
@@ -59,6 +73,13 @@ func TestAutoscaler(t *testing.T) {
 		schedulerPolicy     *scheduler.SchedulerPolicy
 		deschedulerPolicy   *scheduler.SchedulerPolicy
 		reserved            map[types.NamespacedName]map[string]int32
+		forecaster          DemandForecaster
+		queueDepth          QueueDepthSource
+		queueDepthThreshold int64
+		vreplicaCost        func(vpod scheduler.VPod) float64
+		maxScaleUpStep      int32
+		maxScaleDownStep    int32
+		yield               bool
 	}{
 		{
 			name:     "no replicas, no placements, no pending",
@@ -139,6 +160,28 @@ func TestAutoscaler(t *testing.T) {
 			wantReplicas:        int32(1),
 			schedulerPolicyType: scheduler.MAXFILLUP,
 		},
+		{
+			name:     "with replicas, no placements, with pending, scale down yielded to external scalers",
+			replicas: int32(3),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 5, nil),
+			},
+			scaleDown:           true,
+			yield:               true,
+			wantReplicas:        int32(3),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+		},
+		{
+			name:     "with replicas, no placements, with pending, scale up when yielding to external scalers",
+			replicas: int32(1),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 25, nil),
+			},
+			scaleDown:           true,
+			yield:               true,
+			wantReplicas:        int32(3),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+		},
 		{
 			name:     "with replicas, no placements, with pending, scale down disabled",
 			replicas: int32(3),
@@ -309,6 +352,257 @@ func TestAutoscaler(t *testing.T) {
 			wantReplicas:        int32(5),
 			schedulerPolicyType: scheduler.MAXFILLUP,
 		},
+		{
+			name:     "with replicas, with placements, no pending, forecast exceeds demand",
+			replicas: int32(2),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 15, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(8)},
+					{PodName: "statefulset-name-1", VReplicas: int32(7)}}),
+			},
+			wantReplicas:        int32(4),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			forecaster:          staticForecaster(35),
+		},
+		{
+			name:     "with replicas, with placements, no pending, forecast under-shoots demand, scale down",
+			replicas: int32(5),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 15, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(8)},
+					{PodName: "statefulset-name-1", VReplicas: int32(7)}}),
+			},
+			scaleDown:           true,
+			wantReplicas:        int32(2),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			forecaster:          staticForecaster(5),
+		},
+		{
+			name:     "with replicas, no placements, with pending, high backlog",
+			replicas: int32(1),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 5, nil),
+			},
+			wantReplicas:        int32(3),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			queueDepth:          staticQueueDepth{"statefulset-name-0": 250},
+			queueDepthThreshold: 100,
+		},
+		{
+			name:     "with replicas, no placements, with pending, low backlog",
+			replicas: int32(1),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 5, nil),
+			},
+			wantReplicas:        int32(1),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			queueDepth:          staticQueueDepth{"statefulset-name-0": 80},
+			queueDepthThreshold: 100,
+		},
+		{
+			name:     "with replicas, no placements, with pending, high backlog, no threshold",
+			replicas: int32(1),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 5, nil),
+			},
+			wantReplicas:        int32(1),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			queueDepth:          staticQueueDepth{"statefulset-name-0": 250},
+		},
+		{
+			name:     "with replicas, no placements, with pending, queue depth fails",
+			replicas: int32(1),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 5, nil),
+			},
+			wantReplicas:        int32(1),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			queueDepth:          failingQueueDepth{},
+			queueDepthThreshold: 100,
+		},
+		{
+			name:     "with replicas, no placements, with pending, high backlog, scale down",
+			replicas: int32(4),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 5, nil),
+			},
+			scaleDown:           true,
+			wantReplicas:        int32(2),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			queueDepth:          staticQueueDepth{"statefulset-name-0": 120, "statefulset-name-1": 60, "statefulset-name-2": 0},
+			queueDepthThreshold: 100,
+		},
+		{
+			name:     "with replicas, no placements, with pending, forecast fails",
+			replicas: int32(3),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 45, nil),
+			},
+			wantReplicas:        int32(5),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			forecaster:          failingForecaster{},
+		},
+		{
+			name:     "with replicas, no placements, with pending, light and heavy vpods",
+			replicas: int32(1),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-light", 10, nil),
+				tscheduler.NewVPod(testNs, "vpod-heavy", 10, nil),
+			},
+			wantReplicas:        int32(3),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			vreplicaCost:        costByName(map[string]float64{"vpod-light": 0.5, "vpod-heavy": 2}),
+		},
+		{
+			name:     "with replicas, with placements, no pending, light vpods, scale down",
+			replicas: int32(3),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 15, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(8)},
+					{PodName: "statefulset-name-1", VReplicas: int32(7)}}),
+			},
+			scaleDown:           true,
+			wantReplicas:        int32(1),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			vreplicaCost:        costByName(map[string]float64{"vpod-1": 0.5}),
+		},
+		{
+			name:     "with replicas, no placements, with pending, invalid cost defaults to 1",
+			replicas: int32(1),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 15, nil),
+			},
+			wantReplicas:        int32(2),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			vreplicaCost:        costByName(map[string]float64{"vpod-1": -1}),
+		},
+		{
+			name:     "with replicas, no placements, with pending, scale up step limited",
+			replicas: int32(3),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 500, nil),
+			},
+			wantReplicas:        int32(8),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			maxScaleUpStep:      5,
+		},
+		{
+			name:     "with replicas, no placements, with pending, scale up within step",
+			replicas: int32(1),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 15, nil),
+			},
+			wantReplicas:        int32(2),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			maxScaleUpStep:      5,
+		},
+		{
+			name:     "with replicas, with placements, no pending, scale down not limited by step",
+			replicas: int32(5),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 15, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(8)},
+					{PodName: "statefulset-name-1", VReplicas: int32(7)}}),
+			},
+			scaleDown:           true,
+			wantReplicas:        int32(2),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			maxScaleUpStep:      1,
+		},
+		{
+			name:     "with replicas, with placements, no pending, scale down step limited",
+			replicas: int32(5),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 15, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(8)},
+					{PodName: "statefulset-name-1", VReplicas: int32(7)}}),
+			},
+			scaleDown:           true,
+			wantReplicas:        int32(3),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			maxScaleDownStep:    2,
+		},
+		{
+			name:     "with replicas, with placements, no pending, scale down within step",
+			replicas: int32(5),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 15, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(8)},
+					{PodName: "statefulset-name-1", VReplicas: int32(7)}}),
+			},
+			scaleDown:           true,
+			wantReplicas:        int32(2),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			maxScaleDownStep:    3,
+		},
+		{
+			name:     "with replicas, with placements, no pending, no scale down, scale down step limited",
+			replicas: int32(5),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 15, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(8)},
+					{PodName: "statefulset-name-1", VReplicas: int32(7)}}),
+			},
+			wantReplicas:        int32(5),
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			maxScaleDownStep:    2,
+		},
+		{
+			name:     "with replicas, no placements, with pending, scale up step limited, with Zone Priorities",
+			replicas: int32(3),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 200, nil),
+			},
+			wantReplicas: int32(6),
+			schedulerPolicy: &scheduler.SchedulerPolicy{
+				Predicates: []scheduler.PredicatePolicy{
+					{Name: "PodFitsResources"},
+				},
+				Priorities: []scheduler.PriorityPolicy{
+					{Name: "AvailabilityZonePriority", Weight: 10, Args: "{\"MaxSkew\": 1}"},
+					{Name: "LowestOrdinalPriority", Weight: 5},
+				},
+			},
+			maxScaleUpStep: 5,
+		},
+		{
+			name:     "with replicas, no placements, with pending, scale up step lower than factor, with Zone Priorities",
+			replicas: int32(3),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 200, nil),
+			},
+			wantReplicas: int32(6),
+			schedulerPolicy: &scheduler.SchedulerPolicy{
+				Predicates: []scheduler.PredicatePolicy{
+					{Name: "PodFitsResources"},
+				},
+				Priorities: []scheduler.PriorityPolicy{
+					{Name: "AvailabilityZonePriority", Weight: 10, Args: "{\"MaxSkew\": 1}"},
+					{Name: "LowestOrdinalPriority", Weight: 5},
+				},
+			},
+			maxScaleUpStep: 1,
+		},
+		{
+			name:     "with replicas, with placements, no pending, forecast exceeds demand, with Pod Predicates and Priorities",
+			replicas: int32(2),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 15, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(8)},
+					{PodName: "statefulset-name-1", VReplicas: int32(7)}}),
+			},
+			wantReplicas: int32(4),
+			schedulerPolicy: &scheduler.SchedulerPolicy{
+				Predicates: []scheduler.PredicatePolicy{
+					{Name: "PodFitsResources"},
+					{Name: "EvenPodSpread", Args: "{\"MaxSkew\": 1}"},
+				},
+				Priorities: []scheduler.PriorityPolicy{
+					{Name: "LowestOrdinalPriority", Weight: 5},
+				},
+			},
+			forecaster: staticForecaster(18),
+		},
 		{
 			name:     "with replicas, with placements, with pending, enough capacity, with Predicates and Zone Priorities",
 			replicas: int32(2),
@@ -471,6 +765,14 @@ func TestAutoscaler(t *testing.T) {
 				getReserved: func() map[types.NamespacedName]map[string]int32 {
 					return tc.reserved
 				},
+				DemandForecaster: tc.forecaster,
+				QueueDepthSource: tc.queueDepth,
+				VReplicaCost:     tc.vreplicaCost,
+				MaxScaleUpStep:   tc.maxScaleUpStep,
+				MaxScaleDownStep: tc.maxScaleDownStep,
+
+				YieldToExternalScalers: tc.yield,
+				QueueDepthThreshold:    tc.queueDepthThreshold,
 			}
 			autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
 			_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
@@ -496,34 +798,333 @@ func TestAutoscaler(t *testing.T) {
 	}
 }
 
-func TestAutoscalerScaleDownToZero(t *testing.T) {
-	ctx, cancel := tscheduler.SetupFakeContext(t)
+func TestAutoscalerScaleDownDisabled(t *testing.T) {
+	testCases := []struct {
+		name          string
+		replicas      int32
+		disabled      bool
+		wantReplicas  int32
+		wantEvictions int
+	}{
+		{
+			name:          "scale down",
+			replicas:      3,
+			wantReplicas:  1,
+			wantEvictions: 0,
+		},
+		{
+			name:          "scale down disabled",
+			replicas:      3,
+			disabled:      true,
+			wantReplicas:  3,
+			wantEvictions: 0,
+		},
+		{
+			name:          "compaction",
+			replicas:      1,
+			wantReplicas:  1,
+			wantEvictions: 1,
+		},
+		{
+			name:          "compaction disabled",
+			replicas:      1,
+			disabled:      true,
+			wantReplicas:  1,
+			wantEvictions: 0,
+		},
+		{
+			name:          "scale up",
+			replicas:      0,
+			disabled:      true,
+			wantReplicas:  1,
+			wantEvictions: 0,
+		},
+	}
 
-	afterUpdate := make(chan bool)
-	kubeclient.Get(ctx).PrependReactor("update", "statefulsets", func(action gtesting.Action) (handled bool, ret runtime.Object, err error) {
-		if action.GetSubresource() == "scale" {
-			afterUpdate <- true
-		}
-		return false, nil, nil
-	})
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
 
-	vpodClient := tscheduler.NewVPodClient()
-	ls := listers.NewListers(nil)
-	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+			vpodClient := tscheduler.NewVPodClient()
+			vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 10, []duckv1alpha1.Placement{
+				{PodName: "statefulset-name-0", VReplicas: int32(8)},
+				{PodName: "statefulset-name-1", VReplicas: int32(2)}}))
+			s := &st.State{
+				FreeCap:         []int32{2, 8},
+				SchedulablePods: []int32{0, 1},
+				LastOrdinal:     1,
+				Replicas:        tc.replicas,
+				Capacity:        10,
+				SchedulerPolicy: scheduler.MAXFILLUP,
+				ExpectedVReplicaByVPod: map[types.NamespacedName]int32{
+					{Namespace: testNs, Name: "vpod-1"}: 10,
+				},
+			}
 
-	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
-	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 10), metav1.CreateOptions{})
-	if err != nil {
-		t.Fatal("unexpected error", err)
-	}
+			sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+			_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, tc.replicas), metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
 
-	noopEvictor := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
-		return nil
-	}
+			evictions := 0
+			countEvictions := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+				evictions++
+				return nil
+			}
 
-	cfg := &Config{
-		StatefulSetNamespace: testNs,
-		StatefulSetName:      sfsName,
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           vpodClient.List,
+				Evictor:              countEvictions,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+				ScaleDownDisabled:    tc.disabled,
+				getReserved: func() map[types.NamespacedName]map[string]int32 {
+					return nil
+				},
+			}
+			autoscaler := newAutoscaler(ctx, cfg, &fixedStateAccessor{state: s})
+			_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+			if err := autoscaler.syncAutoscale(ctx, true); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if scale.Spec.Replicas != tc.wantReplicas {
+				t.Errorf("unexpected number of replicas, got %d, want %d", scale.Spec.Replicas, tc.wantReplicas)
+			}
+			if evictions != tc.wantEvictions {
+				t.Errorf("unexpected number of evictions, got %d, want %d", evictions, tc.wantEvictions)
+			}
+		})
+	}
+}
+
+func TestAutoscalerNoCompactionWhenDemandClamped(t *testing.T) {
+	testCases := []struct {
+		name          string
+		expected      int32
+		pending       int32
+		maxReplicas   int32
+		wantReplicas  int32
+		wantEvictions int
+	}{
+		{
+			name:          "replicas with slack",
+			expected:      10,
+			wantReplicas:  1,
+			wantEvictions: 1,
+		},
+		{
+			name:          "replicas clamped to the max replicas",
+			expected:      15,
+			pending:       5,
+			maxReplicas:   1,
+			wantReplicas:  1,
+			wantEvictions: 0,
+		},
+		{
+			name:          "pending vreplicas with max replicas reached",
+			expected:      10,
+			pending:       1,
+			maxReplicas:   1,
+			wantReplicas:  1,
+			wantEvictions: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			vpodKey := types.NamespacedName{Namespace: testNs, Name: "vpod-1"}
+			vpodClient := tscheduler.NewVPodClient()
+			vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", tc.expected, []duckv1alpha1.Placement{
+				{PodName: "statefulset-name-0", VReplicas: int32(8)},
+				{PodName: "statefulset-name-1", VReplicas: int32(2)}}))
+			s := &st.State{
+				FreeCap:                []int32{2, 8},
+				SchedulablePods:        []int32{0, 1},
+				LastOrdinal:            1,
+				Replicas:               1,
+				Capacity:               10,
+				SchedulerPolicy:        scheduler.MAXFILLUP,
+				Pending:                map[types.NamespacedName]int32{vpodKey: tc.pending},
+				ExpectedVReplicaByVPod: map[types.NamespacedName]int32{vpodKey: tc.expected},
+			}
+
+			sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+			_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 1), metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			evictions := 0
+			countEvictions := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+				evictions++
+				return nil
+			}
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           vpodClient.List,
+				Evictor:              countEvictions,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+				MaxReplicas:          tc.maxReplicas,
+				getReserved: func() map[types.NamespacedName]map[string]int32 {
+					return nil
+				},
+			}
+			autoscaler := newAutoscaler(ctx, cfg, &fixedStateAccessor{state: s})
+			_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+			if err := autoscaler.syncAutoscale(ctx, true); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if scale.Spec.Replicas != tc.wantReplicas {
+				t.Errorf("unexpected number of replicas, got %d, want %d", scale.Spec.Replicas, tc.wantReplicas)
+			}
+			if evictions != tc.wantEvictions {
+				t.Errorf("unexpected number of evictions, got %d, want %d", evictions, tc.wantEvictions)
+			}
+		})
+	}
+}
+
+func TestAutoscalerLimitReplicas(t *testing.T) {
+	testCases := []struct {
+		name             string
+		wanted           int32
+		replicas         int32
+		scaleUpFactor    int32
+		attemptScaleDown bool
+		maxScaleUpStep   int32
+		maxScaleDownStep int32
+		want             int32
+	}{
+		{
+			name:          "unlimited scale up",
+			wanted:        10,
+			replicas:      2,
+			scaleUpFactor: 1,
+			want:          10,
+		},
+		{
+			name:           "scale up step, HA scaling",
+			wanted:         12,
+			replicas:       3,
+			scaleUpFactor:  3,
+			maxScaleUpStep: 4,
+			want:           6,
+		},
+		{
+			name:             "unlimited scale down",
+			wanted:           1,
+			replicas:         9,
+			scaleUpFactor:    1,
+			attemptScaleDown: true,
+			want:             1,
+		},
+		{
+			name:             "scale down step",
+			wanted:           1,
+			replicas:         9,
+			scaleUpFactor:    1,
+			attemptScaleDown: true,
+			maxScaleDownStep: 2,
+			want:             7,
+		},
+		{
+			name:             "scale down step, HA scaling",
+			wanted:           3,
+			replicas:         12,
+			scaleUpFactor:    3,
+			attemptScaleDown: true,
+			maxScaleDownStep: 4,
+			want:             9,
+		},
+		{
+			name:             "scale down step lower than the scale up factor, HA scaling",
+			wanted:           3,
+			replicas:         12,
+			scaleUpFactor:    3,
+			attemptScaleDown: true,
+			maxScaleDownStep: 1,
+			want:             9,
+		},
+		{
+			name:             "scale down step, scale down not attempted",
+			wanted:           1,
+			replicas:         9,
+			scaleUpFactor:    1,
+			maxScaleDownStep: 2,
+			want:             9,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+				MaxScaleUpStep:       tc.maxScaleUpStep,
+				MaxScaleDownStep:     tc.maxScaleDownStep,
+			}
+			autoscaler := newAutoscaler(ctx, cfg, nil)
+
+			got := autoscaler.limitReplicas(tc.wanted, tc.replicas, tc.scaleUpFactor, tc.attemptScaleDown)
+			if got != tc.want {
+				t.Errorf("unexpected number of replicas, got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAutoscalerScaleDownToZero(t *testing.T) {
+	ctx, cancel := tscheduler.SetupFakeContext(t)
+
+	afterUpdate := make(chan bool)
+	kubeclient.Get(ctx).PrependReactor("update", "statefulsets", func(action gtesting.Action) (handled bool, ret runtime.Object, err error) {
+		if action.GetSubresource() == "scale" {
+			afterUpdate <- true
+		}
+		return false, nil, nil
+	})
+
+	vpodClient := tscheduler.NewVPodClient()
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 10), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	noopEvictor := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+		return nil
+	}
+
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
 		VPodLister:           vpodClient.List,
 		Evictor:              noopEvictor,
 		RefreshPeriod:        2 * time.Second,
@@ -574,6 +1175,10 @@ func TestCompactor(t *testing.T) {
 		wantEvictions       map[types.NamespacedName][]duckv1alpha1.Placement
 		schedulerPolicy     *scheduler.SchedulerPolicy
 		deschedulerPolicy   *scheduler.SchedulerPolicy
+		compactionHeadroom  float64
+		cordonedNodes       []string
+		nodeAware           bool
+		compactionBatchSize int32
 	}{
 		{
 			name:     "no replicas, no placements, no pending",
@@ -619,6 +1224,75 @@ func TestCompactor(t *testing.T) {
 				{Name: "vpod-1", Namespace: testNs}: {{PodName: "statefulset-name-1", VReplicas: int32(2)}},
 			},
 		},
+		{
+			name:     "one vpod, with placements in 2 pods, not compacted, lower pod on a cordoned node",
+			replicas: int32(2),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 10, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(8)},
+					{PodName: "statefulset-name-1", VReplicas: int32(2)}}),
+			},
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			cordonedNodes:       []string{"node0"},
+			nodeAware:           true,
+			wantEvictions:       nil,
+		},
+		{
+			name:     "one vpod, with placements in 3 pods, not compacted, last pod on a cordoned node",
+			replicas: int32(3),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 20, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(8)},
+					{PodName: "statefulset-name-1", VReplicas: int32(9)},
+					{PodName: "statefulset-name-2", VReplicas: int32(3)}}),
+			},
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			cordonedNodes:       []string{"node2"},
+			nodeAware:           true,
+			wantEvictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {{PodName: "statefulset-name-2", VReplicas: int32(3)}},
+			},
+		},
+		{
+			name:     "one vpod, with placements in 2 pods, no headroom left",
+			replicas: int32(2),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 10, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(8)},
+					{PodName: "statefulset-name-1", VReplicas: int32(2)}}),
+			},
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			compactionHeadroom:  0.1,
+			wantEvictions:       nil,
+		},
+		{
+			name:     "one vpod, with placements in 3 pods, enough headroom left",
+			replicas: int32(3),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 8, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(4)},
+					{PodName: "statefulset-name-1", VReplicas: int32(2)},
+					{PodName: "statefulset-name-2", VReplicas: int32(2)}}),
+			},
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			compactionHeadroom:  0.6,
+			wantEvictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {{PodName: "statefulset-name-2", VReplicas: int32(2)}},
+			},
+		},
+		{
+			name:     "one vpod, with placements in 3 pods, not enough headroom left",
+			replicas: int32(3),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 8, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(4)},
+					{PodName: "statefulset-name-1", VReplicas: int32(2)},
+					{PodName: "statefulset-name-2", VReplicas: int32(2)}}),
+			},
+			schedulerPolicyType: scheduler.MAXFILLUP,
+			compactionHeadroom:  0.65,
+			wantEvictions:       nil,
+		},
 		{
 			name:     "multiple vpods, with placements in multiple pods, compacted",
 			replicas: int32(3),
@@ -908,74 +1582,253 @@ func TestCompactor(t *testing.T) {
 				{Name: "vpod-1", Namespace: testNs}: {{PodName: "statefulset-name-5", VReplicas: int32(3)}, {PodName: "statefulset-name-4", VReplicas: int32(3)}, {PodName: "statefulset-name-3", VReplicas: int32(2)}},
 			},
 		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			ctx, _ := tscheduler.SetupFakeContext(t)
-
-			nodelist := make([]runtime.Object, 0, numZones)
-			podlist := make([]runtime.Object, 0, tc.replicas)
-			vpodClient := tscheduler.NewVPodClient()
-
-			for i := int32(0); i < numZones; i++ {
-				for j := int32(0); j < numNodes/numZones; j++ {
-					nodeName := "node" + fmt.Sprint((j*((numNodes/numZones)+1))+i)
-					zoneName := "zone" + fmt.Sprint(i)
-					node, err := kubeclient.Get(ctx).CoreV1().Nodes().Create(ctx, tscheduler.MakeNode(nodeName, zoneName), metav1.CreateOptions{})
-					if err != nil {
-						t.Fatal("unexpected error", err)
-					}
-					nodelist = append(nodelist, node)
-				}
-			}
-			for i := int32(0); i < tc.replicas; i++ {
-				nodeName := "node" + fmt.Sprint(i)
-				podName := sfsName + "-" + fmt.Sprint(i)
-				pod, err := kubeclient.Get(ctx).CoreV1().Pods(testNs).Create(ctx, tscheduler.MakePod(testNs, podName, nodeName), metav1.CreateOptions{})
-				if err != nil {
-					t.Fatal("unexpected error", err)
-				}
-				podlist = append(podlist, pod)
-			}
-
-			_, err := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs).Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, tc.replicas), metav1.CreateOptions{})
-			if err != nil {
-				t.Fatal("unexpected error", err)
-			}
-
-			lsp := listers.NewListers(podlist)
-			lsn := listers.NewListers(nodelist)
-			stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, tc.schedulerPolicyType, tc.schedulerPolicy, tc.deschedulerPolicy, lsp.GetPodLister().Pods(testNs), lsn.GetNodeLister())
-
-			evictions := make(map[types.NamespacedName][]duckv1alpha1.Placement)
-			recordEviction := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
-				evictions[vpod.GetKey()] = append(evictions[vpod.GetKey()], *from)
-				return nil
-			}
-
-			cfg := &Config{
-				StatefulSetNamespace: testNs,
-				StatefulSetName:      sfsName,
-				VPodLister:           vpodClient.List,
-				Evictor:              recordEviction,
-				RefreshPeriod:        10 * time.Second,
-				PodCapacity:          10,
-			}
-			autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
-			_ = autoscaler.Promote(reconciler.UniversalBucket(), func(bucket reconciler.Bucket, name types.NamespacedName) {})
-			assert.Equal(t, true, autoscaler.isLeader.Load())
-
-			for _, vpod := range tc.vpods {
-				vpodClient.Append(vpod)
-			}
-
-			state, err := stateAccessor.State(nil)
-			if err != nil {
-				t.Fatalf("unexpected error: %v", err)
-			}
-
-			var scaleUpFactor int32
+		{
+			name:     "one vpod, with placements in multiple pods, one on a cordoned node, not compacted as it would reduce the zone spread, with Predicates and HA Priorities",
+			replicas: int32(6),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 24, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(4)},
+					{PodName: "statefulset-name-1", VReplicas: int32(10)},
+					{PodName: "statefulset-name-2", VReplicas: int32(4)},
+					{PodName: "statefulset-name-3", VReplicas: int32(2)},
+					{PodName: "statefulset-name-4", VReplicas: int32(2)},
+					{PodName: "statefulset-name-5", VReplicas: int32(2)}}),
+			},
+			schedulerPolicy: &scheduler.SchedulerPolicy{
+				Predicates: []scheduler.PredicatePolicy{
+					{Name: "PodFitsResources"},
+					{Name: "EvenPodSpread", Args: "{\"MaxSkew\": 1}"},
+				},
+				Priorities: []scheduler.PriorityPolicy{
+					{Name: "AvailabilityZonePriority", Weight: 10, Args: "{\"MaxSkew\": 1}"},
+					{Name: "LowestOrdinalPriority", Weight: 5},
+				},
+			},
+			cordonedNodes: []string{"node1"},
+			// The only schedulable pod in zone1 is evicted.
+			wantEvictions: nil,
+		},
+		{
+			name:     "one vpod, with placements in multiple pods, one on a cordoned node, node aware, not compacted, with Predicates and HA Priorities",
+			replicas: int32(6),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 24, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(4)},
+					{PodName: "statefulset-name-1", VReplicas: int32(10)},
+					{PodName: "statefulset-name-2", VReplicas: int32(4)},
+					{PodName: "statefulset-name-3", VReplicas: int32(2)},
+					{PodName: "statefulset-name-4", VReplicas: int32(2)},
+					{PodName: "statefulset-name-5", VReplicas: int32(2)}}),
+			},
+			schedulerPolicy: &scheduler.SchedulerPolicy{
+				Predicates: []scheduler.PredicatePolicy{
+					{Name: "PodFitsResources"},
+					{Name: "EvenPodSpread", Args: "{\"MaxSkew\": 1}"},
+				},
+				Priorities: []scheduler.PriorityPolicy{
+					{Name: "AvailabilityZonePriority", Weight: 10, Args: "{\"MaxSkew\": 1}"},
+					{Name: "LowestOrdinalPriority", Weight: 5},
+				},
+			},
+			cordonedNodes: []string{"node1"},
+			nodeAware:     true,
+			wantEvictions: nil,
+		},
+		{
+			name:     "one vpod, with placements in 9 pods, compacted one group at a time, with Predicates and HA Priorities",
+			replicas: int32(9),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 18, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(4)},
+					{PodName: "statefulset-name-1", VReplicas: int32(4)},
+					{PodName: "statefulset-name-2", VReplicas: int32(4)},
+					{PodName: "statefulset-name-3", VReplicas: int32(1)},
+					{PodName: "statefulset-name-4", VReplicas: int32(1)},
+					{PodName: "statefulset-name-5", VReplicas: int32(1)},
+					{PodName: "statefulset-name-6", VReplicas: int32(1)},
+					{PodName: "statefulset-name-7", VReplicas: int32(1)},
+					{PodName: "statefulset-name-8", VReplicas: int32(1)}}),
+			},
+			schedulerPolicy: &scheduler.SchedulerPolicy{
+				Predicates: []scheduler.PredicatePolicy{
+					{Name: "PodFitsResources"},
+					{Name: "EvenPodSpread", Args: "{\"MaxSkew\": 1}"},
+				},
+				Priorities: []scheduler.PriorityPolicy{
+					{Name: "AvailabilityZonePriority", Weight: 10, Args: "{\"MaxSkew\": 1}"},
+					{Name: "LowestOrdinalPriority", Weight: 5},
+				},
+			},
+			wantEvictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {{PodName: "statefulset-name-8", VReplicas: int32(1)}, {PodName: "statefulset-name-7", VReplicas: int32(1)}, {PodName: "statefulset-name-6", VReplicas: int32(1)}},
+			},
+		},
+		{
+			name:     "one vpod, with placements in 9 pods, compacted in a batch of 2 groups, with Predicates and HA Priorities",
+			replicas: int32(9),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 18, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(4)},
+					{PodName: "statefulset-name-1", VReplicas: int32(4)},
+					{PodName: "statefulset-name-2", VReplicas: int32(4)},
+					{PodName: "statefulset-name-3", VReplicas: int32(1)},
+					{PodName: "statefulset-name-4", VReplicas: int32(1)},
+					{PodName: "statefulset-name-5", VReplicas: int32(1)},
+					{PodName: "statefulset-name-6", VReplicas: int32(1)},
+					{PodName: "statefulset-name-7", VReplicas: int32(1)},
+					{PodName: "statefulset-name-8", VReplicas: int32(1)}}),
+			},
+			schedulerPolicy: &scheduler.SchedulerPolicy{
+				Predicates: []scheduler.PredicatePolicy{
+					{Name: "PodFitsResources"},
+					{Name: "EvenPodSpread", Args: "{\"MaxSkew\": 1}"},
+				},
+				Priorities: []scheduler.PriorityPolicy{
+					{Name: "AvailabilityZonePriority", Weight: 10, Args: "{\"MaxSkew\": 1}"},
+					{Name: "LowestOrdinalPriority", Weight: 5},
+				},
+			},
+			compactionBatchSize: 2,
+			wantEvictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {{PodName: "statefulset-name-8", VReplicas: int32(1)}, {PodName: "statefulset-name-7", VReplicas: int32(1)}, {PodName: "statefulset-name-6", VReplicas: int32(1)}, {PodName: "statefulset-name-5", VReplicas: int32(1)}, {PodName: "statefulset-name-4", VReplicas: int32(1)}, {PodName: "statefulset-name-3", VReplicas: int32(1)}},
+			},
+		},
+		{
+			name:     "one vpod, with placements in 9 pods, batch of 3 groups limited by HA, with Predicates and HA Priorities",
+			replicas: int32(9),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 18, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(4)},
+					{PodName: "statefulset-name-1", VReplicas: int32(4)},
+					{PodName: "statefulset-name-2", VReplicas: int32(4)},
+					{PodName: "statefulset-name-3", VReplicas: int32(1)},
+					{PodName: "statefulset-name-4", VReplicas: int32(1)},
+					{PodName: "statefulset-name-5", VReplicas: int32(1)},
+					{PodName: "statefulset-name-6", VReplicas: int32(1)},
+					{PodName: "statefulset-name-7", VReplicas: int32(1)},
+					{PodName: "statefulset-name-8", VReplicas: int32(1)}}),
+			},
+			schedulerPolicy: &scheduler.SchedulerPolicy{
+				Predicates: []scheduler.PredicatePolicy{
+					{Name: "PodFitsResources"},
+					{Name: "EvenPodSpread", Args: "{\"MaxSkew\": 1}"},
+				},
+				Priorities: []scheduler.PriorityPolicy{
+					{Name: "AvailabilityZonePriority", Weight: 10, Args: "{\"MaxSkew\": 1}"},
+					{Name: "LowestOrdinalPriority", Weight: 5},
+				},
+			},
+			compactionBatchSize: 3,
+			wantEvictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {{PodName: "statefulset-name-8", VReplicas: int32(1)}, {PodName: "statefulset-name-7", VReplicas: int32(1)}, {PodName: "statefulset-name-6", VReplicas: int32(1)}, {PodName: "statefulset-name-5", VReplicas: int32(1)}, {PodName: "statefulset-name-4", VReplicas: int32(1)}, {PodName: "statefulset-name-3", VReplicas: int32(1)}},
+			},
+		},
+		{
+			name:     "one vpod, with placements in 9 pods, batch of 2 groups limited by capacity, with Predicates and HA Priorities",
+			replicas: int32(9),
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 36, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(8)},
+					{PodName: "statefulset-name-1", VReplicas: int32(8)},
+					{PodName: "statefulset-name-2", VReplicas: int32(8)},
+					{PodName: "statefulset-name-3", VReplicas: int32(2)},
+					{PodName: "statefulset-name-4", VReplicas: int32(2)},
+					{PodName: "statefulset-name-5", VReplicas: int32(2)},
+					{PodName: "statefulset-name-6", VReplicas: int32(2)},
+					{PodName: "statefulset-name-7", VReplicas: int32(2)},
+					{PodName: "statefulset-name-8", VReplicas: int32(2)}}),
+			},
+			schedulerPolicy: &scheduler.SchedulerPolicy{
+				Predicates: []scheduler.PredicatePolicy{
+					{Name: "PodFitsResources"},
+					{Name: "EvenPodSpread", Args: "{\"MaxSkew\": 1}"},
+				},
+				Priorities: []scheduler.PriorityPolicy{
+					{Name: "AvailabilityZonePriority", Weight: 10, Args: "{\"MaxSkew\": 1}"},
+					{Name: "LowestOrdinalPriority", Weight: 5},
+				},
+			},
+			compactionBatchSize: 2,
+			wantEvictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {{PodName: "statefulset-name-8", VReplicas: int32(2)}, {PodName: "statefulset-name-7", VReplicas: int32(2)}, {PodName: "statefulset-name-6", VReplicas: int32(2)}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			nodelist := make([]runtime.Object, 0, numZones)
+			podlist := make([]runtime.Object, 0, tc.replicas)
+			vpodClient := tscheduler.NewVPodClient()
+
+			for i := int32(0); i < numZones; i++ {
+				for j := int32(0); j < numNodes/numZones; j++ {
+					nodeName := "node" + fmt.Sprint((j*((numNodes/numZones)+1))+i)
+					zoneName := "zone" + fmt.Sprint(i)
+					node := tscheduler.MakeNode(nodeName, zoneName)
+					for _, cordoned := range tc.cordonedNodes {
+						node.Spec.Unschedulable = node.Spec.Unschedulable || cordoned == nodeName
+					}
+					node, err := kubeclient.Get(ctx).CoreV1().Nodes().Create(ctx, node, metav1.CreateOptions{})
+					if err != nil {
+						t.Fatal("unexpected error", err)
+					}
+					nodelist = append(nodelist, node)
+				}
+			}
+			for i := int32(0); i < tc.replicas; i++ {
+				nodeName := "node" + fmt.Sprint(i%numNodes)
+				podName := sfsName + "-" + fmt.Sprint(i)
+				pod, err := kubeclient.Get(ctx).CoreV1().Pods(testNs).Create(ctx, tscheduler.MakePod(testNs, podName, nodeName), metav1.CreateOptions{})
+				if err != nil {
+					t.Fatal("unexpected error", err)
+				}
+				podlist = append(podlist, pod)
+			}
+
+			_, err := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs).Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, tc.replicas), metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			lsp := listers.NewListers(podlist)
+			lsn := listers.NewListers(nodelist)
+			stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, tc.schedulerPolicyType, tc.schedulerPolicy, tc.deschedulerPolicy, lsp.GetPodLister().Pods(testNs), lsn.GetNodeLister())
+
+			evictions := make(map[types.NamespacedName][]duckv1alpha1.Placement)
+			recordEviction := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+				evictions[vpod.GetKey()] = append(evictions[vpod.GetKey()], *from)
+				return nil
+			}
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           vpodClient.List,
+				Evictor:              recordEviction,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+				CompactionHeadroom:   tc.compactionHeadroom,
+				NodeAware:            tc.nodeAware,
+				NodeLister:           lsn.GetNodeLister(),
+				CompactionBatchSize:  tc.compactionBatchSize,
+			}
+			autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+			_ = autoscaler.Promote(reconciler.UniversalBucket(), func(bucket reconciler.Bucket, name types.NamespacedName) {})
+			assert.Equal(t, true, autoscaler.isLeader.Load())
+
+			for _, vpod := range tc.vpods {
+				vpodClient.Append(vpod)
+			}
+
+			state, err := stateAccessor.State(nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var scaleUpFactor int32
 			if tc.schedulerPolicy != nil && contains(nil, tc.schedulerPolicy.Priorities, st.AvailabilityZonePriority) { //HA scaling across zones
 				scaleUpFactor = state.NumZones
 			} else if tc.schedulerPolicy != nil && contains(nil, tc.schedulerPolicy.Priorities, st.AvailabilityNodePriority) { //HA scalingacross nodes
@@ -984,7 +1837,7 @@ func TestCompactor(t *testing.T) {
 				scaleUpFactor = 1 // Non-HA scaling
 			}
 
-			autoscaler.mayCompact(state, scaleUpFactor)
+			autoscaler.mayCompact(ctx, state, scaleUpFactor)
 
 			if tc.wantEvictions == nil && len(evictions) != 0 {
 				t.Fatalf("unexpected evictions: %v", evictions)
@@ -1013,8 +1866,3944 @@ func TestCompactor(t *testing.T) {
 	}
 }
 
-func TestEphemeralKeyStableValues(t *testing.T) {
-	// Do not modify expected values
-	assert.Equal(t, "knative-eventing", ephemeralLeaderElectionObject.Namespace)
-	assert.Equal(t, "autoscaler-ephemeral", ephemeralLeaderElectionObject.Name)
+func TestCompactorSkipsUnexpectedPodNames(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	vpodClient := tscheduler.NewVPodClient()
+	vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 9, []duckv1alpha1.Placement{
+		{PodName: "statefulset-name-0", VReplicas: int32(7)},
+		{PodName: "statefulset-name-1", VReplicas: int32(2)}}))
+	vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-2", 3, []duckv1alpha1.Placement{
+		{PodName: "renamed-statefulset-1", VReplicas: int32(1)},
+		{PodName: "statefulset-name-01", VReplicas: int32(1)},
+		{PodName: "statefulset-name-x", VReplicas: int32(1)}}))
+
+	evictions := make(map[types.NamespacedName][]duckv1alpha1.Placement)
+	recordEviction := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+		evictions[vpod.GetKey()] = append(evictions[vpod.GetKey()], *from)
+		return nil
+	}
+
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		Evictor:              recordEviction,
+		RefreshPeriod:        10 * time.Second,
+		PodCapacity:          10,
+	}
+	autoscaler := newAutoscaler(ctx, cfg, nil)
+
+	if err := autoscaler.compact(ctx, &st.State{LastOrdinal: 1}, 1); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	want := map[types.NamespacedName][]duckv1alpha1.Placement{
+		{Name: "vpod-1", Namespace: testNs}: {{PodName: "statefulset-name-1", VReplicas: int32(2)}},
+	}
+	if !reflect.DeepEqual(want, evictions) {
+		t.Errorf("unexpected evictions, want %v, got %v", want, evictions)
+	}
+}
+
+func TestCompactorTransientListerError(t *testing.T) {
+	testCases := []struct {
+		name        string
+		err         error
+		wantSkipped bool
+	}{
+		{
+			name:        "informer not synced",
+			err:         fmt.Errorf("listing vpods: %w", scheduler.ErrVPodsNotSynced),
+			wantSkipped: true,
+		},
+		{
+			name:        "api server unavailable",
+			err:         apierrors.NewServiceUnavailable("overloaded"),
+			wantSkipped: true,
+		},
+		{
+			name:        "permanent error",
+			err:         errors.New("boom"),
+			wantSkipped: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			vpodClient := tscheduler.NewVPodClient()
+			ls := listers.NewListers(nil)
+			stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+			sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+			_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 0), metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			reporter := &recordingStatsReporter{}
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister: func() ([]scheduler.VPod, error) {
+					return nil, tc.err
+				},
+				Evictor: func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+					return nil
+				},
+				RefreshPeriod: 10 * time.Second,
+				PodCapacity:   10,
+				getReserved: func() map[types.NamespacedName]map[string]int32 {
+					return nil
+				},
+				StatsReporter: reporter,
+			}
+			autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+			_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+			vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 15, nil))
+
+			// The scale up doesn't depend on the failing lister.
+			if err := autoscaler.syncAutoscale(ctx, true); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if want := []string{AutoscaleOutcomeScaleUp}; !reflect.DeepEqual(want, reporter.outcomes) {
+				t.Errorf("unexpected reported outcomes, want %v, got %v", want, reporter.outcomes)
+			}
+
+			autoscaler.lastCompactAttempt = autoscaler.clock.Now()
+			autoscaler.cycleOutcome = AutoscaleOutcomeCompaction
+			autoscaler.compactWithEvents(ctx, &st.State{LastOrdinal: 1}, 1)
+
+			gotSkipped := autoscaler.lastCompactAttempt.IsZero()
+			if gotSkipped != tc.wantSkipped {
+				t.Errorf("unexpected compaction skipped, want %v, got %v", tc.wantSkipped, gotSkipped)
+			}
+			wantOutcome := AutoscaleOutcomeCompaction
+			if tc.wantSkipped {
+				wantOutcome = AutoscaleOutcomeNoop
+			}
+			if autoscaler.cycleOutcome != wantOutcome {
+				t.Errorf("unexpected cycle outcome, want %v, got %v", wantOutcome, autoscaler.cycleOutcome)
+			}
+		})
+	}
+}
+
+func TestCompactorTargetedEviction(t *testing.T) {
+	testCases := []struct {
+		name            string
+		evictionTargets func(s *st.State) []string
+		wantPreferred   []string
+		wantPlacements  []duckv1alpha1.Placement
+	}{
+		{
+			name:          "pods with free capacity",
+			wantPreferred: []string{"statefulset-name-1", "statefulset-name-2"},
+			wantPlacements: []duckv1alpha1.Placement{
+				{PodName: "statefulset-name-0", VReplicas: int32(10)},
+				{PodName: "statefulset-name-1", VReplicas: int32(2)},
+			},
+		},
+		{
+			name: "custom targets",
+			evictionTargets: func(s *st.State) []string {
+				return []string{"statefulset-name-3", "statefulset-name-2", "statefulset-name-1"}
+			},
+			wantPreferred: []string{"statefulset-name-2", "statefulset-name-1"},
+			wantPlacements: []duckv1alpha1.Placement{
+				{PodName: "statefulset-name-0", VReplicas: int32(10)},
+				{PodName: "statefulset-name-2", VReplicas: int32(2)},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			vpodClient := tscheduler.NewVPodClient()
+			vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 12, []duckv1alpha1.Placement{
+				{PodName: "statefulset-name-0", VReplicas: int32(10)},
+				{PodName: "statefulset-name-3", VReplicas: int32(2)}}))
+			vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-2", 6, []duckv1alpha1.Placement{
+				{PodName: "statefulset-name-1", VReplicas: int32(6)}}))
+
+			s := &st.State{
+				Capacity:        10,
+				LastOrdinal:     3,
+				FreeCap:         []int32{0, 4, 10, 8},
+				SchedulablePods: []int32{0, 1, 2, 3},
+			}
+
+			// The fake scheduler places the evicted vreplicas on the preferred pods first.
+			var preferred []string
+			placements := make(map[types.NamespacedName][]duckv1alpha1.Placement)
+			evictAndSchedule := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement, preferredPods []string) error {
+				preferred = preferredPods
+				left := from.VReplicas
+				var got []duckv1alpha1.Placement
+				for _, p := range vpod.GetPlacements() {
+					if p.PodName != from.PodName {
+						got = append(got, p)
+					}
+				}
+				for _, podName := range preferredPods {
+					ordinal := st.OrdinalFromPodName(podName)
+					allocation := s.Free(ordinal)
+					if allocation > left {
+						allocation = left
+					}
+					if allocation > 0 {
+						got = append(got, duckv1alpha1.Placement{PodName: podName, VReplicas: allocation})
+						s.SetFree(ordinal, s.Free(ordinal)-allocation)
+						left -= allocation
+					}
+				}
+				if left > 0 {
+					return fmt.Errorf("%d vreplicas left unscheduled", left)
+				}
+				placements[vpod.GetKey()] = got
+				return nil
+			}
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           vpodClient.List,
+				TargetedEvictor:      evictAndSchedule,
+				EvictionTargets:      tc.evictionTargets,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+			}
+			autoscaler := newAutoscaler(ctx, cfg, nil)
+
+			if err := autoscaler.compact(ctx, s, 1); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			if !reflect.DeepEqual(tc.wantPreferred, preferred) {
+				t.Errorf("unexpected preferred pods, want %v, got %v", tc.wantPreferred, preferred)
+			}
+			want := map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: tc.wantPlacements,
+			}
+			if !reflect.DeepEqual(want, placements) {
+				t.Errorf("unexpected placements, want %v, got %v", want, placements)
+			}
+		})
+	}
+}
+
+func TestCompactorZoneSpread(t *testing.T) {
+	zonePolicy := &scheduler.SchedulerPolicy{
+		Priorities: []scheduler.PriorityPolicy{
+			{Name: "AvailabilityZonePriority", Weight: 10, Args: "{\"MaxSkew\": 1}"},
+			{Name: "LowestOrdinalPriority", Weight: 5},
+		},
+	}
+
+	testCases := []struct {
+		name       string
+		policy     *scheduler.SchedulerPolicy
+		freeCap    []int32
+		placements map[string]map[int32]int32
+		unexpected map[string]map[string]int32
+		pods       int32
+		want       bool
+	}{
+		{
+			name:    "evicted vreplicas spread over the remaining zones",
+			policy:  zonePolicy,
+			freeCap: []int32{10, 10, 10, 9, 9, 9},
+			placements: map[string]map[int32]int32{
+				"vpod-1": {3: 1, 4: 1, 5: 1},
+			},
+			pods: 3,
+			want: true,
+		},
+		{
+			name:    "no free capacity left in a zone",
+			policy:  zonePolicy,
+			freeCap: []int32{10, 0, 10, 9, 9, 9},
+			placements: map[string]map[int32]int32{
+				"vpod-1": {3: 1, 4: 1, 5: 1},
+			},
+			pods: 3,
+			want: false,
+		},
+		{
+			name:    "vpod kept in the zone without free capacity",
+			policy:  zonePolicy,
+			freeCap: []int32{10, 0, 10, 9, 9, 9},
+			placements: map[string]map[int32]int32{
+				"vpod-1": {1: 10, 3: 1, 5: 1},
+			},
+			pods: 3,
+			want: true,
+		},
+		{
+			name:    "remaining pods not covering all zones",
+			policy:  zonePolicy,
+			freeCap: []int32{10, 10, 9, 9, 9, 9},
+			placements: map[string]map[int32]int32{
+				"vpod-1": {2: 1, 3: 1, 4: 1},
+			},
+			pods: 4,
+			want: false,
+		},
+		{
+			name:    "vpod not spread over all zones before",
+			policy:  zonePolicy,
+			freeCap: []int32{10, 0, 10, 10, 10, 9},
+			placements: map[string]map[int32]int32{
+				"vpod-1": {5: 1},
+			},
+			pods: 3,
+			want: true,
+		},
+		{
+			name:    "placements on unexpected pod names not evicted",
+			policy:  zonePolicy,
+			freeCap: []int32{10, 1, 10, 9, 9, 9},
+			placements: map[string]map[int32]int32{
+				"vpod-1": {4: 1},
+				"vpod-2": {3: 1, 4: 1, 5: 1},
+			},
+			unexpected: map[string]map[string]int32{
+				"vpod-1": {"statefulset-name-x": 1},
+			},
+			pods: 3,
+			want: true,
+		},
+		{
+			name:    "zone spread not a priority",
+			policy:  &scheduler.SchedulerPolicy{Priorities: []scheduler.PriorityPolicy{{Name: "LowestOrdinalPriority", Weight: 5}}},
+			freeCap: []int32{10, 0, 10, 9, 9, 9},
+			placements: map[string]map[int32]int32{
+				"vpod-1": {3: 1, 4: 1, 5: 1},
+			},
+			pods: 3,
+			want: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			// Pod i runs on node i, in zone i%3.
+			nodeToZone := make(map[string]string)
+			podlist := make([]runtime.Object, 0, len(tc.freeCap))
+			schedulablePods := make([]int32, 0, len(tc.freeCap))
+			for i := int32(0); i < int32(len(tc.freeCap)); i++ {
+				nodeName := "node" + fmt.Sprint(i)
+				nodeToZone[nodeName] = "zone" + fmt.Sprint(i%3)
+				podlist = append(podlist, tscheduler.MakePod(testNs, st.PodNameFromOrdinal(sfsName, i), nodeName))
+				schedulablePods = append(schedulablePods, i)
+			}
+
+			podSpread := make(map[types.NamespacedName]map[string]int32)
+			zoneSpread := make(map[types.NamespacedName]map[string]int32)
+			for name, placements := range tc.placements {
+				key := types.NamespacedName{Namespace: testNs, Name: name}
+				podSpread[key] = make(map[string]int32)
+				zoneSpread[key] = make(map[string]int32)
+				for ordinal, vreplicas := range placements {
+					podSpread[key][st.PodNameFromOrdinal(sfsName, ordinal)] += vreplicas
+					zoneSpread[key]["zone"+fmt.Sprint(ordinal%3)] += vreplicas
+				}
+			}
+			for name, placements := range tc.unexpected {
+				key := types.NamespacedName{Namespace: testNs, Name: name}
+				for podName, vreplicas := range placements {
+					podSpread[key][podName] += vreplicas
+				}
+			}
+
+			lsp := listers.NewListers(podlist)
+			s := &st.State{
+				FreeCap:         tc.freeCap,
+				SchedulablePods: schedulablePods,
+				LastOrdinal:     int32(len(tc.freeCap)) - 1,
+				Capacity:        10,
+				SchedPolicy:     tc.policy,
+				NodeToZoneMap:   nodeToZone,
+				PodLister:       lsp.GetPodLister().Pods(testNs),
+				PodSpread:       podSpread,
+				ZoneSpread:      zoneSpread,
+			}
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+			}
+			autoscaler := newAutoscaler(ctx, cfg, nil)
+
+			if got := autoscaler.preservesZoneSpread(s, tc.pods); got != tc.want {
+				t.Errorf("unexpected zone spread preserved, want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPlanCompaction(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	vpodClient := tscheduler.NewVPodClient()
+	vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 12, []duckv1alpha1.Placement{
+		{PodName: "statefulset-name-0", VReplicas: int32(7)},
+		{PodName: "statefulset-name-1", VReplicas: int32(2)},
+		{PodName: "statefulset-name-2", VReplicas: int32(3)}}))
+	vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-2", 5, []duckv1alpha1.Placement{
+		{PodName: "statefulset-name-0", VReplicas: int32(1)},
+		{PodName: "statefulset-name-2", VReplicas: int32(4)}}))
+
+	failEviction := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+		t.Errorf("unexpected eviction of %v from %s", vpod.GetKey(), from.PodName)
+		return nil
+	}
+
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		Evictor:              failEviction,
+		RefreshPeriod:        10 * time.Second,
+		PodCapacity:          10,
+	}
+	autoscaler := newAutoscaler(ctx, cfg, nil)
+
+	testCases := []struct {
+		name          string
+		scaleUpFactor int32
+		want          []EvictionPlanItem
+	}{
+		{
+			name:          "last pod",
+			scaleUpFactor: 1,
+			want: []EvictionPlanItem{
+				{VPod: types.NamespacedName{Name: "vpod-1", Namespace: testNs}, Placement: duckv1alpha1.Placement{PodName: "statefulset-name-2", VReplicas: 3}},
+				{VPod: types.NamespacedName{Name: "vpod-2", Namespace: testNs}, Placement: duckv1alpha1.Placement{PodName: "statefulset-name-2", VReplicas: 4}},
+			},
+		},
+		{
+			name:          "last 2 pods",
+			scaleUpFactor: 2,
+			want: []EvictionPlanItem{
+				{VPod: types.NamespacedName{Name: "vpod-1", Namespace: testNs}, Placement: duckv1alpha1.Placement{PodName: "statefulset-name-2", VReplicas: 3}},
+				{VPod: types.NamespacedName{Name: "vpod-1", Namespace: testNs}, Placement: duckv1alpha1.Placement{PodName: "statefulset-name-1", VReplicas: 2}},
+				{VPod: types.NamespacedName{Name: "vpod-2", Namespace: testNs}, Placement: duckv1alpha1.Placement{PodName: "statefulset-name-2", VReplicas: 4}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := autoscaler.PlanCompaction(&st.State{LastOrdinal: 2}, tc.scaleUpFactor)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			got := make([]EvictionPlanItem, 0, len(plan))
+			for _, item := range plan {
+				got = append(got, EvictionPlanItem{VPod: item.VPod, Placement: item.Placement})
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("unexpected plan, want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAutoscalerExplainCompaction(t *testing.T) {
+	zonePolicy := &scheduler.SchedulerPolicy{
+		Priorities: []scheduler.PriorityPolicy{
+			{Name: "AvailabilityZonePriority", Weight: 10, Args: "{\"MaxSkew\": 1}"},
+			{Name: "LowestOrdinalPriority", Weight: 5},
+		},
+	}
+
+	testCases := []struct {
+		name       string
+		notLeader  bool
+		paused     bool
+		recent     bool
+		policy     *scheduler.SchedulerPolicy
+		freeCap    []int32
+		placements map[int32]int32
+		vpodKey    string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "eligible",
+			freeCap:    []int32{5, 5},
+			placements: map[int32]int32{0: 5, 1: 5},
+			want:       "eligible for compaction: placements on statefulset-name-1 (5 vreplicas) would be evicted",
+		},
+		{
+			name:       "not on the last pod",
+			freeCap:    []int32{5, 5},
+			placements: map[int32]int32{0: 5},
+			want:       "not compacted: not on the last 1 pod(s)",
+		},
+		{
+			name:       "insufficient free capacity",
+			freeCap:    []int32{2, 5},
+			placements: map[int32]int32{1: 5},
+			want:       "not compacted: insufficient free capacity elsewhere",
+		},
+		{
+			name:       "single pod",
+			freeCap:    []int32{5},
+			placements: map[int32]int32{0: 5},
+			want:       "not compacted: not enough pods to compact (1 schedulable, scale up factor 1)",
+		},
+		{
+			name:       "not leader",
+			notLeader:  true,
+			freeCap:    []int32{5, 5},
+			placements: map[int32]int32{1: 5},
+			want:       "not compacted: autoscaler is not leader",
+		},
+		{
+			name:       "paused",
+			paused:     true,
+			freeCap:    []int32{5, 5},
+			placements: map[int32]int32{1: 5},
+			want:       "not compacted: autoscaler is paused",
+		},
+		{
+			name:       "compaction attempted recently",
+			recent:     true,
+			freeCap:    []int32{5, 5},
+			placements: map[int32]int32{1: 5},
+			want:       "not compacted: last compaction attempted less than a refresh period ago, next attempt at 2023-01-01T00:00:10Z",
+		},
+		{
+			name:       "eligible with zone spread",
+			policy:     zonePolicy,
+			freeCap:    []int32{10, 10, 10, 9, 9, 9},
+			placements: map[int32]int32{3: 1, 4: 1, 5: 1},
+			want:       "eligible for compaction: placements on statefulset-name-3 (1 vreplicas), statefulset-name-4 (1 vreplicas), statefulset-name-5 (1 vreplicas) would be evicted",
+		},
+		{
+			name:       "blocked by the zone spread",
+			policy:     zonePolicy,
+			freeCap:    []int32{10, 0, 10, 9, 9, 9},
+			placements: map[int32]int32{3: 1, 4: 1, 5: 1},
+			want:       "not compacted: blocked by HA constraint: the zone spread would be reduced",
+		},
+		{
+			name:       "insufficient free capacity with zone spread",
+			policy:     zonePolicy,
+			freeCap:    []int32{1, 1, 1, 5, 5, 5},
+			placements: map[int32]int32{3: 5, 4: 5, 5: 5},
+			want:       "not compacted: insufficient free capacity elsewhere",
+		},
+		{
+			name:    "unknown vpod",
+			freeCap: []int32{5, 5},
+			vpodKey: testNs + "/vpod-2",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			// Pod i runs on node i, in zone i%3.
+			nodeToZone := make(map[string]string)
+			podlist := make([]runtime.Object, 0, len(tc.freeCap))
+			schedulablePods := make([]int32, 0, len(tc.freeCap))
+			for i := int32(0); i < int32(len(tc.freeCap)); i++ {
+				nodeName := "node" + fmt.Sprint(i)
+				nodeToZone[nodeName] = "zone" + fmt.Sprint(i%3)
+				podlist = append(podlist, tscheduler.MakePod(testNs, st.PodNameFromOrdinal(sfsName, i), nodeName))
+				schedulablePods = append(schedulablePods, i)
+			}
+
+			key := types.NamespacedName{Namespace: testNs, Name: "vpod-1"}
+			placements := make([]duckv1alpha1.Placement, 0, len(tc.placements))
+			podSpread := map[types.NamespacedName]map[string]int32{key: {}}
+			zoneSpread := map[types.NamespacedName]map[string]int32{key: {}}
+			for ordinal := int32(0); ordinal < int32(len(tc.freeCap)); ordinal++ {
+				vreplicas, ok := tc.placements[ordinal]
+				if !ok {
+					continue
+				}
+				podName := st.PodNameFromOrdinal(sfsName, ordinal)
+				placements = append(placements, duckv1alpha1.Placement{PodName: podName, VReplicas: vreplicas})
+				podSpread[key][podName] += vreplicas
+				zoneSpread[key]["zone"+fmt.Sprint(ordinal%3)] += vreplicas
+			}
+
+			lsp := listers.NewListers(podlist)
+			s := &st.State{
+				FreeCap:         tc.freeCap,
+				SchedulablePods: schedulablePods,
+				LastOrdinal:     int32(len(tc.freeCap)) - 1,
+				Capacity:        10,
+				Replicas:        int32(len(tc.freeCap)),
+				SchedulerPolicy: scheduler.MAXFILLUP,
+				NumZones:        3,
+				NodeToZoneMap:   nodeToZone,
+				PodLister:       lsp.GetPodLister().Pods(testNs),
+				PodSpread:       podSpread,
+				ZoneSpread:      zoneSpread,
+			}
+			if tc.policy != nil {
+				s.SchedulerPolicy = ""
+				s.SchedPolicy = tc.policy
+			}
+
+			vpod := tscheduler.NewVPod(testNs, "vpod-1", 10, placements)
+			fakeClock := clocktesting.NewFakeClock(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister: func() ([]scheduler.VPod, error) {
+					return []scheduler.VPod{vpod}, nil
+				},
+				RefreshPeriod: 10 * time.Second,
+				PodCapacity:   10,
+				getReserved: func() map[types.NamespacedName]map[string]int32 {
+					return nil
+				},
+				clock: fakeClock,
+			}
+			autoscaler := newAutoscaler(ctx, cfg, &fixedStateAccessor{state: s})
+			if !tc.notLeader {
+				_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+			}
+			if tc.paused {
+				autoscaler.Pause()
+			}
+			if tc.recent {
+				autoscaler.lastCompactAttempt = fakeClock.Now()
+			}
+
+			vpodKey := tc.vpodKey
+			if vpodKey == "" {
+				vpodKey = key.String()
+			}
+			got, err := autoscaler.ExplainCompaction(vpodKey)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error, wantErr %v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("unexpected explanation, want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCompactorPodDisruptionBudgets(t *testing.T) {
+	makePDB := func(name string, selector map[string]string, disruptionsAllowed int32) *policyv1.PodDisruptionBudget {
+		return &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNs},
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: selector},
+			},
+			Status: policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: disruptionsAllowed},
+		}
+	}
+	podLabels := map[string]string{"app": sfsName}
+
+	testCases := []struct {
+		name      string
+		pdbAware  bool
+		pdbs      []*policyv1.PodDisruptionBudget
+		evictions map[types.NamespacedName][]duckv1alpha1.Placement
+	}{
+		{
+			name:     "not pdb aware",
+			pdbAware: false,
+			pdbs:     []*policyv1.PodDisruptionBudget{makePDB("pdb", podLabels, 0)},
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {
+					{PodName: "statefulset-name-2", VReplicas: int32(2)},
+					{PodName: "statefulset-name-1", VReplicas: int32(3)}},
+				{Name: "vpod-2", Namespace: testNs}: {
+					{PodName: "statefulset-name-1", VReplicas: int32(1)},
+					{PodName: "statefulset-name-2", VReplicas: int32(1)}},
+			},
+		},
+		{
+			name:     "no pdb",
+			pdbAware: true,
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {
+					{PodName: "statefulset-name-2", VReplicas: int32(2)},
+					{PodName: "statefulset-name-1", VReplicas: int32(3)}},
+				{Name: "vpod-2", Namespace: testNs}: {
+					{PodName: "statefulset-name-1", VReplicas: int32(1)},
+					{PodName: "statefulset-name-2", VReplicas: int32(1)}},
+			},
+		},
+		{
+			name:     "pdb not selecting the pods",
+			pdbAware: true,
+			pdbs:     []*policyv1.PodDisruptionBudget{makePDB("pdb", map[string]string{"app": "other"}, 0)},
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {
+					{PodName: "statefulset-name-2", VReplicas: int32(2)},
+					{PodName: "statefulset-name-1", VReplicas: int32(3)}},
+				{Name: "vpod-2", Namespace: testNs}: {
+					{PodName: "statefulset-name-1", VReplicas: int32(1)},
+					{PodName: "statefulset-name-2", VReplicas: int32(1)}},
+			},
+		},
+		{
+			name:      "no disruption allowed",
+			pdbAware:  true,
+			pdbs:      []*policyv1.PodDisruptionBudget{makePDB("pdb", podLabels, 0)},
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{},
+		},
+		{
+			name:     "one disruption allowed",
+			pdbAware: true,
+			pdbs:     []*policyv1.PodDisruptionBudget{makePDB("pdb", podLabels, 1)},
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {
+					{PodName: "statefulset-name-2", VReplicas: int32(2)}},
+				{Name: "vpod-2", Namespace: testNs}: {
+					{PodName: "statefulset-name-2", VReplicas: int32(1)}},
+			},
+		},
+		{
+			name:     "most restrictive pdb applies",
+			pdbAware: true,
+			pdbs: []*policyv1.PodDisruptionBudget{
+				makePDB("pdb-1", podLabels, 2),
+				makePDB("pdb-2", map[string]string{}, 1),
+			},
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {
+					{PodName: "statefulset-name-2", VReplicas: int32(2)}},
+				{Name: "vpod-2", Namespace: testNs}: {
+					{PodName: "statefulset-name-2", VReplicas: int32(1)}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			for _, pdb := range tc.pdbs {
+				_, err := kubeclient.Get(ctx).PolicyV1().PodDisruptionBudgets(testNs).Create(ctx, pdb, metav1.CreateOptions{})
+				if err != nil {
+					t.Fatal("unexpected error", err)
+				}
+			}
+
+			podlist := make([]runtime.Object, 0, 3)
+			for i := int32(0); i < 3; i++ {
+				pod := tscheduler.MakePod(testNs, st.PodNameFromOrdinal(sfsName, i), "node-0")
+				pod.Labels = podLabels
+				podlist = append(podlist, pod)
+			}
+			lsp := listers.NewListers(podlist)
+
+			vpodClient := tscheduler.NewVPodClient()
+			vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 10, []duckv1alpha1.Placement{
+				{PodName: "statefulset-name-0", VReplicas: int32(5)},
+				{PodName: "statefulset-name-1", VReplicas: int32(3)},
+				{PodName: "statefulset-name-2", VReplicas: int32(2)}}))
+			vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-2", 2, []duckv1alpha1.Placement{
+				{PodName: "statefulset-name-2", VReplicas: int32(1)},
+				{PodName: "statefulset-name-1", VReplicas: int32(1)}}))
+
+			evictions := make(map[types.NamespacedName][]duckv1alpha1.Placement)
+			recordEviction := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+				evictions[vpod.GetKey()] = append(evictions[vpod.GetKey()], *from)
+				return nil
+			}
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           vpodClient.List,
+				Evictor:              recordEviction,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+				PDBAware:             tc.pdbAware,
+			}
+			autoscaler := newAutoscaler(ctx, cfg, nil)
+
+			s := &st.State{LastOrdinal: 2, PodLister: lsp.GetPodLister().Pods(testNs)}
+			if err := autoscaler.compact(ctx, s, 2); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			if !reflect.DeepEqual(tc.evictions, evictions) {
+				t.Errorf("unexpected evictions, want %v, got %v", tc.evictions, evictions)
+			}
+		})
+	}
+}
+
+func TestAutoscalerCompactAnnotatesPods(t *testing.T) {
+	testCases := []struct {
+		name      string
+		disabled  bool
+		evictErr  error
+		conflicts int
+		// cancelled cancels the context of the compaction while evicting.
+		cancelled bool
+		wantErr   bool
+	}{
+		{
+			name: "evicted",
+		},
+		{
+			name:     "eviction failed",
+			evictErr: errors.New("eviction failed"),
+			wantErr:  true,
+		},
+		{
+			name:      "update conflicts",
+			conflicts: 2,
+		},
+		{
+			name:      "cancelled while evicting",
+			cancelled: true,
+			wantErr:   true,
+		},
+		{
+			name:     "disabled",
+			disabled: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			podlist := make([]runtime.Object, 0, 3)
+			for i := int32(0); i < 3; i++ {
+				pod := tscheduler.MakePod(testNs, st.PodNameFromOrdinal(sfsName, i), "node-0")
+				pod.Annotations = map[string]string{"other": "annotation"}
+				if _, err := kubeclient.Get(ctx).CoreV1().Pods(testNs).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+					t.Fatal("unexpected error", err)
+				}
+				podlist = append(podlist, pod)
+			}
+			lsp := listers.NewListers(podlist)
+
+			conflicts := tc.conflicts
+			kubeclient.Get(ctx).PrependReactor("update", "pods", func(action gtesting.Action) (bool, runtime.Object, error) {
+				if conflicts > 0 {
+					conflicts--
+					return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, "pod", errors.New("object has been modified"))
+				}
+				return false, nil, nil
+			})
+
+			vpodClient := tscheduler.NewVPodClient()
+			vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 10, []duckv1alpha1.Placement{
+				{PodName: "statefulset-name-0", VReplicas: int32(8)},
+				{PodName: "statefulset-name-2", VReplicas: int32(2)}}))
+
+			compactCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			// The annotations of the pods, as seen while evicting their vreplicas.
+			evicting := make(map[string]map[string]string)
+			evictor := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+				current, err := kubeclient.Get(ctx).CoreV1().Pods(testNs).Get(ctx, from.PodName, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				evicting[from.PodName] = current.Annotations
+				if tc.cancelled {
+					cancel()
+					return compactCtx.Err()
+				}
+				return tc.evictErr
+			}
+
+			cfg := &Config{
+				StatefulSetNamespace:   testNs,
+				StatefulSetName:        sfsName,
+				VPodLister:             vpodClient.List,
+				Evictor:                evictor,
+				RefreshPeriod:          10 * time.Second,
+				PodCapacity:            10,
+				AnnotateCompactingPods: !tc.disabled,
+			}
+			autoscaler := newAutoscaler(ctx, cfg, nil)
+			autoscaler.kubeClient = &contextAwareKubeClient{Interface: autoscaler.kubeClient}
+
+			s := &st.State{LastOrdinal: 2, PodLister: lsp.GetPodLister().Pods(testNs)}
+			err := autoscaler.compact(compactCtx, s, 1)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("unexpected error, want error %v, got %v", tc.wantErr, err)
+			}
+
+			wantEvicting := map[string]string{"other": "annotation"}
+			if !tc.disabled {
+				wantEvicting[CompactingAnnotationKey] = "true"
+			}
+			if want := map[string]map[string]string{"statefulset-name-2": wantEvicting}; !reflect.DeepEqual(evicting, want) {
+				t.Errorf("unexpected annotations while evicting, want %v, got %v", want, evicting)
+			}
+
+			// Only the compacted pod was annotated, and the annotation is cleared afterward.
+			for i := int32(0); i < 3; i++ {
+				pod, err := kubeclient.Get(ctx).CoreV1().Pods(testNs).Get(ctx, st.PodNameFromOrdinal(sfsName, i), metav1.GetOptions{})
+				if err != nil {
+					t.Fatal("unexpected error", err)
+				}
+				if want := map[string]string{"other": "annotation"}; !reflect.DeepEqual(pod.Annotations, want) {
+					t.Errorf("unexpected annotations of %s after the compaction, want %v, got %v", pod.Name, want, pod.Annotations)
+				}
+			}
+		})
+	}
+}
+
+// contextAwareKubeClient fails the pods requests made with a done context, like the real
+// clients and unlike the fake ones.
+type contextAwareKubeClient struct {
+	kubernetes.Interface
+}
+
+func (c *contextAwareKubeClient) CoreV1() typedcorev1.CoreV1Interface {
+	return &contextAwareCoreV1{CoreV1Interface: c.Interface.CoreV1()}
+}
+
+type contextAwareCoreV1 struct {
+	typedcorev1.CoreV1Interface
+}
+
+func (c *contextAwareCoreV1) Pods(namespace string) typedcorev1.PodInterface {
+	return &contextAwarePods{PodInterface: c.CoreV1Interface.Pods(namespace)}
+}
+
+type contextAwarePods struct {
+	typedcorev1.PodInterface
+}
+
+func (p *contextAwarePods) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Pod, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.PodInterface.Get(ctx, name, opts)
+}
+
+func (p *contextAwarePods) Update(ctx context.Context, pod *corev1.Pod, opts metav1.UpdateOptions) (*corev1.Pod, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.PodInterface.Update(ctx, pod, opts)
+}
+
+func TestAutoscalerCompactPod(t *testing.T) {
+	testCases := []struct {
+		name      string
+		podName   string
+		vpods     []scheduler.VPod
+		notLeader bool
+		wantErr   bool
+		evictions map[types.NamespacedName][]duckv1alpha1.Placement
+	}{
+		{
+			name:    "drain pod",
+			podName: "statefulset-name-1",
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 9, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(3)},
+					{PodName: "statefulset-name-1", VReplicas: int32(4)},
+					{PodName: "statefulset-name-2", VReplicas: int32(2)}}),
+				tscheduler.NewVPod(testNs, "vpod-2", 3, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-1", VReplicas: int32(3)}}),
+				tscheduler.NewVPod(testNs, "vpod-3", 1, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-2", VReplicas: int32(1)}}),
+			},
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {{PodName: "statefulset-name-1", VReplicas: int32(4)}},
+				{Name: "vpod-2", Namespace: testNs}: {{PodName: "statefulset-name-1", VReplicas: int32(3)}},
+			},
+		},
+		{
+			name:    "empty pod",
+			podName: "statefulset-name-2",
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 5, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(5)}}),
+			},
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{},
+		},
+		{
+			name:    "not enough capacity",
+			podName: "statefulset-name-0",
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 25, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(10)},
+					{PodName: "statefulset-name-1", VReplicas: int32(10)},
+					{PodName: "statefulset-name-2", VReplicas: int32(5)}}),
+			},
+			wantErr:   true,
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{},
+		},
+		{
+			name:      "pod of another statefulset",
+			podName:   "other-1",
+			wantErr:   true,
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{},
+		},
+		{
+			name:      "not leader",
+			podName:   "statefulset-name-1",
+			notLeader: true,
+			wantErr:   true,
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			vpodClient := tscheduler.NewVPodClient()
+			for _, vpod := range tc.vpods {
+				vpodClient.Append(vpod)
+			}
+			objs := []runtime.Object{tscheduler.MakeNode("node-0", "zone-0")}
+			for i := int32(0); i < 3; i++ {
+				objs = append(objs, tscheduler.MakePod(testNs, st.PodNameFromOrdinal(sfsName, i), "node-0"))
+			}
+			ls := listers.NewListers(objs)
+			stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, ls.GetPodLister().Pods(testNs), ls.GetNodeLister())
+
+			_, err := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs).Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 3), metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			evictions := make(map[types.NamespacedName][]duckv1alpha1.Placement)
+			recordEviction := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+				evictions[vpod.GetKey()] = append(evictions[vpod.GetKey()], *from)
+				return nil
+			}
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           vpodClient.List,
+				Evictor:              recordEviction,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+				getReserved: func() map[types.NamespacedName]map[string]int32 {
+					return nil
+				},
+			}
+			autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+			if !tc.notLeader {
+				_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+			}
+
+			err = autoscaler.CompactPod(ctx, tc.podName)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("unexpected error, want error %v, got %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(tc.evictions, evictions) {
+				t.Errorf("unexpected evictions, want %v, got %v", tc.evictions, evictions)
+			}
+		})
+	}
+}
+
+func TestAutoscalerDrainNode(t *testing.T) {
+	// Pods 0 and 1 run on node-0, pod 2 on node-1 and pod 3 on node-2.
+	podNodes := []string{"node-0", "node-0", "node-1", "node-2"}
+
+	testCases := []struct {
+		name      string
+		nodeName  string
+		vpods     []scheduler.VPod
+		notLeader bool
+		wantErr   bool
+		evictions map[types.NamespacedName][]duckv1alpha1.Placement
+	}{
+		{
+			name:     "drain node",
+			nodeName: "node-0",
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 9, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(3)},
+					{PodName: "statefulset-name-1", VReplicas: int32(4)},
+					{PodName: "statefulset-name-2", VReplicas: int32(2)}}),
+				tscheduler.NewVPod(testNs, "vpod-2", 3, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-3", VReplicas: int32(3)}}),
+			},
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{
+				{Name: "vpod-1", Namespace: testNs}: {
+					{PodName: "statefulset-name-1", VReplicas: int32(4)},
+					{PodName: "statefulset-name-0", VReplicas: int32(3)}},
+			},
+		},
+		{
+			name:     "node without pods",
+			nodeName: "node-3",
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 5, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(5)}}),
+			},
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{},
+		},
+		{
+			name:     "not enough capacity",
+			nodeName: "node-0",
+			vpods: []scheduler.VPod{
+				tscheduler.NewVPod(testNs, "vpod-1", 30, []duckv1alpha1.Placement{
+					{PodName: "statefulset-name-0", VReplicas: int32(10)},
+					{PodName: "statefulset-name-1", VReplicas: int32(10)},
+					{PodName: "statefulset-name-2", VReplicas: int32(5)},
+					{PodName: "statefulset-name-3", VReplicas: int32(5)}}),
+			},
+			wantErr:   true,
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{},
+		},
+		{
+			name:      "not leader",
+			nodeName:  "node-0",
+			notLeader: true,
+			wantErr:   true,
+			evictions: map[types.NamespacedName][]duckv1alpha1.Placement{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			vpodClient := tscheduler.NewVPodClient()
+			for _, vpod := range tc.vpods {
+				vpodClient.Append(vpod)
+			}
+			objs := []runtime.Object{
+				tscheduler.MakeNode("node-0", "zone-0"),
+				tscheduler.MakeNode("node-1", "zone-1"),
+				tscheduler.MakeNode("node-2", "zone-2"),
+				tscheduler.MakePod(testNs, "other-0", "node-0"),
+			}
+			for i, nodeName := range podNodes {
+				objs = append(objs, tscheduler.MakePod(testNs, st.PodNameFromOrdinal(sfsName, int32(i)), nodeName))
+			}
+			ls := listers.NewListers(objs)
+			stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, ls.GetPodLister().Pods(testNs), ls.GetNodeLister())
+
+			_, err := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs).Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, int32(len(podNodes))), metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			evictions := make(map[types.NamespacedName][]duckv1alpha1.Placement)
+			recordEviction := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+				evictions[vpod.GetKey()] = append(evictions[vpod.GetKey()], *from)
+				return nil
+			}
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           vpodClient.List,
+				Evictor:              recordEviction,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+				getReserved: func() map[types.NamespacedName]map[string]int32 {
+					return nil
+				},
+			}
+			autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+			if !tc.notLeader {
+				_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+			}
+
+			err = autoscaler.DrainNode(ctx, tc.nodeName)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("unexpected error, want error %v, got %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(tc.evictions, evictions) {
+				t.Errorf("unexpected evictions, want %v, got %v", tc.evictions, evictions)
+			}
+		})
+	}
+}
+
+func TestAutoscalerDrain(t *testing.T) {
+	testCases := []struct {
+		name           string
+		placements     map[string][]duckv1alpha1.Placement
+		notLeader      bool
+		paused         bool
+		pauseOnEvict   bool
+		wantErr        error
+		wantReplicas   int32
+		wantPlacements map[string][]duckv1alpha1.Placement
+	}{
+		{
+			name: "drain down to the first pod",
+			placements: map[string][]duckv1alpha1.Placement{
+				"vpod-1": {
+					{PodName: "statefulset-name-0", VReplicas: int32(3)},
+					{PodName: "statefulset-name-2", VReplicas: int32(3)}},
+				"vpod-2": {
+					{PodName: "statefulset-name-1", VReplicas: int32(2)}},
+			},
+			wantErr:      ErrDrainIncomplete,
+			wantReplicas: 1,
+			wantPlacements: map[string][]duckv1alpha1.Placement{
+				"vpod-1": {{PodName: "statefulset-name-0", VReplicas: int32(6)}},
+				"vpod-2": {{PodName: "statefulset-name-0", VReplicas: int32(2)}},
+			},
+		},
+		{
+			name: "not enough capacity",
+			placements: map[string][]duckv1alpha1.Placement{
+				"vpod-1": {
+					{PodName: "statefulset-name-0", VReplicas: int32(10)},
+					{PodName: "statefulset-name-1", VReplicas: int32(10)},
+					{PodName: "statefulset-name-2", VReplicas: int32(5)}},
+			},
+			wantErr:      ErrDrainIncomplete,
+			wantReplicas: 3,
+			wantPlacements: map[string][]duckv1alpha1.Placement{
+				"vpod-1": {
+					{PodName: "statefulset-name-0", VReplicas: int32(10)},
+					{PodName: "statefulset-name-1", VReplicas: int32(10)},
+					{PodName: "statefulset-name-2", VReplicas: int32(5)}},
+			},
+		},
+		{
+			name:           "no vreplicas",
+			placements:     map[string][]duckv1alpha1.Placement{},
+			wantReplicas:   0,
+			wantPlacements: map[string][]duckv1alpha1.Placement{},
+		},
+		{
+			name: "paused",
+			placements: map[string][]duckv1alpha1.Placement{
+				"vpod-1": {
+					{PodName: "statefulset-name-0", VReplicas: int32(3)},
+					{PodName: "statefulset-name-2", VReplicas: int32(3)}},
+			},
+			paused:       true,
+			wantErr:      ErrDrainPaused,
+			wantReplicas: 3,
+			wantPlacements: map[string][]duckv1alpha1.Placement{
+				"vpod-1": {
+					{PodName: "statefulset-name-0", VReplicas: int32(3)},
+					{PodName: "statefulset-name-2", VReplicas: int32(3)}},
+			},
+		},
+		{
+			name: "paused during the drain",
+			placements: map[string][]duckv1alpha1.Placement{
+				"vpod-1": {
+					{PodName: "statefulset-name-0", VReplicas: int32(3)},
+					{PodName: "statefulset-name-2", VReplicas: int32(3)}},
+				"vpod-2": {
+					{PodName: "statefulset-name-1", VReplicas: int32(2)}},
+			},
+			pauseOnEvict: true,
+			wantErr:      ErrDrainPaused,
+			wantReplicas: 3,
+			wantPlacements: map[string][]duckv1alpha1.Placement{
+				"vpod-1": {{PodName: "statefulset-name-0", VReplicas: int32(6)}},
+				"vpod-2": {{PodName: "statefulset-name-1", VReplicas: int32(2)}},
+			},
+		},
+		{
+			name:           "not leader",
+			placements:     map[string][]duckv1alpha1.Placement{},
+			notLeader:      true,
+			wantReplicas:   3,
+			wantPlacements: map[string][]duckv1alpha1.Placement{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			placements := tc.placements
+			vpodLister := func() ([]scheduler.VPod, error) {
+				var vpods []scheduler.VPod
+				for name, ps := range placements {
+					vpods = append(vpods, tscheduler.NewVPod(testNs, name, scheduler.GetTotalVReplicas(ps), ps))
+				}
+				return vpods, nil
+			}
+
+			objs := []runtime.Object{tscheduler.MakeNode("node-0", "zone-0")}
+			for i := int32(0); i < 3; i++ {
+				objs = append(objs, tscheduler.MakePod(testNs, st.PodNameFromOrdinal(sfsName, i), "node-0"))
+			}
+			ls := listers.NewListers(objs)
+			stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodLister, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, ls.GetPodLister().Pods(testNs), ls.GetNodeLister())
+
+			sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+			_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 3), metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			// pause pauses the autoscaler, once it's created.
+			var pause func()
+
+			fakeClock := clocktesting.NewFakeClock(time.Now())
+
+			// The fake scheduler places the evicted vreplicas on the pods with the lowest
+			// ordinal first.
+			evictAndSchedule := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+				if tc.pauseOnEvict {
+					pause()
+				}
+				used := make(map[string]int32)
+				for _, ps := range placements {
+					for _, p := range ps {
+						used[p.PodName] += p.VReplicas
+					}
+				}
+				used[from.PodName] -= from.VReplicas
+
+				byPod := make(map[string]int32)
+				for _, p := range vpod.GetPlacements() {
+					if p.PodName != from.PodName {
+						byPod[p.PodName] = p.VReplicas
+					}
+				}
+				left := from.VReplicas
+				for o := int32(0); o < st.OrdinalFromPodName(from.PodName) && left > 0; o++ {
+					podName := st.PodNameFromOrdinal(sfsName, o)
+					allocation := 10 - used[podName]
+					if allocation > left {
+						allocation = left
+					}
+					byPod[podName] += allocation
+					left -= allocation
+				}
+				if left > 0 {
+					return fmt.Errorf("%d vreplicas left unscheduled", left)
+				}
+
+				var ps []duckv1alpha1.Placement
+				for o := int32(0); o < 3; o++ {
+					podName := st.PodNameFromOrdinal(sfsName, o)
+					if byPod[podName] > 0 {
+						ps = append(ps, duckv1alpha1.Placement{PodName: podName, VReplicas: byPod[podName]})
+					}
+				}
+				placements[vpod.GetKey().Name] = ps
+				return nil
+			}
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           vpodLister,
+				Evictor:              evictAndSchedule,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+				getReserved: func() map[types.NamespacedName]map[string]int32 {
+					return nil
+				},
+				clock: fakeClock,
+			}
+			autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+			pause = autoscaler.Pause
+			if !tc.notLeader {
+				_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+			}
+			if tc.paused {
+				autoscaler.Pause()
+			}
+
+			drained := make(chan error, 1)
+			go func() {
+				drained <- autoscaler.Drain(ctx)
+			}()
+
+			// Drain waits for a refresh period between its steps.
+			var drainErr error
+			err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				select {
+				case drainErr = <-drained:
+					return true, nil
+				default:
+				}
+				if fakeClock.HasWaiters() {
+					fakeClock.Step(cfg.RefreshPeriod)
+				}
+				return false, nil
+			})
+			if err != nil {
+				t.Fatal("timeout waiting for the drain to return")
+			}
+			if tc.notLeader {
+				if drainErr == nil {
+					t.Fatal("expected an error draining without being leader")
+				}
+			} else if !errors.Is(drainErr, tc.wantErr) {
+				t.Fatalf("unexpected error, want %v, got %v", tc.wantErr, drainErr)
+			}
+			if autoscaler.draining.Load() {
+				t.Error("expected the drain to be over")
+			}
+
+			scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if scale.Spec.Replicas != tc.wantReplicas {
+				t.Errorf("unexpected number of replicas, got %d, want %d", scale.Spec.Replicas, tc.wantReplicas)
+			}
+			if !reflect.DeepEqual(tc.wantPlacements, placements) {
+				t.Errorf("unexpected placements, want %v, got %v", tc.wantPlacements, placements)
+			}
+		})
+	}
+}
+
+func TestAutoscalerLifecycleEvents(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	vpodClient := tscheduler.NewVPodClient()
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 0), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	noopEvictor := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+		return nil
+	}
+
+	ceClient := adaptertest.NewTestClient()
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		Evictor:              noopEvictor,
+		RefreshPeriod:        10 * time.Second,
+		PodCapacity:          10,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+		EventsClient: ceClient,
+		EventsSink:   "http://sink.test-ns.svc.cluster.local",
+		EventTypes: AutoscalerEventTypes{
+			ScaledUp: "custom.scaledup",
+		},
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 5, nil))
+
+	if err := autoscaler.syncAutoscale(ctx, false); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	autoscaler.compactWithEvents(ctx, &st.State{LastOrdinal: 0}, 1)
+
+	wantTypes := []string{"custom.scaledup", CompactionStartedEventType, CompactionCompletedEventType}
+	err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return len(ceClient.Sent()) == len(wantTypes), nil
+	})
+	if err != nil {
+		t.Fatalf("timeout waiting for %d events, got %v", len(wantTypes), ceClient.Sent())
+	}
+
+	gotTypes := sets.NewString()
+	for _, e := range ceClient.Sent() {
+		gotTypes.Insert(e.Type())
+		if e.Type() == "custom.scaledup" {
+			data := scaleEventData{}
+			if err := e.DataAs(&data); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if want := (scaleEventData{StatefulSet: sfsName, From: 0, To: 1}); data != want {
+				t.Errorf("unexpected scaled up event data, want %+v, got %+v", want, data)
+			}
+		}
+	}
+	if !gotTypes.HasAll(wantTypes...) {
+		t.Errorf("unexpected event types, want %v, got %v", wantTypes, gotTypes.List())
+	}
+}
+
+func TestAutoscalerScaleVerification(t *testing.T) {
+	testCases := []struct {
+		name           string
+		readyReplicas  int32
+		wantNotReached bool
+	}{
+		{
+			name:          "scale reached",
+			readyReplicas: 3,
+		},
+		{
+			name:           "scale not reached",
+			readyReplicas:  1,
+			wantNotReached: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+			fakeClock := clocktesting.NewFakeClock(time.Now())
+
+			vpodClient := tscheduler.NewVPodClient()
+			ls := listers.NewListers(nil)
+			stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+			sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+			sfs := tscheduler.MakeStatefulset(testNs, sfsName, 1)
+			sfs.Status.ReadyReplicas = tc.readyReplicas
+			if _, err := sfsClient.Create(ctx, sfs, metav1.CreateOptions{}); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			var applied []int32
+			ceClient := adaptertest.NewTestClient()
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           vpodClient.List,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+				getReserved: func() map[types.NamespacedName]map[string]int32 {
+					return nil
+				},
+				EventsClient: ceClient,
+				EventsSink:   "http://sink.test-ns.svc.cluster.local",
+				OnScaleApplied: func(target int32) {
+					applied = append(applied, target)
+				},
+				ScaleVerificationTimeout: 30 * time.Second,
+				clock:                    fakeClock,
+			}
+			a := newAutoscaler(ctx, cfg, stateAccessor)
+			_ = a.Promote(reconciler.UniversalBucket(), nil)
+
+			vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 25, nil))
+
+			if err := a.doautoscale(ctx, false); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if want := []int32{3}; !reflect.DeepEqual(applied, want) {
+				t.Errorf("unexpected applied scales, want %v, got %v", want, applied)
+			}
+
+			// Wait for the verification to start, then advance the time until it completes.
+			err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				return fakeClock.HasWaiters(), nil
+			})
+			if err != nil {
+				t.Fatal("timeout waiting for the scale verification to start")
+			}
+			err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				if !fakeClock.HasWaiters() {
+					return true, nil
+				}
+				fakeClock.Step(time.Second)
+				return false, nil
+			})
+			if err != nil {
+				t.Fatal("timeout waiting for the scale verification to complete")
+			}
+
+			wantTypes := []string{ScaledUpEventType}
+			if tc.wantNotReached {
+				wantTypes = append(wantTypes, ScaleNotReachedEventType)
+			}
+			err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				return len(ceClient.Sent()) == len(wantTypes), nil
+			})
+			if err != nil {
+				t.Fatalf("timeout waiting for %d events, got %v", len(wantTypes), ceClient.Sent())
+			}
+
+			gotTypes := sets.NewString()
+			for _, e := range ceClient.Sent() {
+				gotTypes.Insert(e.Type())
+				if e.Type() == ScaleNotReachedEventType {
+					data := scaleNotReachedEventData{}
+					if err := e.DataAs(&data); err != nil {
+						t.Fatal("unexpected error", err)
+					}
+					if want := (scaleNotReachedEventData{StatefulSet: sfsName, Target: 3, ReadyReplicas: tc.readyReplicas}); data != want {
+						t.Errorf("unexpected scale not reached event data, want %+v, got %+v", want, data)
+					}
+				}
+			}
+			if !gotTypes.Equal(sets.NewString(wantTypes...)) {
+				t.Errorf("unexpected event types, want %v, got %v", wantTypes, gotTypes.List())
+			}
+		})
+	}
+}
+
+func TestCompactorGracePeriod(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	vpodClient := tscheduler.NewVPodClient()
+	vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 10, []duckv1alpha1.Placement{
+		{PodName: "statefulset-name-0", VReplicas: int32(8)},
+		{PodName: "statefulset-name-1", VReplicas: int32(2)}}))
+
+	evictions := 0
+	countEvictions := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+		evictions++
+		return nil
+	}
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	reporter := &recordingStatsReporter{}
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		Evictor:              countEvictions,
+		RefreshPeriod:        10 * time.Second,
+		PodCapacity:          10,
+		StatsReporter:        reporter,
+		clock:                fakeClock,
+	}
+	autoscaler := newAutoscaler(ctx, cfg, nil)
+
+	state := &st.State{
+		FreeCap:         []int32{2, 8},
+		SchedulablePods: []int32{0, 1},
+		LastOrdinal:     1,
+		Capacity:        10,
+		SchedulerPolicy: scheduler.MAXFILLUP,
+	}
+
+	autoscaler.mayCompact(ctx, state, 1)
+	if evictions != 1 {
+		t.Fatalf("expected compaction on first attempt, got %d evictions", evictions)
+	}
+	assertCompactionCounts(t, reporter, 0, 1)
+
+	fakeClock.Step(cfg.RefreshPeriod - time.Second)
+	autoscaler.mayCompact(ctx, state, 1)
+	if evictions != 1 {
+		t.Fatalf("expected no compaction within the grace period, got %d evictions", evictions)
+	}
+	assertCompactionCounts(t, reporter, 1, 1)
+
+	fakeClock.Step(time.Second)
+	autoscaler.mayCompact(ctx, state, 1)
+	if evictions != 2 {
+		t.Fatalf("expected compaction after the grace period, got %d evictions", evictions)
+	}
+	assertCompactionCounts(t, reporter, 1, 2)
+}
+
+func assertCompactionCounts(t *testing.T, r *recordingStatsReporter, suppressed, executed int) {
+	t.Helper()
+	if r.compactionSuppressed != suppressed || r.compactionExecuted != executed {
+		t.Fatalf("unexpected compaction counts, want %d suppressed and %d executed, got %d and %d",
+			suppressed, executed, r.compactionSuppressed, r.compactionExecuted)
+	}
+}
+
+func TestAutoscalerRefreshPeriod(t *testing.T) {
+	ctx, cancel := tscheduler.SetupFakeContext(t)
+
+	afterUpdate := make(chan bool)
+	kubeclient.Get(ctx).PrependReactor("update", "statefulsets", func(action gtesting.Action) (handled bool, ret runtime.Object, err error) {
+		if action.GetSubresource() == "scale" {
+			afterUpdate <- true
+		}
+		return false, nil, nil
+	})
+
+	vpodClient := tscheduler.NewVPodClient()
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 10), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	noopEvictor := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+		return nil
+	}
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		Evictor:              noopEvictor,
+		RefreshPeriod:        time.Hour,
+		PodCapacity:          10,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+		clock: fakeClock,
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	done := make(chan bool)
+	go func() {
+		autoscaler.Start(ctx)
+		done <- true
+	}()
+
+	waitForWaiter := func() {
+		err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+			return fakeClock.HasWaiters(), nil
+		})
+		if err != nil {
+			t.Fatal("timeout waiting for the autoscaler to wait for the refresh period")
+		}
+	}
+
+	waitForWaiter()
+	fakeClock.Step(cfg.RefreshPeriod - time.Second)
+
+	select {
+	case <-afterUpdate:
+		t.Fatal("unexpected scale down before the refresh period")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	fakeClock.Step(time.Second)
+
+	select {
+	case <-afterUpdate:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for scale subresource to be updated")
+	}
+
+	sfs, err := sfsClient.Get(ctx, sfsName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if *sfs.Spec.Replicas != 0 {
+		t.Errorf("unexpected number of replicas, got %d, want 0", *sfs.Spec.Replicas)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		t.Fatal("timeout waiting for autoscaler to stop")
+	}
+}
+
+func TestAutoscalerForceReconcile(t *testing.T) {
+	ctx, cancel := tscheduler.SetupFakeContext(t)
+
+	afterUpdate := make(chan bool)
+	kubeclient.Get(ctx).PrependReactor("update", "statefulsets", func(action gtesting.Action) (handled bool, ret runtime.Object, err error) {
+		if action.GetSubresource() == "scale" {
+			afterUpdate <- true
+		}
+		return false, nil, nil
+	})
+
+	vpodClient := tscheduler.NewVPodClient()
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 10), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	noopEvictor := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+		return nil
+	}
+
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		Evictor:              noopEvictor,
+		RefreshPeriod:        time.Hour,
+		PodCapacity:          10,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+		// The refresh period never elapses.
+		clock: clocktesting.NewFakeClock(time.Now()),
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	done := make(chan bool)
+	go func() {
+		autoscaler.Start(ctx)
+		done <- true
+	}()
+
+	autoscaler.ForceReconcile(ctx)
+	// A second call while the first one is pending must not block.
+	autoscaler.ForceReconcile(ctx)
+
+	select {
+	case <-afterUpdate:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for scale subresource to be updated")
+	}
+
+	sfs, err := sfsClient.Get(ctx, sfsName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if *sfs.Spec.Replicas != 0 {
+		t.Errorf("unexpected number of replicas, got %d, want 0", *sfs.Spec.Replicas)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		t.Fatal("timeout waiting for autoscaler to stop")
+	}
+}
+
+func TestAutoscalerTriggerBackpressure(t *testing.T) {
+	ctx, cancel := tscheduler.SetupFakeContext(t)
+
+	vpodClient := tscheduler.NewVPodClient()
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	_, err := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs).Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 0), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		RefreshPeriod:        time.Hour,
+		PodCapacity:          10,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+		clock: clocktesting.NewFakeClock(time.Now()),
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	// The autoscaler loop isn't running, the triggers beyond the pending one are dropped.
+	triggered := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			autoscaler.Autoscale(ctx)
+		}
+		triggered <- true
+	}()
+	select {
+	case <-triggered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Autoscale blocked while a trigger was pending")
+	}
+
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer timeoutCancel()
+	if err := autoscaler.AutoscaleBlocking(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected AutoscaleBlocking to wait for the pending trigger, got %v", err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		autoscaler.Start(ctx)
+		done <- true
+	}()
+
+	blockingCtx, blockingCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer blockingCancel()
+	if err := autoscaler.AutoscaleBlocking(blockingCtx); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for autoscaler to stop")
+	}
+}
+
+func TestAutoscalerStartupGracePeriod(t *testing.T) {
+	ctx, cancel := tscheduler.SetupFakeContext(t)
+
+	afterGet := make(chan bool, 1)
+	afterUpdate := make(chan bool, 1)
+	kubeclient.Get(ctx).PrependReactor("*", "statefulsets", func(action gtesting.Action) (handled bool, ret runtime.Object, err error) {
+		if action.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		switch action.GetVerb() {
+		case "get":
+			select {
+			case afterGet <- true:
+			default:
+			}
+		case "update":
+			afterUpdate <- true
+		}
+		return false, nil, nil
+	})
+
+	vpodClient := tscheduler.NewVPodClient()
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 10), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	noopEvictor := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+		return nil
+	}
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		Evictor:              noopEvictor,
+		RefreshPeriod:        time.Hour,
+		StartupGracePeriod:   time.Minute,
+		PodCapacity:          10,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+		clock: fakeClock,
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	done := make(chan bool)
+	go func() {
+		autoscaler.Start(ctx)
+		done <- true
+	}()
+
+	// Within the grace period the forced reconcile must not scale down.
+	autoscaler.ForceReconcile(ctx)
+	select {
+	case <-afterGet:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for scale subresource to be read")
+	}
+	// Wait for the autoscaling cycle to complete.
+	autoscaler.lock.Lock()
+	autoscaler.lock.Unlock()
+
+	select {
+	case <-afterUpdate:
+		t.Fatal("unexpected scale update during the startup grace period")
+	default:
+	}
+
+	fakeClock.Step(2 * time.Minute)
+
+	autoscaler.ForceReconcile(ctx)
+	select {
+	case <-afterUpdate:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for scale subresource to be updated")
+	}
+
+	sfs, err := sfsClient.Get(ctx, sfsName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if *sfs.Spec.Replicas != 0 {
+		t.Errorf("unexpected number of replicas, got %d, want 0", *sfs.Spec.Replicas)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		t.Fatal("timeout waiting for autoscaler to stop")
+	}
+}
+
+func TestAutoscalerScaleUpProtectionWindow(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	var vpods []scheduler.VPod
+	vpodLister := func() ([]scheduler.VPod, error) {
+		return vpods, nil
+	}
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodLister, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 0), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	cfg := &Config{
+		StatefulSetNamespace:    testNs,
+		StatefulSetName:         sfsName,
+		VPodLister:              vpodLister,
+		RefreshPeriod:           10 * time.Second,
+		ScaleUpProtectionWindow: time.Minute,
+		PodCapacity:             10,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+		clock: fakeClock,
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	steps := []struct {
+		name      string
+		vreplicas int32
+		elapsed   time.Duration
+		scaleDown bool
+		want      int32
+	}{
+		{
+			name:      "burst scales up",
+			vreplicas: 15,
+			want:      2,
+		},
+		{
+			name:      "refresh within the protection window keeps the capacity",
+			elapsed:   10 * time.Second,
+			scaleDown: true,
+			want:      2,
+		},
+		{
+			name:      "refresh after the protection window scales down",
+			elapsed:   time.Minute,
+			scaleDown: true,
+			want:      0,
+		},
+	}
+
+	for _, step := range steps {
+		vpods = nil
+		if step.vreplicas > 0 {
+			vpods = []scheduler.VPod{tscheduler.NewVPod(testNs, "vpod-1", step.vreplicas, nil)}
+		}
+		fakeClock.Step(step.elapsed)
+
+		if err := autoscaler.syncAutoscale(ctx, step.scaleDown); err != nil {
+			t.Fatalf("%s: unexpected error %v", step.name, err)
+		}
+
+		scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if scale.Spec.Replicas != step.want {
+			t.Fatalf("%s: unexpected number of replicas, got %d, want %d", step.name, scale.Spec.Replicas, step.want)
+		}
+	}
+}
+
+func TestAutoscalerMinScaleInterval(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	var vpods []scheduler.VPod
+	vpodLister := func() ([]scheduler.VPod, error) {
+		return vpods, nil
+	}
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodLister, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 0), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodLister,
+		RefreshPeriod:        10 * time.Second,
+		MinScaleInterval:     30 * time.Second,
+		PodCapacity:          10,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+		clock: fakeClock,
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	steps := []struct {
+		name      string
+		vreplicas int32
+		elapsed   time.Duration
+		scaleDown bool
+		want      int32
+	}{
+		{
+			name:      "first change is applied",
+			vreplicas: 15,
+			want:      2,
+		},
+		{
+			name:      "scale up within the interval is deferred",
+			vreplicas: 35,
+			elapsed:   10 * time.Second,
+			want:      2,
+		},
+		{
+			name:      "scale up after the interval is applied",
+			vreplicas: 35,
+			elapsed:   20 * time.Second,
+			want:      4,
+		},
+		{
+			name:      "scale down within the interval is deferred",
+			elapsed:   29 * time.Second,
+			scaleDown: true,
+			want:      4,
+		},
+		{
+			name:      "scale down after the interval is applied",
+			elapsed:   time.Second,
+			scaleDown: true,
+			want:      0,
+		},
+	}
+
+	for _, step := range steps {
+		vpods = nil
+		if step.vreplicas > 0 {
+			vpods = []scheduler.VPod{tscheduler.NewVPod(testNs, "vpod-1", step.vreplicas, nil)}
+		}
+		fakeClock.Step(step.elapsed)
+
+		if err := autoscaler.syncAutoscale(ctx, step.scaleDown); err != nil {
+			t.Fatalf("%s: unexpected error %v", step.name, err)
+		}
+
+		scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if scale.Spec.Replicas != step.want {
+			t.Fatalf("%s: unexpected number of replicas, got %d, want %d", step.name, scale.Spec.Replicas, step.want)
+		}
+	}
+}
+
+func TestAutoscalerReadinessGap(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	var vpods []scheduler.VPod
+	vpodLister := func() ([]scheduler.VPod, error) {
+		return vpods, nil
+	}
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodLister, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 1), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodLister,
+		RefreshPeriod:        10 * time.Second,
+		ReadinessGapTimeout:  30 * time.Second,
+		PodCapacity:          10,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+		clock: fakeClock,
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	steps := []struct {
+		name          string
+		vreplicas     int32
+		readyReplicas int32
+		elapsed       time.Duration
+		scaleDown     bool
+		want          int32
+	}{
+		{
+			name:          "all replicas ready",
+			vreplicas:     15,
+			readyReplicas: 1,
+			want:          2,
+		},
+		{
+			name:          "recent readiness gap",
+			vreplicas:     35,
+			readyReplicas: 1,
+			elapsed:       10 * time.Second,
+			want:          4,
+		},
+		{
+			name:          "persistent readiness gap suppresses scale up",
+			vreplicas:     55,
+			readyReplicas: 1,
+			elapsed:       30 * time.Second,
+			want:          4,
+		},
+		{
+			name:          "closed readiness gap",
+			vreplicas:     55,
+			readyReplicas: 4,
+			elapsed:       10 * time.Second,
+			want:          6,
+		},
+		{
+			name:          "new readiness gap",
+			vreplicas:     55,
+			readyReplicas: 4,
+			elapsed:       10 * time.Second,
+			want:          6,
+		},
+		{
+			name:          "persistent readiness gap allows scale down",
+			vreplicas:     15,
+			readyReplicas: 4,
+			elapsed:       time.Minute,
+			scaleDown:     true,
+			want:          2,
+		},
+	}
+
+	for _, step := range steps {
+		vpods = []scheduler.VPod{tscheduler.NewVPod(testNs, "vpod-1", step.vreplicas, nil)}
+		fakeClock.Step(step.elapsed)
+
+		sfs, err := sfsClient.Get(ctx, sfsName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		sfs.Status.ReadyReplicas = step.readyReplicas
+		if _, err := sfsClient.UpdateStatus(ctx, sfs, metav1.UpdateOptions{}); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if err := autoscaler.syncAutoscale(ctx, step.scaleDown); err != nil {
+			t.Fatalf("%s: unexpected error %v", step.name, err)
+		}
+
+		scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if scale.Spec.Replicas != step.want {
+			t.Fatalf("%s: unexpected number of replicas, got %d, want %d", step.name, scale.Spec.Replicas, step.want)
+		}
+	}
+}
+
+func TestAutoscalerExtremeReplicas(t *testing.T) {
+	testCases := []struct {
+		name         string
+		vreplicas    []int32
+		capacity     int32
+		vreplicaCost float64
+		maxReplicas  int32
+		wantReplicas int32
+	}{
+		{
+			name:         "total vreplicas overflowing int32",
+			vreplicas:    []int32{math.MaxInt32, math.MaxInt32},
+			capacity:     10,
+			wantReplicas: 429496730,
+		},
+		{
+			name:         "demand overflowing int32",
+			vreplicas:    []int32{10},
+			capacity:     10,
+			vreplicaCost: 1e12,
+			wantReplicas: math.MaxInt32,
+		},
+		{
+			name:         "demand overflowing int32, max replicas",
+			vreplicas:    []int32{10},
+			capacity:     10,
+			vreplicaCost: 1e12,
+			maxReplicas:  100,
+			wantReplicas: 100,
+		},
+		{
+			name:         "zero capacity",
+			vreplicas:    []int32{10},
+			capacity:     0,
+			wantReplicas: math.MaxInt32,
+		},
+		{
+			name:         "zero capacity and no demand",
+			vreplicas:    []int32{0},
+			capacity:     0,
+			wantReplicas: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			vpodClient := tscheduler.NewVPodClient()
+			ls := listers.NewListers(nil)
+			stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, tc.capacity, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+			sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+			_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 0), metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           vpodClient.List,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          tc.capacity,
+				MaxReplicas:          tc.maxReplicas,
+				getReserved: func() map[types.NamespacedName]map[string]int32 {
+					return nil
+				},
+			}
+			if tc.vreplicaCost > 0 {
+				cfg.VReplicaCost = func(scheduler.VPod) float64 {
+					return tc.vreplicaCost
+				}
+			}
+			autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+			_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+			for i, vreplicas := range tc.vreplicas {
+				vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-"+fmt.Sprint(i), vreplicas, nil))
+			}
+
+			if err := autoscaler.syncAutoscale(ctx, true); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if scale.Spec.Replicas != tc.wantReplicas {
+				t.Errorf("unexpected number of replicas, got %d, want %d", scale.Spec.Replicas, tc.wantReplicas)
+			}
+		})
+	}
+}
+
+func TestAutoscalerCapacityExhausted(t *testing.T) {
+	testCases := []struct {
+		name         string
+		vreplicas    int32
+		maxReplicas  int32
+		wantReplicas int32
+		wantPending  []int32
+	}{
+		{
+			name:         "max replicas blocking the scale up",
+			vreplicas:    50,
+			maxReplicas:  3,
+			wantReplicas: 3,
+			wantPending:  []int32{50},
+		},
+		{
+			name:         "enough replicas",
+			vreplicas:    20,
+			maxReplicas:  3,
+			wantReplicas: 2,
+		},
+		{
+			name:         "no max replicas",
+			vreplicas:    50,
+			wantReplicas: 5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			vpodClient := tscheduler.NewVPodClient()
+			ls := listers.NewListers(nil)
+			stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+			sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+			_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 0), metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			var pending []int32
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           vpodClient.List,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+				MaxReplicas:          tc.maxReplicas,
+				OnCapacityExhausted: func(p int32) {
+					pending = append(pending, p)
+				},
+				getReserved: func() map[types.NamespacedName]map[string]int32 {
+					return nil
+				},
+			}
+			autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+			_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+			vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", tc.vreplicas, nil))
+
+			if err := autoscaler.syncAutoscale(ctx, true); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if scale.Spec.Replicas != tc.wantReplicas {
+				t.Errorf("unexpected number of replicas, got %d, want %d", scale.Spec.Replicas, tc.wantReplicas)
+			}
+			if !reflect.DeepEqual(tc.wantPending, pending) {
+				t.Errorf("unexpected pending vreplicas of the capacity exhausted calls, got %v, want %v", pending, tc.wantPending)
+			}
+		})
+	}
+}
+
+func TestAutoscalerDemandSmoothing(t *testing.T) {
+	type step struct {
+		vreplicas int32
+		scaleDown bool
+		want      int32
+	}
+	testCases := []struct {
+		name            string
+		smoothing       float64
+		initialReplicas int32
+		steps           []step
+	}{
+		{
+			name:            "no smoothing follows the noisy demand",
+			initialReplicas: 2,
+			steps: []step{
+				{vreplicas: 20, scaleDown: true, want: 2},
+				{vreplicas: 80, scaleDown: true, want: 8},
+				{vreplicas: 20, scaleDown: true, want: 2},
+				{vreplicas: 80, scaleDown: true, want: 8},
+				{vreplicas: 20, scaleDown: true, want: 2},
+			},
+		},
+		{
+			name:            "transient spikes are smoothed",
+			smoothing:       0.2,
+			initialReplicas: 2,
+			steps: []step{
+				{vreplicas: 20, scaleDown: true, want: 2},
+				{vreplicas: 80, scaleDown: true, want: 4},
+				{vreplicas: 20, scaleDown: true, want: 3},
+				{vreplicas: 20, scaleDown: true, want: 3},
+				{vreplicas: 20, scaleDown: true, want: 3},
+				{vreplicas: 80, scaleDown: true, want: 4},
+				{vreplicas: 20, scaleDown: true, want: 4},
+			},
+		},
+		{
+			name:            "sustained demand is reached",
+			smoothing:       0.5,
+			initialReplicas: 2,
+			steps: []step{
+				{vreplicas: 20, scaleDown: true, want: 2},
+				{vreplicas: 60, scaleDown: true, want: 4},
+				{vreplicas: 60, scaleDown: true, want: 5},
+				{vreplicas: 60, scaleDown: true, want: 6},
+				{vreplicas: 60, scaleDown: true, want: 6},
+			},
+		},
+		{
+			name:            "never scales down below the actual demand",
+			smoothing:       0.2,
+			initialReplicas: 8,
+			steps: []step{
+				{vreplicas: 20, want: 8},
+				{vreplicas: 60, scaleDown: true, want: 6},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			var vpods []scheduler.VPod
+			vpodLister := func() ([]scheduler.VPod, error) {
+				return vpods, nil
+			}
+			ls := listers.NewListers(nil)
+			stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodLister, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+			sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+			_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, tc.initialReplicas), metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			cfg := &Config{
+				StatefulSetNamespace:  testNs,
+				StatefulSetName:       sfsName,
+				VPodLister:            vpodLister,
+				RefreshPeriod:         10 * time.Second,
+				PodCapacity:           10,
+				DemandSmoothingFactor: tc.smoothing,
+				getReserved: func() map[types.NamespacedName]map[string]int32 {
+					return nil
+				},
+			}
+			autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+			_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+			for i, step := range tc.steps {
+				vpods = []scheduler.VPod{tscheduler.NewVPod(testNs, "vpod-1", step.vreplicas, nil)}
+
+				if err := autoscaler.syncAutoscale(ctx, step.scaleDown); err != nil {
+					t.Fatalf("step %d: unexpected error %v", i, err)
+				}
+
+				scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+				if err != nil {
+					t.Fatal("unexpected error", err)
+				}
+				if scale.Spec.Replicas != step.want {
+					t.Fatalf("step %d: unexpected number of replicas, got %d, want %d", i, scale.Spec.Replicas, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAutoscalerReload(t *testing.T) {
+	validConfig := func() *Config {
+		return &Config{
+			StatefulSetNamespace:   testNs,
+			StatefulSetName:        sfsName,
+			RefreshPeriod:          time.Minute,
+			PodCapacity:            20,
+			StaleStateThreshold:    3,
+			StartupGracePeriod:     time.Minute,
+			MaxScaleUpStep:         4,
+			MaxScaleDownStep:       2,
+			MaxReplicas:            100,
+			PDBAware:               true,
+			NodeAware:              true,
+			TopologyKey:            "example.com/rack",
+			AnnotateCompactingPods: true,
+			EventTypes:             AutoscalerEventTypes{ScaledUp: "custom.scaledup"},
+
+			YieldToExternalScalers: true,
+			ScaleDownDisabled:      true,
+			CompactionHeadroom:     0.1,
+			CompactionBatchSize:    2,
+			QueueDepthThreshold:    50,
+
+			ScaleVerificationTimeout: time.Minute,
+			ReadinessGapTimeout:      5 * time.Minute,
+			ScaleUpProtectionWindow:  2 * time.Minute,
+			MinScaleInterval:         30 * time.Second,
+			DemandSmoothingFactor:    0.3,
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		cfg     func() *Config
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			cfg:  validConfig,
+		},
+		{
+			name: "nil config",
+			cfg: func() *Config {
+				return nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "different statefulset name",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.StatefulSetName = "other"
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "different statefulset namespace",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.StatefulSetNamespace = "other"
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero refresh period",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.RefreshPeriod = 0
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero pod capacity",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.PodCapacity = 0
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative stale state threshold",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.StaleStateThreshold = -1
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max scale up step",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.MaxScaleUpStep = -1
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max scale down step",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.MaxScaleDownStep = -1
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max replicas",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.MaxReplicas = -1
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative demand smoothing factor",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.DemandSmoothingFactor = -0.1
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "demand smoothing factor above 1",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.DemandSmoothingFactor = 1.5
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative startup grace period",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.StartupGracePeriod = -time.Second
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative scale up protection window",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.ScaleUpProtectionWindow = -time.Second
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative min scale interval",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.MinScaleInterval = -time.Second
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative scale verification timeout",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.ScaleVerificationTimeout = -time.Second
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative readiness gap timeout",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.ReadinessGapTimeout = -time.Second
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative compaction batch size",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.CompactionBatchSize = -1
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative queue depth threshold",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.QueueDepthThreshold = -1
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative compaction headroom",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.CompactionHeadroom = -0.1
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "compaction headroom of the whole capacity",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.CompactionHeadroom = 1
+				return cfg
+			},
+			wantErr: true,
+		},
+		{
+			name: "NaN compaction headroom",
+			cfg: func() *Config {
+				cfg := validConfig()
+				cfg.CompactionHeadroom = math.NaN()
+				return cfg
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+			}
+			a := newAutoscaler(ctx, cfg, nil)
+
+			err := a.Reload(tc.cfg())
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("unexpected error, want error %v, got %v", tc.wantErr, err)
+			}
+
+			want := &autoscaler{
+				refreshPeriod: 10 * time.Second,
+				capacity:      10,
+				eventTypes:    AutoscalerEventTypes{}.withDefaults(),
+			}
+			if !tc.wantErr {
+				want = &autoscaler{
+					refreshPeriod:       time.Minute,
+					capacity:            20,
+					staleStateThreshold: 3,
+					startupGracePeriod:  time.Minute,
+					maxScaleUpStep:      4,
+					maxScaleDownStep:    2,
+					maxReplicas:         100,
+					pdbAware:            true,
+					nodeAware:           true,
+
+					annotateCompactingPods: true,
+					topologyKey:            "example.com/rack",
+					eventTypes:             AutoscalerEventTypes{ScaledUp: "custom.scaledup"}.withDefaults(),
+
+					yieldToExternalScalers: true,
+					scaleDownDisabled:      true,
+					compactionHeadroom:     0.1,
+					compactionBatchSize:    2,
+					queueDepthThreshold:    50,
+
+					scaleVerificationTimeout: time.Minute,
+					readinessGapTimeout:      5 * time.Minute,
+					scaleUpProtectionWindow:  2 * time.Minute,
+					minScaleInterval:         30 * time.Second,
+					demandSmoothingFactor:    0.3,
+				}
+			}
+
+			assert.Equal(t, want.refreshPeriod, a.getRefreshPeriod())
+			assert.Equal(t, want.capacity, a.capacity)
+			assert.Equal(t, want.staleStateThreshold, a.staleStateThreshold)
+			assert.Equal(t, want.startupGracePeriod, a.startupGracePeriod)
+			assert.Equal(t, want.maxScaleUpStep, a.maxScaleUpStep)
+			assert.Equal(t, want.maxScaleDownStep, a.maxScaleDownStep)
+			assert.Equal(t, want.maxReplicas, a.maxReplicas)
+			assert.Equal(t, want.demandSmoothingFactor, a.demandSmoothingFactor)
+			assert.Equal(t, want.pdbAware, a.pdbAware)
+			assert.Equal(t, want.annotateCompactingPods, a.annotateCompactingPods)
+			assert.Equal(t, want.nodeAware, a.nodeAware)
+			assert.Equal(t, want.topologyKey, a.topologyKey)
+			assert.Equal(t, want.yieldToExternalScalers, a.yieldToExternalScalers)
+			assert.Equal(t, want.scaleDownDisabled, a.scaleDownDisabled)
+			assert.Equal(t, want.compactionHeadroom, a.compactionHeadroom)
+			assert.Equal(t, want.compactionBatchSize, a.compactionBatchSize)
+			assert.Equal(t, want.queueDepthThreshold, a.queueDepthThreshold)
+			assert.Equal(t, want.scaleVerificationTimeout, a.scaleVerificationTimeout)
+			assert.Equal(t, want.readinessGapTimeout, a.readinessGapTimeout)
+			assert.Equal(t, want.scaleUpProtectionWindow, a.scaleUpProtectionWindow)
+			assert.Equal(t, want.minScaleInterval, a.minScaleInterval)
+			assert.Equal(t, want.eventTypes, a.eventTypes)
+			assert.Equal(t, sfsName, a.statefulSetName)
+		})
+	}
+}
+
+func TestAutoscalerPause(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	vpodClient := tscheduler.NewVPodClient()
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 0), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		RefreshPeriod:        10 * time.Second,
+		PodCapacity:          10,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 15, nil))
+
+	steps := []struct {
+		name         string
+		step         func()
+		paused       bool
+		wantReplicas int32
+	}{
+		{
+			name:         "paused",
+			step:         autoscaler.Pause,
+			paused:       true,
+			wantReplicas: 0,
+		},
+		{
+			name:         "paused twice",
+			step:         autoscaler.Pause,
+			paused:       true,
+			wantReplicas: 0,
+		},
+		{
+			name:         "resumed",
+			step:         autoscaler.Resume,
+			paused:       false,
+			wantReplicas: 2,
+		},
+	}
+
+	for _, s := range steps {
+		s.step()
+		assert.Equal(t, s.paused, autoscaler.IsPaused(), s.name)
+		assert.Equal(t, true, autoscaler.IsLeader(), s.name)
+
+		if err := autoscaler.syncAutoscale(ctx, false); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if scale.Spec.Replicas != s.wantReplicas {
+			t.Errorf("%s: unexpected number of replicas, got %d, want %d", s.name, scale.Spec.Replicas, s.wantReplicas)
+		}
+	}
+}
+
+func TestAutoscalerIsLeader(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		RefreshPeriod:        10 * time.Second,
+		PodCapacity:          10,
+	}
+	autoscaler := newAutoscaler(ctx, cfg, nil)
+
+	var otherBucket reconciler.Bucket = emptyBucket{}
+	steps := []struct {
+		name   string
+		step   func()
+		leader bool
+	}{
+		{
+			name:   "initial",
+			step:   func() {},
+			leader: false,
+		},
+		{
+			name: "promoted, bucket without the leader election object",
+			step: func() {
+				_ = autoscaler.Promote(otherBucket, nil)
+			},
+			leader: false,
+		},
+		{
+			name: "promoted",
+			step: func() {
+				_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+			},
+			leader: true,
+		},
+		{
+			name: "demoted, bucket without the leader election object",
+			step: func() {
+				autoscaler.Demote(otherBucket)
+			},
+			leader: true,
+		},
+		{
+			name: "demoted",
+			step: func() {
+				autoscaler.Demote(reconciler.UniversalBucket())
+			},
+			leader: false,
+		},
+	}
+
+	for _, s := range steps {
+		s.step()
+		if got := autoscaler.IsLeader(); got != s.leader {
+			t.Errorf("%s: expected leader %v, got %v", s.name, s.leader, got)
+		}
+	}
+}
+
+// emptyBucket is a reconciler.Bucket without any key.
+type emptyBucket struct{}
+
+func (emptyBucket) Name() string                    { return "empty" }
+func (emptyBucket) Has(_ types.NamespacedName) bool { return false }
+
+func TestAutoscalerScaleUpStepRampUp(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	vpodClient := tscheduler.NewVPodClient()
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 0), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		RefreshPeriod:        10 * time.Second,
+		PodCapacity:          10,
+		MaxScaleUpStep:       15,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 500, nil))
+
+	for _, want := range []int32{15, 30, 45, 50, 50} {
+		if err := autoscaler.syncAutoscale(ctx, false); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if scale.Spec.Replicas != want {
+			t.Fatalf("unexpected number of replicas, got %d, want %d", scale.Spec.Replicas, want)
+		}
+	}
+}
+
+func TestAutoscalerScaleConflicts(t *testing.T) {
+	testCases := []struct {
+		name         string
+		conflicts    int
+		wantReplicas int32
+		wantErr      bool
+	}{
+		{
+			name:         "no conflict",
+			wantReplicas: 2,
+		},
+		{
+			name:         "conflict resolved by retrying",
+			conflicts:    2,
+			wantReplicas: 2,
+		},
+		{
+			name:         "persistent conflicts",
+			conflicts:    100,
+			wantReplicas: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+
+			vpodClient := tscheduler.NewVPodClient()
+			ls := listers.NewListers(nil)
+			stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+			sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+			_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 1), metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			// Simulate another controller updating the scale subresource.
+			conflicts := 0
+			kubeclient.Get(ctx).PrependReactor("update", "statefulsets", func(action gtesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "scale" || conflicts >= tc.conflicts {
+					return false, nil, nil
+				}
+				conflicts++
+				return true, nil, apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "statefulsets"}, sfsName, errors.New("object has been modified"))
+			})
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           vpodClient.List,
+				RefreshPeriod:        10 * time.Second,
+				PodCapacity:          10,
+				getReserved: func() map[types.NamespacedName]map[string]int32 {
+					return nil
+				},
+			}
+			a := newAutoscaler(ctx, cfg, stateAccessor)
+			_ = a.Promote(reconciler.UniversalBucket(), nil)
+
+			vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 15, nil))
+
+			err = a.doautoscale(ctx, false)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("unexpected error, got %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr && !apierrors.IsConflict(err) {
+				t.Errorf("expected a conflict error, got %v", err)
+			}
+
+			scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if scale.Spec.Replicas != tc.wantReplicas {
+				t.Errorf("unexpected number of replicas, got %d, want %d", scale.Spec.Replicas, tc.wantReplicas)
+			}
+			if tc.wantErr && a.scaleConflicts < persistentScaleConflicts {
+				t.Errorf("expected persistent conflicts to be tracked, got %d", a.scaleConflicts)
+			}
+			if !tc.wantErr && a.scaleConflicts != 0 {
+				t.Errorf("expected conflicts to be reset, got %d", a.scaleConflicts)
+			}
+		})
+	}
+}
+
+func TestStateSummaryDiff(t *testing.T) {
+	prev := &st.State{
+		SchedulablePods: []int32{0, 1, 2},
+		FreeCap:         []int32{2, 5, 10},
+		LastOrdinal:     1,
+		Capacity:        10,
+		Replicas:        3,
+		Pending:         map[types.NamespacedName]int32{{Namespace: testNs, Name: "vpod-1"}: 3},
+	}
+
+	testCases := []struct {
+		name  string
+		state *st.State
+		want  []zap.Field
+	}{
+		{
+			name:  "unchanged",
+			state: prev,
+		},
+		{
+			name: "pods and pending changed",
+			state: &st.State{
+				SchedulablePods: []int32{0, 1, 3},
+				FreeCap:         []int32{2, 5, 10, 10},
+				LastOrdinal:     1,
+				Capacity:        10,
+				Replicas:        4,
+				Pending:         map[types.NamespacedName]int32{{Namespace: testNs, Name: "vpod-1"}: 1},
+			},
+			want: []zap.Field{
+				zap.Int32s("addedPods", []int32{3}),
+				zap.Int32s("removedPods", []int32{2}),
+				zap.Int32s("replicas", []int32{3, 4}),
+				zap.Int32s("pending", []int32{3, 1}),
+			},
+		},
+		{
+			name: "capacity changed",
+			state: &st.State{
+				SchedulablePods: []int32{0, 1, 2},
+				FreeCap:         []int32{12, 15, 20},
+				LastOrdinal:     1,
+				Capacity:        20,
+				Replicas:        3,
+				Pending:         map[types.NamespacedName]int32{{Namespace: testNs, Name: "vpod-1"}: 3},
+			},
+			want: []zap.Field{
+				zap.Int32s("capacity", []int32{10, 20}),
+				zap.Int32s("freeCapacity", []int32{17, 47}),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := summarizeState(tc.state).diff(summarizeState(prev))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected diff, want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCombineReserved(t *testing.T) {
+	vpod1 := types.NamespacedName{Namespace: testNs, Name: "vpod-1"}
+	vpod2 := types.NamespacedName{Namespace: testNs, Name: "vpod-2"}
+	vpod3 := types.NamespacedName{Namespace: testNs, Name: "vpod-3"}
+
+	primary := func() map[types.NamespacedName]map[string]int32 {
+		return map[types.NamespacedName]map[string]int32{
+			vpod1: {"statefulset-name-0": 2},
+			vpod2: {"statefulset-name-0": 3, "statefulset-name-1": 1},
+		}
+	}
+	other := func() map[types.NamespacedName]map[string]int32 {
+		return map[types.NamespacedName]map[string]int32{
+			vpod2: {"statefulset-name-1": 4},
+			vpod3: {"statefulset-name-2": 5},
+		}
+	}
+	empty := func() map[types.NamespacedName]map[string]int32 {
+		return nil
+	}
+
+	ctx, _ := tscheduler.SetupFakeContext(t)
+	logger := logging.FromContext(ctx)
+
+	if got := combineReserved(logger, primary)(); !reflect.DeepEqual(got, primary()) {
+		t.Errorf("expected single source to be returned as is, got %v", got)
+	}
+
+	got := combineReserved(logger, primary, other, empty, nil)()
+	want := map[types.NamespacedName]map[string]int32{
+		vpod1: {"statefulset-name-0": 2},
+		vpod2: {"statefulset-name-0": 3, "statefulset-name-1": 5},
+		vpod3: {"statefulset-name-2": 5},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("unexpected merged reservations, want %v, got %v", want, got)
+	}
+}
+
+func TestAutoscalerStaleState(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	vpodClient := tscheduler.NewVPodClient()
+	ls := listers.NewListers(nil)
+	stateAccessor := &flakyStateAccessor{
+		StateAccessor: state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister()),
+	}
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 1), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	noopEvictor := func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+		return nil
+	}
+
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		Evictor:              noopEvictor,
+		RefreshPeriod:        10 * time.Second,
+		PodCapacity:          10,
+		StaleStateThreshold:  2,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 25, nil))
+
+	setReplicas := func(replicas int32) {
+		scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		scale.Spec.Replicas = replicas
+		if _, err := sfsClient.UpdateScale(ctx, sfsName, scale, metav1.UpdateOptions{}); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+	assertReplicas := func(want int32) {
+		scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if scale.Spec.Replicas != want {
+			t.Errorf("unexpected number of replicas, got %d, want %d", scale.Spec.Replicas, want)
+		}
+	}
+
+	// Healthy state.
+	if err := autoscaler.doautoscale(ctx, true); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	assertReplicas(3)
+
+	stateAccessor.fail = true
+
+	// Below the threshold, the error is returned.
+	if err := autoscaler.doautoscale(ctx, true); err == nil {
+		t.Fatal("expected error while the state is unavailable")
+	}
+
+	// Reaching the threshold, the stale state is used without scaling down.
+	setReplicas(5)
+	if err := autoscaler.doautoscale(ctx, true); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	assertReplicas(5)
+
+	// The stale state is used to hold the current capacity.
+	setReplicas(1)
+	if err := autoscaler.doautoscale(ctx, true); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	assertReplicas(3)
+
+	// Recovering resets the failures count.
+	stateAccessor.fail = false
+	if err := autoscaler.doautoscale(ctx, true); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	stateAccessor.fail = true
+	if err := autoscaler.doautoscale(ctx, true); err == nil {
+		t.Fatal("expected error while the state is unavailable")
+	}
+}
+
+type flakyStateAccessor struct {
+	st.StateAccessor
+	fail bool
+}
+
+func (f *flakyStateAccessor) State(reserved map[types.NamespacedName]map[string]int32) (*st.State, error) {
+	if f.fail {
+		return nil, fmt.Errorf("state unavailable")
+	}
+	return f.StateAccessor.State(reserved)
+}
+
+type fixedStateAccessor struct {
+	state *st.State
+}
+
+func (f *fixedStateAccessor) State(reserved map[types.NamespacedName]map[string]int32) (*st.State, error) {
+	return f.state, nil
+}
+
+// costByName returns the cost of the vpods by name, 1 for unknown vpods.
+func costByName(costs map[string]float64) func(vpod scheduler.VPod) float64 {
+	return func(vpod scheduler.VPod) float64 {
+		if c, ok := costs[vpod.GetKey().Name]; ok {
+			return c
+		}
+		return 1
+	}
+}
+
+type staticForecaster int32
+
+func (f staticForecaster) Forecast(context.Context) (int32, error) {
+	return int32(f), nil
+}
+
+type failingForecaster struct{}
+
+func (failingForecaster) Forecast(context.Context) (int32, error) {
+	return 0, fmt.Errorf("forecast unavailable")
+}
+
+type staticQueueDepth map[string]int64
+
+func (d staticQueueDepth) QueueDepth(context.Context) (map[string]int64, error) {
+	return d, nil
+}
+
+type failingQueueDepth struct{}
+
+func (failingQueueDepth) QueueDepth(context.Context) (map[string]int64, error) {
+	return nil, fmt.Errorf("queue depth unavailable")
+}
+
+func TestEphemeralKeyStableValues(t *testing.T) {
+	// Do not modify expected values
+	assert.Equal(t, "knative-eventing", ephemeralLeaderElectionObject.Namespace)
+	assert.Equal(t, "autoscaler-ephemeral", ephemeralLeaderElectionObject.Name)
+}
+
+type recordingStatsReporter struct {
+	outcomes             []string
+	compactionSuppressed int
+	compactionExecuted   int
+}
+
+func (r *recordingStatsReporter) ReportAutoscaleCycle(outcome string, _ time.Duration) error {
+	r.outcomes = append(r.outcomes, outcome)
+	return nil
+}
+
+func (r *recordingStatsReporter) ReportPodCapacity(*st.State, map[types.NamespacedName]map[string]int32) error {
+	return nil
+}
+
+func (r *recordingStatsReporter) ReportCompactionSuppressed() error {
+	r.compactionSuppressed++
+	return nil
+}
+
+func (r *recordingStatsReporter) ReportCompactionExecuted() error {
+	r.compactionExecuted++
+	return nil
+}
+
+func TestAutoscalerCycleReporting(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	vpodClient := tscheduler.NewVPodClient()
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 0), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	reporter := &recordingStatsReporter{}
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		RefreshPeriod:        10 * time.Second,
+		PodCapacity:          10,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+		StatsReporter: reporter,
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 5, nil))
+
+	if err := autoscaler.syncAutoscale(ctx, false); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if err := autoscaler.syncAutoscale(ctx, false); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	want := []string{AutoscaleOutcomeScaleUp, AutoscaleOutcomeNoop}
+	if !reflect.DeepEqual(want, reporter.outcomes) {
+		t.Errorf("unexpected reported outcomes, want %v, got %v", want, reporter.outcomes)
+	}
+}
+
+type recordingAuditSink struct {
+	entries []AuditEntry
+}
+
+func (r *recordingAuditSink) Record(_ context.Context, entry AuditEntry) {
+	r.entries = append(r.entries, entry)
+}
+
+func TestAutoscalerAuditSink(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	vpodClient := tscheduler.NewVPodClient()
+	nodelist := make([]runtime.Object, 0, 2)
+	podlist := make([]runtime.Object, 0, 2)
+	for i := 0; i < 2; i++ {
+		node, err := kubeclient.Get(ctx).CoreV1().Nodes().Create(ctx, tscheduler.MakeNode("node"+fmt.Sprint(i), "zone"+fmt.Sprint(i)), metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		nodelist = append(nodelist, node)
+		pod, err := kubeclient.Get(ctx).CoreV1().Pods(testNs).Create(ctx, tscheduler.MakePod(testNs, sfsName+"-"+fmt.Sprint(i), "node"+fmt.Sprint(i)), metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		podlist = append(podlist, pod)
+	}
+	lsp := listers.NewListers(podlist)
+	lsn := listers.NewListers(nodelist)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, lsp.GetPodLister().Pods(testNs), lsn.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 1), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	sink := &recordingAuditSink{}
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		Evictor: func(pod *corev1.Pod, vpod scheduler.VPod, from *duckv1alpha1.Placement) error {
+			return nil
+		},
+		RefreshPeriod: 10 * time.Second,
+		PodCapacity:   10,
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+		AuditSink:     sink,
+		AuditIdentity: "autoscaler-0",
+		clock:         fakeClock,
+	}
+	autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+
+	vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 14, []duckv1alpha1.Placement{
+		{PodName: sfsName + "-0", VReplicas: 7},
+		{PodName: sfsName + "-1", VReplicas: 2},
+	}))
+
+	// Scales up from 1 to 2 replicas, then nothing changes.
+	for i := 0; i < 2; i++ {
+		if err := autoscaler.syncAutoscale(ctx, false); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+	if len(sink.entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %v", sink.entries)
+	}
+	entry := sink.entries[0]
+	assert.Equal(t, fakeClock.Now(), entry.Time)
+	assert.Equal(t, types.NamespacedName{Namespace: testNs, Name: sfsName}, entry.StatefulSet)
+	assert.Equal(t, AuditActionScale, entry.Action)
+	assert.Equal(t, AuditReasonDemand, entry.Reason)
+	assert.Equal(t, "autoscaler-0", entry.Leader)
+	assert.Equal(t, int32(1), entry.OldReplicas)
+	assert.Equal(t, int32(2), entry.NewReplicas)
+	assert.Equal(t, int32(14), entry.State.ExpectedVReplicas)
+
+	s, err := stateAccessor.State(nil)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if err := autoscaler.compact(ctx, s, 1); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(sink.entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %v", sink.entries)
+	}
+	entry = sink.entries[1]
+	assert.Equal(t, AuditActionEviction, entry.Action)
+	assert.Equal(t, AuditReasonCompaction, entry.Reason)
+	assert.Equal(t, "", entry.Error)
+	wantEvictions := []EvictionPlanItem{{
+		VPod:      types.NamespacedName{Namespace: testNs, Name: "vpod-1"},
+		Placement: duckv1alpha1.Placement{PodName: sfsName + "-1", VReplicas: 2},
+	}}
+	if len(entry.Evictions) != len(wantEvictions) {
+		t.Fatalf("unexpected evictions, want %v, got %v", wantEvictions, entry.Evictions)
+	}
+	for i := range wantEvictions {
+		assert.Equal(t, wantEvictions[i].VPod, entry.Evictions[i].VPod)
+		assert.Equal(t, wantEvictions[i].Placement, entry.Evictions[i].Placement)
+	}
+}
+
+func TestAutoscalerExportImportState(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		RefreshPeriod:        10 * time.Second,
+		PodCapacity:          10,
+	}
+	now := time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)
+	exporter := newAutoscaler(ctx, cfg, nil)
+	exporter.lastScaleTime = now.Add(-time.Minute)
+	exporter.lastScaleUpTime = now.Add(-2 * time.Minute)
+	exporter.lastCompactAttempt = now.Add(-30 * time.Second)
+	exporter.smoothedDemand = 12.5
+	exporter.demandObserved = true
+	exporter.readinessGapSince = now.Add(-10 * time.Second)
+
+	data, err := exporter.ExportState()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	importer := newAutoscaler(ctx, cfg, nil)
+	if err := importer.ImportState(data); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	assert.True(t, exporter.lastScaleTime.Equal(importer.lastScaleTime))
+	assert.True(t, exporter.lastScaleUpTime.Equal(importer.lastScaleUpTime))
+	assert.True(t, exporter.lastCompactAttempt.Equal(importer.lastCompactAttempt))
+	assert.True(t, exporter.readinessGapSince.Equal(importer.readinessGapSince))
+	assert.Equal(t, exporter.smoothedDemand, importer.smoothedDemand)
+	assert.Equal(t, exporter.demandObserved, importer.demandObserved)
+
+	if err := importer.ImportState([]byte("{")); err == nil {
+		t.Error("expected an error importing an invalid state")
+	}
+}
+
+func TestAutoscalerStateStore(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	var vpods []scheduler.VPod
+	vpodLister := func() ([]scheduler.VPod, error) {
+		return vpods, nil
+	}
+	ls := listers.NewListers(nil)
+	stateAccessor := state.NewStateBuilder(ctx, testNs, sfsName, vpodLister, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+	sfsClient := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs)
+	_, err := sfsClient.Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 0), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	store := NewConfigMapStateStore(kubeclient.Get(ctx), testNs, "autoscaler-state")
+	newLeader := func() *autoscaler {
+		cfg := &Config{
+			StatefulSetNamespace: testNs,
+			StatefulSetName:      sfsName,
+			VPodLister:           vpodLister,
+			RefreshPeriod:        10 * time.Second,
+			MinScaleInterval:     30 * time.Second,
+			PodCapacity:          10,
+			StateStore:           store,
+			getReserved: func() map[types.NamespacedName]map[string]int32 {
+				return nil
+			},
+			clock: fakeClock,
+		}
+		a := newAutoscaler(ctx, cfg, stateAccessor)
+		_ = a.Promote(reconciler.UniversalBucket(), nil)
+		return a
+	}
+
+	vpods = []scheduler.VPod{tscheduler.NewVPod(testNs, "vpod-1", 15, nil)}
+	first := newLeader()
+	if err := first.syncAutoscale(ctx, false); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	first.Demote(reconciler.UniversalBucket())
+
+	cm, err := kubeclient.Get(ctx).CoreV1().ConfigMaps(testNs).Get(ctx, "autoscaler-state", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("expected the state to be saved", err)
+	}
+	if cm.Data[autoscalerStateKey] == "" {
+		t.Fatal("expected the state to be saved, got", cm.Data)
+	}
+
+	// The new leader resumes with the last scale time of the previous one and defers the
+	// scale up, which would be applied right away starting cold.
+	fakeClock.Step(10 * time.Second)
+	vpods = []scheduler.VPod{tscheduler.NewVPod(testNs, "vpod-1", 35, nil)}
+	second := newLeader()
+	if err := second.syncAutoscale(ctx, false); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	assert.True(t, first.lastScaleTime.Equal(second.lastScaleTime))
+
+	scale, err := sfsClient.GetScale(ctx, sfsName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if scale.Spec.Replicas != 2 {
+		t.Fatalf("unexpected number of replicas, got %d, want %d", scale.Spec.Replicas, 2)
+	}
+}
+
+func TestAutoscalerStatefulSetsInNamespaces(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	testCases := []struct {
+		namespace    string
+		vreplicas    int32
+		wantReplicas int32
+	}{
+		{namespace: "ns-a", vreplicas: 5, wantReplicas: 1},
+		{namespace: "ns-b", vreplicas: 25, wantReplicas: 3},
+	}
+
+	// The autoscalers share the kube client, like in a process managing statefulsets across
+	// namespaces.
+	autoscalers := make([]*autoscaler, 0, len(testCases))
+	for _, tc := range testCases {
+		vpodClient := tscheduler.NewVPodClient()
+		vpodClient.Append(tscheduler.NewVPod(tc.namespace, "vpod-1", tc.vreplicas, nil))
+		ls := listers.NewListers(nil)
+		stateAccessor := state.NewStateBuilder(ctx, tc.namespace, sfsName, vpodClient.List, 10, scheduler.MAXFILLUP, &scheduler.SchedulerPolicy{}, &scheduler.SchedulerPolicy{}, nil, ls.GetNodeLister())
+
+		_, err := kubeclient.Get(ctx).AppsV1().StatefulSets(tc.namespace).Create(ctx, tscheduler.MakeStatefulset(tc.namespace, sfsName, 0), metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		cfg := &Config{
+			StatefulSetNamespace: tc.namespace,
+			StatefulSetName:      sfsName,
+			VPodLister:           vpodClient.List,
+			RefreshPeriod:        10 * time.Second,
+			PodCapacity:          10,
+			getReserved: func() map[types.NamespacedName]map[string]int32 {
+				return nil
+			},
+		}
+		autoscaler := newAutoscaler(ctx, cfg, stateAccessor)
+		_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+		autoscalers = append(autoscalers, autoscaler)
+	}
+
+	for _, autoscaler := range autoscalers {
+		if err := autoscaler.syncAutoscale(ctx, false); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	for _, tc := range testCases {
+		scale, err := kubeclient.Get(ctx).AppsV1().StatefulSets(tc.namespace).GetScale(ctx, sfsName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if scale.Spec.Replicas != tc.wantReplicas {
+			t.Errorf("unexpected number of replicas in %s, got %d, want %d", tc.namespace, scale.Spec.Replicas, tc.wantReplicas)
+		}
+	}
+}
+
+func TestAutoscalerMinNonZeroFreeCap(t *testing.T) {
+	testCases := []struct {
+		name  string
+		state *state.State
+		want  int32
+	}{
+		{
+			name:  "no schedulable pods",
+			state: &state.State{Capacity: 10, FreeCap: []int32{3, 4}},
+			want:  10,
+		},
+		{
+			name:  "least non-zero free capacity",
+			state: &state.State{Capacity: 10, FreeCap: []int32{0, 4, 7}, SchedulablePods: []int32{0, 1, 2}},
+			want:  4,
+		},
+		{
+			name:  "stale free capacity entries",
+			state: &state.State{Capacity: 10, FreeCap: []int32{8, 1, 5, 2}, SchedulablePods: []int32{0, 2}},
+			want:  5,
+		},
+		{
+			name:  "schedulable pods beyond the free capacity entries",
+			state: &state.State{Capacity: 10, FreeCap: []int32{0}, SchedulablePods: []int32{0, 3}},
+			want:  10,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &autoscaler{capacity: 10}
+			if got := a.minNonZeroFreeCap(tc.state); got != tc.want {
+				t.Errorf("got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAutoscalerTopologyKey(t *testing.T) {
+	const rackLabel = "example.com/rack"
+
+	haPolicy := &scheduler.SchedulerPolicy{
+		Priorities: []scheduler.PriorityPolicy{{Name: st.AvailabilityZonePriority, Weight: 10}},
+	}
+
+	testCases := []struct {
+		name            string
+		topologyKey     string
+		schedulablePods []int32
+		want            int32
+	}{
+		{
+			name:            "zones without topology key",
+			schedulablePods: []int32{0, 1, 2, 3},
+			want:            2,
+		},
+		{
+			name:            "distinct racks",
+			topologyKey:     rackLabel,
+			schedulablePods: []int32{0, 1, 2, 3},
+			want:            3,
+		},
+		{
+			name:            "racks of the schedulable pods only",
+			topologyKey:     rackLabel,
+			schedulablePods: []int32{0, 1},
+			want:            1,
+		},
+		{
+			name:            "no schedulable pods",
+			topologyKey:     rackLabel,
+			schedulablePods: []int32{},
+			want:            2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			racks := map[string]string{"node0": "rack-a", "node1": "rack-a", "node2": "rack-b"}
+			nodelist := make([]runtime.Object, 0, 4)
+			podlist := make([]runtime.Object, 0, 4)
+			for i := 0; i < 4; i++ {
+				nodeName := "node" + fmt.Sprint(i)
+				node := tscheduler.MakeNode(nodeName, "zone"+fmt.Sprint(i%2))
+				if rack, ok := racks[nodeName]; ok {
+					node.Labels[rackLabel] = rack
+				}
+				nodelist = append(nodelist, node)
+				podlist = append(podlist, tscheduler.MakePod(testNs, sfsName+"-"+fmt.Sprint(i), nodeName))
+			}
+			lsp := listers.NewListers(podlist)
+			lsn := listers.NewListers(nodelist)
+
+			a := &autoscaler{
+				statefulSetName: sfsName,
+				topologyKey:     tc.topologyKey,
+				nodeLister:      lsn.GetNodeLister(),
+			}
+			s := &st.State{
+				SchedulablePods: tc.schedulablePods,
+				NumZones:        2,
+				NumNodes:        4,
+				SchedPolicy:     haPolicy,
+				PodLister:       lsp.GetPodLister().Pods(testNs),
+			}
+
+			if got := a.scaleUpFactorFor(s); got != tc.want {
+				t.Errorf("unexpected scale up factor, got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAutoscalerConfigSnapshot(t *testing.T) {
+	ctx, _ := tscheduler.SetupFakeContext(t)
+
+	vpodClient := tscheduler.NewVPodClient()
+	vpodClient.Append(tscheduler.NewVPod(testNs, "vpod-1", 10, []duckv1alpha1.Placement{
+		{PodName: "statefulset-name-0", VReplicas: int32(10)}}))
+	s := &st.State{
+		FreeCap:         []int32{0},
+		SchedulablePods: []int32{0},
+		LastOrdinal:     0,
+		Replicas:        1,
+		Capacity:        10,
+		NumZones:        3,
+		SchedulerPolicy: scheduler.MAXFILLUP,
+		SchedPolicy: &scheduler.SchedulerPolicy{
+			Priorities: []scheduler.PriorityPolicy{{Name: st.AvailabilityZonePriority, Weight: 10}},
+		},
+	}
+
+	_, err := kubeclient.Get(ctx).AppsV1().StatefulSets(testNs).Create(ctx, tscheduler.MakeStatefulset(testNs, sfsName, 1), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	cfg := &Config{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		VPodLister:           vpodClient.List,
+		RefreshPeriod:        10 * time.Second,
+		PodCapacity:          10,
+		MaxReplicas:          20,
+		PDBAware:             true,
+		EventsSink:           "http://sink.example.com",
+		getReserved: func() map[types.NamespacedName]map[string]int32 {
+			return nil
+		},
+	}
+	autoscaler := newAutoscaler(ctx, cfg, &fixedStateAccessor{state: s})
+
+	want := AutoscalerConfigSnapshot{
+		StatefulSetNamespace: testNs,
+		StatefulSetName:      sfsName,
+		PodCapacity:          10,
+		RefreshPeriod:        10 * time.Second,
+		MaxReplicas:          20,
+		PDBAware:             true,
+		EventsSink:           "http://sink.example.com",
+		EventTypes:           AutoscalerEventTypes{}.withDefaults(),
+	}
+	if got := autoscaler.ConfigSnapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected snapshot, got %+v, want %+v", got, want)
+	}
+
+	// The snapshot reflects the reloaded config and the scale up factor of the last state.
+	reloaded := *cfg
+	reloaded.RefreshPeriod = time.Minute
+	reloaded.PodCapacity = 20
+	reloaded.MaxReplicas = 0
+	reloaded.ScaleDownDisabled = true
+	reloaded.CompactionHeadroom = 0.2
+	if err := autoscaler.Reload(&reloaded); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	_ = autoscaler.Promote(reconciler.UniversalBucket(), nil)
+	if err := autoscaler.syncAutoscale(ctx, false); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	want.RefreshPeriod = time.Minute
+	want.PodCapacity = 20
+	want.MaxReplicas = 0
+	want.ScaleDownDisabled = true
+	want.CompactionHeadroom = 0.2
+	want.SchedulerPolicy = scheduler.MAXFILLUP
+	want.ScaleUpFactor = 3
+	got := autoscaler.ConfigSnapshot()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected snapshot after reload, got %+v, want %+v", got, want)
+	}
+
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal("failed to serialize the snapshot", err)
+	}
+	var decoded AutoscalerConfigSnapshot
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal("failed to deserialize the snapshot", err)
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("unexpected deserialized snapshot, got %+v, want %+v", decoded, want)
+	}
 }
@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/retry"
+)
+
+// CompactingAnnotationKey is the annotation set on the pods whose vreplicas are being evicted
+// by a compaction, when AnnotateCompactingPods is enabled.
+const CompactingAnnotationKey = "eventing.knative.dev/compacting"
+
+// clearCompactingTimeout bounds the removal of CompactingAnnotationKey after a compaction.
+const clearCompactingTimeout = 30 * time.Second
+
+// compactedPods returns the names of the pods the plan evicts vreplicas from, sorted.
+func compactedPods(plan []EvictionPlanItem) []string {
+	pods := sets.NewString()
+	for _, item := range plan {
+		pods.Insert(item.Placement.PodName)
+	}
+	return pods.List()
+}
+
+// annotateCompacting sets or removes CompactingAnnotationKey on the given pods. The
+// annotation is only informational: failing to update it is logged and doesn't prevent the
+// compaction.
+func (a *autoscaler) annotateCompacting(ctx context.Context, podNames []string, compacting bool) {
+	pods := a.kubeClient.CoreV1().Pods(a.statefulSetNamespace)
+	for _, name := range podNames {
+		// The pods are also updated by the kubelet and other controllers, on conflicts the
+		// pod is fetched again and the update retried.
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			pod, err := pods.Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			_, annotated := pod.Annotations[CompactingAnnotationKey]
+			if annotated == compacting {
+				return nil
+			}
+			if compacting {
+				if pod.Annotations == nil {
+					pod.Annotations = make(map[string]string, 1)
+				}
+				pod.Annotations[CompactingAnnotationKey] = "true"
+			} else {
+				delete(pod.Annotations, CompactingAnnotationKey)
+			}
+			_, err = pods.Update(ctx, pod, metav1.UpdateOptions{})
+			return err
+		})
+		if err != nil {
+			a.logger.Warnw("failed to update the compacting annotation",
+				zap.String("pod", name),
+				zap.Bool("compacting", compacting),
+				zap.Error(err))
+		}
+	}
+}
+
+// clearCompacting removes CompactingAnnotationKey from the given pods. It doesn't use the
+// context of the compaction, which might be cancelled by then, so that the pods aren't left
+// annotated.
+func (a *autoscaler) clearCompacting(podNames []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), clearCompactingTimeout)
+	defer cancel()
+	a.annotateCompacting(ctx, podNames, false)
+}
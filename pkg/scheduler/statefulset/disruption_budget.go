@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	clientpolicyv1 "k8s.io/client-go/kubernetes/typed/policy/v1"
+)
+
+// disruptionBudgets tracks the disruptions allowed by the PodDisruptionBudgets of the
+// statefulset namespace during a single compaction.
+type disruptionBudgets struct {
+	client clientpolicyv1.PodDisruptionBudgetInterface
+
+	// pdbs are listed lazily, the first time a disruption is requested.
+	pdbs []policyv1.PodDisruptionBudget
+	// remaining is the number of disruptions still allowed by each PDB in pdbs.
+	remaining []int32
+	// disrupted are the pods whose disruption was already accounted for.
+	disrupted sets.String
+}
+
+func newDisruptionBudgets(client clientpolicyv1.PodDisruptionBudgetInterface) *disruptionBudgets {
+	return &disruptionBudgets{
+		client:    client,
+		disrupted: sets.NewString(),
+	}
+}
+
+// allowDisruption reports whether the given pod can be disrupted without breaching any
+// of the PodDisruptionBudgets selecting it. When allowed, the disruption is subtracted
+// from the budgets.
+func (b *disruptionBudgets) allowDisruption(ctx context.Context, pod *v1.Pod) (bool, error) {
+	if pod == nil {
+		// Without the pod we can't know which budgets apply.
+		return false, nil
+	}
+	if b.disrupted.Has(pod.Name) {
+		return true, nil
+	}
+
+	if b.pdbs == nil {
+		pdbs, err := b.client.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		b.pdbs = pdbs.Items
+		b.remaining = make([]int32, len(pdbs.Items))
+		for i := range pdbs.Items {
+			b.remaining[i] = pdbs.Items[i].Status.DisruptionsAllowed
+		}
+	}
+
+	var matching []int
+	for i := range b.pdbs {
+		if b.pdbs[i].Spec.Selector == nil {
+			// A nil selector selects no pods.
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(b.pdbs[i].Spec.Selector)
+		if err != nil || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if b.remaining[i] < 1 {
+			return false, nil
+		}
+		matching = append(matching, i)
+	}
+
+	for _, i := range matching {
+		b.remaining[i]--
+	}
+	b.disrupted.Insert(pod.Name)
+	return true, nil
+}
@@ -19,18 +19,21 @@ package statefulset
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"math/big"
 	"sort"
 	"sync"
 	"time"
 
+	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"go.uber.org/zap"
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/types"
 	clientappsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
 	"k8s.io/utils/integer"
 	"knative.dev/pkg/reconciler"
 
@@ -74,12 +77,173 @@ type Config struct {
 	DeschedPolicy   *scheduler.SchedulerPolicy    `json:"deschedPolicy"`
 
 	Evictor scheduler.Evictor `json:"-"`
+	// TargetedEvictor, when set, is used instead of Evictor to evict vreplicas, with the
+	// pods the evicted vreplicas should preferably be placed on.
+	TargetedEvictor scheduler.TargetedEvictor `json:"-"`
+	// EvictionTargets optionally returns the names of the pods the vreplicas evicted from
+	// the given state should preferably be placed on, in order of preference (e.g. pods on
+	// nodes with spare capacity). The pods being evicted are never preferred. Defaults to
+	// the schedulable pods with free capacity, lowest ordinal first.
+	EvictionTargets func(s *st.State) []string `json:"-"`
 
 	VPodLister scheduler.VPodLister     `json:"-"`
 	NodeLister corev1listers.NodeLister `json:"-"`
 
+	// DemandForecaster optionally predicts the upcoming vreplica demand so that the
+	// autoscaler can scale up ahead of time.
+	DemandForecaster DemandForecaster `json:"-"`
+
+	// QueueDepthSource optionally reports the backlog of each pod, so that the autoscaler
+	// also scales up when the average backlog per pod exceeds QueueDepthThreshold, even if
+	// the vreplicas look balanced. The statefulset never has less replicas than needed for
+	// the vreplicas.
+	QueueDepthSource QueueDepthSource `json:"-"`
+	// QueueDepthThreshold is the average backlog per pod above which the autoscaler scales
+	// up. 0 disables scaling on the backlog.
+	QueueDepthThreshold int64 `json:"queueDepthThreshold"`
+
+	// VReplicaCost optionally returns the cost of a single vreplica of the given vpod,
+	// relative to the pod capacity. The autoscaler sizes the statefulset for the demand
+	// weighted by cost (MAXFILLUP only). Defaults to 1 for every vpod.
+	VReplicaCost func(vpod scheduler.VPod) float64 `json:"-"`
+
+	// MaxScaleUpStep is the maximum number of replicas added in a single autoscaling cycle,
+	// so that large scale ups happen over several cycles. With HA scaling the step is rounded
+	// down to a multiple of the scale up factor. 0 means unlimited.
+	MaxScaleUpStep int32 `json:"maxScaleUpStep"`
+	// MaxScaleDownStep is the maximum number of replicas removed in a single autoscaling
+	// cycle, so that large scale downs (e.g. after a broad compaction) happen over several
+	// cycles. With HA scaling the step is rounded down to a multiple of the scale up factor.
+	// 0 means unlimited.
+	MaxScaleDownStep int32 `json:"maxScaleDownStep"`
+	// MaxReplicas is the maximum number of replicas the autoscaler computes for the
+	// statefulset. 0 means unlimited, up to the int32 limit.
+	MaxReplicas int32 `json:"maxReplicas"`
+	// DemandSmoothingFactor is the weight, in (0, 1], of the latest total expected vreplicas
+	// in an exponentially weighted moving average of the demand. Scale ups are limited to the
+	// smoothed demand so that transient spikes don't over-provision, while the statefulset is
+	// never scaled down below the actual demand. 0 disables the smoothing.
+	DemandSmoothingFactor float64 `json:"demandSmoothingFactor"`
+
+	// StaleStateThreshold is the number of consecutive failures to get the scheduler state
+	// after which the autoscaler uses the last known good state to hold the current capacity,
+	// never scaling down. 0 disables the fallback.
+	StaleStateThreshold int32 `json:"staleStateThreshold"`
+
+	// AdditionalReserved are additional sources of reserved vreplicas (e.g. from other
+	// controllers), merged with the scheduler reservations by the autoscaler.
+	AdditionalReserved []GetReserved `json:"-"`
+
+	// EventsClient optionally sends CloudEvents about the autoscaler lifecycle
+	// (scaling and compaction) to EventsSink.
+	EventsClient cloudevents.Client `json:"-"`
+	// EventsSink is the URL the autoscaler lifecycle events are sent to.
+	EventsSink string `json:"eventsSink"`
+	// EventTypes overrides the types of the autoscaler lifecycle events.
+	EventTypes AutoscalerEventTypes `json:"eventTypes"`
+
+	// CompactionHeadroom is the fraction of the capacity of the remaining pods that must
+	// still be free after a compaction (e.g. 0.1 keeps 10% free), so that compacting doesn't
+	// leave the pods saturated. Must be in [0, 1), 0 disables the headroom.
+	CompactionHeadroom float64 `json:"compactionHeadroom"`
+
+	// CompactionBatchSize is the maximum number of groups of HA pods (one pod per zone or
+	// node) the policy based compaction evicts per cycle, as long as enough pods remain for
+	// HA scaling. 0 and 1 compact one group at a time.
+	CompactionBatchSize int32 `json:"compactionBatchSize"`
+
+	// OnScaleApplied is optionally called with the target number of replicas after the
+	// statefulset scale is updated. It's called synchronously by the autoscaler and must not
+	// block.
+	OnScaleApplied func(target int32) `json:"-"`
+	// OnCapacityExhausted is optionally called with the number of pending vreplicas when
+	// they can't be scheduled because MaxReplicas prevents the statefulset from being scaled
+	// up, e.g. to surface a condition or alert. It's called synchronously by the autoscaler
+	// and must not block.
+	OnCapacityExhausted func(pending int32) `json:"-"`
+	// ScaleVerificationTimeout is the time allowed for the statefulset to have as many ready
+	// replicas as targeted by a scale up. When it doesn't, a warning is logged and a scale
+	// not reached event is emitted. 0 disables the verification.
+	ScaleVerificationTimeout time.Duration `json:"scaleVerificationTimeout"`
+	// ReadinessGapTimeout is the duration the statefulset can have fewer ready replicas than
+	// its spec replicas before the autoscaler stops scaling it up, e.g. when the pods are
+	// crash looping or can't be scheduled, so that adding more pods doesn't mask the
+	// problem. The scale ups resume once the gap is closed. 0 disables the check.
+	ReadinessGapTimeout time.Duration `json:"readinessGapTimeout"`
+
+	// StartupGracePeriod is the duration after the autoscaler starts during which it never
+	// scales down nor compacts, giving the informers time to sync. 0 disables the grace period.
+	StartupGracePeriod time.Duration `json:"startupGracePeriod"`
+
+	// ScaleUpProtectionWindow is the duration after a scale up during which the autoscaler
+	// never scales down nor compacts, so that the capacity added for a burst isn't removed on
+	// the next refresh. 0 disables the protection.
+	ScaleUpProtectionWindow time.Duration `json:"scaleUpProtectionWindow"`
+
+	// MinScaleInterval is the minimum duration between two changes of the statefulset
+	// replicas by the autoscaler. A change wanted sooner is deferred to a later cycle, the
+	// replicas being kept as they are. 0 disables the limit.
+	MinScaleInterval time.Duration `json:"minScaleInterval"`
+
+	// PDBAware makes the compaction honor the PodDisruptionBudgets selecting the statefulset
+	// pods: the eviction of vreplicas from a pod is deferred to the next compaction when it
+	// would breach a budget.
+	PDBAware bool `json:"pdbAware"`
+
+	// AnnotateCompactingPods makes the compaction annotate the pods whose vreplicas are being
+	// evicted with CompactingAnnotationKey, so that the pods being drained for a compaction
+	// can be told apart. The annotation is removed once the evictions are done.
+	AnnotateCompactingPods bool `json:"annotateCompactingPods"`
+
+	// NodeAware makes the compaction with the HA scheduling policies ignore the pods on
+	// cordoned nodes when checking that enough pods remain after a compaction, so that the
+	// compaction doesn't fight an in-progress node drain. It requires NodeLister.
+	NodeAware bool `json:"nodeAware"`
+
+	// TopologyKey is the node label whose distinct values are the failure domains the
+	// vreplicas are spread across for HA (e.g. a rack or region label). When set, the
+	// statefulset is scaled by the number of failure domains of the nodes running the
+	// schedulable pods, instead of the number of zones or nodes of the HA scheduling
+	// policies. The nodes without the label form a single domain. It requires NodeLister.
+	TopologyKey string `json:"topologyKey"`
+
+	// ReservationTTL is the age after which the vreplicas reserved by the scheduler are
+	// released, so that reservations that are never committed (e.g. when the reserving
+	// controller crashed) don't block scale down and compaction. A reservation is
+	// renewed every time its vpod is scheduled. 0 disables the expiration.
+	ReservationTTL time.Duration `json:"reservationTTL"`
+
+	// YieldToExternalScalers makes the autoscaler coexist with other controllers writing the
+	// statefulset scale subresource (e.g. an HPA): it only scales up, leaving scaling down
+	// to them.
+	YieldToExternalScalers bool `json:"yieldToExternalScalers"`
+
+	// ScaleDownDisabled makes the autoscaler only scale up: it never decreases the
+	// statefulset replicas nor compacts the vreplicas, leaving both to a separate, typically
+	// more conservative, process. Unlike YieldToExternalScalers, the compaction is disabled
+	// too. Explicit requests (CompactPod, DrainNode, Drain) are still honored.
+	ScaleDownDisabled bool `json:"scaleDownDisabled"`
+
+	// StatsReporter reports the autoscaler metrics. Defaults to an OpenCensus reporter.
+	StatsReporter AutoscalerStatsReporter `json:"-"`
+
+	// AuditSink optionally records every scale change and compaction eviction for
+	// long-term storage.
+	AuditSink AuditSink `json:"-"`
+	// AuditIdentity identifies this autoscaler instance in the audit entries. Defaults to
+	// the hostname.
+	AuditIdentity string `json:"auditIdentity"`
+
+	// StateStore optionally persists the autoscaler runtime decision state, so that a newly
+	// promoted leader resumes with the context of the previous one, e.g. see
+	// NewConfigMapStateStore.
+	StateStore AutoscalerStateStore `json:"-"`
+
 	// getReserved returns reserved replicas
 	getReserved GetReserved
+
+	// clock overrides the autoscaler clock (testing only).
+	clock clock.Clock
 }
 
 func New(ctx context.Context, cfg *Config) (scheduler.Scheduler, error) {
@@ -140,6 +304,12 @@ type StatefulSetScheduler struct {
 	// committed yet (ie. not appearing in vpodLister)
 	reserved   map[types.NamespacedName]map[string]int32
 	reservedMu sync.Mutex
+	// reservedAt is when the reservations of each vpod were last updated.
+	reservedAt map[types.NamespacedName]time.Time
+	// reservationTTL is the age after which reservations are released by Reserved.
+	reservationTTL time.Duration
+
+	clock clock.Clock
 }
 
 var (
@@ -162,12 +332,113 @@ func (s *StatefulSetScheduler) Demote(b reconciler.Bucket) {
 	}
 }
 
+// ForceReconcile immediately reconciles the statefulset scale, allowing both scale up and
+// scale down. It can be invoked on demand, for instance by an admin endpoint.
+func (s *StatefulSetScheduler) ForceReconcile(ctx context.Context) {
+	if s.autoscaler != nil {
+		s.autoscaler.ForceReconcile(ctx)
+	}
+}
+
+// Reload applies the tunable fields of cfg to the running autoscaler.
+// See Autoscaler.Reload.
+func (s *StatefulSetScheduler) Reload(cfg *Config) error {
+	if s.autoscaler == nil {
+		return nil
+	}
+	return s.autoscaler.Reload(cfg)
+}
+
+// Pause stops the automatic scaling without giving up the leadership. See Autoscaler.Pause.
+func (s *StatefulSetScheduler) Pause() {
+	if s.autoscaler != nil {
+		s.autoscaler.Pause()
+	}
+}
+
+// Resume restarts the automatic scaling stopped by Pause.
+func (s *StatefulSetScheduler) Resume() {
+	if s.autoscaler != nil {
+		s.autoscaler.Resume()
+	}
+}
+
+// IsPaused returns whether the automatic scaling is paused.
+func (s *StatefulSetScheduler) IsPaused() bool {
+	return s.autoscaler != nil && s.autoscaler.IsPaused()
+}
+
+// CompactPod drains the vreplicas placed on the given pod. See Autoscaler.CompactPod.
+func (s *StatefulSetScheduler) CompactPod(ctx context.Context, podName string) error {
+	if s.autoscaler == nil {
+		return nil
+	}
+	return s.autoscaler.CompactPod(ctx, podName)
+}
+
+// DrainNode evicts the vreplicas placed on the pods of the given node. See
+// Autoscaler.DrainNode.
+func (s *StatefulSetScheduler) DrainNode(ctx context.Context, nodeName string) error {
+	if s.autoscaler == nil {
+		return nil
+	}
+	return s.autoscaler.DrainNode(ctx, nodeName)
+}
+
+// ExplainCompaction explains whether the given vpod is eligible for compaction. See
+// Autoscaler.ExplainCompaction.
+func (s *StatefulSetScheduler) ExplainCompaction(vpodKey string) (string, error) {
+	if s.autoscaler == nil {
+		return "autoscaler disabled", nil
+	}
+	return s.autoscaler.ExplainCompaction(vpodKey)
+}
+
+// ConfigSnapshot returns the effective configuration of the autoscaler, the zero snapshot
+// when the autoscaler is disabled. See Autoscaler.ConfigSnapshot.
+func (s *StatefulSetScheduler) ConfigSnapshot() AutoscalerConfigSnapshot {
+	if s.autoscaler == nil {
+		return AutoscalerConfigSnapshot{}
+	}
+	return s.autoscaler.ConfigSnapshot()
+}
+
+// ExportState serializes the autoscaler runtime decision state. See Autoscaler.ExportState.
+func (s *StatefulSetScheduler) ExportState() ([]byte, error) {
+	if s.autoscaler == nil {
+		return nil, errors.New("autoscaler disabled")
+	}
+	return s.autoscaler.ExportState()
+}
+
+// ImportState restores the autoscaler runtime decision state. See Autoscaler.ImportState.
+func (s *StatefulSetScheduler) ImportState(data []byte) error {
+	if s.autoscaler == nil {
+		return errors.New("autoscaler disabled")
+	}
+	return s.autoscaler.ImportState(data)
+}
+
+// Drain moves all the vreplicas off the statefulset, scaling it down to zero, for instance
+// before deleting it. See Autoscaler.Drain.
+func (s *StatefulSetScheduler) Drain(ctx context.Context) error {
+	if s.autoscaler == nil {
+		return nil
+	}
+	return s.autoscaler.Drain(ctx)
+}
+
 func newStatefulSetScheduler(ctx context.Context,
 	cfg *Config,
 	stateAccessor st.StateAccessor,
 	autoscaler Autoscaler,
 	podlister corev1listers.PodNamespaceLister) *StatefulSetScheduler {
 
+	var c clock.Clock = clock.RealClock{}
+	if cfg.clock != nil {
+		c = cfg.clock
+	}
+
 	scheduler := &StatefulSetScheduler{
 		ctx:                  ctx,
 		logger:               logging.FromContext(ctx),
@@ -179,7 +450,10 @@ func newStatefulSetScheduler(ctx context.Context,
 		lock:                 new(sync.Mutex),
 		stateAccessor:        stateAccessor,
 		reserved:             make(map[types.NamespacedName]map[string]int32),
+		reservedAt:           make(map[types.NamespacedName]time.Time),
+		reservationTTL:       cfg.ReservationTTL,
 		autoscaler:           autoscaler,
+		clock:                c,
 	}
 
 	// Monitor our statefulset
@@ -745,6 +1019,8 @@ func (s *StatefulSetScheduler) updateStatefulset(obj interface{}) {
 }
 
 func (s *StatefulSetScheduler) reservePlacements(vpod scheduler.VPod, placements []duckv1alpha1.Placement) {
+	s.reservedAt[vpod.GetKey()] = s.clock.Now()
+
 	if len(placements) == 0 { // clear our old placements in reserved
 		s.reserved[vpod.GetKey()] = make(map[string]int32)
 	}
@@ -783,12 +1059,31 @@ func (s *StatefulSetScheduler) notEnoughPodReplicas(left int32) error {
 	)
 }
 
+// Reserved returns a copy of the reserved vreplicas, after releasing the reservations older
+// than the reservation TTL.
 func (s *StatefulSetScheduler) Reserved() map[types.NamespacedName]map[string]int32 {
 	s.reservedMu.Lock()
 	defer s.reservedMu.Unlock()
 
+	// Committed reservations are removed from reserved when computing the state.
+	for k := range s.reservedAt {
+		if _, ok := s.reserved[k]; !ok {
+			delete(s.reservedAt, k)
+		}
+	}
+
+	now := s.clock.Now()
 	r := make(map[types.NamespacedName]map[string]int32, len(s.reserved))
 	for k1, v1 := range s.reserved {
+		if at, ok := s.reservedAt[k1]; ok && s.reservationTTL > 0 && now.Sub(at) > s.reservationTTL {
+			s.logger.Warnw("releasing stale reservation",
+				zap.Any("vpod", k1),
+				zap.Time("reservedAt", at),
+				zap.Duration("ttl", s.reservationTTL))
+			delete(s.reserved, k1)
+			delete(s.reservedAt, k1)
+			continue
+		}
 		r[k1] = make(map[string]int32, len(v1))
 		for k2, v2 := range v1 {
 			r[k1][k2] = v2
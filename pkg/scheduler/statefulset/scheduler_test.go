@@ -19,16 +19,20 @@ package statefulset
 import (
 	"context"
 	"fmt"
+	"io"
 	"reflect"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	clocktesting "k8s.io/utils/clock/testing"
 	kubeclient "knative.dev/pkg/client/injection/kube/client/fake"
 	_ "knative.dev/pkg/client/injection/kube/informers/apps/v1/statefulset/fake"
 	"knative.dev/pkg/controller"
@@ -897,14 +901,157 @@ func TestReservePlacements(t *testing.T) {
 	}
 }
 
+func TestReservedTTL(t *testing.T) {
+	vpod1 := tscheduler.NewVPod(testNs, "vpod-1", 1, nil)
+	vpod2 := tscheduler.NewVPod(testNs, "vpod-2", 1, nil)
+	placements := []duckv1alpha1.Placement{{PodName: "statefulset-name-0", VReplicas: 1}}
+
+	testCases := []struct {
+		name string
+		ttl  time.Duration
+		// renew reserves vpod-1 again before checking the reservations.
+		renew bool
+		want  map[types.NamespacedName]map[string]int32
+	}{
+		{
+			name: "no ttl",
+			want: map[types.NamespacedName]map[string]int32{
+				vpod1.GetKey(): {"statefulset-name-0": 1},
+				vpod2.GetKey(): {"statefulset-name-0": 1},
+			},
+		},
+		{
+			name: "stale reservation aged out",
+			ttl:  time.Minute,
+			want: map[types.NamespacedName]map[string]int32{
+				vpod2.GetKey(): {"statefulset-name-0": 1},
+			},
+		},
+		{
+			name:  "renewed reservation",
+			ttl:   time.Minute,
+			renew: true,
+			want: map[types.NamespacedName]map[string]int32{
+				vpod1.GetKey(): {"statefulset-name-0": 1},
+				vpod2.GetKey(): {"statefulset-name-0": 1},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, _ := tscheduler.SetupFakeContext(t)
+			fakeClock := clocktesting.NewFakeClock(time.Now())
+
+			cfg := &Config{
+				StatefulSetNamespace: testNs,
+				StatefulSetName:      sfsName,
+				VPodLister:           tscheduler.NewVPodClient().List,
+				ReservationTTL:       tc.ttl,
+				clock:                fakeClock,
+			}
+			s := newStatefulSetScheduler(ctx, cfg, nil, newFakeAutoscaler(), nil)
+			warnings := 0
+			core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(io.Discard), zap.WarnLevel)
+			s.logger = zap.New(core, zap.Hooks(func(zapcore.Entry) error {
+				warnings++
+				return nil
+			})).Sugar()
+
+			s.reservePlacements(vpod1, placements)
+			fakeClock.Step(30 * time.Second)
+			s.reservePlacements(vpod2, placements)
+			fakeClock.Step(45 * time.Second)
+			if tc.renew {
+				s.reservePlacements(vpod1, placements)
+			}
+
+			if got := s.Reserved(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+			if got := s.Reserved(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v on the second call, want %v", got, tc.want)
+			}
+			// Stale reservations are released, and logged once.
+			_, kept := s.reserved[vpod1.GetKey()]
+			if wantKept := len(tc.want) == 2; kept != wantKept {
+				t.Errorf("expected vpod-1 reservation kept %v, got %v", wantKept, kept)
+			}
+			if wantWarnings := 2 - len(tc.want); warnings != wantWarnings {
+				t.Errorf("expected %d warnings, got %d", wantWarnings, warnings)
+			}
+		})
+	}
+}
+
 type fakeAutoscaler struct {
-	isLeader atomic.Bool
+	isLeader   atomic.Bool
+	autoscaled atomic.Int32
 }
 
 func (f *fakeAutoscaler) Start(ctx context.Context) {
 }
 
 func (f *fakeAutoscaler) Autoscale(ctx context.Context) {
+	f.autoscaled.Add(1)
+}
+
+func (f *fakeAutoscaler) AutoscaleBlocking(ctx context.Context) error {
+	f.Autoscale(ctx)
+	return nil
+}
+
+func (f *fakeAutoscaler) ForceReconcile(ctx context.Context) {
+}
+
+func (f *fakeAutoscaler) Reload(cfg *Config) error {
+	return nil
+}
+
+func (f *fakeAutoscaler) IsLeader() bool {
+	return f.isLeader.Load()
+}
+
+func (f *fakeAutoscaler) Pause() {
+}
+
+func (f *fakeAutoscaler) Resume() {
+}
+
+func (f *fakeAutoscaler) IsPaused() bool {
+	return false
+}
+
+func (f *fakeAutoscaler) CompactPod(ctx context.Context, podName string) error {
+	return nil
+}
+
+func (f *fakeAutoscaler) DrainNode(ctx context.Context, nodeName string) error {
+	return nil
+}
+
+func (f *fakeAutoscaler) ExportState() ([]byte, error) {
+	return nil, nil
+}
+
+func (f *fakeAutoscaler) ImportState(data []byte) error {
+	return nil
+}
+
+func (f *fakeAutoscaler) Drain(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeAutoscaler) PlanCompaction(s *state.State, scaleUpFactor int32) ([]EvictionPlanItem, error) {
+	return nil, nil
+}
+
+func (f *fakeAutoscaler) ExplainCompaction(vpodKey string) (string, error) {
+	return "", nil
+}
+
+func (f *fakeAutoscaler) ConfigSnapshot() AutoscalerConfigSnapshot {
+	return AutoscalerConfigSnapshot{}
 }
 
 func newFakeAutoscaler() *fakeAutoscaler {
@@ -0,0 +1,92 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	st "knative.dev/eventing/pkg/scheduler/state"
+)
+
+// stateSummary is a compact summary of the scheduler state, kept between autoscaler cycles
+// to log how the state evolved instead of the full state.
+type stateSummary struct {
+	schedulablePods sets.Int32
+	lastOrdinal     int32
+	replicas        int32
+	capacity        int32
+	freeCapacity    int32
+	pending         int32
+	expected        int32
+}
+
+func summarizeState(s *st.State) *stateSummary {
+	return &stateSummary{
+		schedulablePods: sets.NewInt32(s.SchedulablePods...),
+		lastOrdinal:     s.LastOrdinal,
+		replicas:        s.Replicas,
+		capacity:        s.Capacity,
+		freeCapacity:    s.FreeCapacity(),
+		pending:         s.TotalPending(),
+		expected:        s.TotalExpectedVReplicas(),
+	}
+}
+
+// diff returns the log fields describing the changes from prev to s, or nil when nothing
+// changed. Changed values are logged as [previous, current].
+func (s *stateSummary) diff(prev *stateSummary) []zap.Field {
+	var fields []zap.Field
+	if added := s.schedulablePods.Difference(prev.schedulablePods); added.Len() > 0 {
+		fields = append(fields, zap.Int32s("addedPods", added.List()))
+	}
+	if removed := prev.schedulablePods.Difference(s.schedulablePods); removed.Len() > 0 {
+		fields = append(fields, zap.Int32s("removedPods", removed.List()))
+	}
+	deltas := []struct {
+		name      string
+		prev, cur int32
+	}{
+		{"lastOrdinal", prev.lastOrdinal, s.lastOrdinal},
+		{"replicas", prev.replicas, s.replicas},
+		{"capacity", prev.capacity, s.capacity},
+		{"freeCapacity", prev.freeCapacity, s.freeCapacity},
+		{"pending", prev.pending, s.pending},
+		{"expectedVReplicas", prev.expected, s.expected},
+	}
+	for _, d := range deltas {
+		if d.prev != d.cur {
+			fields = append(fields, zap.Int32s(d.name, []int32{d.prev, d.cur}))
+		}
+	}
+	return fields
+}
+
+// logStateDiff logs the changes of the scheduler state since the previous cycle and keeps
+// the summary of s for the next cycle.
+func (a *autoscaler) logStateDiff(s *st.State) {
+	summary := summarizeState(s)
+	prev := a.lastStateSummary
+	a.lastStateSummary = summary
+	if prev == nil {
+		return
+	}
+
+	if fields := summary.diff(prev); len(fields) > 0 {
+		a.logger.Desugar().Debug("scheduler state changed since the last cycle", fields...)
+	}
+}
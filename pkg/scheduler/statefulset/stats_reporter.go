@@ -0,0 +1,265 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/metrics"
+
+	eventingmetrics "knative.dev/eventing/pkg/metrics"
+	st "knative.dev/eventing/pkg/scheduler/state"
+)
+
+// Outcomes of an autoscaling cycle.
+const (
+	AutoscaleOutcomeScaleUp    = "scaleup"
+	AutoscaleOutcomeScaleDown  = "scaledown"
+	AutoscaleOutcomeCompaction = "compaction"
+	AutoscaleOutcomeNoop       = "noop"
+	AutoscaleOutcomeError      = "error"
+)
+
+var (
+	// autoscaleCycleTimeInMsecM records the time spent in an autoscaling cycle, including
+	// the retries, in milliseconds.
+	autoscaleCycleTimeInMsecM = stats.Float64(
+		"autoscaler_cycle_latencies",
+		"The time spent in an autoscaling cycle",
+		stats.UnitMilliseconds,
+	)
+
+	// podFreeCapacityM records the free capacity of each statefulset pod, in vreplicas.
+	podFreeCapacityM = stats.Int64(
+		"autoscaler_pod_free_capacity",
+		"The free capacity of the statefulset pod",
+		stats.UnitDimensionless,
+	)
+
+	// podUsedCapacityM records the used capacity of each statefulset pod, in vreplicas.
+	podUsedCapacityM = stats.Int64(
+		"autoscaler_pod_used_capacity",
+		"The used capacity of the statefulset pod",
+		stats.UnitDimensionless,
+	)
+
+	// podReservedVReplicasM records the vreplicas reserved on each statefulset pod.
+	podReservedVReplicasM = stats.Int64(
+		"autoscaler_pod_reserved_vreplicas",
+		"The vreplicas reserved on the statefulset pod",
+		stats.UnitDimensionless,
+	)
+
+	// compactionSuppressedCountM counts the compactions skipped because the previous one
+	// happened less than a refresh period ago.
+	compactionSuppressedCountM = stats.Int64(
+		"autoscaler_compaction_suppressed_count",
+		"Number of compactions suppressed by the grace period",
+		stats.UnitDimensionless,
+	)
+
+	// compactionExecutedCountM counts the compactions executed.
+	compactionExecutedCountM = stats.Int64(
+		"autoscaler_compaction_executed_count",
+		"Number of compactions executed",
+		stats.UnitDimensionless,
+	)
+
+	namespaceKey        = tag.MustNewKey(eventingmetrics.LabelNamespaceName)
+	statefulSetNameKey  = tag.MustNewKey(eventingmetrics.LabelStatefulSetName)
+	autoscaleOutcomeKey = tag.MustNewKey(eventingmetrics.LabelAutoscaleOutcome)
+	podNameKey          = tag.MustNewKey(eventingmetrics.LabelPodName)
+)
+
+func init() {
+	registerAutoscalerViews()
+}
+
+// AutoscalerStatsReporter defines the interface for sending autoscaler metrics.
+type AutoscalerStatsReporter interface {
+	// ReportAutoscaleCycle captures the duration of an autoscaling cycle and its outcome.
+	ReportAutoscaleCycle(outcome string, d time.Duration) error
+	// ReportPodCapacity captures the free capacity, used capacity and reserved vreplicas of
+	// each statefulset pod.
+	ReportPodCapacity(s *st.State, reserved map[types.NamespacedName]map[string]int32) error
+	// ReportCompactionSuppressed counts a compaction skipped because of the grace period.
+	ReportCompactionSuppressed() error
+	// ReportCompactionExecuted counts an executed compaction.
+	ReportCompactionExecuted() error
+}
+
+var _ AutoscalerStatsReporter = (*autoscalerReporter)(nil)
+
+type autoscalerReporter struct {
+	namespace       string
+	statefulSetName string
+
+	lock sync.Mutex
+	// reportedPods are the pods whose capacity was last reported.
+	reportedPods map[string]struct{}
+}
+
+// NewAutoscalerStatsReporter creates a reporter that collects and reports the metrics of
+// the autoscaler of the given statefulset.
+func NewAutoscalerStatsReporter(namespace, statefulSetName string) AutoscalerStatsReporter {
+	return &autoscalerReporter{
+		namespace:       namespace,
+		statefulSetName: statefulSetName,
+	}
+}
+
+func registerAutoscalerViews() {
+	err := view.Register(
+		&view.View{
+			Description: autoscaleCycleTimeInMsecM.Description(),
+			Measure:     autoscaleCycleTimeInMsecM,
+			Aggregation: view.Distribution(metrics.Buckets125(1, 100000)...), // 1ms to 100s
+			TagKeys:     []tag.Key{namespaceKey, statefulSetNameKey, autoscaleOutcomeKey},
+		},
+		&view.View{
+			Description: podFreeCapacityM.Description(),
+			Measure:     podFreeCapacityM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{namespaceKey, statefulSetNameKey, podNameKey},
+		},
+		&view.View{
+			Description: podUsedCapacityM.Description(),
+			Measure:     podUsedCapacityM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{namespaceKey, statefulSetNameKey, podNameKey},
+		},
+		&view.View{
+			Description: podReservedVReplicasM.Description(),
+			Measure:     podReservedVReplicasM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{namespaceKey, statefulSetNameKey, podNameKey},
+		},
+		&view.View{
+			Description: compactionSuppressedCountM.Description(),
+			Measure:     compactionSuppressedCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{namespaceKey, statefulSetNameKey},
+		},
+		&view.View{
+			Description: compactionExecutedCountM.Description(),
+			Measure:     compactionExecutedCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{namespaceKey, statefulSetNameKey},
+		},
+	)
+	if err != nil {
+		log.Printf("failed to register opencensus views, %s", err)
+	}
+}
+
+// ReportAutoscaleCycle captures the duration of an autoscaling cycle and its outcome.
+func (r *autoscalerReporter) ReportAutoscaleCycle(outcome string, d time.Duration) error {
+	ctx, err := tag.New(
+		context.Background(),
+		tag.Insert(namespaceKey, r.namespace),
+		tag.Insert(statefulSetNameKey, r.statefulSetName),
+		tag.Insert(autoscaleOutcomeKey, outcome))
+	if err != nil {
+		return err
+	}
+	// convert time.Duration in nanoseconds to milliseconds.
+	metrics.Record(ctx, autoscaleCycleTimeInMsecM.M(float64(d/time.Millisecond)))
+	return nil
+}
+
+// ReportCompactionSuppressed counts a compaction skipped because of the grace period.
+func (r *autoscalerReporter) ReportCompactionSuppressed() error {
+	return r.recordCount(compactionSuppressedCountM)
+}
+
+// ReportCompactionExecuted counts an executed compaction.
+func (r *autoscalerReporter) ReportCompactionExecuted() error {
+	return r.recordCount(compactionExecutedCountM)
+}
+
+func (r *autoscalerReporter) recordCount(m *stats.Int64Measure) error {
+	ctx, err := tag.New(
+		context.Background(),
+		tag.Insert(namespaceKey, r.namespace),
+		tag.Insert(statefulSetNameKey, r.statefulSetName))
+	if err != nil {
+		return err
+	}
+	metrics.Record(ctx, m.M(1))
+	return nil
+}
+
+// ReportPodCapacity captures the free capacity, used capacity and reserved vreplicas of
+// each statefulset pod. The pods which were reported before but no longer exist are reset
+// to zero so that they don't keep their last values.
+func (r *autoscalerReporter) ReportPodCapacity(s *st.State, reserved map[types.NamespacedName]map[string]int32) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	reservedByPod := make(map[string]int32)
+	for _, placements := range reserved {
+		for podName, vreplicas := range placements {
+			reservedByPod[podName] += vreplicas
+		}
+	}
+
+	pods := s.Replicas
+	if n := int32(len(s.FreeCap)); n > pods {
+		pods = n
+	}
+	reported := make(map[string]struct{}, pods)
+	for ordinal := int32(0); ordinal < pods; ordinal++ {
+		podName := st.PodNameFromOrdinal(r.statefulSetName, ordinal)
+		free := s.Free(ordinal)
+		if err := r.recordPodCapacity(podName, free, s.Capacity-free, reservedByPod[podName]); err != nil {
+			return err
+		}
+		reported[podName] = struct{}{}
+	}
+
+	for podName := range r.reportedPods {
+		if _, ok := reported[podName]; !ok {
+			if err := r.recordPodCapacity(podName, 0, 0, 0); err != nil {
+				return err
+			}
+		}
+	}
+	r.reportedPods = reported
+	return nil
+}
+
+func (r *autoscalerReporter) recordPodCapacity(podName string, free, used, reserved int32) error {
+	ctx, err := tag.New(
+		context.Background(),
+		tag.Insert(namespaceKey, r.namespace),
+		tag.Insert(statefulSetNameKey, r.statefulSetName),
+		tag.Insert(podNameKey, podName))
+	if err != nil {
+		return err
+	}
+	metrics.Record(ctx, podFreeCapacityM.M(int64(free)))
+	metrics.Record(ctx, podUsedCapacityM.M(int64(used)))
+	metrics.Record(ctx, podReservedVReplicasM.M(int64(reserved)))
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/metrics/metricstest"
+	_ "knative.dev/pkg/metrics/testing"
+
+	eventingmetrics "knative.dev/eventing/pkg/metrics"
+	st "knative.dev/eventing/pkg/scheduler/state"
+)
+
+func TestReportPodCapacity(t *testing.T) {
+	resetMetrics()
+
+	r := NewAutoscalerStatsReporter(testNs, sfsName)
+
+	reserved := map[types.NamespacedName]map[string]int32{
+		{Namespace: testNs, Name: "vpod-1"}: {"statefulset-name-0": 2, "statefulset-name-1": 1},
+		{Namespace: testNs, Name: "vpod-2"}: {"statefulset-name-0": 1},
+	}
+	s := &st.State{Capacity: 10, Replicas: 3, FreeCap: []int32{4, 9}}
+	if err := r.ReportPodCapacity(s, reserved); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	assertPodCapacity(t, "autoscaler_pod_free_capacity", map[string]int64{
+		"statefulset-name-0": 4, "statefulset-name-1": 9, "statefulset-name-2": 10,
+	})
+	assertPodCapacity(t, "autoscaler_pod_used_capacity", map[string]int64{
+		"statefulset-name-0": 6, "statefulset-name-1": 1, "statefulset-name-2": 0,
+	})
+	assertPodCapacity(t, "autoscaler_pod_reserved_vreplicas", map[string]int64{
+		"statefulset-name-0": 3, "statefulset-name-1": 1, "statefulset-name-2": 0,
+	})
+
+	// The pods removed by a scale down are reset.
+	s = &st.State{Capacity: 10, Replicas: 1, FreeCap: []int32{7}}
+	if err := r.ReportPodCapacity(s, nil); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	assertPodCapacity(t, "autoscaler_pod_free_capacity", map[string]int64{
+		"statefulset-name-0": 7, "statefulset-name-1": 0, "statefulset-name-2": 0,
+	})
+	assertPodCapacity(t, "autoscaler_pod_used_capacity", map[string]int64{
+		"statefulset-name-0": 3, "statefulset-name-1": 0, "statefulset-name-2": 0,
+	})
+	assertPodCapacity(t, "autoscaler_pod_reserved_vreplicas", map[string]int64{
+		"statefulset-name-0": 0, "statefulset-name-1": 0, "statefulset-name-2": 0,
+	})
+}
+
+func TestReportCompaction(t *testing.T) {
+	resetMetrics()
+
+	r := NewAutoscalerStatsReporter(testNs, sfsName)
+	for i := 0; i < 2; i++ {
+		if err := r.ReportCompactionSuppressed(); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+	if err := r.ReportCompactionExecuted(); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	wantTags := map[string]string{
+		eventingmetrics.LabelNamespaceName:   testNs,
+		eventingmetrics.LabelStatefulSetName: sfsName,
+	}
+	metricstest.CheckCountData(t, "autoscaler_compaction_suppressed_count", wantTags, 2)
+	metricstest.CheckCountData(t, "autoscaler_compaction_executed_count", wantTags, 1)
+}
+
+func assertPodCapacity(t *testing.T, name string, want map[string]int64) {
+	t.Helper()
+
+	metricstest.EnsureRecorded()
+	rows, err := view.RetrieveData(name)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	got := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key == podNameKey {
+				got[tag.Value] = int64(row.Data.(*view.LastValueData).Value)
+			}
+		}
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("unexpected %s, want %v, got %v", name, want, got)
+	}
+}
+
+func resetMetrics() {
+	// OpenCensus metrics carry global state that need to be reset between unit tests.
+	metricstest.Unregister(
+		"autoscaler_cycle_latencies",
+		"autoscaler_pod_free_capacity",
+		"autoscaler_pod_used_capacity",
+		"autoscaler_pod_reserved_vreplicas",
+		"autoscaler_compaction_suppressed_count",
+		"autoscaler_compaction_executed_count")
+	registerAutoscalerViews()
+}
@@ -28,6 +28,9 @@ const (
 	MessagingDestinationAttributeName = "messaging.destination"
 	MessagingProtocolAttributeName    = "messaging.protocol"
 	MessagingMessageIDAttributeName   = "messaging.message_id"
+
+	ChannelHostAttributeName              = "knative.channel.host"
+	ChannelAddressResolutionAttributeName = "knative.channel.address_resolution"
 )
 
 var (
@@ -43,6 +46,16 @@ func MessagingMessageIDAttribute(ID string) trace.Attribute {
 	return trace.StringAttribute(MessagingMessageIDAttributeName, ID)
 }
 
+// ChannelHostAttribute records the host of the channel an event was dispatched to.
+func ChannelHostAttribute(host string) trace.Attribute {
+	return trace.StringAttribute(ChannelHostAttributeName, host)
+}
+
+// ChannelAddressResolutionAttribute records how the channel address was resolved.
+func ChannelAddressResolutionAttribute(resolution string) trace.Attribute {
+	return trace.StringAttribute(ChannelAddressResolutionAttributeName, resolution)
+}
+
 func BrokerMessagingDestination(b types.NamespacedName) string {
 	return fmt.Sprintf("broker:%s.%s", b.Name, b.Namespace)
 }
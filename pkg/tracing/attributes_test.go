@@ -119,3 +119,12 @@ func TestTriggerMessagingDestinationAttribute(t *testing.T) {
 		})
 	}
 }
+
+func TestChannelAttributes(t *testing.T) {
+	if got, want := ChannelHostAttribute("channel.ns.svc.cluster.local"), trace.StringAttribute("knative.channel.host", "channel.ns.svc.cluster.local"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ChannelHostAttribute() = %v, want %v", got, want)
+	}
+	if got, want := ChannelAddressResolutionAttribute("annotation"), trace.StringAttribute("knative.channel.address_resolution", "annotation"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ChannelAddressResolutionAttribute() = %v, want %v", got, want)
+	}
+}
@@ -47,17 +47,26 @@ func PassThroughHeaders(headers http.Header) http.Header {
 	h := http.Header{}
 
 	for n, v := range headers {
-		lower := strings.ToLower(n)
-		if forwardHeaders.Has(lower) {
+		if isForwardedHeader(n) {
 			h[n] = v
-			continue
-		}
-		for _, prefix := range forwardPrefixes {
-			if strings.HasPrefix(lower, prefix) {
-				h[n] = v
-				break
-			}
 		}
 	}
 	return h
 }
+
+// isForwardedHeader reports whether the header name, compared case-insensitively, is in the
+// `forwardHeaders` set or has any of the prefixes in `forwardPrefixes`. It doesn't allocate,
+// as it's called for every header of every event.
+func isForwardedHeader(name string) bool {
+	for forwarded := range forwardHeaders {
+		if strings.EqualFold(name, forwarded) {
+			return true
+		}
+	}
+	for _, prefix := range forwardPrefixes {
+		if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+			return true
+		}
+	}
+	return false
+}